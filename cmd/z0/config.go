@@ -1,12 +1,23 @@
 package main
 
 import (
+	"crypto/ecdsa"
+
 	"github.com/zipper-project/z0/node"
 	"github.com/zipper-project/z0/zcnd"
 )
 
 type z0Config struct {
 	ConfigFileFlag string
+	DevFlag        bool
+	DevPeriodFlag  uint64
 	NodeCfg        *node.Config
 	ZcndCfg        *zcnd.Config
+
+	// Ledgers hosted next to each other by the node. A ledger inherits the
+	// settings of ZcndCfg it doesn't override, without ledgers the node hosts
+	// ZcndCfg alone.
+	Ledgers []*zcnd.Config `toml:",omitempty"`
+
+	devKey *ecdsa.PrivateKey // Developer key sealing the blocks in developer mode
 }
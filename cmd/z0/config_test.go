@@ -0,0 +1,120 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/txpool"
+)
+
+func TestLoadLedgers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "z0-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.toml")
+	ioutil.WriteFile(file, []byte(`
+[ZcndCfg]
+DatabaseCache = 128
+[ZcndCfg.TxPool]
+PriceLimit = 5
+[ZcndCfg.Miner]
+Start = true
+
+[[Ledgers]]
+Name = "alpha"
+
+[[Ledgers]]
+Name = "beta"
+DatabaseCache = 64
+[Ledgers.TxPool]
+GlobalSlots = 10
+`), 0644)
+
+	cfg := defaultZ0Config()
+	if err := loadConfig(file, cfg); err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if len(cfg.Ledgers) != 2 {
+		t.Fatalf("ledger count mismatch: have %d, want 2", len(cfg.Ledgers))
+	}
+	alpha, beta := cfg.Ledgers[0], cfg.Ledgers[1]
+	if alpha.Name != "alpha" || beta.Name != "beta" {
+		t.Fatalf("ledger names mismatch: %q, %q", alpha.Name, beta.Name)
+	}
+	// Ledgers inherit what they don't override, defaults included
+	if alpha.DatabaseCache != 128 || alpha.TxPool.PriceLimit != 5 || !alpha.Miner.Start || alpha.TrieCache != 256 {
+		t.Errorf("alpha didn't inherit: %+v", alpha)
+	}
+	if beta.DatabaseCache != 64 || beta.TxPool.GlobalSlots != 10 || beta.TxPool.PriceLimit != 5 || beta.TxPool.AccountSlots != 16 {
+		t.Errorf("beta overrides mismatch: %+v %+v", beta, beta.TxPool)
+	}
+	// Overrides don't leak into the shared settings
+	if cfg.ZcndCfg.DatabaseCache != 128 || cfg.ZcndCfg.TxPool.GlobalSlots != 4096 || alpha.TxPool.GlobalSlots != 4096 {
+		t.Errorf("override leaked: %+v", cfg.ZcndCfg.TxPool)
+	}
+}
+
+func TestLoadPolicies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "z0-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.toml")
+	ioutil.WriteFile(file, []byte(`
+[ZcndCfg.TxPool.Policies]
+Deny = ["0x0000000000000000000000000000000000000001"]
+
+[[ZcndCfg.TxPool.Policies.Assets]]
+Asset = "0x00000000000000000000000000000000000000aa"
+Senders = ["0x0000000000000000000000000000000000000002", "0x0000000000000000000000000000000000000003"]
+
+[ZcndCfg.TxPool.Policies.RateLimit]
+Rate = 0.5
+Burst = 4
+`), 0644)
+
+	cfg := defaultZ0Config()
+	if err := loadConfig(file, cfg); err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	policies := cfg.ZcndCfg.TxPool.Policies
+	if len(policies.Deny) != 1 || policies.Deny[0] != (common.Address{19: 0x01}) {
+		t.Errorf("deny-list mismatch: %x", policies.Deny)
+	}
+	if len(policies.Allow) != 0 {
+		t.Errorf("allow-list mismatch: %x", policies.Allow)
+	}
+	if len(policies.Assets) != 1 || policies.Assets[0].Asset != (common.Address{19: 0xaa}) || len(policies.Assets[0].Senders) != 2 {
+		t.Errorf("asset restrictions mismatch: %+v", policies.Assets)
+	}
+	if limit := policies.RateLimit; limit == nil || limit.Rate != 0.5 || limit.Burst != 4 {
+		t.Errorf("rate limit mismatch: %+v", limit)
+	}
+	if len(txpool.NewPolicies(policies)) != 3 {
+		t.Errorf("policies not created")
+	}
+}
@@ -17,10 +17,9 @@
 package main
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
-	"os"
+	"io/ioutil"
 	"reflect"
 	"time"
 	"unicode"
@@ -28,11 +27,14 @@ import (
 	"github.com/ethereum/go-ethereum/common/fdlimit"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/naoina/toml"
+	"github.com/naoina/toml/ast"
 	"github.com/zipper-project/z0/config"
+	"github.com/zipper-project/z0/miner"
 	"github.com/zipper-project/z0/node"
 	"github.com/zipper-project/z0/params"
 	"github.com/zipper-project/z0/txpool"
 	"github.com/zipper-project/z0/zcnd"
+	"github.com/zipper-project/z0/zcnd/gasprice"
 )
 
 // These settings ensure that TOML keys use the same names as Go struct fields.
@@ -79,13 +81,14 @@ func setUpConfig() {
 }
 
 func loadConfig(file string, cfg *z0Config) error {
-	f, err := os.Open(file)
+	data, err := ioutil.ReadFile(file)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-
-	err = tomlSettings.NewDecoder(bufio.NewReader(f)).Decode(cfg)
+	err = tomlSettings.Unmarshal(data, cfg)
+	if err == nil {
+		err = inheritLedgers(data, cfg)
+	}
 	// Add file name to errors that have a line number.
 	if _, ok := err.(*toml.LineError); ok {
 		err = errors.New(file + ", " + err.Error())
@@ -93,6 +96,30 @@ func loadConfig(file string, cfg *z0Config) error {
 	return err
 }
 
+// inheritLedgers decodes the tables of the ledgers again, on top of copies of
+// ZcndCfg, so that every ledger only needs to set what it overrides.
+func inheritLedgers(data []byte, cfg *z0Config) error {
+	if len(cfg.Ledgers) == 0 {
+		return nil
+	}
+	root, err := toml.Parse(data)
+	if err != nil {
+		return err
+	}
+	tables, ok := root.Fields["Ledgers"].([]*ast.Table)
+	if !ok || len(tables) != len(cfg.Ledgers) {
+		return errors.New("Ledgers must be an array of tables")
+	}
+	for i, table := range tables {
+		ledger := cfg.ZcndCfg.Copy()
+		if err := tomlSettings.UnmarshalTable(table, ledger); err != nil {
+			return err
+		}
+		cfg.Ledgers[i] = ledger
+	}
+	return nil
+}
+
 func defaultZ0Config() *z0Config {
 	return &z0Config{
 		NodeCfg: defaultNodeConfig(),
@@ -107,6 +134,8 @@ func defaultZcndConfig() *zcnd.Config {
 		TrieCache:       256,
 		TrieTimeout:     60 * time.Minute,
 		TxPool:          defaultTxPoolConfig(),
+		Miner:           defaultMinerConfig(),
+		GPO:             gasprice.DefaultConfig,
 	}
 }
 
@@ -134,6 +163,12 @@ func defaultTxPoolConfig() *txpool.Config {
 	}
 }
 
+func defaultMinerConfig() *miner.Config {
+	return &miner.Config{
+		Interval: time.Second,
+	}
+}
+
 // makeDatabaseHandles raises out the number of allowed file handles per process
 // for z0 and returns half of the allowance to assign to the database.
 func makeDatabaseHandles() int {
@@ -0,0 +1,59 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/zcnd"
+)
+
+// setupDeveloper turns the configuration into an ephemeral developer chain.
+// The databases are kept in memory and a funded developer key is generated,
+// sealing a block for every transaction or every period seconds if set. The
+// developer chain is the only ledger of the node.
+func setupDeveloper(cfg *z0Config) error {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return err
+	}
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	log.Info("Using developer account", "address", addr.Hex(), "key", hexutil.Encode(crypto.FromECDSA(key)))
+
+	cfg.NodeCfg.DataDir = ""
+	cfg.ZcndCfg.Genesis = core.DeveloperGenesisBlock(cfg.DevPeriodFlag, addr)
+	cfg.ZcndCfg.TxPool.Journal = ""
+	cfg.ZcndCfg.Miner.Start = true
+	cfg.ZcndCfg.Miner.Coinbase = addr
+	cfg.ZcndCfg.Miner.Interval = time.Duration(cfg.DevPeriodFlag) * time.Second
+	cfg.Ledgers = nil
+	cfg.devKey = key
+	return nil
+}
+
+// authorizeDeveloper lets the developer key seal the blocks of the service.
+func authorizeDeveloper(z *zcnd.Zcnd, key *ecdsa.PrivateKey) error {
+	return z.Miner().Authorize(crypto.PubkeyToAddress(key.PublicKey), func(_ common.Address, hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, key)
+	})
+}
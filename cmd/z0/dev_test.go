@@ -0,0 +1,119 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core"
+	"github.com/zipper-project/z0/core/asset"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/node"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/zcnd"
+)
+
+func TestDeveloperMode(t *testing.T) {
+	cfg := defaultZ0Config()
+	cfg.DevFlag = true
+	if err := setupDeveloper(cfg); err != nil {
+		t.Fatalf("failed to set up developer mode: %v", err)
+	}
+	if cfg.NodeCfg.DataDir != "" {
+		t.Fatalf("developer chain not ephemeral: datadir %q", cfg.NodeCfg.DataDir)
+	}
+	stack := node.New(cfg.NodeCfg)
+	if err := registerService(stack, cfg); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer stack.Stop()
+
+	var z *zcnd.Zcnd
+	if err := stack.Service(&z); err != nil {
+		t.Fatalf("failed to retrieve service: %v", err)
+	}
+	statedb, err := z.BlockChain().StateAt(z.BlockChain().CurrentBlock().Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	gld, err := asset.NewAsset(statedb).GetAssetBySymbol("GLD")
+	if err != nil {
+		t.Fatalf("sample asset missing: %v", err)
+	}
+
+	// A transaction of the developer account is sealed right away
+	var (
+		signer = types.MakeSigner(cfg.ZcndCfg.Genesis.Config.ChainID)
+		to     = common.Address{0x10}
+		zip    = types.ZipAssetID
+	)
+	tx := types.NewTransaction(0, 100000, big.NewInt(1), nil)
+	tx.WithInput(types.AMInput{AssertID: &zip}, types.AMInput{AssertID: &gld.Address})
+	tx.WithOutput(
+		types.AMOutput{AssertID: &zip, Address: &to, Value: big.NewInt(1000)},
+		types.AMOutput{AssertID: &gld.Address, Address: &to, Value: big.NewInt(10)},
+	)
+	tx, err = types.SignTx(tx, signer, cfg.devKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := z.TxPool().AddLocal(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for z.BlockChain().CurrentBlock().NumberU64() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("transaction not sealed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	head := z.BlockChain().CurrentBlock()
+	if len(head.Transactions()) != 1 || head.Transactions()[0].Hash() != tx.Hash() {
+		t.Fatalf("sealed block doesn't hold the transaction")
+	}
+	if head.Coinbase() != crypto.PubkeyToAddress(cfg.devKey.PublicKey) {
+		t.Fatalf("coinbase mismatch: %x", head.Coinbase())
+	}
+	statedb, err = z.BlockChain().StateAt(head.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := asset.NewAsset(statedb)
+	if balance := a.GetBalance(to, zip).(*big.Int); balance.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("ZIP balance mismatch: have %v, want 1000", balance)
+	}
+	if balance := a.GetBalance(to, gld.Address).(*big.Int); balance.Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("GLD balance mismatch: have %v, want 10", balance)
+	}
+}
+
+func TestDeveloperGenesis(t *testing.T) {
+	faucet := common.Address{0x11}
+	genesis := core.DeveloperGenesisBlock(5, faucet)
+	if err := genesis.Validate(); err != nil {
+		t.Fatalf("invalid developer genesis: %v", err)
+	}
+	if genesis.Config.PoA == nil || genesis.Config.PoA.Period != 5 {
+		t.Fatalf("block period not configured: %v", genesis.Config.PoA)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/zipper-project/z0/core"
+	"github.com/zipper-project/z0/node"
+)
+
+// dumpGenesisCmd represents the dumpgenesis command
+var dumpGenesisCmd = &cobra.Command{
+	Use:   "dumpgenesis",
+	Short: "Dump the genesis block JSON configuration to stdout",
+	Long:  `Dump the genesis block JSON configuration stored in the data directory to stdout`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := dumpGenesis(os.Stdout, zconfig.NodeCfg, ledger); err != nil {
+			fmt.Println(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(dumpGenesisCmd)
+	dumpGenesisCmd.Flags().StringVarP(&zconfig.NodeCfg.DataDir, "datadir", "d", defaultDataDir(), "Data directory for the databases and keystore")
+	dumpGenesisCmd.Flags().StringVar(&ledger, "ledger", "", "Name of the hosted ledger to dump")
+}
+
+// dumpGenesis writes the genesis stored in the chain database of the ledger to
+// w as indented JSON.
+func dumpGenesis(w io.Writer, cfg *node.Config, ledger string) error {
+	db, err := openChainDB(cfg, ledger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	genesis, err := core.ReadGenesis(db)
+	if err != nil {
+		return fmt.Errorf("Failed to read genesis: %v", err)
+	}
+	out, err := json.MarshalIndent(genesis, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
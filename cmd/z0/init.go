@@ -17,6 +17,7 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -25,9 +26,16 @@ import (
 	"runtime"
 
 	"github.com/spf13/cobra"
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core"
+	"github.com/zipper-project/z0/node"
+	"github.com/zipper-project/z0/utils/zdb"
+	"github.com/zipper-project/z0/zcnd"
 )
 
-var dataDir string
+// ledger selects the hosted ledger whose chain database the genesis commands
+// use, the single ledger of the node if empty.
+var ledger string
 
 // initCmd represents the init command
 var initCmd = &cobra.Command{
@@ -45,6 +53,7 @@ var initCmd = &cobra.Command{
 func init() {
 	RootCmd.AddCommand(initCmd)
 	initCmd.Flags().StringVarP(&zconfig.NodeCfg.DataDir, "datadir", "d", defaultDataDir(), "Data directory for the databases and keystore")
+	initCmd.Flags().StringVar(&ledger, "ledger", "", "Name of the hosted ledger to initialize")
 }
 
 // initGenesis will initialise the given JSON format genesis file and writes it as
@@ -61,9 +70,50 @@ func initGenesis(args []string) error {
 	}
 	defer file.Close()
 
+	genesis := new(core.Genesis)
+	if err := json.NewDecoder(file).Decode(genesis); err != nil {
+		return fmt.Errorf("Invalid genesis file: %v", err)
+	}
+	hash, err := writeGenesis(zconfig.NodeCfg, ledger, genesis)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Successfully wrote genesis state, hash %s\n", hash.Hex())
 	return nil
 }
 
+// writeGenesis validates the genesis and commits it into the chain database
+// of the ledger, returning the genesis hash.
+func writeGenesis(cfg *node.Config, ledger string, genesis *core.Genesis) (common.Hash, error) {
+	if err := genesis.Validate(); err != nil {
+		return common.Hash{}, fmt.Errorf("Invalid genesis: %v", err)
+	}
+	db, err := openChainDB(cfg, ledger)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	defer db.Close()
+
+	_, hash, err := core.SetupGenesisBlock(db, genesis)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("Failed to write genesis block: %v", err)
+	}
+	return hash, nil
+}
+
+// openChainDB opens the chain database of the ledger in the data directory of
+// the node.
+func openChainDB(cfg *node.Config, ledger string) (*zdb.LDBDatabase, error) {
+	if cfg.DataDir == "" {
+		return nil, errors.New("Must supply a data directory")
+	}
+	db, err := zdb.NewLDBDatabase(cfg.ResolvePath(zcnd.LedgerPath(ledger, "chaindata")), 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open chain database: %v", err)
+	}
+	return db, nil
+}
+
 // defaultDataDir is the default data directory to use for the databases and other
 // persistence requirements.
 func defaultDataDir() string {
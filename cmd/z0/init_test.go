@@ -0,0 +1,88 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core"
+	"github.com/zipper-project/z0/node"
+	"github.com/zipper-project/z0/params"
+)
+
+func TestInitAndDumpGenesis(t *testing.T) {
+	dir, err := ioutil.TempDir("", "z0-init")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := node.NewConfig(params.ClientIdentifier, dir)
+
+	genesis := core.DefaultGenesisBlock()
+	genesis.Config = &params.ChainConfig{ChainID: big.NewInt(7)}
+	genesis.Assets = []core.GenesisAsset{{Name: "gold", Symbol: "GLD", Total: big.NewInt(100), Owner: common.Address{0x10}}}
+	genesis.Alloc = core.GenesisAlloc{common.Address{0x11}: {Balances: map[string]*big.Int{"GLD": big.NewInt(10)}}}
+	want, err := genesis.ToBlock(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Invalid genesis specifications are not written
+	invalid := *genesis
+	invalid.GasLimit = 0
+	if _, err := writeGenesis(cfg, "", &invalid); err == nil {
+		t.Fatalf("invalid genesis written")
+	}
+
+	hash, err := writeGenesis(cfg, "", genesis)
+	if err != nil {
+		t.Fatalf("failed to write genesis: %v", err)
+	}
+	if hash != want.Hash() {
+		t.Fatalf("genesis hash mismatch: have %x, want %x", hash, want.Hash())
+	}
+	// Writing it again is a no-op
+	if hash, err := writeGenesis(cfg, "", genesis); err != nil || hash != want.Hash() {
+		t.Fatalf("rewriting genesis failed: %x, %v", hash, err)
+	}
+
+	var out bytes.Buffer
+	if err := dumpGenesis(&out, cfg, ""); err != nil {
+		t.Fatalf("failed to dump genesis: %v", err)
+	}
+	dumped := new(core.Genesis)
+	if err := json.Unmarshal(out.Bytes(), dumped); err != nil {
+		t.Fatalf("invalid genesis dump: %v", err)
+	}
+	if block, err := dumped.ToBlock(nil); err != nil || block.Hash() != hash {
+		t.Fatalf("dumped genesis hash mismatch: %v", err)
+	}
+
+	// A different genesis is refused, naming the differences
+	genesis.Alloc = nil
+	_, err = writeGenesis(cfg, "", genesis)
+	if err == nil || !strings.Contains(err.Error(), "balances.GLD: have 10, new <missing>") {
+		t.Fatalf("mismatch error expected, got %v", err)
+	}
+}
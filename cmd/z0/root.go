@@ -37,8 +37,12 @@ var RootCmd = &cobra.Command{
 	// has an action associated with it:
 	Run: func(cmd *cobra.Command, args []string) {
 		setUpConfig()
-		node := makeNode()
-		if err := registerService(node); err != nil {
+		node, err := makeNode()
+		if err != nil {
+			log.Error("z0 make node failed.", "err", err)
+			return
+		}
+		if err := registerService(node, zconfig); err != nil {
 			log.Error("z0 start node failed.", "err", err)
 		}
 
@@ -50,14 +54,19 @@ var RootCmd = &cobra.Command{
 	},
 }
 
-func makeNode() *node.Node {
+func makeNode() (*node.Node, error) {
 	//  load config file.
 	if file := zconfig.ConfigFileFlag; file != "" {
 		if err := loadConfig(file, zconfig); err != nil {
 			log.Error("load config file %v", err)
 		}
 	}
-	return node.New(zconfig.NodeCfg)
+	if zconfig.DevFlag {
+		if err := setupDeveloper(zconfig); err != nil {
+			return nil, err
+		}
+	}
+	return node.New(zconfig.NodeCfg), nil
 }
 
 // start up the node itself
@@ -83,11 +92,25 @@ func startNode(stack *node.Node) error {
 	return nil
 }
 
-func registerService(stack *node.Node) error {
+func registerService(stack *node.Node, cfg *z0Config) error {
+	if len(cfg.Ledgers) > 0 {
+		return stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+			return zcnd.NewLedgers(ctx, cfg.Ledgers)
+		})
+	}
 	var err error
 	// register zcnd
 	err = stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
-		return zcnd.New(ctx, zconfig.ZcndCfg)
+		z, err := zcnd.New(ctx, cfg.ZcndCfg)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.devKey != nil {
+			if err := authorizeDeveloper(z, cfg.devKey); err != nil {
+				return nil, err
+			}
+		}
+		return z, nil
 	})
 	return err
 }
@@ -103,6 +126,10 @@ func init() {
 	// config file
 	falgs.StringVarP(&zconfig.ConfigFileFlag, "config", "c", "", "TOML configuration file")
 
+	// developer mode
+	falgs.BoolVar(&zconfig.DevFlag, "dev", false, "Ephemeral developer chain with a funded developer account and sample assets")
+	falgs.Uint64Var(&zconfig.DevPeriodFlag, "dev_period", 0, "Block period in seconds of the developer chain, zero seals blocks as transactions arrive")
+
 	// node
 	falgs.StringVarP(&zconfig.NodeCfg.DataDir, "datadir", "d", defaultDataDir(), "Data directory for the databases and keystore")
 
@@ -116,13 +143,17 @@ func init() {
 	falgs.StringVar(&zconfig.ZcndCfg.TxPool.Journal, "txpool_journal", zconfig.ZcndCfg.TxPool.Journal, "Disk journal for local transaction to survive node restarts")
 	falgs.DurationVar(&zconfig.ZcndCfg.TxPool.Rejournal, "txpool_rejournal", zconfig.ZcndCfg.TxPool.Rejournal, "Time interval to regenerate the local transaction journal")
 	falgs.Uint64Var(&zconfig.ZcndCfg.TxPool.PriceBump, "txpool_pricebump", zconfig.ZcndCfg.TxPool.PriceBump, "Price bump percentage to replace an already existing transaction")
-	falgs.Uint64Var(&zconfig.ZcndCfg.TxPool.PriceLimit, "txpool_pricelimit", zconfig.ZcndCfg.TxPool.PriceLimit, "Minimum gas price limit to enforce for acceptance into the pool")
+	falgs.Uint64Var(&zconfig.ZcndCfg.TxPool.PriceLimit, "txpool_pricelimit", zconfig.ZcndCfg.TxPool.PriceLimit, "Minimum tip in ZIP per gas to enforce for acceptance into the pool")
 	falgs.Uint64Var(&zconfig.ZcndCfg.TxPool.AccountSlots, "txpool_accountslots", zconfig.ZcndCfg.TxPool.AccountSlots, "Minimum number of executable transaction slots guaranteed per account")
 	falgs.Uint64Var(&zconfig.ZcndCfg.TxPool.AccountQueue, "txpool_accountqueue", zconfig.ZcndCfg.TxPool.AccountQueue, "Maximum number of non-executable transaction slots permitted per account")
 	falgs.Uint64Var(&zconfig.ZcndCfg.TxPool.GlobalSlots, "txpool_globalslots", zconfig.ZcndCfg.TxPool.GlobalSlots, "Maximum number of executable transaction slots for all accounts")
 	falgs.Uint64Var(&zconfig.ZcndCfg.TxPool.GlobalQueue, "txpool_globalqueue", zconfig.ZcndCfg.TxPool.GlobalQueue, "Minimum number of non-executable transaction slots for all accounts")
 	falgs.DurationVar(&zconfig.ZcndCfg.TxPool.Lifetime, "txpool_lifetime", zconfig.ZcndCfg.TxPool.Lifetime, "Maximum amount of time non-executable transaction are queued")
 
+	// miner
+	falgs.BoolVar(&zconfig.ZcndCfg.Miner.Start, "miner_start", zconfig.ZcndCfg.Miner.Start, "Start producing blocks with the node")
+	falgs.DurationVar(&zconfig.ZcndCfg.Miner.Interval, "miner_interval", zconfig.ZcndCfg.Miner.Interval, "Time between two produced blocks, zero produces them as transactions arrive")
+
 }
 
 // Execute adds all child commands to the root command sets flags appropriately.
@@ -0,0 +1,405 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package bft implements a byzantine fault tolerant consensus engine with
+// deterministic finality, following the Tendermint propose, prevote and
+// precommit rounds over a fixed validator set.
+package bft
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/hashicorp/golang-lru"
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/consensus"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/params"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/rlp"
+	"github.com/zipper-project/z0/utils/zdb"
+)
+
+const (
+	inmemorySignatures = 4096 // Number of recent block signatures to keep in memory
+
+	defaultTimeout = 1000 // Default base round step timeout in milliseconds
+	inboxSize      = 1024 // Number of queued inbound messages before dropping new ones
+	backlogHeights = 16   // Number of future heights to buffer messages for
+)
+
+var (
+	extraVanity = 32 // Fixed number of extra-data prefix bytes reserved for proposer vanity
+	extraSeal   = 65 // Fixed number of extra-data suffix bytes reserved for proposer seal
+
+	blockDifficulty = big.NewInt(1) // Every block has the same weight, finality decides the chain
+)
+
+var (
+	// errUnknownBlock is returned when the parent of a block is not part of the
+	// local blockchain.
+	errUnknownBlock = errors.New("unknown block")
+
+	// errInvalidExtra is returned if the extra-data isn't a 32 byte vanity
+	// followed by a 65 byte proposer seal.
+	errInvalidExtra = errors.New("invalid extra-data layout")
+
+	// errInvalidMixDigest is returned if a block's mix digest is non-zero.
+	errInvalidMixDigest = errors.New("non-zero mix digest")
+
+	// errInvalidDifficulty is returned if the difficulty of a block is not 1.
+	errInvalidDifficulty = errors.New("invalid difficulty")
+
+	// errInvalidTimestamp is returned if the timestamp of a block is lower than
+	// the previous block's timestamp + the minimum block period.
+	errInvalidTimestamp = errors.New("invalid timestamp")
+
+	// errInvalidProposer is returned if a block isn't sealed by the proposer of
+	// the round stored in its nonce.
+	errInvalidProposer = errors.New("block not sealed by the round proposer")
+
+	// errMissingCommit is returned if a block has no stored commit signatures.
+	errMissingCommit = errors.New("missing commit signatures")
+
+	// errStaleBlock is returned if a block is sealed on top of a height that has
+	// already been committed.
+	errStaleBlock = errors.New("sealing block of a committed height")
+
+	// errNotRunning is returned if the engine is asked to seal while it's not
+	// taking part in the consensus rounds.
+	errNotRunning = errors.New("consensus engine not running")
+)
+
+// Broadcaster delivers consensus messages to the other validators.
+type Broadcaster interface {
+	Broadcast(msg *Message)
+}
+
+// sealHash returns the hash of a block prior to it being sealed.
+func sealHash(header *types.Header) (hash common.Hash) {
+	enc, _ := rlp.EncodeToBytes([]interface{}{
+		header.ParentHash,
+		header.Coinbase,
+		header.Root,
+		header.TxHash,
+		header.ReceiptHash,
+		header.Bloom,
+		header.Difficulty,
+		header.Number,
+		header.GasLimit,
+		header.GasUsed,
+		header.Time,
+		header.Extra[:len(header.Extra)-extraSeal], // Yes, this will panic if extra is too short
+		header.MixDigest,
+		header.Nonce,
+		header.BaseFee,
+	})
+	return crypto.Keccak256Hash(enc)
+}
+
+// ecrecover extracts the proposer address from a sealed header.
+func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, error) {
+	hash := header.Hash()
+	if address, known := sigcache.Get(hash); known {
+		return address.(common.Address), nil
+	}
+	if len(header.Extra) < extraSeal {
+		return common.Address{}, errInvalidExtra
+	}
+	signer, err := recoverSigner(sealHash(header), header.Extra[len(header.Extra)-extraSeal:])
+	if err != nil {
+		return common.Address{}, err
+	}
+	sigcache.Add(hash, signer)
+	return signer, nil
+}
+
+// BFT is the byzantine fault tolerant consensus engine.
+type BFT struct {
+	config     *params.BFTConfig // Consensus engine configuration parameters
+	db         zdb.Database      // Database to store and retrieve commit signatures
+	validators *ValidatorSet     // Validators proposing and voting on blocks
+	signatures *lru.ARCCache     // Proposers of recent blocks to speed up verification
+
+	signer common.Address     // Address of the signing key
+	signFn consensus.SignerFn // Signer function to authorize hashes with
+	lock   sync.RWMutex       // Protects the signer fields
+
+	chain       consensus.ChainReader
+	broadcaster Broadcaster
+	state       *heightState          // Consensus state of the current height, owned by the loop
+	backlog     map[uint64][]*Message // Messages of future heights
+	pending     *sealRequest          // Block waiting to be proposed and committed
+	answered    map[uint64]time.Time  // Last time a lagging validator was sent a height's commit
+	inbox       chan *Message         // Inbound consensus messages
+	seals       chan *sealRequest     // Blocks handed over for sealing
+	heads       chan *types.Header    // New chain heads
+	timeouts    chan timeoutEvent     // Expired round step timeouts
+	quit        chan struct{}         // Terminates the consensus loop
+	running     bool                  // Whether the consensus loop is running
+	runLock     sync.Mutex            // Protects the running state
+	wg          sync.WaitGroup
+}
+
+// New creates a byzantine fault tolerant consensus engine for the configured
+// validator set.
+func New(config *params.BFTConfig, db zdb.Database) *BFT {
+	conf := *config
+	if conf.Timeout == 0 {
+		conf.Timeout = defaultTimeout
+	}
+	signatures, _ := lru.NewARC(inmemorySignatures)
+
+	return &BFT{
+		config:     &conf,
+		db:         db,
+		validators: NewValidatorSet(conf.Validators),
+		signatures: signatures,
+	}
+}
+
+// Validators returns the validator set of the engine.
+func (b *BFT) Validators() *ValidatorSet { return b.validators }
+
+// Authorize injects a private key into the consensus engine to propose and vote
+// with.
+func (b *BFT) Authorize(signer common.Address, signFn consensus.SignerFn) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.signer = signer
+	b.signFn = signFn
+}
+
+// Author implements consensus.Engine, returning the proposer recovered from the
+// seal in the header's extra-data section.
+func (b *BFT) Author(header *types.Header) (common.Address, error) {
+	return ecrecover(header, b.signatures)
+}
+
+// VerifyHeader implements consensus.Engine, checking the extra-data layout, the
+// difficulty and the block period of a header.
+func (b *BFT) VerifyHeader(chain consensus.ChainReader, header, parent *types.Header) error {
+	if len(header.Extra) != extraVanity+extraSeal {
+		return errInvalidExtra
+	}
+	if header.MixDigest != (common.Hash{}) {
+		return errInvalidMixDigest
+	}
+	if header.Difficulty == nil || header.Difficulty.Cmp(blockDifficulty) != 0 {
+		return errInvalidDifficulty
+	}
+	if parent.Time.Uint64()+b.config.Period > header.Time.Uint64() {
+		return errInvalidTimestamp
+	}
+	return nil
+}
+
+// verifyProposer checks that the header is sealed by the proposer of the round
+// stored in its nonce.
+func (b *BFT) verifyProposer(header *types.Header) error {
+	proposer, err := ecrecover(header, b.signatures)
+	if err != nil {
+		return err
+	}
+	if proposer != b.validators.Proposer(header.Number.Uint64(), header.Nonce.Uint64()) {
+		return errInvalidProposer
+	}
+	return nil
+}
+
+// VerifySeal implements consensus.Engine, checking the proposer seal and the
+// stored commit signatures of a header.
+func (b *BFT) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
+	number := header.Number.Uint64()
+	if number == 0 {
+		return errUnknownBlock
+	}
+	if err := b.verifyProposer(header); err != nil {
+		return err
+	}
+	commit := ReadCommit(b.db, header.Hash(), number)
+	if commit == nil {
+		return errMissingCommit
+	}
+	return commit.verify(b.validators, number, header.Hash())
+}
+
+// Finalized implements consensus.Finality, every block with a valid commit is
+// final.
+func (b *BFT) Finalized(chain consensus.ChainReader, header *types.Header) bool {
+	commit := ReadCommit(b.db, header.Hash(), header.Number.Uint64())
+	return commit != nil && commit.verify(b.validators, header.Number.Uint64(), header.Hash()) == nil
+}
+
+// Prepare implements consensus.Engine, preparing all the consensus fields of the
+// header for running the transactions on top.
+func (b *BFT) Prepare(chain consensus.ChainReader, header *types.Header) error {
+	number := header.Number.Uint64()
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return errUnknownBlock
+	}
+	header.Difficulty = new(big.Int).Set(blockDifficulty)
+	header.MixDigest = common.Hash{}
+	header.Nonce = types.BlockNonce{}
+
+	// Ensure the extra data has all its components
+	if len(header.Extra) < extraVanity {
+		header.Extra = append(header.Extra, bytes.Repeat([]byte{0x00}, extraVanity-len(header.Extra))...)
+	}
+	header.Extra = append(header.Extra[:extraVanity], make([]byte, extraSeal)...)
+
+	// Ensure the timestamp respects the block period
+	header.Time = new(big.Int).Add(parent.Time, new(big.Int).SetUint64(b.config.Period))
+	if header.Time.Cmp(parent.Time) <= 0 {
+		header.Time.Add(parent.Time, common.Big1)
+	}
+	if now := big.NewInt(time.Now().Unix()); header.Time.Cmp(now) < 0 {
+		header.Time = now
+	}
+	return nil
+}
+
+// Seal implements consensus.Engine, handing the block over to the consensus
+// rounds of its height. It returns the block committed at that height, which is
+// the given block only if it was proposed and accepted.
+func (b *BFT) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	if block.NumberU64() == 0 {
+		return nil, errUnknownBlock
+	}
+	b.runLock.Lock()
+	running, quit := b.running, b.quit
+	b.runLock.Unlock()
+	if !running {
+		return nil, errNotRunning
+	}
+	// Don't propose a block the other validators would reject as a future block
+	delay := time.Unix(block.Time().Int64(), 0).Sub(time.Now())
+	select {
+	case <-stop:
+		return nil, nil
+	case <-quit:
+		return nil, errNotRunning
+	case <-time.After(delay):
+	}
+	req := &sealRequest{block: block, result: make(chan *types.Block, 1)}
+	select {
+	case b.seals <- req:
+	case <-stop:
+		return nil, nil
+	case <-quit:
+		return nil, errNotRunning
+	}
+	select {
+	case committed := <-req.result:
+		if committed == nil {
+			return nil, errStaleBlock
+		}
+		return committed, nil
+	case <-stop:
+		return nil, nil
+	case <-quit:
+		return nil, errNotRunning
+	}
+}
+
+// CalcDifficulty implements consensus.Engine, every block has the same
+// difficulty.
+func (b *BFT) CalcDifficulty(chain consensus.ChainReader, time uint64, parent *types.Header) *big.Int {
+	return new(big.Int).Set(blockDifficulty)
+}
+
+// Start joins the consensus rounds, starting at the height after the current
+// head of the chain. Messages are sent to the other validators through the
+// broadcaster.
+func (b *BFT) Start(chain consensus.ChainReader, broadcaster Broadcaster) error {
+	b.runLock.Lock()
+	defer b.runLock.Unlock()
+
+	if b.running {
+		return nil
+	}
+	b.chain = chain
+	b.broadcaster = broadcaster
+	b.backlog = make(map[uint64][]*Message)
+	b.answered = make(map[uint64]time.Time)
+	b.pending = nil
+	b.inbox = make(chan *Message, inboxSize)
+	b.seals = make(chan *sealRequest)
+	b.heads = make(chan *types.Header, 16)
+	b.timeouts = make(chan timeoutEvent, 16)
+	b.quit = make(chan struct{})
+	b.running = true
+
+	b.wg.Add(1)
+	go b.loop()
+	return nil
+}
+
+// Stop leaves the consensus rounds.
+func (b *BFT) Stop() {
+	b.runLock.Lock()
+	if !b.running {
+		b.runLock.Unlock()
+		return
+	}
+	b.running = false
+	close(b.quit)
+	b.runLock.Unlock()
+
+	b.wg.Wait()
+}
+
+// HandleMessage queues a consensus message received from another validator.
+// Messages are dropped if the engine isn't running or can't keep up, the
+// protocol tolerates message loss.
+func (b *BFT) HandleMessage(msg *Message) {
+	b.runLock.Lock()
+	defer b.runLock.Unlock()
+
+	if !b.running {
+		return
+	}
+	cpy := *msg
+	select {
+	case b.inbox <- &cpy:
+	default:
+		log.Debug("Dropping consensus message, inbox full", "code", msg.Code, "height", msg.Height, "round", msg.Round)
+	}
+}
+
+// NewChainHead notifies the engine about a new chain head, letting it move on
+// to the next height.
+func (b *BFT) NewChainHead(header *types.Header) {
+	b.runLock.Lock()
+	defer b.runLock.Unlock()
+
+	if !b.running {
+		return
+	}
+	select {
+	case b.heads <- header:
+	default:
+		// The loop checks the chain head when moving on, a dropped notification
+		// is only a delay.
+		log.Debug("Dropping chain head notification", "number", header.Number)
+	}
+}
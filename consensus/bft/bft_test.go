@@ -0,0 +1,330 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core"
+	"github.com/zipper-project/z0/core/vm"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/params"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/zdb"
+)
+
+// testNode is a validator of the in-process test network.
+type testNode struct {
+	key    *ecdsa.PrivateKey
+	addr   common.Address
+	engine *BFT
+	chain  *core.BlockChain
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+func (n *testNode) sign(addr common.Address, hash []byte) ([]byte, error) {
+	return crypto.Sign(hash, n.key)
+}
+
+// testNetwork connects validators over channels, dropping messages at the
+// configured rate.
+type testNetwork struct {
+	t     *testing.T
+	nodes []*testNode
+	loss  float64
+
+	lock sync.Mutex
+	rand *rand.Rand
+}
+
+// nodeBroadcaster is the broadcaster of a single node in the test network.
+type nodeBroadcaster struct {
+	net  *testNetwork
+	from int
+}
+
+func (nb *nodeBroadcaster) Broadcast(msg *Message) {
+	for i, node := range nb.net.nodes {
+		if i == nb.from || nb.net.lost() {
+			continue
+		}
+		// Hand every node its own block copy, as decoding it off the wire would
+		cpy := *msg
+		if msg.Block != nil {
+			cpy.Block = types.NewBlockWithHeader(msg.Block.Header()).WithBody(msg.Block.Transactions())
+		}
+		node.engine.HandleMessage(&cpy)
+	}
+}
+
+func (net *testNetwork) lost() bool {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+
+	return net.rand.Float64() < net.loss
+}
+
+func newTestNetwork(t *testing.T, n int, loss float64) *testNetwork {
+	net := &testNetwork{t: t, loss: loss, rand: rand.New(rand.NewSource(int64(n)))}
+	validators := make([]common.Address, n)
+	for i := 0; i < n; i++ {
+		key, _ := crypto.GenerateKey()
+		net.nodes = append(net.nodes, &testNode{key: key, addr: crypto.PubkeyToAddress(key.PublicKey)})
+		validators[i] = net.nodes[i].addr
+	}
+	config := &params.ChainConfig{
+		ChainID: big.NewInt(1),
+		BFT:     &params.BFTConfig{Validators: validators, Timeout: 50},
+	}
+	genesis := &core.Genesis{
+		Config:     config,
+		GasLimit:   params.MinGasLimit * 100,
+		Difficulty: big.NewInt(1),
+	}
+	for _, node := range net.nodes {
+		db := zdb.NewMemDatabase()
+		if _, err := genesis.Commit(db); err != nil {
+			t.Fatalf("failed to commit genesis: %v", err)
+		}
+		node.engine = New(config.BFT, db)
+		chain, err := core.NewBlockChain(db, nil, config, node.engine, nil, vm.Config{})
+		if err != nil {
+			t.Fatalf("failed to create chain: %v", err)
+		}
+		node.chain = chain
+		node.engine.Authorize(node.addr, node.sign)
+	}
+	return net
+}
+
+// start runs the consensus rounds on the given nodes, each building on its own
+// chain head and inserting the committed blocks.
+func (net *testNetwork) start(nodes ...int) {
+	for _, i := range nodes {
+		node := net.nodes[i]
+		node.quit, node.done = make(chan struct{}), make(chan struct{})
+		node.engine.Start(node.chain, &nodeBroadcaster{net: net, from: i})
+		go net.run(node)
+	}
+}
+
+func (net *testNetwork) run(node *testNode) {
+	defer close(node.done)
+
+	for {
+		parent := node.chain.CurrentBlock()
+		header := &types.Header{
+			ParentHash:  parent.Hash(),
+			Number:      new(big.Int).Add(parent.Number(), common.Big1),
+			GasLimit:    parent.GasLimit(),
+			Root:        parent.Root(),
+			TxHash:      types.EmptyRootHash,
+			ReceiptHash: types.EmptyRootHash,
+		}
+		if err := node.engine.Prepare(node.chain, header); err != nil {
+			net.t.Errorf("failed to prepare header: %v", err)
+			return
+		}
+		// Keep the timestamps in the past so proposals don't wait for the slot
+		header.Time = new(big.Int).Add(parent.Time(), common.Big1)
+
+		block, err := node.engine.Seal(node.chain, types.NewBlockWithHeader(header), node.quit)
+		switch {
+		case block == nil && err == nil:
+			return
+		case err == errStaleBlock:
+			continue
+		case err != nil:
+			net.t.Errorf("failed to seal block: %v", err)
+			return
+		}
+		if _, err := node.chain.InsertChain(types.Blocks{block}); err != nil {
+			net.t.Errorf("failed to insert committed block %d: %v", block.NumberU64(), err)
+			return
+		}
+		node.engine.NewChainHead(block.Header())
+	}
+}
+
+// stop halts the consensus rounds, leaving the chains open for inspection.
+func (net *testNetwork) stop() {
+	for _, node := range net.nodes {
+		if node.quit != nil {
+			close(node.quit)
+			<-node.done
+			node.engine.Stop()
+			node.quit = nil
+		}
+	}
+}
+
+func (net *testNetwork) close() {
+	net.stop()
+	for _, node := range net.nodes {
+		node.chain.Stop()
+	}
+}
+
+// waitHeight waits until the given nodes reach the height.
+func (net *testNetwork) waitHeight(height uint64, timeout time.Duration, nodes ...int) {
+	deadline := time.Now().Add(timeout)
+	for _, i := range nodes {
+		for net.nodes[i].chain.CurrentBlock().NumberU64() < height {
+			if time.Now().After(deadline) {
+				net.t.Fatalf("node %d stuck at height %d, want %d", i, net.nodes[i].chain.CurrentBlock().NumberU64(), height)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+// checkSafety verifies that the nodes committed the same finalized blocks.
+func (net *testNetwork) checkSafety(height uint64, nodes ...int) {
+	ref := net.nodes[nodes[0]]
+	for number := uint64(1); number <= height; number++ {
+		want := ref.chain.GetBlockByNumber(number)
+		for _, i := range nodes {
+			node := net.nodes[i]
+			have := node.chain.GetBlockByNumber(number)
+			if have == nil || have.Hash() != want.Hash() {
+				net.t.Fatalf("node %d: block %d mismatch", i, number)
+			}
+			if !node.engine.Finalized(node.chain, have.Header()) {
+				net.t.Fatalf("node %d: block %d not finalized", i, number)
+			}
+		}
+	}
+}
+
+func all(n int) []int {
+	nodes := make([]int, n)
+	for i := range nodes {
+		nodes[i] = i
+	}
+	return nodes
+}
+
+func TestConsensus(t *testing.T) {
+	for n := 4; n <= 7; n++ {
+		net := newTestNetwork(t, n, 0)
+		defer net.close()
+		net.start(all(n)...)
+		net.waitHeight(5, 20*time.Second, all(n)...)
+		net.stop()
+		net.checkSafety(5, all(n)...)
+	}
+}
+
+func TestConsensusMessageLoss(t *testing.T) {
+	for _, n := range []int{4, 7} {
+		net := newTestNetwork(t, n, 0.2)
+		defer net.close()
+		net.start(all(n)...)
+		net.waitHeight(4, 60*time.Second, all(n)...)
+		net.stop()
+		net.checkSafety(4, all(n)...)
+	}
+}
+
+func TestConsensusFaultyValidators(t *testing.T) {
+	// Four validators tolerate one silent validator, seven tolerate two
+	for _, n := range []int{4, 7} {
+		net := newTestNetwork(t, n, 0)
+		defer net.close()
+		f := net.nodes[0].engine.Validators().F()
+		live := all(n)[f:]
+		net.start(live...)
+		net.waitHeight(4, 30*time.Second, live...)
+		net.stop()
+		net.checkSafety(4, live...)
+	}
+}
+
+func TestConsensusNoQuorum(t *testing.T) {
+	// Without a quorum of live validators nothing gets committed
+	net := newTestNetwork(t, 4, 0)
+	defer net.close()
+	net.start(0, 1)
+	time.Sleep(500 * time.Millisecond)
+	net.stop()
+
+	for i, node := range net.nodes {
+		if head := node.chain.CurrentBlock().NumberU64(); head != 0 {
+			t.Fatalf("node %d committed block %d without quorum", i, head)
+		}
+	}
+}
+
+func TestFinalizedReorg(t *testing.T) {
+	net := newTestNetwork(t, 4, 0)
+	defer net.close()
+	net.start(all(4)...)
+	net.waitHeight(2, 20*time.Second, all(4)...)
+	net.stop()
+
+	// Have all validators equivocate on a longer fork of the first node's chain
+	node := net.nodes[0]
+	head := node.chain.CurrentBlock()
+	genesis := node.chain.GetBlockByNumber(0)
+
+	var fork types.Blocks
+	parent := genesis
+	for number := uint64(1); number <= head.NumberU64()+1; number++ {
+		header := &types.Header{
+			ParentHash:  parent.Hash(),
+			Number:      new(big.Int).SetUint64(number),
+			GasLimit:    parent.GasLimit(),
+			Root:        parent.Root(),
+			TxHash:      types.EmptyRootHash,
+			ReceiptHash: types.EmptyRootHash,
+			Difficulty:  big.NewInt(1),
+			Extra:       make([]byte, extraVanity+extraSeal),
+			Time:        new(big.Int).Add(parent.Time(), big.NewInt(2)),
+		}
+		proposer := node.engine.Validators().Proposer(number, 0)
+		for _, n := range net.nodes {
+			if n.addr == proposer {
+				sig, _ := n.sign(proposer, sealHash(header).Bytes())
+				copy(header.Extra[extraVanity:], sig)
+			}
+		}
+		block := types.NewBlockWithHeader(header)
+		commit := &Commit{}
+		for _, n := range net.nodes {
+			sig, _ := n.sign(n.addr, voteHash(MsgPrecommit, number, 0, block.Hash(), -1).Bytes())
+			commit.Signatures = append(commit.Signatures, sig)
+		}
+		if err := WriteCommit(node.engine.db, block.Hash(), number, commit); err != nil {
+			t.Fatalf("failed to write commit: %v", err)
+		}
+		fork = append(fork, block)
+		parent = block
+	}
+	if _, err := node.chain.InsertChain(fork); err != core.ErrFinalizedReorg {
+		t.Fatalf("error mismatch: have %v, want %v", err, core.ErrFinalizedReorg)
+	}
+	if node.chain.CurrentBlock().Hash() != head.Hash() {
+		t.Fatalf("finalized head reorganized")
+	}
+}
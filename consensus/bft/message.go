@@ -0,0 +1,181 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"errors"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/rawdb"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/rlp"
+)
+
+// MsgCode identifies the kind of a consensus message.
+type MsgCode uint8
+
+// Consensus message codes.
+const (
+	MsgProposal  MsgCode = iota // Block proposed by the round proposer
+	MsgPrevote                  // First round vote on a proposal
+	MsgPrecommit                // Second round vote, a quorum of them commits the block
+	MsgCommit                   // Committed block with its commit signatures, for lagging validators
+)
+
+func (c MsgCode) String() string {
+	switch c {
+	case MsgProposal:
+		return "proposal"
+	case MsgPrevote:
+		return "prevote"
+	case MsgPrecommit:
+		return "precommit"
+	case MsgCommit:
+		return "commit"
+	}
+	return "unknown"
+}
+
+var (
+	// errInvalidMessage is returned if a message is malformed.
+	errInvalidMessage = errors.New("invalid consensus message")
+
+	// errNotValidator is returned if a message or commit signature is not from
+	// a validator.
+	errNotValidator = errors.New("signer is not a validator")
+
+	// errInvalidCommit is returned if a commit lacks a quorum of validator
+	// signatures.
+	errInvalidCommit = errors.New("commit lacks a validator quorum")
+)
+
+// Message is a signed consensus message exchanged between validators.
+type Message struct {
+	Code       MsgCode
+	Height     uint64
+	Round      uint64
+	Hash       common.Hash  // Hash of the block voted on, zero to vote for no block
+	ValidRound int64        // Round the proposed block got a prevote quorum in, -1 if none
+	Block      *types.Block // Block of a proposal or commit message
+	Commit     *Commit      // Commit signatures of a commit message
+	Signature  []byte
+
+	from common.Address // Sender recovered from the signature
+}
+
+// From returns the sender of a verified message.
+func (m *Message) From() common.Address { return m.from }
+
+// sigHash returns the hash the sender signs. The precommit signatures double as
+// the commit signatures of a block.
+func (m *Message) sigHash() common.Hash {
+	return voteHash(m.Code, m.Height, m.Round, m.Hash, m.ValidRound)
+}
+
+func voteHash(code MsgCode, height, round uint64, hash common.Hash, validRound int64) common.Hash {
+	enc, _ := rlp.EncodeToBytes([]interface{}{
+		uint8(code),
+		height,
+		round,
+		hash,
+		uint64(validRound + 1),
+	})
+	return crypto.Keccak256Hash(enc)
+}
+
+// recover resolves the sender of the message and checks that it's a validator.
+func (m *Message) recover(validators *ValidatorSet) error {
+	if m.Code > MsgCommit {
+		return errInvalidMessage
+	}
+	if (m.Code == MsgProposal || m.Code == MsgCommit) && (m.Block == nil || m.Block.Hash() != m.Hash) {
+		return errInvalidMessage
+	}
+	if m.Code == MsgCommit && m.Commit == nil {
+		return errInvalidMessage
+	}
+	signer, err := recoverSigner(m.sigHash(), m.Signature)
+	if err != nil {
+		return err
+	}
+	if !validators.Contains(signer) {
+		return errNotValidator
+	}
+	m.from = signer
+	return nil
+}
+
+func recoverSigner(hash common.Hash, sig []byte) (common.Address, error) {
+	pubkey, err := crypto.Ecrecover(hash.Bytes(), sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var signer common.Address
+	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+	return signer, nil
+}
+
+// Commit is the quorum of precommit signatures finalizing a block.
+type Commit struct {
+	Round      uint64   // Round the block was committed in
+	Signatures [][]byte // Precommit signatures of the validators
+}
+
+// verify checks that the commit carries precommits from a quorum of distinct
+// validators for the given block.
+func (c *Commit) verify(validators *ValidatorSet, height uint64, hash common.Hash) error {
+	sighash := voteHash(MsgPrecommit, height, c.Round, hash, -1)
+	signers := make(map[common.Address]struct{})
+	for _, sig := range c.Signatures {
+		signer, err := recoverSigner(sighash, sig)
+		if err != nil {
+			return err
+		}
+		if !validators.Contains(signer) {
+			return errNotValidator
+		}
+		signers[signer] = struct{}{}
+	}
+	if len(signers) < validators.Quorum() {
+		return errInvalidCommit
+	}
+	return nil
+}
+
+// ReadCommit retrieves the commit signatures finalizing a block.
+func ReadCommit(db rawdb.DatabaseReader, hash common.Hash, number uint64) *Commit {
+	data := rawdb.ReadCommitRLP(db, hash, number)
+	if len(data) == 0 {
+		return nil
+	}
+	commit := new(Commit)
+	if err := rlp.DecodeBytes(data, commit); err != nil {
+		return nil
+	}
+	return commit
+}
+
+// WriteCommit stores the commit signatures finalizing a block.
+func WriteCommit(db rawdb.DatabaseWriter, hash common.Hash, number uint64, commit *Commit) error {
+	data, err := rlp.EncodeToBytes(commit)
+	if err != nil {
+		return err
+	}
+	rawdb.WriteCommitRLP(db, hash, number, data)
+	return nil
+}
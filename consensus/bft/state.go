@@ -0,0 +1,605 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/types"
+)
+
+// step is the stage of a consensus round.
+type step uint8
+
+const (
+	stepPropose   step = iota // Waiting for the proposal of the round
+	stepPrevote               // Prevoted, waiting for a prevote quorum
+	stepPrecommit             // Precommitted, waiting for a precommit quorum
+	stepCommitted             // Height committed, waiting for the chain head to move on
+)
+
+// timeoutEvent is an expired timeout of a round step.
+type timeoutEvent struct {
+	height uint64
+	round  uint64
+	step   step
+}
+
+// sealRequest is a block handed over by Seal, answered with the block committed
+// at its height.
+type sealRequest struct {
+	block  *types.Block
+	result chan *types.Block
+}
+
+// voteSet collects the votes of one kind cast in a round.
+type voteSet struct {
+	votes map[common.Address]*Message
+	count map[common.Hash]int
+}
+
+func newVoteSet() *voteSet {
+	return &voteSet{
+		votes: make(map[common.Address]*Message),
+		count: make(map[common.Hash]int),
+	}
+}
+
+// add records a vote, only the first vote of every validator counts.
+func (vs *voteSet) add(msg *Message) bool {
+	if _, ok := vs.votes[msg.from]; ok {
+		return false
+	}
+	vs.votes[msg.from] = msg
+	vs.count[msg.Hash]++
+	return true
+}
+
+// signatures returns the signatures of the votes for the given block.
+func (vs *voteSet) signatures(hash common.Hash) [][]byte {
+	var sigs [][]byte
+	for _, vote := range vs.votes {
+		if vote.Hash == hash {
+			sigs = append(sigs, vote.Signature)
+		}
+	}
+	return sigs
+}
+
+// heightState is the consensus state of the height being decided.
+type heightState struct {
+	height uint64
+	round  uint64
+	step   step
+
+	lockedRound int64        // Round the validator precommitted a block in, -1 if none
+	lockedBlock *types.Block // Block the validator is locked on
+	validRound  int64        // Last round a proposal got a prevote quorum in, -1 if none
+	validBlock  *types.Block // Proposal that got the last prevote quorum
+
+	proposals  map[uint64]*Message
+	prevotes   map[uint64]*voteSet
+	precommits map[uint64]*voteSet
+	senders    map[uint64]map[common.Address]struct{} // Validators heard from per round
+	polka      map[uint64]bool                        // Rounds a proposal prevote quorum was acted on
+	proposed   map[uint64]bool                        // Rounds the validator sent its proposal in
+	valid      map[common.Hash]error                  // Validation results of proposed blocks
+	sent       []*Message                             // Proposals and votes the validator sent
+	decided    *types.Block                           // Block committed at the height
+}
+
+func newHeightState(height uint64) *heightState {
+	return &heightState{
+		height:      height,
+		lockedRound: -1,
+		validRound:  -1,
+		proposals:   make(map[uint64]*Message),
+		prevotes:    make(map[uint64]*voteSet),
+		precommits:  make(map[uint64]*voteSet),
+		senders:     make(map[uint64]map[common.Address]struct{}),
+		polka:       make(map[uint64]bool),
+		proposed:    make(map[uint64]bool),
+		valid:       make(map[common.Hash]error),
+	}
+}
+
+func (s *heightState) prevotesOf(round uint64) *voteSet {
+	if s.prevotes[round] == nil {
+		s.prevotes[round] = newVoteSet()
+	}
+	return s.prevotes[round]
+}
+
+func (s *heightState) precommitsOf(round uint64) *voteSet {
+	if s.precommits[round] == nil {
+		s.precommits[round] = newVoteSet()
+	}
+	return s.precommits[round]
+}
+
+// loop runs the consensus rounds until the engine is stopped.
+func (b *BFT) loop() {
+	defer b.wg.Done()
+
+	b.startHeight(b.chain.CurrentHeader().Number.Uint64() + 1)
+	for {
+		select {
+		case msg := <-b.inbox:
+			b.handleMessage(msg)
+
+		case ev := <-b.timeouts:
+			b.handleTimeout(ev)
+
+		case req := <-b.seals:
+			b.handleSeal(req)
+
+		case head := <-b.heads:
+			if head.Number.Uint64() >= b.state.height {
+				b.startHeight(head.Number.Uint64() + 1)
+			}
+
+		case <-b.quit:
+			return
+		}
+	}
+}
+
+// startHeight resets the consensus state for a new height.
+func (b *BFT) startHeight(height uint64) {
+	log.Debug("Starting consensus height", "height", height)
+
+	b.state = newHeightState(height)
+	for h := range b.backlog {
+		if h < height {
+			delete(b.backlog, h)
+		}
+	}
+	for h := range b.answered {
+		if h+backlogHeights < height {
+			delete(b.answered, h)
+		}
+	}
+	if b.pending != nil && b.pending.block.NumberU64() < height {
+		b.pending.result <- nil
+		b.pending = nil
+	}
+	b.startRound(0)
+
+	backlog := b.backlog[height]
+	delete(b.backlog, height)
+	for _, msg := range backlog {
+		b.handleMessage(msg)
+	}
+}
+
+// startRound moves the current height to a new round.
+func (b *BFT) startRound(round uint64) {
+	s := b.state
+	s.round, s.step = round, stepPropose
+
+	// Messages may have been lost, resend the ones of the earlier rounds so that
+	// the validators learn about the blocks others are locked on
+	for _, msg := range s.sent {
+		b.broadcaster.Broadcast(msg)
+	}
+	b.schedule(stepPropose)
+	if b.validators.Proposer(s.height, round) == b.address() {
+		b.propose()
+	}
+	b.process()
+}
+
+// schedule starts the timeout of the given step of the current round. Later
+// rounds wait longer, so that the validators eventually overlap in a round.
+func (b *BFT) schedule(st step) {
+	ev := timeoutEvent{height: b.state.height, round: b.state.round, step: st}
+	timeout := time.Duration(b.config.Timeout*(ev.round+1)) * time.Millisecond
+	if st == stepCommitted {
+		timeout = time.Duration(b.config.Timeout) * time.Millisecond
+	}
+	timeouts, quit := b.timeouts, b.quit
+	time.AfterFunc(timeout, func() {
+		select {
+		case timeouts <- ev:
+		case <-quit:
+		}
+	})
+}
+
+// address returns the address of the local validator.
+func (b *BFT) address() common.Address {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.signer
+}
+
+// sign signs the message with the local validator key.
+func (b *BFT) sign(msg *Message) error {
+	b.lock.RLock()
+	signer, signFn := b.signer, b.signFn
+	b.lock.RUnlock()
+
+	if signFn == nil {
+		return errNotValidator
+	}
+	sig, err := signFn(signer, msg.sigHash().Bytes())
+	if err != nil {
+		return err
+	}
+	msg.Signature, msg.from = sig, signer
+	return nil
+}
+
+// broadcast signs the message and sends it to the other validators.
+func (b *BFT) broadcast(msg *Message) bool {
+	if !b.validators.Contains(b.address()) {
+		return false
+	}
+	if err := b.sign(msg); err != nil {
+		log.Warn("Failed to sign consensus message", "code", msg.Code, "err", err)
+		return false
+	}
+	b.broadcaster.Broadcast(msg)
+	return true
+}
+
+// propose sends the proposal of the current round if the validator has a block
+// to propose.
+func (b *BFT) propose() {
+	s := b.state
+	if s.proposed[s.round] || s.step != stepPropose {
+		return
+	}
+	block := s.validBlock
+	if block == nil {
+		if b.pending == nil || b.pending.block.NumberU64() != s.height {
+			return
+		}
+		// Seal the candidate for this round, the nonce records the round
+		b.lock.RLock()
+		signer, signFn := b.signer, b.signFn
+		b.lock.RUnlock()
+
+		header := b.pending.block.Header()
+		header.Nonce = types.EncodeNonce(s.round)
+		sig, err := signFn(signer, sealHash(header).Bytes())
+		if err != nil {
+			log.Warn("Failed to seal proposal", "height", s.height, "err", err)
+			return
+		}
+		copy(header.Extra[len(header.Extra)-extraSeal:], sig)
+		block = b.pending.block.WithSeal(header)
+	}
+	msg := &Message{
+		Code:       MsgProposal,
+		Height:     s.height,
+		Round:      s.round,
+		Hash:       block.Hash(),
+		ValidRound: s.validRound,
+		Block:      block,
+	}
+	if b.broadcast(msg) {
+		s.proposed[s.round] = true
+		s.sent = append(s.sent, msg)
+		b.record(msg)
+	}
+}
+
+// vote casts a prevote or precommit in the current round, the zero hash votes
+// for no block.
+func (b *BFT) vote(code MsgCode, hash common.Hash) {
+	msg := &Message{
+		Code:       code,
+		Height:     b.state.height,
+		Round:      b.state.round,
+		Hash:       hash,
+		ValidRound: -1,
+	}
+	if b.broadcast(msg) {
+		b.state.sent = append(b.state.sent, msg)
+		b.record(msg)
+	}
+}
+
+// handleSeal takes over a block to propose, or answers right away if its
+// height has been committed.
+func (b *BFT) handleSeal(req *sealRequest) {
+	s := b.state
+	number := req.block.NumberU64()
+	switch {
+	case number < s.height:
+		req.result <- nil
+		return
+	case number == s.height && s.decided != nil:
+		req.result <- s.decided
+		return
+	}
+	if b.pending != nil {
+		b.pending.result <- nil
+	}
+	b.pending = req
+	if number == s.height && b.validators.Proposer(s.height, s.round) == b.address() {
+		b.propose()
+		b.process()
+	}
+}
+
+// handleMessage verifies an inbound message and feeds it to the state of its
+// height.
+func (b *BFT) handleMessage(msg *Message) {
+	if err := msg.recover(b.validators); err != nil {
+		log.Debug("Discarding invalid consensus message", "code", msg.Code, "err", err)
+		return
+	}
+	s := b.state
+	switch {
+	case msg.Height < s.height:
+		b.answerLagging(msg.Height)
+		return
+	case msg.Height > s.height:
+		if msg.Height <= s.height+backlogHeights {
+			b.backlog[msg.Height] = append(b.backlog[msg.Height], msg)
+		}
+		return
+	case s.decided != nil:
+		return
+	}
+	if msg.Code == MsgCommit {
+		b.handleCommit(msg)
+		return
+	}
+	b.record(msg)
+
+	// Catch up with a round a correct validator has reached
+	if msg.Round > s.round && len(s.senders[msg.Round]) > b.validators.F() {
+		b.startRound(msg.Round)
+		return
+	}
+	b.process()
+}
+
+// record adds a message of the current height to the round state.
+func (b *BFT) record(msg *Message) {
+	s := b.state
+	switch msg.Code {
+	case MsgProposal:
+		if msg.from != b.validators.Proposer(s.height, msg.Round) || s.proposals[msg.Round] != nil {
+			return
+		}
+		s.proposals[msg.Round] = msg
+	case MsgPrevote:
+		if !s.prevotesOf(msg.Round).add(msg) {
+			return
+		}
+	case MsgPrecommit:
+		if !s.precommitsOf(msg.Round).add(msg) {
+			return
+		}
+	}
+	if s.senders[msg.Round] == nil {
+		s.senders[msg.Round] = make(map[common.Address]struct{})
+	}
+	s.senders[msg.Round][msg.from] = struct{}{}
+}
+
+// handleCommit commits the block of a commit message from a validator that has
+// already decided the current height.
+func (b *BFT) handleCommit(msg *Message) {
+	if err := msg.Commit.verify(b.validators, msg.Height, msg.Hash); err != nil {
+		log.Debug("Discarding invalid commit", "height", msg.Height, "err", err)
+		return
+	}
+	if err := b.validate(msg.Block); err != nil {
+		log.Debug("Discarding invalid committed block", "height", msg.Height, "err", err)
+		return
+	}
+	b.commit(msg.Block, msg.Commit)
+}
+
+// answerLagging sends the commit of an already decided height to a validator
+// still working on it.
+func (b *BFT) answerLagging(height uint64) {
+	timeout := time.Duration(b.config.Timeout) * time.Millisecond
+	if last, ok := b.answered[height]; ok && time.Since(last) < timeout {
+		return
+	}
+	header := b.chain.GetHeaderByNumber(height)
+	if header == nil {
+		return
+	}
+	block := b.chain.GetBlock(header.Hash(), height)
+	commit := ReadCommit(b.db, header.Hash(), height)
+	if block == nil || commit == nil {
+		return
+	}
+	b.answered[height] = time.Now()
+	b.broadcast(&Message{
+		Code:       MsgCommit,
+		Height:     height,
+		Round:      commit.Round,
+		Hash:       block.Hash(),
+		ValidRound: -1,
+		Block:      block,
+		Commit:     commit,
+	})
+}
+
+// validate checks a proposed block against the local chain, caching the result.
+func (b *BFT) validate(block *types.Block) error {
+	s := b.state
+	if err, ok := s.valid[block.Hash()]; ok {
+		return err
+	}
+	err := func() error {
+		header := block.Header()
+		if header.Number.Uint64() != s.height {
+			return errInvalidMessage
+		}
+		parent := b.chain.GetHeader(header.ParentHash, s.height-1)
+		if parent == nil {
+			return errUnknownBlock
+		}
+		if err := b.VerifyHeader(b.chain, header, parent); err != nil {
+			return err
+		}
+		if header.Time.Cmp(parent.Time) <= 0 {
+			return errInvalidTimestamp
+		}
+		if types.DeriveSha(block.Transactions()) != header.TxHash {
+			return errInvalidMessage
+		}
+		return b.verifyProposer(header)
+	}()
+	s.valid[block.Hash()] = err
+	return err
+}
+
+// process applies the round rules until the state stops changing.
+func (b *BFT) process() {
+	for b.state.decided == nil && b.processOnce() {
+	}
+}
+
+// processOnce applies the first round rule whose conditions hold and reports
+// whether the state changed.
+func (b *BFT) processOnce() bool {
+	s := b.state
+	quorum := b.validators.Quorum()
+
+	// A precommit quorum for a proposal of any round decides the height
+	for round, prop := range s.proposals {
+		precommits := s.precommits[round]
+		if precommits != nil && precommits.count[prop.Hash] >= quorum && b.validate(prop.Block) == nil {
+			b.commit(prop.Block, &Commit{Round: round, Signatures: precommits.signatures(prop.Hash)})
+			return false
+		}
+	}
+	prop := s.proposals[s.round]
+	prevotes := s.prevotesOf(s.round)
+
+	// Prevote on the proposal of the round, unless locked on another block
+	if s.step == stepPropose && prop != nil {
+		vr := prop.ValidRound
+		switch {
+		case vr < 0:
+			vote := common.Hash{}
+			if b.validate(prop.Block) == nil && (s.lockedRound < 0 || s.lockedBlock.Hash() == prop.Hash) {
+				vote = prop.Hash
+			}
+			b.enterPrevote(vote)
+			return true
+
+		case vr < int64(s.round) && s.prevotesOf(uint64(vr)).count[prop.Hash] >= quorum:
+			vote := common.Hash{}
+			if b.validate(prop.Block) == nil && (s.lockedRound <= vr || s.lockedBlock.Hash() == prop.Hash) {
+				vote = prop.Hash
+			}
+			b.enterPrevote(vote)
+			return true
+		}
+	}
+	// A prevote quorum for the proposal locks it and precommits it
+	if prop != nil && s.step >= stepPrevote && !s.polka[s.round] && prevotes.count[prop.Hash] >= quorum && b.validate(prop.Block) == nil {
+		s.polka[s.round] = true
+		if s.step == stepPrevote {
+			s.lockedRound, s.lockedBlock = int64(s.round), prop.Block
+			b.enterPrecommit(prop.Hash)
+		}
+		s.validRound, s.validBlock = int64(s.round), prop.Block
+		return true
+	}
+	// A prevote quorum for no block precommits no block
+	if s.step == stepPrevote && prevotes.count[common.Hash{}] >= quorum {
+		b.enterPrecommit(common.Hash{})
+		return true
+	}
+	return false
+}
+
+func (b *BFT) enterPrevote(hash common.Hash) {
+	b.state.step = stepPrevote
+	b.vote(MsgPrevote, hash)
+	b.schedule(stepPrevote)
+}
+
+func (b *BFT) enterPrecommit(hash common.Hash) {
+	b.state.step = stepPrecommit
+	b.vote(MsgPrecommit, hash)
+	b.schedule(stepPrecommit)
+}
+
+// handleTimeout moves a round on whose step timed out.
+func (b *BFT) handleTimeout(ev timeoutEvent) {
+	s := b.state
+	if ev.height != s.height {
+		return
+	}
+	if ev.step == stepCommitted {
+		// Move on if the chain head has been updated without a notification
+		if head := b.chain.CurrentHeader(); head.Number.Uint64() >= s.height {
+			b.startHeight(head.Number.Uint64() + 1)
+		} else {
+			b.schedule(stepCommitted)
+		}
+		return
+	}
+	if ev.round != s.round || ev.step != s.step {
+		return
+	}
+	switch ev.step {
+	case stepPropose:
+		b.enterPrevote(common.Hash{})
+	case stepPrevote:
+		b.enterPrecommit(common.Hash{})
+	case stepPrecommit:
+		b.startRound(s.round + 1)
+		return
+	}
+	b.process()
+}
+
+// commit stores the commit signatures of the decided block and hands the block
+// over to the pending seal request.
+func (b *BFT) commit(block *types.Block, commit *Commit) {
+	s := b.state
+	if err := WriteCommit(b.db, block.Hash(), block.NumberU64(), commit); err != nil {
+		log.Error("Failed to store block commit", "number", block.NumberU64(), "err", err)
+		return
+	}
+	log.Debug("Committed block", "number", block.NumberU64(), "hash", block.Hash(), "round", commit.Round)
+
+	s.step, s.decided = stepCommitted, block
+	if b.pending != nil && b.pending.block.NumberU64() == s.height {
+		b.pending.result <- block
+		b.pending = nil
+	}
+	// Let the validators still deciding the height catch up
+	b.answered[s.height] = time.Now()
+	b.broadcast(&Message{
+		Code:       MsgCommit,
+		Height:     s.height,
+		Round:      commit.Round,
+		Hash:       block.Hash(),
+		ValidRound: -1,
+		Block:      block,
+		Commit:     commit,
+	})
+	b.schedule(stepCommitted)
+}
@@ -0,0 +1,73 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"github.com/zipper-project/z0/common"
+)
+
+// ValidatorSet is the fixed set of validators proposing and voting on blocks.
+type ValidatorSet struct {
+	list  []common.Address
+	index map[common.Address]int
+}
+
+// NewValidatorSet creates a validator set, the proposer rotation follows the
+// order of the given addresses.
+func NewValidatorSet(validators []common.Address) *ValidatorSet {
+	set := &ValidatorSet{
+		list:  make([]common.Address, 0, len(validators)),
+		index: make(map[common.Address]int),
+	}
+	for _, v := range validators {
+		if _, ok := set.index[v]; ok {
+			continue
+		}
+		set.index[v] = len(set.list)
+		set.list = append(set.list, v)
+	}
+	return set
+}
+
+// Size returns the number of validators.
+func (s *ValidatorSet) Size() int { return len(s.list) }
+
+// List returns the validators in proposer order.
+func (s *ValidatorSet) List() []common.Address {
+	return append([]common.Address(nil), s.list...)
+}
+
+// Contains reports whether the address is a validator.
+func (s *ValidatorSet) Contains(addr common.Address) bool {
+	_, ok := s.index[addr]
+	return ok
+}
+
+// F returns the number of faulty validators the set tolerates.
+func (s *ValidatorSet) F() int { return (len(s.list) - 1) / 3 }
+
+// Quorum returns the number of votes needed for a decision, which is more
+// than two thirds of the validators.
+func (s *ValidatorSet) Quorum() int { return 2*len(s.list)/3 + 1 }
+
+// Proposer returns the validator proposing at the given height and round.
+func (s *ValidatorSet) Proposer(height, round uint64) common.Address {
+	if len(s.list) == 0 {
+		return common.Address{}
+	}
+	return s.list[(height+round)%uint64(len(s.list))]
+}
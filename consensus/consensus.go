@@ -19,16 +19,72 @@ package consensus
 import (
 	"math/big"
 
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/params"
 	"github.com/zipper-project/z0/types"
 )
 
 // ChainReader defines a small collection of methods needed to access the local
 // blockchain during header and/or uncle verification.
 type ChainReader interface {
+	// Config retrieves the blockchain's chain configuration.
+	Config() *params.ChainConfig
+
+	// CurrentHeader retrieves the current header from the local chain.
+	CurrentHeader() *types.Header
+
+	// GetHeader retrieves a block header from the database by hash and number.
+	GetHeader(hash common.Hash, number uint64) *types.Header
+
+	// GetHeaderByNumber retrieves a block header from the database by number.
+	GetHeaderByNumber(number uint64) *types.Header
+
+	// GetBlock retrieves a block from the database by hash and number.
+	GetBlock(hash common.Hash, number uint64) *types.Block
 }
 
 // Engine is an algorithm agnostic consensus engine.
 type Engine interface {
-	CalcDifficulty(chain ChainReader, time uint64, parent *types.Header) *big.Int
+	// Author retrieves the address of the account that sealed the given block.
+	Author(header *types.Header) (common.Address, error)
+
+	// VerifyHeader checks the engine specific fields of a header. The generic
+	// fields are checked by the block validator, and the parent is passed in
+	// because it may not be reachable through the chain yet.
+	VerifyHeader(chain ChainReader, header, parent *types.Header) error
+
+	// VerifySeal checks whether the signature or proof securing a header is
+	// valid according to the consensus rules of the given engine.
 	VerifySeal(chain ChainReader, header *types.Header) error
+
+	// Prepare initializes the consensus fields of a block header according to
+	// the rules of a particular engine.
+	Prepare(chain ChainReader, header *types.Header) error
+
+	// Seal generates a new block for the given input block with the local
+	// miner's seal place on top. It blocks until the seal is available or stop
+	// is closed, in which case a nil block is returned.
+	Seal(chain ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error)
+
+	// CalcDifficulty is the difficulty adjustment algorithm. It returns the
+	// difficulty that a new block should have.
+	CalcDifficulty(chain ChainReader, time uint64, parent *types.Header) *big.Int
+}
+
+// SignerFn is a signer callback function to request a hash to be signed by a
+// backing account.
+type SignerFn func(signer common.Address, hash []byte) ([]byte, error)
+
+// Authorizer is implemented by consensus engines sealing blocks with a local
+// signing key.
+type Authorizer interface {
+	// Authorize injects the key to seal new blocks with.
+	Authorize(signer common.Address, signFn SignerFn)
+}
+
+// Finality is implemented by consensus engines with deterministic finality.
+// Blocks reported as finalized are irreversible and never reorganized.
+type Finality interface {
+	// Finalized reports whether the given block has been finalized.
+	Finalized(chain ChainReader, header *types.Header) bool
 }
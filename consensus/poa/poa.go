@@ -0,0 +1,601 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package poa implements the proof-of-authority consensus engine.
+package poa
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/hashicorp/golang-lru"
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/consensus"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/params"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/rlp"
+	"github.com/zipper-project/z0/utils/zdb"
+)
+
+const (
+	checkpointInterval = 1024 // Number of blocks after which to save the vote snapshot to the database
+	inmemorySnapshots  = 128  // Number of recent vote snapshots to keep in memory
+	inmemorySignatures = 4096 // Number of recent block signatures to keep in memory
+
+	wiggleTime = 500 * time.Millisecond // Random delay (per signer) to allow concurrent signers
+
+	snapshotPrefix = "poa-" // snapshotPrefix + hash -> vote snapshot
+)
+
+// Proof-of-authority protocol constants.
+var (
+	epochLength = uint64(30000) // Default number of blocks after which to checkpoint and reset the pending votes
+
+	extraVanity = 32 // Fixed number of extra-data prefix bytes reserved for signer vanity
+	extraSeal   = 65 // Fixed number of extra-data suffix bytes reserved for signer seal
+
+	nonceAuthVote = types.BlockNonce{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff} // Magic nonce number to vote on adding a new signer
+	nonceDropVote = types.BlockNonce{}                                               // Magic nonce number to vote on removing a signer.
+
+	diffInTurn = big.NewInt(2) // Block difficulty for in-turn signatures
+	diffNoTurn = big.NewInt(1) // Block difficulty for out-of-turn signatures
+)
+
+// Various error messages to mark blocks invalid. These should be private to
+// prevent engine specific errors from being referenced in the remainder of the
+// codebase, inherently breaking if the engine is swapped out. Please put common
+// error types into the consensus package.
+var (
+	// errUnknownBlock is returned when the list of signers is requested for a block
+	// that is not part of the local blockchain.
+	errUnknownBlock = errors.New("unknown block")
+
+	// errInvalidCheckpointVote is returned if a checkpoint block carries a vote.
+	errInvalidCheckpointVote = errors.New("vote in checkpoint block")
+
+	// errInvalidVote is returned if a nonce value is something else that the two
+	// allowed constants of 0x00..0 or 0xff..f.
+	errInvalidVote = errors.New("vote nonce not 0x00..0 or 0xff..f")
+
+	// errMissingVanity is returned if a block's extra-data section is shorter than
+	// 32 bytes, which is required to store the signer vanity.
+	errMissingVanity = errors.New("extra-data 32 byte vanity prefix missing")
+
+	// errMissingSignature is returned if a block's extra-data section doesn't seem
+	// to contain a 65 byte secp256k1 signature.
+	errMissingSignature = errors.New("extra-data 65 byte signature suffix missing")
+
+	// errExtraSigners is returned if non-checkpoint block contain signer data in
+	// their extra-data fields.
+	errExtraSigners = errors.New("non-checkpoint block contains extra signer list")
+
+	// errInvalidCheckpointSigners is returned if a checkpoint block contains an
+	// invalid list of signers (i.e. non divisible by 20 bytes).
+	errInvalidCheckpointSigners = errors.New("invalid signer list on checkpoint block")
+
+	// errMismatchingCheckpointSigners is returned if a checkpoint block contains a
+	// list of signers different than the one the local node calculated.
+	errMismatchingCheckpointSigners = errors.New("mismatching signer list on checkpoint block")
+
+	// errInvalidDifficulty is returned if the difficulty of a block is neither 1 or 2.
+	errInvalidDifficulty = errors.New("invalid difficulty")
+
+	// errWrongDifficulty is returned if the difficulty of a block doesn't match the
+	// turn of the signer.
+	errWrongDifficulty = errors.New("wrong difficulty")
+
+	// errInvalidTimestamp is returned if the timestamp of a block is lower than
+	// the previous block's timestamp + the minimum block period.
+	errInvalidTimestamp = errors.New("invalid timestamp")
+
+	// errInvalidVotingChain is returned if an authorization list is attempted to
+	// be modified via out-of-range or non-contiguous headers.
+	errInvalidVotingChain = errors.New("invalid voting chain")
+
+	// errUnauthorizedSigner is returned if a header is signed by a non-authorized entity.
+	errUnauthorizedSigner = errors.New("unauthorized signer")
+
+	// errRecentlySigned is returned if a header is signed by an authorized entity
+	// that already signed a header recently, thus is temporarily not allowed to.
+	errRecentlySigned = errors.New("recently signed")
+
+	// errWaitTransactions is returned if an empty block is attempted to be sealed
+	// on an instant chain (0 second period).
+	errWaitTransactions = errors.New("waiting for transactions")
+)
+
+// sealHash returns the hash of a block prior to it being sealed.
+func sealHash(header *types.Header) (hash common.Hash) {
+	enc, _ := rlp.EncodeToBytes([]interface{}{
+		header.ParentHash,
+		header.Coinbase,
+		header.Root,
+		header.TxHash,
+		header.ReceiptHash,
+		header.Bloom,
+		header.Difficulty,
+		header.Number,
+		header.GasLimit,
+		header.GasUsed,
+		header.Time,
+		header.Extra[:len(header.Extra)-extraSeal], // Yes, this will panic if extra is too short
+		header.MixDigest,
+		header.Nonce,
+		header.BaseFee,
+	})
+	return crypto.Keccak256Hash(enc)
+}
+
+// ecrecover extracts the account address from a signed header.
+func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, error) {
+	// If the signature's already cached, return that
+	hash := header.Hash()
+	if address, known := sigcache.Get(hash); known {
+		return address.(common.Address), nil
+	}
+	// Retrieve the signature from the header extra-data
+	if len(header.Extra) < extraSeal {
+		return common.Address{}, errMissingSignature
+	}
+	signature := header.Extra[len(header.Extra)-extraSeal:]
+
+	// Recover the public key and the account address
+	pubkey, err := crypto.Ecrecover(sealHash(header).Bytes(), signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var signer common.Address
+	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+
+	sigcache.Add(hash, signer)
+	return signer, nil
+}
+
+// extraSigners returns the signer list embedded in a checkpoint header.
+func extraSigners(header *types.Header) ([]common.Address, error) {
+	if len(header.Extra) < extraVanity+extraSeal {
+		return nil, errInvalidCheckpointSigners
+	}
+	list := header.Extra[extraVanity : len(header.Extra)-extraSeal]
+	if len(list) == 0 || len(list)%common.AddressLength != 0 {
+		return nil, errInvalidCheckpointSigners
+	}
+	signers := make([]common.Address, len(list)/common.AddressLength)
+	for i := 0; i < len(signers); i++ {
+		copy(signers[i][:], list[i*common.AddressLength:])
+	}
+	return signers, nil
+}
+
+// GenesisExtra builds the extra-data of a genesis block authorizing the given
+// signers.
+func GenesisExtra(vanity []byte, signers []common.Address) []byte {
+	extra := make([]byte, extraVanity, extraVanity+len(signers)*common.AddressLength+extraSeal)
+	copy(extra, vanity)
+	for _, signer := range signers {
+		extra = append(extra, signer[:]...)
+	}
+	return append(extra, make([]byte, extraSeal)...)
+}
+
+// PoA is the proof-of-authority consensus engine.
+type PoA struct {
+	config *params.PoAConfig // Consensus engine configuration parameters
+	db     zdb.Database      // Database to store and retrieve snapshot checkpoints
+
+	recents    *lru.ARCCache // Snapshots for recent block to speed up reorgs
+	signatures *lru.ARCCache // Signatures of recent blocks to speed up mining
+
+	proposals map[common.Address]bool // Current list of proposals we are pushing
+
+	signer common.Address     // Address of the signing key
+	signFn consensus.SignerFn // Signer function to authorize hashes with
+	lock   sync.RWMutex       // Protects the signer and proposals fields
+}
+
+// New creates a proof-of-authority consensus engine with the initial signers
+// set to the ones provided by the genesis block.
+func New(config *params.PoAConfig, db zdb.Database) *PoA {
+	// Set any missing consensus parameters to their defaults
+	conf := *config
+	if conf.Epoch == 0 {
+		conf.Epoch = epochLength
+	}
+	recents, _ := lru.NewARC(inmemorySnapshots)
+	signatures, _ := lru.NewARC(inmemorySignatures)
+
+	return &PoA{
+		config:     &conf,
+		db:         db,
+		recents:    recents,
+		signatures: signatures,
+		proposals:  make(map[common.Address]bool),
+	}
+}
+
+// Author implements consensus.Engine, returning the address recovered from the
+// signature in the header's extra-data section.
+func (p *PoA) Author(header *types.Header) (common.Address, error) {
+	return ecrecover(header, p.signatures)
+}
+
+// VerifyHeader implements consensus.Engine, checking the extra-data layout, the
+// vote fields, the difficulty range and the block period of a header.
+func (p *PoA) VerifyHeader(chain consensus.ChainReader, header, parent *types.Header) error {
+	if header.Number == nil {
+		return errUnknownBlock
+	}
+	number := header.Number.Uint64()
+
+	// Checkpoint blocks need to enforce zero votes
+	checkpoint := (number % p.config.Epoch) == 0
+	if checkpoint && header.MixDigest != (common.Hash{}) {
+		return errInvalidCheckpointVote
+	}
+	// Nonces must be 0x00..0 or 0xff..f, zeroes enforced on checkpoints
+	if !bytes.Equal(header.Nonce[:], nonceAuthVote[:]) && !bytes.Equal(header.Nonce[:], nonceDropVote[:]) {
+		return errInvalidVote
+	}
+	if checkpoint && !bytes.Equal(header.Nonce[:], nonceDropVote[:]) {
+		return errInvalidCheckpointVote
+	}
+	// Check that the extra-data contains both the vanity and signature
+	if len(header.Extra) < extraVanity {
+		return errMissingVanity
+	}
+	if len(header.Extra) < extraVanity+extraSeal {
+		return errMissingSignature
+	}
+	// Ensure that the extra-data contains a signer list on checkpoint, but none otherwise
+	signersBytes := len(header.Extra) - extraVanity - extraSeal
+	if !checkpoint && signersBytes != 0 {
+		return errExtraSigners
+	}
+	if checkpoint && signersBytes%common.AddressLength != 0 {
+		return errInvalidCheckpointSigners
+	}
+	// Ensure that the block's difficulty is meaningful (may not be correct at this point)
+	if number > 0 && (header.Difficulty == nil || (header.Difficulty.Cmp(diffInTurn) != 0 && header.Difficulty.Cmp(diffNoTurn) != 0)) {
+		return errInvalidDifficulty
+	}
+	// Ensure that the block period is respected
+	if parent.Time.Uint64()+p.config.Period > header.Time.Uint64() {
+		return errInvalidTimestamp
+	}
+	// If the block is a checkpoint block, verify the signer list
+	if !checkpoint {
+		return nil
+	}
+	snap, err := p.snapshot(chain, number-1, header.ParentHash)
+	if err != nil {
+		return err
+	}
+	signers := make([]byte, 0, len(snap.Signers)*common.AddressLength)
+	for _, signer := range snap.signers() {
+		signers = append(signers, signer[:]...)
+	}
+	if !bytes.Equal(header.Extra[extraVanity:len(header.Extra)-extraSeal], signers) {
+		return errMismatchingCheckpointSigners
+	}
+	return nil
+}
+
+// VerifySeal implements consensus.Engine, checking whether the signature
+// contained in the header satisfies the consensus protocol requirements.
+func (p *PoA) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
+	// Verifying the genesis block is not supported
+	number := header.Number.Uint64()
+	if number == 0 {
+		return errUnknownBlock
+	}
+	// Retrieve the snapshot needed to verify this header and cache it
+	snap, err := p.snapshot(chain, number-1, header.ParentHash)
+	if err != nil {
+		return err
+	}
+	// Resolve the authorization key and check against signers
+	signer, err := ecrecover(header, p.signatures)
+	if err != nil {
+		return err
+	}
+	if _, ok := snap.Signers[signer]; !ok {
+		return errUnauthorizedSigner
+	}
+	for seen, recent := range snap.Recents {
+		if recent == signer {
+			// Signer is among recents, only fail if the current block doesn't shift it out
+			if limit := uint64(len(snap.Signers)/2 + 1); seen > number-limit {
+				return errRecentlySigned
+			}
+		}
+	}
+	// Ensure that the difficulty corresponds to the turn-ness of the signer
+	inturn := snap.inturn(number, signer)
+	if inturn && header.Difficulty.Cmp(diffInTurn) != 0 {
+		return errWrongDifficulty
+	}
+	if !inturn && header.Difficulty.Cmp(diffNoTurn) != 0 {
+		return errWrongDifficulty
+	}
+	return nil
+}
+
+// Prepare implements consensus.Engine, preparing all the consensus fields of the
+// header for running the transactions on top.
+func (p *PoA) Prepare(chain consensus.ChainReader, header *types.Header) error {
+	// Reset the vote fields, a vote is only cast below on non-checkpoint blocks
+	header.MixDigest = common.Hash{}
+	header.Nonce = types.BlockNonce{}
+
+	number := header.Number.Uint64()
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return errUnknownBlock
+	}
+	// Assemble the voting snapshot to check which votes make sense
+	snap, err := p.snapshot(chain, number-1, header.ParentHash)
+	if err != nil {
+		return err
+	}
+	p.lock.RLock()
+	if number%p.config.Epoch != 0 {
+		// If the block isn't a checkpoint, cast a random vote (good enough for now),
+		// picking among the proposals that make sense voting on
+		addresses := make([]common.Address, 0, len(p.proposals))
+		for address, authorize := range p.proposals {
+			if snap.validVote(address, authorize) {
+				addresses = append(addresses, address)
+			}
+		}
+		// If there's pending proposals, cast a vote on them
+		if len(addresses) > 0 {
+			candidate := addresses[rand.Intn(len(addresses))]
+			header.MixDigest = common.BytesToHash(candidate[:])
+			if p.proposals[candidate] {
+				copy(header.Nonce[:], nonceAuthVote[:])
+			} else {
+				copy(header.Nonce[:], nonceDropVote[:])
+			}
+		}
+	}
+	// Set the correct difficulty
+	header.Difficulty = calcDifficulty(snap, p.signer)
+	p.lock.RUnlock()
+
+	// Ensure the extra data has all its components
+	if len(header.Extra) < extraVanity {
+		header.Extra = append(header.Extra, bytes.Repeat([]byte{0x00}, extraVanity-len(header.Extra))...)
+	}
+	header.Extra = header.Extra[:extraVanity]
+
+	if number%p.config.Epoch == 0 {
+		for _, signer := range snap.signers() {
+			header.Extra = append(header.Extra, signer[:]...)
+		}
+	}
+	header.Extra = append(header.Extra, make([]byte, extraSeal)...)
+
+	// Ensure the timestamp respects the block period
+	header.Time = new(big.Int).Add(parent.Time, new(big.Int).SetUint64(p.config.Period))
+	if header.Time.Cmp(parent.Time) <= 0 {
+		header.Time.Add(parent.Time, common.Big1)
+	}
+	if now := big.NewInt(time.Now().Unix()); header.Time.Cmp(now) < 0 {
+		header.Time = now
+	}
+	return nil
+}
+
+// Authorize injects a private key into the consensus engine to mint new blocks
+// with.
+func (p *PoA) Authorize(signer common.Address, signFn consensus.SignerFn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.signer = signer
+	p.signFn = signFn
+}
+
+// Propose injects a new authorization proposal that the local signer will
+// attempt to push through.
+func (p *PoA) Propose(address common.Address, authorize bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.proposals[address] = authorize
+}
+
+// Discard drops a currently running proposal, stopping the signer from casting
+// further votes (either for or against).
+func (p *PoA) Discard(address common.Address) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.proposals, address)
+}
+
+// Proposals returns the current proposals the local signer is voting on.
+func (p *PoA) Proposals() map[common.Address]bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	proposals := make(map[common.Address]bool)
+	for address, auth := range p.proposals {
+		proposals[address] = auth
+	}
+	return proposals
+}
+
+// Signers retrieves the list of authorized signers at the given block.
+func (p *PoA) Signers(chain consensus.ChainReader, header *types.Header) ([]common.Address, error) {
+	snap, err := p.snapshot(chain, header.Number.Uint64(), header.Hash())
+	if err != nil {
+		return nil, err
+	}
+	return snap.signers(), nil
+}
+
+// Seal implements consensus.Engine, attempting to create a sealed block using
+// the local signing credentials.
+func (p *PoA) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	header := block.Header()
+
+	// Sealing the genesis block is not supported
+	number := header.Number.Uint64()
+	if number == 0 {
+		return nil, errUnknownBlock
+	}
+	// For 0-period chains, refuse to seal empty blocks (no reward but would spin sealing)
+	if p.config.Period == 0 && len(block.Transactions()) == 0 {
+		return nil, errWaitTransactions
+	}
+	// Don't hold the signer fields for the entire sealing procedure
+	p.lock.RLock()
+	signer, signFn := p.signer, p.signFn
+	p.lock.RUnlock()
+
+	// Bail out if we're unauthorized to sign a block
+	snap, err := p.snapshot(chain, number-1, header.ParentHash)
+	if err != nil {
+		return nil, err
+	}
+	if _, authorized := snap.Signers[signer]; !authorized {
+		return nil, errUnauthorizedSigner
+	}
+	// If we're amongst the recent signers, wait for the next block
+	for seen, recent := range snap.Recents {
+		if recent == signer {
+			// Signer is among recents, only wait if the current block doesn't shift it out
+			if limit := uint64(len(snap.Signers)/2 + 1); number < limit || seen > number-limit {
+				return nil, errRecentlySigned
+			}
+		}
+	}
+	// Sweet, the protocol permits us to sign the block, wait for our time
+	delay := time.Unix(header.Time.Int64(), 0).Sub(time.Now())
+	if header.Difficulty.Cmp(diffNoTurn) == 0 {
+		// It's not our turn explicitly to sign, delay it a bit
+		wiggle := time.Duration(len(snap.Signers)/2+1) * wiggleTime
+		delay += time.Duration(rand.Int63n(int64(wiggle)))
+
+		log.Trace("Out-of-turn signing requested", "wiggle", common.PrettyDuration(wiggle))
+	}
+	log.Trace("Waiting for slot to sign and propagate", "delay", common.PrettyDuration(delay))
+
+	select {
+	case <-stop:
+		return nil, nil
+	case <-time.After(delay):
+	}
+	// Sign all the things!
+	sighash, err := signFn(signer, sealHash(header).Bytes())
+	if err != nil {
+		return nil, err
+	}
+	copy(header.Extra[len(header.Extra)-extraSeal:], sighash)
+
+	return block.WithSeal(header), nil
+}
+
+// CalcDifficulty implements consensus.Engine, returning the difficulty a block
+// sealed by the local signer on top of parent should have.
+func (p *PoA) CalcDifficulty(chain consensus.ChainReader, time uint64, parent *types.Header) *big.Int {
+	snap, err := p.snapshot(chain, parent.Number.Uint64(), parent.Hash())
+	if err != nil {
+		return nil
+	}
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return calcDifficulty(snap, p.signer)
+}
+
+// calcDifficulty is the difficulty adjustment algorithm. It returns the difficulty
+// that a new block should have based on the previous blocks in the chain and the
+// current signer.
+func calcDifficulty(snap *Snapshot, signer common.Address) *big.Int {
+	if snap.inturn(snap.Number+1, signer) {
+		return new(big.Int).Set(diffInTurn)
+	}
+	return new(big.Int).Set(diffNoTurn)
+}
+
+// snapshot retrieves the authorization snapshot at a given point in time.
+func (p *PoA) snapshot(chain consensus.ChainReader, number uint64, hash common.Hash) (*Snapshot, error) {
+	// Search for a snapshot in memory or on disk for checkpoints
+	var (
+		headers []*types.Header
+		snap    *Snapshot
+	)
+	for snap == nil {
+		// If an in-memory snapshot was found, use that
+		if s, ok := p.recents.Get(hash); ok {
+			snap = s.(*Snapshot)
+			break
+		}
+		// If an on-disk checkpoint snapshot can be found, use that
+		if number%checkpointInterval == 0 {
+			if s, err := loadSnapshot(p.config, p.signatures, p.db, hash); err == nil {
+				log.Trace("Loaded voting snapshot from disk", "number", number, "hash", hash)
+				snap = s
+				break
+			}
+		}
+		header := chain.GetHeader(hash, number)
+		if header == nil {
+			return nil, errUnknownBlock
+		}
+		// The genesis and epoch blocks carry the full signer list, start from there
+		if number%p.config.Epoch == 0 {
+			signers, err := extraSigners(header)
+			if err != nil {
+				return nil, err
+			}
+			snap = newSnapshot(p.config, p.signatures, number, hash, signers)
+			if err := snap.store(p.db); err != nil {
+				return nil, err
+			}
+			log.Trace("Stored checkpoint snapshot to disk", "number", number, "hash", hash)
+			break
+		}
+		headers = append(headers, header)
+		number, hash = number-1, header.ParentHash
+	}
+	// Previous snapshot found, apply any pending headers on top of it
+	for i := 0; i < len(headers)/2; i++ {
+		headers[i], headers[len(headers)-1-i] = headers[len(headers)-1-i], headers[i]
+	}
+	snap, err := snap.apply(headers)
+	if err != nil {
+		return nil, err
+	}
+	p.recents.Add(snap.Hash, snap)
+
+	// If we've generated a new checkpoint snapshot, save to disk
+	if snap.Number%checkpointInterval == 0 && len(headers) > 0 {
+		if err = snap.store(p.db); err != nil {
+			return nil, err
+		}
+		log.Trace("Stored voting snapshot to disk", "number", snap.Number, "hash", snap.Hash)
+	}
+	return snap, nil
+}
@@ -0,0 +1,314 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package poa
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core"
+	"github.com/zipper-project/z0/core/vm"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/params"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/zdb"
+)
+
+// testSigner is an authorized key of the test chain.
+type testSigner struct {
+	key  *ecdsa.PrivateKey
+	addr common.Address
+}
+
+func (s *testSigner) sign(addr common.Address, hash []byte) ([]byte, error) {
+	return crypto.Sign(hash, s.key)
+}
+
+// testChain is a proof-of-authority chain backed by an in-memory database.
+type testChain struct {
+	t       *testing.T
+	engine  *PoA
+	chain   *core.BlockChain
+	signers []*testSigner
+}
+
+func newTestChain(t *testing.T, n int, epoch uint64) *testChain {
+	signers := make([]*testSigner, n)
+	for i := range signers {
+		key, _ := crypto.GenerateKey()
+		signers[i] = &testSigner{key: key, addr: crypto.PubkeyToAddress(key.PublicKey)}
+	}
+	sort.Slice(signers, func(i, j int) bool {
+		return bytes.Compare(signers[i].addr[:], signers[j].addr[:]) < 0
+	})
+	addrs := make([]common.Address, n)
+	for i, s := range signers {
+		addrs[i] = s.addr
+	}
+	config := &params.ChainConfig{ChainID: big.NewInt(1), PoA: &params.PoAConfig{Period: 1, Epoch: epoch}}
+	genesis := &core.Genesis{
+		Config:     config,
+		ExtraData:  GenesisExtra([]byte("poa test"), addrs),
+		GasLimit:   params.MinGasLimit * 100,
+		Difficulty: big.NewInt(1),
+	}
+	db := zdb.NewMemDatabase()
+	if _, err := genesis.Commit(db); err != nil {
+		t.Fatalf("failed to commit genesis: %v", err)
+	}
+	engine := New(config.PoA, db)
+	chain, err := core.NewBlockChain(db, nil, config, engine, nil, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	return &testChain{t: t, engine: engine, chain: chain, signers: signers}
+}
+
+// block prepares and seals an empty block on top of the current head.
+func (tc *testChain) block(signer *testSigner, modify func(*types.Header)) *types.Block {
+	parent := tc.chain.CurrentBlock()
+	header := &types.Header{
+		ParentHash:  parent.Hash(),
+		Number:      new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:    parent.GasLimit(),
+		Root:        parent.Root(),
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.EmptyRootHash,
+	}
+	tc.engine.Authorize(signer.addr, signer.sign)
+	if err := tc.engine.Prepare(tc.chain, header); err != nil {
+		tc.t.Fatalf("failed to prepare header: %v", err)
+	}
+	// Keep the timestamps in the past so sealing doesn't wait for the slot
+	header.Time = new(big.Int).Add(parent.Time(), common.Big1)
+	if modify != nil {
+		modify(header)
+	}
+	sig, err := signer.sign(signer.addr, sealHash(header).Bytes())
+	if err != nil {
+		tc.t.Fatalf("failed to sign header: %v", err)
+	}
+	copy(header.Extra[len(header.Extra)-extraSeal:], sig)
+	return types.NewBlockWithHeader(header)
+}
+
+func (tc *testChain) insert(block *types.Block) error {
+	_, err := tc.chain.InsertChain(types.Blocks{block})
+	return err
+}
+
+func TestSealInTurn(t *testing.T) {
+	tc := newTestChain(t, 3, 30000)
+	defer tc.chain.Stop()
+
+	for i := 1; i <= 6; i++ {
+		signer := tc.signers[i%len(tc.signers)]
+		block := tc.block(signer, nil)
+		if block.Difficulty().Cmp(diffInTurn) != 0 {
+			t.Fatalf("block %d: difficulty mismatch: have %v, want %v", i, block.Difficulty(), diffInTurn)
+		}
+		if err := tc.insert(block); err != nil {
+			t.Fatalf("block %d: failed to insert: %v", i, err)
+		}
+		author, err := tc.engine.Author(block.Header())
+		if err != nil || author != signer.addr {
+			t.Fatalf("block %d: author mismatch: have %x (%v), want %x", i, author, err, signer.addr)
+		}
+	}
+	if head := tc.chain.CurrentBlock().NumberU64(); head != 6 {
+		t.Fatalf("head mismatch: have %d, want 6", head)
+	}
+}
+
+func TestSeal(t *testing.T) {
+	tc := newTestChain(t, 1, 30000)
+	defer tc.chain.Stop()
+
+	signer := tc.signers[0]
+	tc.engine.Authorize(signer.addr, signer.sign)
+
+	parent := tc.chain.CurrentBlock()
+	header := &types.Header{
+		ParentHash:  parent.Hash(),
+		Number:      big.NewInt(1),
+		GasLimit:    parent.GasLimit(),
+		Root:        parent.Root(),
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.EmptyRootHash,
+	}
+	if err := tc.engine.Prepare(tc.chain, header); err != nil {
+		t.Fatalf("failed to prepare header: %v", err)
+	}
+	block, err := tc.engine.Seal(tc.chain, types.NewBlockWithHeader(header), make(chan struct{}))
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	if err := tc.engine.VerifySeal(tc.chain, block.Header()); err != nil {
+		t.Fatalf("sealed block rejected: %v", err)
+	}
+	if err := tc.insert(block); err != nil {
+		t.Fatalf("failed to insert sealed block: %v", err)
+	}
+}
+
+func TestSealRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		signer func(tc *testChain) *testSigner
+		modify func(*types.Header)
+		err    error
+	}{
+		{
+			name:   "out of turn",
+			signer: func(tc *testChain) *testSigner { return tc.signers[0] },
+		},
+		{
+			name:   "wrong difficulty",
+			signer: func(tc *testChain) *testSigner { return tc.signers[0] },
+			modify: func(h *types.Header) { h.Difficulty = new(big.Int).Set(diffInTurn) },
+			err:    errWrongDifficulty,
+		},
+		{
+			name: "unauthorized",
+			signer: func(tc *testChain) *testSigner {
+				key, _ := crypto.GenerateKey()
+				return &testSigner{key: key, addr: crypto.PubkeyToAddress(key.PublicKey)}
+			},
+			err: errUnauthorizedSigner,
+		},
+		{
+			name:   "recently signed",
+			signer: func(tc *testChain) *testSigner { return tc.signers[1] },
+			err:    errRecentlySigned,
+		},
+		{
+			name:   "invalid difficulty",
+			signer: func(tc *testChain) *testSigner { return tc.signers[2] },
+			modify: func(h *types.Header) { h.Difficulty = big.NewInt(3) },
+			err:    errInvalidDifficulty,
+		},
+		{
+			name:   "signers outside checkpoint",
+			signer: func(tc *testChain) *testSigner { return tc.signers[2] },
+			modify: func(h *types.Header) {
+				h.Extra = append(h.Extra[:extraVanity], make([]byte, common.AddressLength+extraSeal)...)
+			},
+			err: errExtraSigners,
+		},
+		{
+			name:   "invalid vote nonce",
+			signer: func(tc *testChain) *testSigner { return tc.signers[2] },
+			modify: func(h *types.Header) { h.Nonce = types.EncodeNonce(1) },
+			err:    errInvalidVote,
+		},
+	}
+	for _, tt := range tests {
+		tc := newTestChain(t, 3, 30000)
+
+		// Block 1 is in-turn for signers[1]
+		if err := tc.insert(tc.block(tc.signers[1], nil)); err != nil {
+			t.Fatalf("%s: failed to insert block 1: %v", tt.name, err)
+		}
+		err := tc.insert(tc.block(tt.signer(tc), tt.modify))
+		if err != tt.err {
+			t.Errorf("%s: error mismatch: have %v, want %v", tt.name, err, tt.err)
+		}
+		tc.chain.Stop()
+	}
+}
+
+func TestVoting(t *testing.T) {
+	tc := newTestChain(t, 3, 30000)
+	defer tc.chain.Stop()
+
+	candidate := common.HexToAddress("0x0000000000000000000000000000000000000abc")
+	authorized := func() bool {
+		signers, err := tc.engine.Signers(tc.chain, tc.chain.CurrentHeader())
+		if err != nil {
+			t.Fatalf("failed to retrieve signers: %v", err)
+		}
+		for _, signer := range signers {
+			if signer == candidate {
+				return true
+			}
+		}
+		return false
+	}
+	// Two of three signers vote the candidate in
+	tc.engine.Propose(candidate, true)
+	for i, signer := range []*testSigner{tc.signers[1], tc.signers[2]} {
+		block := tc.block(signer, nil)
+		if block.MixDigest() != common.BytesToHash(candidate[:]) || block.Header().Nonce != nonceAuthVote {
+			t.Fatalf("block %d: vote not cast", i+1)
+		}
+		if err := tc.insert(block); err != nil {
+			t.Fatalf("block %d: failed to insert: %v", i+1, err)
+		}
+	}
+	if !authorized() {
+		t.Fatalf("candidate %x not authorized", candidate)
+	}
+	// Voting the candidate back out takes three of the four signers
+	tc.engine.Propose(candidate, false)
+	for i, signer := range tc.signers {
+		if !authorized() {
+			t.Fatalf("candidate dropped after %d votes", i)
+		}
+		if err := tc.insert(tc.block(signer, nil)); err != nil {
+			t.Fatalf("block %d: failed to insert: %v", i+3, err)
+		}
+	}
+	if authorized() {
+		t.Fatalf("candidate %x still authorized", candidate)
+	}
+	// Votes on the dropped candidate are no longer cast
+	if block := tc.block(tc.signers[0], nil); block.MixDigest() != (common.Hash{}) {
+		t.Fatalf("vote cast on settled proposal")
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	tc := newTestChain(t, 2, 3)
+	defer tc.chain.Stop()
+
+	for i := 1; i <= 3; i++ {
+		block := tc.block(tc.signers[i%2], nil)
+		if i == 3 {
+			signers, err := extraSigners(block.Header())
+			if err != nil || len(signers) != 2 || signers[0] != tc.signers[0].addr || signers[1] != tc.signers[1].addr {
+				t.Fatalf("checkpoint signers mismatch: have %x (%v)", signers, err)
+			}
+		}
+		if err := tc.insert(block); err != nil {
+			t.Fatalf("block %d: failed to insert: %v", i, err)
+		}
+	}
+	// A checkpoint with a forged signer list is rejected
+	tc = newTestChain(t, 2, 1)
+	defer tc.chain.Stop()
+
+	block := tc.block(tc.signers[1], func(h *types.Header) {
+		copy(h.Extra[extraVanity:], tc.signers[1].addr[:])
+	})
+	if err := tc.insert(block); err != errMismatchingCheckpointSigners {
+		t.Fatalf("error mismatch: have %v, want %v", err, errMismatchingCheckpointSigners)
+	}
+}
@@ -35,6 +35,14 @@ type AccountAssetInfo struct {
 	Total    *big.Int
 	Decimals uint64
 	Owner    common.Address
+	// MaxSupply caps Total if it is positive, zero means unlimited supply.
+	// It is optional so infos stored before it was added still decode.
+	MaxSupply *big.Int `rlp:"optional"`
+}
+
+// exceedsMaxSupply returns whether total is above the supply cap of the asset.
+func (info *AccountAssetInfo) exceedsMaxSupply(total *big.Int) bool {
+	return info.MaxSupply != nil && info.MaxSupply.Sign() > 0 && total.Cmp(info.MaxSupply) > 0
 }
 
 func registerAccountAsset(db StateDB, accountAddr common.Address, nonce uint64, desc string) (common.Address, error) {
@@ -43,6 +51,12 @@ func registerAccountAsset(db StateDB, accountAddr common.Address, nonce uint64,
 	if err != nil {
 		return common.Address{}, err
 	}
+	if info.Total == nil {
+		info.Total = new(big.Int)
+	}
+	if info.exceedsMaxSupply(info.Total) {
+		return common.Address{}, ErrMaxSupply
+	}
 
 	//save asset info
 	b := new(bytes.Buffer)
@@ -51,6 +65,9 @@ func registerAccountAsset(db StateDB, accountAddr common.Address, nonce uint64,
 		return common.Address{}, err
 	}
 	assetAddr := crypto.CreateAssetAddress(accountAddr, nonce, info.Name)
+	if err := registerAsset(db, assetAddr, &info); err != nil {
+		return common.Address{}, err
+	}
 	db.SetAccount(assetAddr, assetAddr.String(), b.Bytes())
 	//save base type
 	assetTypeKey := assetAddr.String() + string(assetType)
@@ -62,13 +79,10 @@ func registerAccountAsset(db StateDB, accountAddr common.Address, nonce uint64,
 	db.SetAccount(assetAddr, assetTypeKey, b.Bytes())
 	//issue balance to owner
 	setAccountList(AccountModel, db, info.Owner, assetAddr)
-	key := info.Owner.String() + assetAddr.String()
-	b = new(bytes.Buffer)
-	err = rlp.Encode(b, info.Total)
+	err = addAccountlBalance(db, info.Owner, assetAddr, info.Total)
 	if err != nil {
 		return common.Address{}, err
 	}
-	db.SetAccount(info.Owner, key, b.Bytes())
 	return assetAddr, nil
 }
 
@@ -130,6 +144,9 @@ func issueAccountAsset(db StateDB, ownerAddr common.Address, assetAddr common.Ad
 
 	//save asset info
 	info.Total = new(big.Int).Add(info.Total, value)
+	if info.exceedsMaxSupply(info.Total) {
+		return ErrMaxSupply
+	}
 	b := new(bytes.Buffer)
 	err := rlp.Encode(b, &info)
 	if err != nil {
@@ -138,28 +155,7 @@ func issueAccountAsset(db StateDB, ownerAddr common.Address, assetAddr common.Ad
 	db.SetAccount(assetAddr, assetAddr.String(), b.Bytes())
 
 	//save owner balance
-	key := ownerAddr.String() + assetAddr.String()
-	selfAsset := db.GetAccount(ownerAddr, key)
-	var balance *big.Int
-
-	if !bytes.Equal(selfAsset, []byte{}) {
-		err := rlp.Decode(bytes.NewReader(selfAsset), &balance)
-		if err != nil {
-			return err
-		}
-	} else {
-		return fmt.Errorf("Owner asset not exit")
-	}
-	balance = new(big.Int).Add(balance, value)
-
-	b = new(bytes.Buffer)
-	err = rlp.Encode(b, balance)
-	if err != nil {
-		return err
-	}
-	//save target user  balance
-	db.SetAccount(ownerAddr, key, b.Bytes())
-	return nil
+	return addAccountlBalance(db, ownerAddr, assetAddr, value)
 }
 
 func subAccountBalance(db StateDB, targetAddr common.Address, assetAddr common.Address, value *big.Int) error {
@@ -179,6 +175,9 @@ func subAccountBalance(db StateDB, targetAddr common.Address, assetAddr common.A
 	if balance.Cmp(value) < 0 {
 		return fmt.Errorf("Asset not enough")
 	}
+	if balance.Sign() > 0 && balance.Cmp(value) == 0 {
+		changeHolders(db, assetAddr, -1)
+	}
 	balance = new(big.Int).Sub(balance, value)
 	b := new(bytes.Buffer)
 	err := rlp.Encode(b, &balance)
@@ -205,6 +204,9 @@ func addAccountlBalance(db StateDB, targetAddr common.Address, assetAddr common.
 		setAccountList(AccountModel, db, targetAddr, assetAddr)
 	}
 
+	if balance.Sign() == 0 && value.Sign() > 0 {
+		changeHolders(db, assetAddr, 1)
+	}
 	balance = new(big.Int).Add(balance, value)
 
 	b := new(bytes.Buffer)
@@ -228,7 +230,7 @@ func enoughAccountBalance(db StateDB, targetAddr common.Address, assetAddr commo
 			return false, err
 		}
 	} else {
-		return false, fmt.Errorf("Asset not exit")
+		balance = big.NewInt(0)
 	}
 
 	if balance.Cmp(value) < 0 {
@@ -249,8 +251,38 @@ func getAccountBalance(db StateDB, targetAddr common.Address, assetAddr common.A
 			return nil, err
 		}
 	} else {
-		return nil, fmt.Errorf("Asset not exit")
+		// an account that never held the asset has a zero balance
+		balance = big.NewInt(0)
 	}
 
 	return balance, nil
 }
+
+func burnAccountAsset(db StateDB, holderAddr common.Address, assetAddr common.Address, value *big.Int) error {
+	if value.Sign() <= 0 {
+		return ErrNegativeValue
+	}
+	if err := subAccountBalance(db, holderAddr, assetAddr, value); err != nil {
+		return err
+	}
+	return subAssetTotal(db, assetAddr, value)
+}
+
+// subAssetTotal lowers the total supply of an asset by the burnt value.
+func subAssetTotal(db StateDB, assetAddr common.Address, value *big.Int) error {
+	info, err := getAccountAssetInfo(db, assetAddr)
+	if err != nil {
+		return err
+	}
+	if info.Total.Cmp(value) < 0 {
+		return fmt.Errorf("Asset total not enough")
+	}
+	info.Total = new(big.Int).Sub(info.Total, value)
+	b := new(bytes.Buffer)
+	err = rlp.Encode(b, &info)
+	if err != nil {
+		return err
+	}
+	db.SetAccount(assetAddr, assetAddr.String(), b.Bytes())
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package asset
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/types"
+)
+
+// ApplyAction routes an asset action to the matching Asset method. The sender
+// authorizes privileged operations together with the co-signers of the
+// transaction, number is the number of the including block. It returns the
+// asset the action applied to, which is the new asset for
+// types.ActionRegister and the locked asset for the HTLC actions.
+func (a *Asset) ApplyAction(from common.Address, cosigners []common.Address, number uint64, action *types.Action) (common.Address, error) {
+	params, err := action.DecodeParams()
+	if err != nil {
+		return common.Address{}, err
+	}
+	assetAddr := action.AssetID
+
+	switch p := params.(type) {
+	case *types.RegisterParams:
+		if p.BaseType != AccountModel && p.BaseType != UtxoModel {
+			return common.Address{}, fmt.Errorf("unknown asset base type %d", p.BaseType)
+		}
+		info := &AccountAssetInfo{
+			Name:      p.Name,
+			Symbol:    p.Symbol,
+			Total:     p.Total,
+			Decimals:  p.Decimals,
+			Owner:     p.Owner,
+			MaxSupply: p.MaxSupply,
+		}
+		if info.Owner == (common.Address{}) {
+			info.Owner = from
+		}
+		desc, err := json.Marshal(info)
+		if err != nil {
+			return common.Address{}, err
+		}
+		return a.RegisterAsset(int(p.BaseType), from, string(desc))
+
+	case *types.IssueParams:
+		return assetAddr, a.IssueAsset(from, assetAddr, p.Value, cosigners...)
+
+	case *types.TransferOwnerParams:
+		ok, err := a.SetNewOwner(from, assetAddr, p.NewOwner, cosigners...)
+		if err == nil && !ok {
+			err = ErrNotOwner
+		}
+		return assetAddr, err
+
+	case *types.BurnParams:
+		baseType, err := a.getAssetType(assetAddr)
+		if err != nil {
+			return assetAddr, err
+		}
+		if baseType == UtxoModel {
+			return assetAddr, a.BurnAsset(from, assetAddr, p.OutPoint)
+		}
+		return assetAddr, a.BurnAsset(from, assetAddr, p.Value)
+
+	case *types.FreezeParams:
+		if action.Type == types.ActionFreezeAsset {
+			return assetAddr, a.FreezeAsset(from, assetAddr, p.Frozen, cosigners...)
+		}
+		return assetAddr, a.FreezeAccount(from, assetAddr, p.Holder, p.Frozen, cosigners...)
+
+	case *types.PolicyParams:
+		var policy *OwnerPolicy
+		if len(p.Keys) > 0 {
+			policy = &OwnerPolicy{Threshold: p.Threshold}
+			for _, k := range p.Keys {
+				policy.Keys = append(policy.Keys, OwnerKey{Address: k.Address, Weight: k.Weight})
+			}
+		}
+		if action.Type == types.ActionSetAccountPolicy {
+			return assetAddr, a.SetAccountPolicy(from, policy, cosigners...)
+		}
+		return assetAddr, a.SetOwnerPolicy(from, assetAddr, policy, cosigners...)
+
+	case *types.FeeRateParams:
+		var rate *FeeRate
+		if p.Num != nil && p.Num.Sign() != 0 || p.Denom != nil && p.Denom.Sign() != 0 {
+			rate = &FeeRate{Num: p.Num, Denom: p.Denom}
+		}
+		return assetAddr, a.SetFeeRate(from, assetAddr, rate, cosigners...)
+
+	case *types.HTLCLockParams:
+		baseType, err := a.getAssetType(assetAddr)
+		if err != nil {
+			return assetAddr, err
+		}
+		if baseType == UtxoModel {
+			return assetAddr, a.LockHTLC(from, assetAddr, p.Recipient, p.OutPoint, p.HashLock, p.Timeout, number)
+		}
+		return assetAddr, a.LockHTLC(from, assetAddr, p.Recipient, p.Value, p.HashLock, p.Timeout, number)
+
+	case *types.HTLCClaimParams:
+		htlc, err := a.ClaimHTLC(p.Preimage, number)
+		if err != nil {
+			return assetAddr, err
+		}
+		return htlc.AssetID, nil
+
+	case *types.HTLCRefundParams:
+		htlc, err := a.RefundHTLC(p.HashLock, number)
+		if err != nil {
+			return assetAddr, err
+		}
+		return htlc.AssetID, nil
+	}
+	return assetAddr, types.ErrActionType
+}
@@ -18,6 +18,7 @@ package asset
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"math/big"
 
@@ -34,8 +35,22 @@ const (
 )
 
 var (
-	assetlist = []byte("alist")
-	assetType = []byte("aType")
+	assetlist   = []byte("alist")
+	assetType   = []byte("aType")
+	assetFrozen = []byte("frozen")
+)
+
+var (
+	// ErrAssetFrozen is returned if a frozen balance is moved.
+	ErrAssetFrozen = errors.New("asset balance frozen")
+	// ErrMaxSupply is returned if the total supply would exceed the asset cap.
+	ErrMaxSupply = errors.New("asset max supply exceeded")
+	// ErrNotOwner is returned if a privileged operation isn't done by the asset owner.
+	ErrNotOwner = errors.New("not asset owner")
+	// ErrNegativeValue is returned if a non positive amount is burnt.
+	ErrNegativeValue = errors.New("value must be positive")
+
+	errValueType = errors.New("value type does not match asset model")
 )
 
 //Asset operating user assets
@@ -48,8 +63,9 @@ func NewAsset(db StateDB) *Asset {
 	return &Asset{db}
 }
 
-//InitZip Genesis asset ZIP
-func InitZip(db StateDB, total *big.Int, decimals uint64) error {
+//InitZip Genesis asset ZIP, the total supply is credited to the owner which
+//also governs the chain wide asset settings
+func InitZip(db StateDB, total *big.Int, decimals uint64, owner common.Address) error {
 	asset := db.GetAccount(types.ZipAssetID, types.ZipAssetID.String())
 	if !bytes.Equal(asset, []byte{}) {
 		return nil
@@ -59,7 +75,7 @@ func InitZip(db StateDB, total *big.Int, decimals uint64) error {
 		Symbol:   "ZIP",
 		Total:    total,
 		Decimals: decimals,
-		Owner:    types.ZipAccount}
+		Owner:    owner}
 	//save zip asset info
 	b := new(bytes.Buffer)
 	err := rlp.Encode(b, &info)
@@ -67,6 +83,10 @@ func InitZip(db StateDB, total *big.Int, decimals uint64) error {
 		return err
 	}
 	assetAddr := types.ZipAssetID
+	err = registerAsset(db, assetAddr, info)
+	if err != nil {
+		return err
+	}
 	db.SetAccount(assetAddr, assetAddr.String(), b.Bytes())
 
 	//save base type
@@ -82,14 +102,7 @@ func InitZip(db StateDB, total *big.Int, decimals uint64) error {
 	if err != nil {
 		return err
 	}
-	key := info.Owner.String() + assetAddr.String()
-	b = new(bytes.Buffer)
-	err = rlp.Encode(b, info.Total)
-	if err != nil {
-		return err
-	}
-	db.SetAccount(info.Owner, key, b.Bytes())
-	return nil
+	return addAccountlBalance(db, info.Owner, assetAddr, info.Total)
 }
 
 // RegisterAsset create asset
@@ -103,49 +116,168 @@ func (a *Asset) RegisterAsset(baseType int, accountAddr common.Address, desc str
 			return addr, err
 		}
 	case UtxoModel:
-		fmt.Println("Utxo")
+		addr, err = registerUtxoAsset(a.db, accountAddr, a.GetNonce(accountAddr), desc)
+		if err != nil {
+			return addr, err
+		}
 	}
 	return addr, nil
 }
 
-// SetNewOwner .
-func (a *Asset) SetNewOwner(oldOwner common.Address, assetAddr common.Address, newOwner common.Address) (bool, error) {
+// SetNewOwner transfers the ownership of the asset. The privileged operations
+// of Asset are authorized by the signing owner together with the optional
+// cosigners, an asset with an owner policy needs its threshold to be met.
+func (a *Asset) SetNewOwner(oldOwner common.Address, assetAddr common.Address, newOwner common.Address, cosigners ...common.Address) (bool, error) {
 	baseType, err := a.getAssetType(assetAddr)
 	if err != nil {
 		return false, err
 	}
+	owner, err := authorize(a.db, assetAddr, signersOf(oldOwner, cosigners))
+	if err != nil {
+		return false, err
+	}
 	var ok bool
 	switch baseType {
-	case AccountModel:
-		ok, err = setAccountNewOwner(a.db, oldOwner, assetAddr, newOwner)
+	case AccountModel, UtxoModel:
+		ok, err = setAccountNewOwner(a.db, owner, assetAddr, newOwner)
 		if err != nil {
 			return false, err
 		}
-	case UtxoModel:
-		fmt.Println("Utxo")
 	}
 	return ok, nil
 }
 
-// IssueAsset issue asset
-func (a *Asset) IssueAsset(ownerAddr common.Address, assetAddr common.Address, value interface{}) error {
+// IssueAsset issue asset to the asset owner
+func (a *Asset) IssueAsset(ownerAddr common.Address, assetAddr common.Address, value interface{}, cosigners ...common.Address) error {
 	baseType, err := a.getAssetType(assetAddr)
 	if err != nil {
 		return err
 	}
+	owner, err := authorize(a.db, assetAddr, signersOf(ownerAddr, cosigners))
+	if err != nil {
+		return err
+	}
 	switch baseType {
 	case AccountModel:
-		v := value.(*big.Int)
-		err := issueAccountAsset(a.db, ownerAddr, assetAddr, v)
+		v, ok := value.(*big.Int)
+		if !ok {
+			return errValueType
+		}
+		err := issueAccountAsset(a.db, owner, assetAddr, v)
 		if err != nil {
 			return err
 		}
 	case UtxoModel:
-		fmt.Println("Utxo")
+		v, ok := value.(*big.Int)
+		if !ok {
+			return errValueType
+		}
+		err := issueUtxoAsset(a.db, owner, assetAddr, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BurnAsset destroys value of the holder balance and lowers the total supply
+// of the asset accordingly. Utxo model assets burn the types.OutPoint given as
+// value.
+func (a *Asset) BurnAsset(holderAddr common.Address, assetAddr common.Address, value interface{}) error {
+	baseType, err := a.getAssetType(assetAddr)
+	if err != nil {
+		return err
+	}
+	if a.IsFrozen(assetAddr, holderAddr) {
+		return ErrAssetFrozen
+	}
+	switch baseType {
+	case AccountModel:
+		v, ok := value.(*big.Int)
+		if !ok {
+			return errValueType
+		}
+		return burnAccountAsset(a.db, holderAddr, assetAddr, v)
+	case UtxoModel:
+		op, ok := value.(types.OutPoint)
+		if !ok {
+			return errValueType
+		}
+		return burnUtxoAsset(a.db, holderAddr, assetAddr, op)
 	}
 	return nil
 }
 
+// BurnFee lowers the total supply of an account model asset by a fee already
+// taken from the payer, like the base fee of a chain without treasury.
+func (a *Asset) BurnFee(assetAddr common.Address, value *big.Int) error {
+	if value.Sign() < 0 {
+		return ErrNegativeValue
+	}
+	if value.Sign() == 0 {
+		return nil
+	}
+	return subAssetTotal(a.db, assetAddr, value)
+}
+
+// FreezeAsset freezes or unfreezes all balances of the asset, only the asset
+// owner may do so.
+func (a *Asset) FreezeAsset(ownerAddr common.Address, assetAddr common.Address, frozen bool, cosigners ...common.Address) error {
+	if _, err := authorize(a.db, assetAddr, signersOf(ownerAddr, cosigners)); err != nil {
+		return err
+	}
+	setFrozen(a.db, assetAddr, assetAddr.String()+string(assetFrozen), frozen)
+	return nil
+}
+
+// FreezeAccount freezes or unfreezes the balance of a single holder of the
+// asset, only the asset owner may do so.
+func (a *Asset) FreezeAccount(ownerAddr common.Address, assetAddr common.Address, holderAddr common.Address, frozen bool, cosigners ...common.Address) error {
+	if _, err := authorize(a.db, assetAddr, signersOf(ownerAddr, cosigners)); err != nil {
+		return err
+	}
+	setFrozen(a.db, assetAddr, assetAddr.String()+holderAddr.String()+string(assetFrozen), frozen)
+	return nil
+}
+
+// IsFrozen returns whether the balance of holderAddr can't be moved, either
+// because the whole asset or the single holder is frozen.
+func (a *Asset) IsFrozen(assetAddr common.Address, holderAddr common.Address) bool {
+	if !bytes.Equal(a.db.GetAccount(assetAddr, assetAddr.String()+string(assetFrozen)), []byte{}) {
+		return true
+	}
+	return !bytes.Equal(a.db.GetAccount(assetAddr, assetAddr.String()+holderAddr.String()+string(assetFrozen)), []byte{})
+}
+
+// SetOwnerPolicy replaces the owner policy of the asset, a nil policy makes
+// the single owner account authorize privileged operations again. Changing
+// the policy needs the threshold of the current one.
+func (a *Asset) SetOwnerPolicy(ownerAddr common.Address, assetAddr common.Address, policy *OwnerPolicy, cosigners ...common.Address) error {
+	if policy != nil {
+		if err := policy.Validate(); err != nil {
+			return err
+		}
+	}
+	if _, err := authorize(a.db, assetAddr, signersOf(ownerAddr, cosigners)); err != nil {
+		return err
+	}
+	return setOwnerPolicy(a.db, assetAddr, policy)
+}
+
+// GetOwnerPolicy returns the owner policy of the asset, nil if the asset is
+// owned by a single account.
+func (a *Asset) GetOwnerPolicy(assetAddr common.Address) (*OwnerPolicy, error) {
+	return getOwnerPolicy(a.db, assetAddr)
+}
+
+func setFrozen(db StateDB, assetAddr common.Address, key string, frozen bool) {
+	if frozen {
+		db.SetAccount(assetAddr, key, []byte{1})
+	} else {
+		db.SetAccount(assetAddr, key, []byte{})
+	}
+}
+
 //UserAsset user asset info
 type UserAsset struct {
 	baseType  uint
@@ -173,7 +305,7 @@ func (a *Asset) GetUserAssets(address common.Address) ([]UserAsset, error) {
 				return nil, err
 			}
 			switch baseType {
-			case AccountModel:
+			case AccountModel, UtxoModel:
 				balance, err := getAccountBalance(a.db, address, assetAddr)
 				if err != nil {
 					return nil, err
@@ -183,13 +315,11 @@ func (a *Asset) GetUserAssets(address common.Address) ([]UserAsset, error) {
 					return nil, err
 				}
 				asset := UserAsset{
-					baseType:  AccountModel,
+					baseType:  uint(baseType),
 					assetAddr: assetAddr,
 					assetName: info.Symbol,
 					balance:   balance}
 				assets = append(assets, asset)
-			case UtxoModel:
-				fmt.Println("Utxo")
 			}
 		}
 		return assets, nil
@@ -215,26 +345,42 @@ func (a *Asset) CreateAccount(addr common.Address) error {
 	return nil
 }
 
-// SubBalance sub account balance
+// SubBalance sub account balance, utxo model assets are debited by
+// spending the types.OutPoint given as value. Frozen balances can't be
+// debited.
 func (a *Asset) SubBalance(targetAddr common.Address, assetAddr common.Address, value interface{}) error {
 	baseType, err := a.getAssetType(assetAddr)
 	if err != nil {
 		return err
 	}
+	if a.IsFrozen(assetAddr, targetAddr) {
+		return ErrAssetFrozen
+	}
 	switch baseType {
 	case AccountModel:
-		v := value.(*big.Int)
+		v, ok := value.(*big.Int)
+		if !ok {
+			return errValueType
+		}
 		err := subAccountBalance(a.db, targetAddr, assetAddr, v)
 		if err != nil {
 			return err
 		}
 	case UtxoModel:
-		fmt.Println("Utxo")
+		op, ok := value.(types.OutPoint)
+		if !ok {
+			return errValueType
+		}
+		err := spendUtxo(a.db, targetAddr, assetAddr, op)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// AddBalance add account balance
+// AddBalance add account balance, utxo model assets are credited with the
+// *UTXO given as value.
 func (a *Asset) AddBalance(targetAddr common.Address, assetAddr common.Address, value interface{}) error {
 	baseType, err := a.getAssetType(assetAddr)
 	if err != nil {
@@ -242,13 +388,25 @@ func (a *Asset) AddBalance(targetAddr common.Address, assetAddr common.Address,
 	}
 	switch baseType {
 	case AccountModel:
-		v := value.(*big.Int)
+		v, ok := value.(*big.Int)
+		if !ok {
+			return errValueType
+		}
 		err := addAccountlBalance(a.db, targetAddr, assetAddr, v)
 		if err != nil {
 			return err
 		}
 	case UtxoModel:
-		fmt.Println("Utxo")
+		v, ok := value.(*UTXO)
+		if !ok {
+			return errValueType
+		}
+		utxo := *v
+		utxo.Owner = targetAddr
+		err := addUtxo(a.db, assetAddr, &utxo)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -261,14 +419,15 @@ func (a *Asset) EnoughBalance(targetAddr common.Address, assetAddr common.Addres
 	}
 	var enough bool
 	switch baseType {
-	case AccountModel:
-		v := value.(*big.Int)
+	case AccountModel, UtxoModel:
+		v, ok := value.(*big.Int)
+		if !ok {
+			return false, errValueType
+		}
 		enough, err = enoughAccountBalance(a.db, targetAddr, assetAddr, v)
 		if err != nil {
 			return false, err
 		}
-	case UtxoModel:
-		fmt.Println("Utxo")
 	}
 	return enough, nil
 }
@@ -280,18 +439,33 @@ func (a *Asset) GetBalance(targetAddr common.Address, assetAddr common.Address)
 		panic("GetBalance error")
 	}
 	switch baseType {
-	case AccountModel:
+	case AccountModel, UtxoModel:
 		balance, err := getAccountBalance(a.db, targetAddr, assetAddr)
 		if err != nil {
 			panic("GetBalance error")
 		}
 		return balance
-	case UtxoModel:
-		fmt.Println("Utxo")
 	}
 	return nil
 }
 
+// GetUTXO returns the unspent output of an utxo model asset at the outpoint.
+func (a *Asset) GetUTXO(assetAddr common.Address, op types.OutPoint) (*UTXO, error) {
+	return getUtxo(a.db, assetAddr, op)
+}
+
+// GetUTXOs returns all unspent outputs of an utxo model asset owned by targetAddr.
+func (a *Asset) GetUTXOs(targetAddr common.Address, assetAddr common.Address) ([]*UTXO, error) {
+	baseType, err := a.getAssetType(assetAddr)
+	if err != nil {
+		return nil, err
+	}
+	if baseType != UtxoModel {
+		return nil, fmt.Errorf("Asset not utxo model")
+	}
+	return getUtxos(a.db, targetAddr, assetAddr)
+}
+
 // GetNonce get nonce
 func (a *Asset) GetNonce(targetAddr common.Address) uint64 {
 	accountByte := a.db.GetAccount(targetAddr, targetAddr.String())
@@ -17,6 +17,7 @@
 package asset
 
 import (
+	"bytes"
 	"encoding/json"
 	"math/big"
 	"testing"
@@ -24,6 +25,7 @@ import (
 	"github.com/zipper-project/z0/common"
 	"github.com/zipper-project/z0/state"
 	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/rlp"
 	"github.com/zipper-project/z0/utils/zdb"
 )
 
@@ -160,7 +162,7 @@ func TestGeneralAsset(t *testing.T) {
 	}
 	// fmt.Printf("Exist:%v\n", boo)
 
-	InitZip(statedb, big.NewInt(1000), 8)
+	InitZip(statedb, big.NewInt(1000), 8, types.ZipAccount)
 	if err != nil {
 		t.Errorf("Unexpected error : %v", err)
 	}
@@ -183,3 +185,636 @@ func TestGeneralAsset(t *testing.T) {
 	}
 	// fmt.Printf("type:%v address:%v name:%v balance:%v\n", assets[0].baseType, assets[0].assetAddr, assets[0].assetName, assets[0].balance)
 }
+
+func TestUtxoAsset(t *testing.T) {
+	db := zdb.NewMemDatabase()
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	account1 := common.Address{10}
+	owner := common.Address{100}
+	receiver := common.Address{101}
+
+	asset := NewAsset(statedb)
+	info := &AccountAssetInfo{
+		Name:     "test",
+		Symbol:   "UBTC",
+		Total:    big.NewInt(2100),
+		Decimals: 8,
+		Owner:    owner}
+	b, _ := json.Marshal(info)
+	aAddress, err := asset.RegisterAsset(UtxoModel, account1, string(b))
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if err := asset.IssueAsset(owner, aAddress, big.NewInt(20)); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if v := asset.GetBalance(owner, aAddress).(*big.Int); v.Cmp(big.NewInt(2120)) != 0 {
+		t.Fatalf("balance mismatch: have %v, want 2120", v)
+	}
+	utxos, err := asset.GetUTXOs(owner, aAddress)
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if len(utxos) != 2 || utxos[0].OutPoint == utxos[1].OutPoint {
+		t.Fatalf("issued outputs mismatch: %v", utxos)
+	}
+
+	// spend the initial supply into a payment and a change output
+	spent := utxos[0].OutPoint
+	if err := asset.SubBalance(receiver, aAddress, spent); err != ErrUTXOOwner {
+		t.Fatalf("spending foreign output: have %v, want %v", err, ErrUTXOOwner)
+	}
+	if err := asset.SubBalance(owner, aAddress, spent); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if err := asset.SubBalance(owner, aAddress, spent); err != ErrUTXONotFound {
+		t.Fatalf("double spend: have %v, want %v", err, ErrUTXONotFound)
+	}
+	txHash := common.HexToHash("0x01")
+	pay := &UTXO{OutPoint: types.OutPoint{TxHash: txHash, Index: 0}, Value: big.NewInt(100)}
+	change := &UTXO{OutPoint: types.OutPoint{TxHash: txHash, Index: 1}, Value: big.NewInt(2000)}
+	if err := asset.AddBalance(receiver, aAddress, pay); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if err := asset.AddBalance(owner, aAddress, change); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if err := asset.AddBalance(owner, aAddress, change); err != ErrUTXOExist {
+		t.Fatalf("duplicate output: have %v, want %v", err, ErrUTXOExist)
+	}
+	if err := asset.AddBalance(owner, aAddress, big.NewInt(1)); err == nil {
+		t.Fatalf("account model value accepted by utxo asset")
+	}
+
+	if v := asset.GetBalance(owner, aAddress).(*big.Int); v.Cmp(big.NewInt(2020)) != 0 {
+		t.Fatalf("owner balance mismatch: have %v, want 2020", v)
+	}
+	if v := asset.GetBalance(receiver, aAddress).(*big.Int); v.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("receiver balance mismatch: have %v, want 100", v)
+	}
+	utxo, err := asset.GetUTXO(aAddress, pay.OutPoint)
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if utxo.Owner != receiver || utxo.Value.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("output mismatch: %v", utxo)
+	}
+	if utxos, _ = asset.GetUTXOs(owner, aAddress); len(utxos) != 2 {
+		t.Fatalf("owner outputs mismatch: have %d, want 2", len(utxos))
+	}
+	assets, err := asset.GetUserAssets(receiver)
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if len(assets) != 1 || assets[0].baseType != UtxoModel || assets[0].balance.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("user assets mismatch: %v", assets)
+	}
+
+	// spending the last output of an account clears its output list
+	if err := asset.SubBalance(receiver, aAddress, pay.OutPoint); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if utxos, _ = asset.GetUTXOs(receiver, aAddress); len(utxos) != 0 {
+		t.Fatalf("receiver outputs mismatch: have %d, want 0", len(utxos))
+	}
+	InitZip(statedb, big.NewInt(1000), 8, types.ZipAccount)
+	if _, err := asset.GetUTXOs(types.ZipAccount, types.ZipAssetID); err == nil {
+		t.Fatalf("utxo query on account model asset succeeded")
+	}
+}
+
+func newTestAsset(t *testing.T, baseType int, owner common.Address, total, maxSupply *big.Int) (*Asset, common.Address) {
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(zdb.NewMemDatabase()))
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	asset := NewAsset(statedb)
+	info := &AccountAssetInfo{
+		Name:      "test",
+		Symbol:    "TST",
+		Total:     total,
+		Decimals:  8,
+		Owner:     owner,
+		MaxSupply: maxSupply}
+	b, _ := json.Marshal(info)
+	aAddress, err := asset.RegisterAsset(baseType, common.Address{10}, string(b))
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	return asset, aAddress
+}
+
+func checkTotal(t *testing.T, asset *Asset, aAddress common.Address, want int64) {
+	info, err := getAccountAssetInfo(asset.db, aAddress)
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if info.Total.Cmp(big.NewInt(want)) != 0 {
+		t.Fatalf("total mismatch: have %v, want %v", info.Total, want)
+	}
+}
+
+func TestBurnAsset(t *testing.T) {
+	owner := common.Address{100}
+	holder := common.Address{101}
+
+	asset, aAddress := newTestAsset(t, AccountModel, owner, big.NewInt(1000), nil)
+	if err := asset.SubBalance(owner, aAddress, big.NewInt(300)); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if err := asset.AddBalance(holder, aAddress, big.NewInt(300)); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if err := asset.BurnAsset(holder, aAddress, big.NewInt(100)); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if v := asset.GetBalance(holder, aAddress).(*big.Int); v.Cmp(big.NewInt(200)) != 0 {
+		t.Fatalf("holder balance mismatch: have %v, want 200", v)
+	}
+	checkTotal(t, asset, aAddress, 900)
+	if err := asset.BurnAsset(holder, aAddress, big.NewInt(201)); err == nil {
+		t.Fatalf("burnt more than the holder balance")
+	}
+	if err := asset.BurnAsset(holder, aAddress, big.NewInt(0)); err != ErrNegativeValue {
+		t.Fatalf("zero burn error mismatch: have %v, want %v", err, ErrNegativeValue)
+	}
+	checkTotal(t, asset, aAddress, 900)
+
+	// utxo model assets burn whole outputs
+	asset, aAddress = newTestAsset(t, UtxoModel, owner, big.NewInt(1000), nil)
+	utxos, _ := asset.GetUTXOs(owner, aAddress)
+	if err := asset.BurnAsset(holder, aAddress, utxos[0].OutPoint); err != ErrUTXOOwner {
+		t.Fatalf("foreign burn error mismatch: have %v, want %v", err, ErrUTXOOwner)
+	}
+	if err := asset.BurnAsset(owner, aAddress, utxos[0].OutPoint); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if v := asset.GetBalance(owner, aAddress).(*big.Int); v.Sign() != 0 {
+		t.Fatalf("owner balance mismatch: have %v, want 0", v)
+	}
+	checkTotal(t, asset, aAddress, 0)
+}
+
+func TestMaxSupply(t *testing.T) {
+	owner := common.Address{100}
+	for _, baseType := range []int{AccountModel, UtxoModel} {
+		asset, aAddress := newTestAsset(t, baseType, owner, big.NewInt(900), big.NewInt(1000))
+		if err := asset.IssueAsset(owner, aAddress, big.NewInt(100)); err != nil {
+			t.Fatalf("model %d: Unexpected error : %v", baseType, err)
+		}
+		if err := asset.IssueAsset(owner, aAddress, big.NewInt(1)); err != ErrMaxSupply {
+			t.Fatalf("model %d: issue error mismatch: have %v, want %v", baseType, err, ErrMaxSupply)
+		}
+		checkTotal(t, asset, aAddress, 1000)
+		if v := asset.GetBalance(owner, aAddress).(*big.Int); v.Cmp(big.NewInt(1000)) != 0 {
+			t.Fatalf("model %d: owner balance mismatch: have %v, want 1000", baseType, v)
+		}
+
+		// burning makes room for new issuance
+		if baseType == AccountModel {
+			if err := asset.BurnAsset(owner, aAddress, big.NewInt(10)); err != nil {
+				t.Fatalf("Unexpected error : %v", err)
+			}
+			if err := asset.IssueAsset(owner, aAddress, big.NewInt(10)); err != nil {
+				t.Fatalf("Unexpected error : %v", err)
+			}
+		}
+	}
+
+	// an unlimited asset accepts any issuance
+	asset, aAddress := newTestAsset(t, AccountModel, owner, big.NewInt(900), nil)
+	if err := asset.IssueAsset(owner, aAddress, big.NewInt(1000000)); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+
+	// the initial supply can't exceed the cap
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(zdb.NewMemDatabase()))
+	b, _ := json.Marshal(&AccountAssetInfo{Name: "test", Symbol: "TST", Total: big.NewInt(2), Owner: owner, MaxSupply: big.NewInt(1)})
+	if _, err := NewAsset(statedb).RegisterAsset(AccountModel, owner, string(b)); err != ErrMaxSupply {
+		t.Fatalf("register error mismatch: have %v, want %v", err, ErrMaxSupply)
+	}
+
+	// infos stored before the cap existed decode as unlimited and encode as
+	// they did
+	legacy, _ := rlp.EncodeToBytes([]interface{}{"old", "OLD", big.NewInt(5), uint64(2), owner})
+	statedb.SetAccount(aAddress, aAddress.String(), legacy)
+	info, err := getAccountAssetInfo(statedb, aAddress)
+	if err != nil {
+		t.Fatalf("legacy info: %v", err)
+	}
+	if info.MaxSupply != nil || info.Total.Int64() != 5 || info.Owner != owner {
+		t.Fatalf("legacy info mismatch: %+v", info)
+	}
+	if enc, _ := rlp.EncodeToBytes(&info); !bytes.Equal(enc, legacy) {
+		t.Fatalf("legacy info encoding changed")
+	}
+}
+
+func TestFreezeAsset(t *testing.T) {
+	owner := common.Address{100}
+	holder := common.Address{101}
+
+	asset, aAddress := newTestAsset(t, AccountModel, owner, big.NewInt(1000), nil)
+	if err := asset.SubBalance(owner, aAddress, big.NewInt(300)); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if err := asset.AddBalance(holder, aAddress, big.NewInt(300)); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+
+	// only the owner may freeze
+	if err := asset.FreezeAccount(holder, aAddress, holder, true); err != ErrNotOwner {
+		t.Fatalf("freeze error mismatch: have %v, want %v", err, ErrNotOwner)
+	}
+	if err := asset.FreezeAccount(owner, aAddress, holder, true); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if !asset.IsFrozen(aAddress, holder) || asset.IsFrozen(aAddress, owner) {
+		t.Fatalf("holder freeze mismatch")
+	}
+	if err := asset.SubBalance(holder, aAddress, big.NewInt(1)); err != ErrAssetFrozen {
+		t.Fatalf("frozen debit error mismatch: have %v, want %v", err, ErrAssetFrozen)
+	}
+	if err := asset.BurnAsset(holder, aAddress, big.NewInt(1)); err != ErrAssetFrozen {
+		t.Fatalf("frozen burn error mismatch: have %v, want %v", err, ErrAssetFrozen)
+	}
+	// frozen holders still receive funds
+	if err := asset.AddBalance(holder, aAddress, big.NewInt(1)); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if err := asset.SubBalance(owner, aAddress, big.NewInt(1)); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if err := asset.FreezeAccount(owner, aAddress, holder, false); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if err := asset.SubBalance(holder, aAddress, big.NewInt(1)); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+
+	// freezing the asset locks every holder
+	if err := asset.FreezeAsset(owner, aAddress, true); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	for _, addr := range []common.Address{owner, holder} {
+		if err := asset.SubBalance(addr, aAddress, big.NewInt(1)); err != ErrAssetFrozen {
+			t.Fatalf("frozen debit error mismatch: have %v, want %v", err, ErrAssetFrozen)
+		}
+	}
+	if err := asset.FreezeAsset(owner, aAddress, false); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if v := asset.GetBalance(holder, aAddress).(*big.Int); v.Cmp(big.NewInt(300)) != 0 {
+		t.Fatalf("holder balance mismatch: have %v, want 300", v)
+	}
+
+	// frozen utxo outputs can't be spent
+	asset, aAddress = newTestAsset(t, UtxoModel, owner, big.NewInt(1000), nil)
+	utxos, _ := asset.GetUTXOs(owner, aAddress)
+	if err := asset.FreezeAccount(owner, aAddress, owner, true); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if err := asset.SubBalance(owner, aAddress, utxos[0].OutPoint); err != ErrAssetFrozen {
+		t.Fatalf("frozen spend error mismatch: have %v, want %v", err, ErrAssetFrozen)
+	}
+}
+
+func TestAssetRegistry(t *testing.T) {
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(zdb.NewMemDatabase()))
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if err := InitZip(statedb, big.NewInt(1000), 8, types.ZipAccount); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	asset := NewAsset(statedb)
+	owner := common.Address{100}
+	holder := common.Address{101}
+
+	register := func(baseType int, creator common.Address, name, symbol string) (common.Address, error) {
+		b, _ := json.Marshal(&AccountAssetInfo{Name: name, Symbol: symbol, Total: big.NewInt(100), Owner: owner})
+		return asset.RegisterAsset(baseType, creator, string(b))
+	}
+	btc, err := register(AccountModel, common.Address{10}, "bitcoin", "BTC")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	eth, err := register(UtxoModel, common.Address{11}, "ether", "ETH")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	wbtc, err := register(AccountModel, common.Address{12}, "bitcoin", "WBTC")
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+
+	// symbols are unique ignoring case, addresses can't be registered twice
+	if _, err := register(AccountModel, common.Address{13}, "fake", "btc"); err != ErrSymbolExist {
+		t.Fatalf("duplicate symbol error mismatch: have %v, want %v", err, ErrSymbolExist)
+	}
+	if _, err := register(AccountModel, common.Address{13}, "zip", "zip"); err != ErrSymbolExist {
+		t.Fatalf("duplicate symbol error mismatch: have %v, want %v", err, ErrSymbolExist)
+	}
+	if _, err := register(AccountModel, common.Address{10}, "bitcoin", "BTC2"); err != ErrAssetExist {
+		t.Fatalf("duplicate address error mismatch: have %v, want %v", err, ErrAssetExist)
+	}
+	if _, err := register(AccountModel, common.Address{13}, "empty", ""); err != ErrEmptySymbol {
+		t.Fatalf("empty symbol error mismatch: have %v, want %v", err, ErrEmptySymbol)
+	}
+
+	// enumerate with paging
+	if count := asset.AssetCount(); count != 4 {
+		t.Fatalf("asset count mismatch: have %d, want 4", count)
+	}
+	want := []common.Address{types.ZipAssetID, btc, eth, wbtc}
+	var all []*RegisteredAsset
+	for offset := uint64(0); ; offset += 3 {
+		page, err := asset.GetAssets(offset, 3)
+		if err != nil {
+			t.Fatalf("Unexpected error : %v", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		all = append(all, page...)
+	}
+	if len(all) != len(want) {
+		t.Fatalf("enumerated assets mismatch: have %d, want %d", len(all), len(want))
+	}
+	for i, a := range all {
+		if a.Address != want[i] {
+			t.Errorf("asset %d mismatch: have %x, want %x", i, a.Address, want[i])
+		}
+	}
+	if all[2].BaseType != UtxoModel || all[2].Info.Symbol != "ETH" {
+		t.Errorf("asset entry mismatch: %v", all[2])
+	}
+
+	// lookups
+	if a, err := asset.GetAssetBySymbol("eth"); err != nil || a.Address != eth {
+		t.Errorf("symbol lookup mismatch: %v, %v", a, err)
+	}
+	if _, err := asset.GetAssetBySymbol("DOGE"); err != ErrAssetNotFound {
+		t.Errorf("unknown symbol error mismatch: have %v, want %v", err, ErrAssetNotFound)
+	}
+	if list, err := asset.GetAssetsByName("bitcoin"); err != nil || len(list) != 2 || list[0].Address != btc || list[1].Address != wbtc {
+		t.Errorf("name lookup mismatch: %v, %v", list, err)
+	}
+
+	// holder counts follow positive balances
+	if n := asset.GetHolderCount(btc); n != 1 {
+		t.Fatalf("holder count mismatch: have %d, want 1", n)
+	}
+	if err := asset.SubBalance(owner, btc, big.NewInt(40)); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if err := asset.AddBalance(holder, btc, big.NewInt(40)); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if n := asset.GetHolderCount(btc); n != 2 {
+		t.Fatalf("holder count mismatch: have %d, want 2", n)
+	}
+	if err := asset.SubBalance(holder, btc, big.NewInt(40)); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if err := asset.AddBalance(owner, btc, big.NewInt(40)); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if n := asset.GetHolderCount(btc); n != 1 {
+		t.Fatalf("holder count mismatch: have %d, want 1", n)
+	}
+	utxos, _ := asset.GetUTXOs(owner, eth)
+	if err := asset.SubBalance(owner, eth, utxos[0].OutPoint); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if a, _ := asset.GetAsset(eth); a.Holders != 0 {
+		t.Fatalf("holder count mismatch: have %d, want 0", a.Holders)
+	}
+}
+
+func TestOwnerPolicy(t *testing.T) {
+	owner := common.Address{100}
+	k1, k2, k3 := common.Address{1, 1}, common.Address{1, 2}, common.Address{1, 3}
+	outsider := common.Address{1, 4}
+
+	asset, aAddress := newTestAsset(t, AccountModel, owner, big.NewInt(1000), nil)
+
+	// invalid policies are rejected
+	for _, policy := range []*OwnerPolicy{
+		{Keys: []OwnerKey{{Address: k1}}, Threshold: 0},
+		{Keys: []OwnerKey{{Address: k1}, {Address: k2}}, Threshold: 3},
+		{Keys: []OwnerKey{{Address: k1}, {Address: k1}}, Threshold: 1},
+	} {
+		if err := asset.SetOwnerPolicy(owner, aAddress, policy); err != ErrInvalidPolicy {
+			t.Fatalf("invalid policy error mismatch: have %v, want %v", err, ErrInvalidPolicy)
+		}
+	}
+
+	// k1 weighs two, k2 and k3 one each, three is needed
+	policy := &OwnerPolicy{Keys: []OwnerKey{{Address: k1, Weight: 2}, {Address: k2}, {Address: k3}}, Threshold: 3}
+	if err := asset.SetOwnerPolicy(outsider, aAddress, policy); err != ErrNotOwner {
+		t.Fatalf("policy change error mismatch: have %v, want %v", err, ErrNotOwner)
+	}
+	if err := asset.SetOwnerPolicy(owner, aAddress, policy); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if p, _ := asset.GetOwnerPolicy(aAddress); p == nil || p.Threshold != 3 || len(p.Keys) != 3 {
+		t.Fatalf("stored policy mismatch: %v", p)
+	}
+
+	// the single owner alone is no longer enough
+	if err := asset.IssueAsset(owner, aAddress, big.NewInt(10)); err != ErrNotOwner {
+		t.Fatalf("issue error mismatch: have %v, want %v", err, ErrNotOwner)
+	}
+	if err := asset.IssueAsset(k2, aAddress, big.NewInt(10), k3, outsider); err != ErrNotOwner {
+		t.Fatalf("issue error mismatch: have %v, want %v", err, ErrNotOwner)
+	}
+	if err := asset.IssueAsset(k1, aAddress, big.NewInt(10), k1); err != ErrNotOwner {
+		t.Fatalf("duplicate signer error mismatch: have %v, want %v", err, ErrNotOwner)
+	}
+	// issued value still goes to the owner account
+	if err := asset.IssueAsset(k1, aAddress, big.NewInt(10), k3); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if v := asset.GetBalance(owner, aAddress).(*big.Int); v.Cmp(big.NewInt(1010)) != 0 {
+		t.Fatalf("owner balance mismatch: have %v, want 1010", v)
+	}
+
+	// freezing needs the threshold too
+	if err := asset.FreezeAsset(k1, aAddress, true); err != ErrNotOwner {
+		t.Fatalf("freeze error mismatch: have %v, want %v", err, ErrNotOwner)
+	}
+	if err := asset.FreezeAccount(k2, aAddress, outsider, true, k1); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if !asset.IsFrozen(aAddress, outsider) {
+		t.Fatalf("holder not frozen")
+	}
+
+	// transferring ownership
+	if ok, err := asset.SetNewOwner(owner, aAddress, outsider); ok || err != ErrNotOwner {
+		t.Fatalf("ownership transfer mismatch: have %v, %v, want false, %v", ok, err, ErrNotOwner)
+	}
+	if ok, err := asset.SetNewOwner(k2, aAddress, outsider, k1); !ok || err != nil {
+		t.Fatalf("ownership transfer mismatch: %v, %v", ok, err)
+	}
+	if err := asset.IssueAsset(k1, aAddress, big.NewInt(5), k2); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if v := asset.GetBalance(outsider, aAddress).(*big.Int); v.Cmp(big.NewInt(5)) != 0 {
+		t.Fatalf("new owner balance mismatch: have %v, want 5", v)
+	}
+
+	// changing the policy needs its own threshold, removing it restores the
+	// single owner
+	if err := asset.SetOwnerPolicy(k2, aAddress, nil, k3); err != ErrNotOwner {
+		t.Fatalf("policy change error mismatch: have %v, want %v", err, ErrNotOwner)
+	}
+	if err := asset.SetOwnerPolicy(k1, aAddress, nil, k2); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if p, _ := asset.GetOwnerPolicy(aAddress); p != nil {
+		t.Fatalf("policy not removed: %v", p)
+	}
+	if err := asset.IssueAsset(outsider, aAddress, big.NewInt(5)); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+}
+
+func TestFeeRate(t *testing.T) {
+	owner := common.Address{100}
+	outsider := common.Address{101}
+
+	asset, aAddress := newTestAsset(t, AccountModel, owner, big.NewInt(1000), nil)
+	if err := InitZip(asset.db, big.NewInt(1000), 8, types.ZipAccount); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if rate, err := asset.GetFeeRate(types.ZipAssetID); err != nil || rate.Num.Cmp(rate.Denom) != 0 {
+		t.Fatalf("ZIP fee rate mismatch: %v, %v", rate, err)
+	}
+	if _, err := asset.GetFeeRate(aAddress); err != ErrNoFeeRate {
+		t.Fatalf("fee rate error mismatch: have %v, want %v", err, ErrNoFeeRate)
+	}
+
+	rate := &FeeRate{Num: big.NewInt(1), Denom: big.NewInt(2)}
+	if err := asset.SetFeeRate(outsider, aAddress, rate); err != ErrNotOwner {
+		t.Fatalf("fee rate error mismatch: have %v, want %v", err, ErrNotOwner)
+	}
+	if err := asset.SetFeeRate(owner, aAddress, &FeeRate{Num: big.NewInt(0), Denom: big.NewInt(2)}); err != ErrInvalidFeeRate {
+		t.Fatalf("fee rate error mismatch: have %v, want %v", err, ErrInvalidFeeRate)
+	}
+	if err := asset.SetFeeRate(owner, types.ZipAssetID, rate); err != ErrInvalidFeeRate {
+		t.Fatalf("fee rate error mismatch: have %v, want %v", err, ErrInvalidFeeRate)
+	}
+	if err := asset.SetFeeRate(owner, aAddress, rate); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	stored, err := asset.GetFeeRate(aAddress)
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if v := stored.ToZip(big.NewInt(11)); v.Cmp(big.NewInt(5)) != 0 {
+		t.Fatalf("converted value mismatch: have %v, want 5", v)
+	}
+	if v := stored.FromZip(big.NewInt(5)); v.Cmp(big.NewInt(10)) != 0 {
+		t.Fatalf("converted value mismatch: have %v, want 10", v)
+	}
+
+	// governance may change the rate of any asset, or remove it
+	if err := asset.SetFeeRate(types.ZipAccount, aAddress, &FeeRate{Num: big.NewInt(3), Denom: big.NewInt(1)}); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if stored, _ := asset.GetFeeRate(aAddress); stored.Num.Cmp(big.NewInt(3)) != 0 {
+		t.Fatalf("fee rate mismatch: %v", stored)
+	}
+	if err := asset.SetFeeRate(types.ZipAccount, aAddress, nil); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if _, err := asset.GetFeeRate(aAddress); err != ErrNoFeeRate {
+		t.Fatalf("fee rate error mismatch: have %v, want %v", err, ErrNoFeeRate)
+	}
+
+	// utxo model assets can't pay fees
+	uAsset, uAddress := newTestAsset(t, UtxoModel, owner, big.NewInt(1000), nil)
+	if err := uAsset.SetFeeRate(owner, uAddress, rate); err != ErrFeeAssetModel {
+		t.Fatalf("fee rate error mismatch: have %v, want %v", err, ErrFeeAssetModel)
+	}
+}
+
+func TestHTLC(t *testing.T) {
+	sender := common.Address{100}
+	recipient := common.Address{101}
+	preimage := []byte("swap secret")
+	hashLock := types.HTLCHash(preimage)
+	escrow := EscrowAddress(hashLock)
+
+	asset, aAddress := newTestAsset(t, AccountModel, sender, big.NewInt(1000), nil)
+	if err := asset.LockHTLC(sender, aAddress, recipient, big.NewInt(400), hashLock, 10, 10); err != ErrHTLCTimeout {
+		t.Fatalf("lock error mismatch: have %v, want %v", err, ErrHTLCTimeout)
+	}
+	if err := asset.LockHTLC(sender, aAddress, recipient, big.NewInt(400), hashLock, 10, 5); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if err := asset.LockHTLC(sender, aAddress, recipient, big.NewInt(100), hashLock, 10, 5); err != ErrHTLCExists {
+		t.Fatalf("lock error mismatch: have %v, want %v", err, ErrHTLCExists)
+	}
+	if v := asset.GetBalance(escrow, aAddress).(*big.Int); v.Cmp(big.NewInt(400)) != 0 {
+		t.Fatalf("escrow balance mismatch: have %v, want 400", v)
+	}
+	if v := asset.GetBalance(sender, aAddress).(*big.Int); v.Cmp(big.NewInt(600)) != 0 {
+		t.Fatalf("sender balance mismatch: have %v, want 600", v)
+	}
+
+	if _, err := asset.RefundHTLC(hashLock, 9); err != ErrHTLCNotTimedOut {
+		t.Fatalf("refund error mismatch: have %v, want %v", err, ErrHTLCNotTimedOut)
+	}
+	if _, err := asset.ClaimHTLC([]byte("wrong secret"), 9); err != ErrHTLCNotFound {
+		t.Fatalf("claim error mismatch: have %v, want %v", err, ErrHTLCNotFound)
+	}
+	if _, err := asset.ClaimHTLC(preimage, 10); err != ErrHTLCTimeout {
+		t.Fatalf("claim error mismatch: have %v, want %v", err, ErrHTLCTimeout)
+	}
+	htlc, err := asset.ClaimHTLC(preimage, 9)
+	if err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if htlc.State != HTLCClaimed || string(htlc.Preimage) != string(preimage) {
+		t.Fatalf("claimed lock mismatch: %+v", htlc)
+	}
+	if v := asset.GetBalance(recipient, aAddress).(*big.Int); v.Cmp(big.NewInt(400)) != 0 {
+		t.Fatalf("recipient balance mismatch: have %v, want 400", v)
+	}
+	if v := asset.GetBalance(escrow, aAddress).(*big.Int); v.Sign() != 0 {
+		t.Fatalf("escrow balance mismatch: have %v, want 0", v)
+	}
+	if _, err := asset.RefundHTLC(hashLock, 11); err != ErrHTLCSettled {
+		t.Fatalf("refund error mismatch: have %v, want %v", err, ErrHTLCSettled)
+	}
+	if stored, _ := asset.GetHTLC(hashLock); stored.State != HTLCClaimed {
+		t.Fatalf("stored lock mismatch: %+v", stored)
+	}
+
+	// utxo model locks hold the whole output and pay it out as a new one
+	uAsset, uAddress := newTestAsset(t, UtxoModel, sender, big.NewInt(1000), nil)
+	utxos, _ := uAsset.GetUTXOs(sender, uAddress)
+	uLock := types.HTLCHash([]byte("utxo secret"))
+	if err := uAsset.LockHTLC(sender, uAddress, recipient, utxos[0].OutPoint, uLock, 10, 5); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	if v := uAsset.GetBalance(sender, uAddress).(*big.Int); v.Sign() != 0 {
+		t.Fatalf("sender balance mismatch: have %v, want 0", v)
+	}
+	if _, err := uAsset.RefundHTLC(uLock, 10); err != nil {
+		t.Fatalf("Unexpected error : %v", err)
+	}
+	refunded, err := uAsset.GetUTXOs(sender, uAddress)
+	if err != nil || len(refunded) != 1 || refunded[0].Value.Cmp(big.NewInt(1000)) != 0 {
+		t.Fatalf("refunded outputs mismatch: %v, %v", refunded, err)
+	}
+	if _, err := uAsset.ClaimHTLC([]byte("utxo secret"), 9); err != ErrHTLCSettled {
+		t.Fatalf("claim error mismatch: have %v, want %v", err, ErrHTLCSettled)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package asset
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/rlp"
+)
+
+var feeRate = []byte("feerate")
+
+var (
+	// ErrNoFeeRate is returned if fees are paid in an asset without a fee rate.
+	ErrNoFeeRate = errors.New("asset has no fee rate")
+	// ErrInvalidFeeRate is returned if a fee rate has a non positive part.
+	ErrInvalidFeeRate = errors.New("invalid fee rate")
+	// ErrFeeAssetModel is returned if an utxo model asset is given a fee rate.
+	ErrFeeAssetModel = errors.New("only account model assets can pay fees")
+)
+
+// FeeRate converts amounts of an asset into ZIP, Denom units of the asset are
+// worth Num units of ZIP.
+type FeeRate struct {
+	Num   *big.Int
+	Denom *big.Int
+}
+
+// Validate checks that both parts of the rate are positive.
+func (r *FeeRate) Validate() error {
+	if r.Num == nil || r.Denom == nil || r.Num.Sign() <= 0 || r.Denom.Sign() <= 0 {
+		return ErrInvalidFeeRate
+	}
+	return nil
+}
+
+// ToZip converts an amount of the asset into ZIP, rounding down.
+func (r *FeeRate) ToZip(value *big.Int) *big.Int {
+	v := new(big.Int).Mul(value, r.Num)
+	return v.Div(v, r.Denom)
+}
+
+// FromZip converts an amount of ZIP into the asset, rounding up so the asset
+// amount is worth at least value.
+func (r *FeeRate) FromZip(value *big.Int) *big.Int {
+	v := new(big.Int).Mul(value, r.Denom)
+	v.Add(v, r.Num)
+	v.Sub(v, big.NewInt(1))
+	return v.Div(v, r.Num)
+}
+
+func getFeeRate(db StateDB, assetAddr common.Address) (*FeeRate, error) {
+	v := db.GetAccount(assetAddr, assetAddr.String()+string(feeRate))
+	if bytes.Equal(v, []byte{}) {
+		return nil, nil
+	}
+	rate := new(FeeRate)
+	if err := rlp.Decode(bytes.NewReader(v), rate); err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+func setFeeRate(db StateDB, assetAddr common.Address, rate *FeeRate) error {
+	key := assetAddr.String() + string(feeRate)
+	if rate == nil {
+		db.SetAccount(assetAddr, key, []byte{})
+		return nil
+	}
+	b, err := rlp.EncodeToBytes(rate)
+	if err != nil {
+		return err
+	}
+	db.SetAccount(assetAddr, key, b)
+	return nil
+}
+
+// SetFeeRate sets the rate fees paid in the asset are converted to ZIP with,
+// a nil rate stops the asset from paying fees. Either the asset owner or the
+// governance, the owner of ZIP, may set it. Only account model assets can pay
+// fees.
+func (a *Asset) SetFeeRate(ownerAddr common.Address, assetAddr common.Address, rate *FeeRate, cosigners ...common.Address) error {
+	if assetAddr == types.ZipAssetID {
+		return ErrInvalidFeeRate
+	}
+	baseType, err := a.getAssetType(assetAddr)
+	if err != nil {
+		return err
+	}
+	if baseType != AccountModel {
+		return ErrFeeAssetModel
+	}
+	if rate != nil {
+		if err := rate.Validate(); err != nil {
+			return err
+		}
+	}
+	signers := signersOf(ownerAddr, cosigners)
+	if _, err := authorize(a.db, assetAddr, signers); err != nil {
+		if _, err := authorize(a.db, types.ZipAssetID, signers); err != nil {
+			return ErrNotOwner
+		}
+	}
+	return setFeeRate(a.db, assetAddr, rate)
+}
+
+// GetFeeRate returns the fee rate of the asset, ZIP always converts one to
+// one. ErrNoFeeRate is returned if the asset can't pay fees.
+func (a *Asset) GetFeeRate(assetAddr common.Address) (*FeeRate, error) {
+	if assetAddr == types.ZipAssetID {
+		return &FeeRate{Num: big.NewInt(1), Denom: big.NewInt(1)}, nil
+	}
+	rate, err := getFeeRate(a.db, assetAddr)
+	if err != nil {
+		return nil, err
+	}
+	if rate == nil {
+		return nil, ErrNoFeeRate
+	}
+	return rate, nil
+}
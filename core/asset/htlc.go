@@ -0,0 +1,243 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package asset
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/rlp"
+)
+
+var htlcKey = []byte("htlc")
+
+var (
+	// ErrHTLCExists is returned if a hashlock is locked twice. Hashlocks stay
+	// taken after the lock settled, so a revealed secret can't lock again.
+	ErrHTLCExists = errors.New("hashlock already used")
+	// ErrHTLCNotFound is returned if no lock is known for a hashlock.
+	ErrHTLCNotFound = errors.New("hashlock not locked")
+	// ErrHTLCSettled is returned if a lock was already claimed or refunded.
+	ErrHTLCSettled = errors.New("lock already settled")
+	// ErrHTLCTimeout is returned if a lock times out before it could be claimed.
+	ErrHTLCTimeout = errors.New("lock timed out")
+	// ErrHTLCNotTimedOut is returned if a lock is refunded before its timeout.
+	ErrHTLCNotTimedOut = errors.New("lock not timed out")
+)
+
+// HTLC states
+const (
+	HTLCLocked uint8 = iota
+	HTLCClaimed
+	HTLCRefunded
+)
+
+// HTLC is a hash-time-locked transfer. The locked amount is held by the
+// escrow address of the hashlock until the recipient claims it with the
+// preimage or the sender gets it refunded after the timeout.
+type HTLC struct {
+	AssetID   common.Address
+	Sender    common.Address
+	Recipient common.Address
+	Value     *big.Int
+	HashLock  common.Hash
+	Timeout   uint64
+	State     uint8
+	Preimage  []byte
+}
+
+// EscrowAddress returns the address holding the amount locked under the
+// hashlock. No key controls it, only the HTLC actions move its balance.
+func EscrowAddress(hashLock common.Hash) common.Address {
+	return common.BytesToAddress(crypto.Keccak256(htlcKey, hashLock[:])[12:])
+}
+
+// escrowOutPoint is the outpoint of the output an utxo model lock is held
+// in, index 0, or paid out with, index 1.
+func escrowOutPoint(hashLock common.Hash, index uint32) types.OutPoint {
+	return types.OutPoint{TxHash: hashLock, Index: index}
+}
+
+func getHTLC(db StateDB, hashLock common.Hash) (*HTLC, error) {
+	escrow := EscrowAddress(hashLock)
+	v := db.GetAccount(escrow, escrow.String()+string(htlcKey))
+	if bytes.Equal(v, []byte{}) {
+		return nil, nil
+	}
+	htlc := new(HTLC)
+	if err := rlp.Decode(bytes.NewReader(v), htlc); err != nil {
+		return nil, err
+	}
+	return htlc, nil
+}
+
+func setHTLC(db StateDB, htlc *HTLC) error {
+	escrow := EscrowAddress(htlc.HashLock)
+	b, err := rlp.EncodeToBytes(htlc)
+	if err != nil {
+		return err
+	}
+	db.SetAccount(escrow, escrow.String()+string(htlcKey), b)
+	return nil
+}
+
+// GetHTLC returns the lock of the hashlock.
+func (a *Asset) GetHTLC(hashLock common.Hash) (*HTLC, error) {
+	htlc, err := getHTLC(a.db, hashLock)
+	if err != nil {
+		return nil, err
+	}
+	if htlc == nil {
+		return nil, ErrHTLCNotFound
+	}
+	return htlc, nil
+}
+
+// LockHTLC moves value from the sender to the escrow address of the hashlock
+// in the block number. Account model assets lock the *big.Int value, utxo
+// model assets the whole output at the types.OutPoint value. The timeout has
+// to be a later block.
+func (a *Asset) LockHTLC(sender, assetAddr, recipient common.Address, value interface{}, hashLock common.Hash, timeout, number uint64) error {
+	if timeout <= number {
+		return ErrHTLCTimeout
+	}
+	if htlc, err := getHTLC(a.db, hashLock); err != nil {
+		return err
+	} else if htlc != nil {
+		return ErrHTLCExists
+	}
+	baseType, err := a.getAssetType(assetAddr)
+	if err != nil {
+		return err
+	}
+	escrow := EscrowAddress(hashLock)
+	var amount *big.Int
+	switch baseType {
+	case AccountModel:
+		v, ok := value.(*big.Int)
+		if !ok {
+			return errValueType
+		}
+		if v == nil || v.Sign() <= 0 {
+			return ErrNegativeValue
+		}
+		if err := a.SubBalance(sender, assetAddr, v); err != nil {
+			return err
+		}
+		if err := a.AddBalance(escrow, assetAddr, v); err != nil {
+			return err
+		}
+		amount = v
+	case UtxoModel:
+		op, ok := value.(types.OutPoint)
+		if !ok {
+			return errValueType
+		}
+		utxo, err := a.GetUTXO(assetAddr, op)
+		if err != nil {
+			return err
+		}
+		if utxo.LockTime > number {
+			return ErrUTXOLocked
+		}
+		if err := a.SubBalance(sender, assetAddr, op); err != nil {
+			return err
+		}
+		if err := a.AddBalance(escrow, assetAddr, &UTXO{OutPoint: escrowOutPoint(hashLock, 0), Value: utxo.Value}); err != nil {
+			return err
+		}
+		amount = utxo.Value
+	}
+	return setHTLC(a.db, &HTLC{
+		AssetID:   assetAddr,
+		Sender:    sender,
+		Recipient: recipient,
+		Value:     new(big.Int).Set(amount),
+		HashLock:  hashLock,
+		Timeout:   timeout,
+	})
+}
+
+// ClaimHTLC pays the lock of the hashlock of the preimage out to its
+// recipient. Anyone knowing the preimage may claim, before the timeout.
+func (a *Asset) ClaimHTLC(preimage []byte, number uint64) (*HTLC, error) {
+	htlc, err := a.openHTLC(types.HTLCHash(preimage))
+	if err != nil {
+		return nil, err
+	}
+	if number >= htlc.Timeout {
+		return nil, ErrHTLCTimeout
+	}
+	htlc.State, htlc.Preimage = HTLCClaimed, common.CopyBytes(preimage)
+	return htlc, a.settleHTLC(htlc, htlc.Recipient)
+}
+
+// RefundHTLC returns the lock of the hashlock to its sender. Anyone may
+// trigger the refund, from the timeout on.
+func (a *Asset) RefundHTLC(hashLock common.Hash, number uint64) (*HTLC, error) {
+	htlc, err := a.openHTLC(hashLock)
+	if err != nil {
+		return nil, err
+	}
+	if number < htlc.Timeout {
+		return nil, ErrHTLCNotTimedOut
+	}
+	htlc.State = HTLCRefunded
+	return htlc, a.settleHTLC(htlc, htlc.Sender)
+}
+
+func (a *Asset) openHTLC(hashLock common.Hash) (*HTLC, error) {
+	htlc, err := a.GetHTLC(hashLock)
+	if err != nil {
+		return nil, err
+	}
+	if htlc.State != HTLCLocked {
+		return nil, ErrHTLCSettled
+	}
+	return htlc, nil
+}
+
+// settleHTLC moves the locked amount from the escrow address to the payee and
+// records the new state of the lock.
+func (a *Asset) settleHTLC(htlc *HTLC, payee common.Address) error {
+	baseType, err := a.getAssetType(htlc.AssetID)
+	if err != nil {
+		return err
+	}
+	escrow := EscrowAddress(htlc.HashLock)
+	switch baseType {
+	case AccountModel:
+		if err := a.SubBalance(escrow, htlc.AssetID, htlc.Value); err != nil {
+			return err
+		}
+		if err := a.AddBalance(payee, htlc.AssetID, htlc.Value); err != nil {
+			return err
+		}
+	case UtxoModel:
+		if err := a.SubBalance(escrow, htlc.AssetID, escrowOutPoint(htlc.HashLock, 0)); err != nil {
+			return err
+		}
+		if err := a.AddBalance(payee, htlc.AssetID, &UTXO{OutPoint: escrowOutPoint(htlc.HashLock, 1), Value: htlc.Value}); err != nil {
+			return err
+		}
+	}
+	return setHTLC(a.db, htlc)
+}
@@ -0,0 +1,216 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package asset
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/rlp"
+)
+
+var (
+	ownerPolicy   = []byte("policy")
+	accountPolicy = []byte("keyset")
+)
+
+var (
+	// ErrInvalidPolicy is returned if an owner policy can never be satisfied
+	// or lists a key twice.
+	ErrInvalidPolicy = errors.New("invalid owner policy")
+	// ErrAccountSigners is returned if the signers of a transaction don't
+	// meet the key set of the sending account.
+	ErrAccountSigners = errors.New("not enough account signatures")
+)
+
+// OwnerKey is a member of an asset owner policy. A zero weight counts as one.
+type OwnerKey struct {
+	Address common.Address
+	Weight  uint64
+}
+
+// OwnerPolicy lets several keys own an asset together. Privileged operations
+// need the signatures of keys whose weights add up to at least Threshold.
+type OwnerPolicy struct {
+	Keys      []OwnerKey
+	Threshold uint64
+}
+
+func (k OwnerKey) weight() uint64 {
+	if k.Weight == 0 {
+		return 1
+	}
+	return k.Weight
+}
+
+// Validate checks that the policy has distinct keys and a reachable, non zero
+// threshold.
+func (p *OwnerPolicy) Validate() error {
+	if p.Threshold == 0 || len(p.Keys) == 0 {
+		return ErrInvalidPolicy
+	}
+	var total uint64
+	seen := make(map[common.Address]bool)
+	for _, k := range p.Keys {
+		if seen[k.Address] {
+			return ErrInvalidPolicy
+		}
+		seen[k.Address] = true
+		if total+k.weight() < total {
+			return ErrInvalidPolicy
+		}
+		total += k.weight()
+	}
+	if total < p.Threshold {
+		return ErrInvalidPolicy
+	}
+	return nil
+}
+
+// Satisfied returns whether the weights of the signing keys reach the threshold.
+func (p *OwnerPolicy) Satisfied(signers []common.Address) bool {
+	signed := make(map[common.Address]bool)
+	for _, addr := range signers {
+		signed[addr] = true
+	}
+	var weight uint64
+	for _, k := range p.Keys {
+		if signed[k.Address] {
+			weight += k.weight()
+			if weight >= p.Threshold {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func getOwnerPolicy(db StateDB, assetAddr common.Address) (*OwnerPolicy, error) {
+	v := db.GetAccount(assetAddr, assetAddr.String()+string(ownerPolicy))
+	if bytes.Equal(v, []byte{}) {
+		return nil, nil
+	}
+	policy := new(OwnerPolicy)
+	if err := rlp.Decode(bytes.NewReader(v), policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func setOwnerPolicy(db StateDB, assetAddr common.Address, policy *OwnerPolicy) error {
+	key := assetAddr.String() + string(ownerPolicy)
+	if policy == nil {
+		db.SetAccount(assetAddr, key, []byte{})
+		return nil
+	}
+	b, err := rlp.EncodeToBytes(policy)
+	if err != nil {
+		return err
+	}
+	db.SetAccount(assetAddr, key, b)
+	return nil
+}
+
+func signersOf(ownerAddr common.Address, cosigners []common.Address) []common.Address {
+	return append([]common.Address{ownerAddr}, cosigners...)
+}
+
+// authorize checks that the signers may run privileged operations on the
+// asset and returns its owner. Without a policy the owner has to sign,
+// otherwise the policy threshold has to be met.
+func authorize(db StateDB, assetAddr common.Address, signers []common.Address) (common.Address, error) {
+	info, err := getAccountAssetInfo(db, assetAddr)
+	if err != nil {
+		return common.Address{}, err
+	}
+	policy, err := getOwnerPolicy(db, assetAddr)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if policy != nil {
+		if !policy.Satisfied(signers) {
+			return common.Address{}, ErrNotOwner
+		}
+		return info.Owner, nil
+	}
+	for _, addr := range signers {
+		if addr == info.Owner {
+			return info.Owner, nil
+		}
+	}
+	return common.Address{}, ErrNotOwner
+}
+
+// GetAccountPolicy returns the key set of the account, nil if the account key
+// signs alone.
+func (a *Asset) GetAccountPolicy(addr common.Address) (*OwnerPolicy, error) {
+	v := a.db.GetAccount(addr, addr.String()+string(accountPolicy))
+	if bytes.Equal(v, []byte{}) {
+		return nil, nil
+	}
+	policy := new(OwnerPolicy)
+	if err := rlp.Decode(bytes.NewReader(v), policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// AuthorizeAccount checks that the signers of a transaction sent from the
+// account meet its key set. The account key signs the transaction, the
+// other keys of the set co-sign it.
+func (a *Asset) AuthorizeAccount(addr common.Address, cosigners []common.Address) error {
+	policy, err := a.GetAccountPolicy(addr)
+	if err != nil {
+		return err
+	}
+	if policy != nil && !policy.Satisfied(signersOf(addr, cosigners)) {
+		return ErrAccountSigners
+	}
+	return nil
+}
+
+// AuthorizeGovernance checks that the signers are the governance of the
+// chain, which is the owner of ZIP.
+func (a *Asset) AuthorizeGovernance(addr common.Address, cosigners ...common.Address) error {
+	_, err := authorize(a.db, types.ZipAssetID, signersOf(addr, cosigners))
+	return err
+}
+
+// SetAccountPolicy replaces the key set of the account, a nil policy lets the
+// account key sign alone again. The current key set has to authorize the
+// change.
+func (a *Asset) SetAccountPolicy(addr common.Address, policy *OwnerPolicy, cosigners ...common.Address) error {
+	if err := a.AuthorizeAccount(addr, cosigners); err != nil {
+		return err
+	}
+	key := addr.String() + string(accountPolicy)
+	if policy == nil {
+		a.db.SetAccount(addr, key, []byte{})
+		return nil
+	}
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	b, err := rlp.EncodeToBytes(policy)
+	if err != nil {
+		return err
+	}
+	a.db.SetAccount(addr, key, b)
+	return nil
+}
@@ -0,0 +1,200 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package asset
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/rlp"
+)
+
+var (
+	registryCount  = []byte("rcount")
+	registryIndex  = []byte("rindex")
+	registrySymbol = []byte("rsymbol")
+	registryName   = []byte("rname")
+	assetHolders   = []byte("holders")
+)
+
+var (
+	// ErrAssetExist is returned if an asset address is registered twice.
+	ErrAssetExist = errors.New("asset already exist")
+	// ErrSymbolExist is returned if the symbol of a new asset is taken.
+	ErrSymbolExist = errors.New("asset symbol already exist")
+	// ErrEmptySymbol is returned if a new asset has no symbol.
+	ErrEmptySymbol = errors.New("asset symbol empty")
+	// ErrAssetNotFound is returned if a registry lookup has no result.
+	ErrAssetNotFound = errors.New("asset not found")
+)
+
+// RegisteredAsset describes an asset of the global registry.
+type RegisteredAsset struct {
+	Address  common.Address
+	BaseType int
+	Info     AccountAssetInfo
+	Holders  uint64
+}
+
+// The registry is kept in the account of types.AssetRegistry. Assets are
+// indexed by registration order, symbols are unique ignoring case and names
+// map to all assets carrying them.
+func registryKey(prefix []byte, suffix string) string {
+	return types.AssetRegistry.String() + string(prefix) + suffix
+}
+
+func getUint64(db StateDB, addr common.Address, key string) uint64 {
+	var n uint64
+	if v := db.GetAccount(addr, key); !bytes.Equal(v, []byte{}) {
+		if err := rlp.Decode(bytes.NewReader(v), &n); err != nil {
+			return 0
+		}
+	}
+	return n
+}
+
+func setUint64(db StateDB, addr common.Address, key string, n uint64) {
+	if n == 0 {
+		db.SetAccount(addr, key, []byte{})
+		return
+	}
+	b, _ := rlp.EncodeToBytes(n)
+	db.SetAccount(addr, key, b)
+}
+
+// registerAsset adds a new asset to the global registry. It must be called
+// before the asset info is stored.
+func registerAsset(db StateDB, assetAddr common.Address, info *AccountAssetInfo) error {
+	if info.Symbol == "" {
+		return ErrEmptySymbol
+	}
+	if !bytes.Equal(db.GetAccount(assetAddr, assetAddr.String()), []byte{}) {
+		return ErrAssetExist
+	}
+	symbolKey := registryKey(registrySymbol, strings.ToUpper(info.Symbol))
+	if !bytes.Equal(db.GetAccount(types.AssetRegistry, symbolKey), []byte{}) {
+		return ErrSymbolExist
+	}
+	db.SetAccount(types.AssetRegistry, symbolKey, assetAddr.Bytes())
+
+	count := getUint64(db, types.AssetRegistry, registryKey(registryCount, ""))
+	db.SetAccount(types.AssetRegistry, registryKey(registryIndex, strconv.FormatUint(count, 10)), assetAddr.Bytes())
+	setUint64(db, types.AssetRegistry, registryKey(registryCount, ""), count+1)
+
+	nameKey := registryKey(registryName, info.Name)
+	var list []common.Address
+	if v := db.GetAccount(types.AssetRegistry, nameKey); !bytes.Equal(v, []byte{}) {
+		if err := rlp.Decode(bytes.NewReader(v), &list); err != nil {
+			return err
+		}
+	}
+	b, err := rlp.EncodeToBytes(append(list, assetAddr))
+	if err != nil {
+		return err
+	}
+	db.SetAccount(types.AssetRegistry, nameKey, b)
+	return nil
+}
+
+// changeHolders adjusts the number of accounts holding a positive balance.
+func changeHolders(db StateDB, assetAddr common.Address, delta int) {
+	key := assetAddr.String() + string(assetHolders)
+	n := getUint64(db, assetAddr, key)
+	if delta < 0 && n == 0 {
+		return
+	}
+	setUint64(db, assetAddr, key, uint64(int64(n)+int64(delta)))
+}
+
+// AssetCount returns the number of registered assets.
+func (a *Asset) AssetCount() uint64 {
+	return getUint64(a.db, types.AssetRegistry, registryKey(registryCount, ""))
+}
+
+// GetAsset returns the registry entry of an asset.
+func (a *Asset) GetAsset(assetAddr common.Address) (*RegisteredAsset, error) {
+	baseType, err := a.getAssetType(assetAddr)
+	if err != nil {
+		return nil, ErrAssetNotFound
+	}
+	info, err := getAccountAssetInfo(a.db, assetAddr)
+	if err != nil {
+		return nil, err
+	}
+	return &RegisteredAsset{
+		Address:  assetAddr,
+		BaseType: baseType,
+		Info:     info,
+		Holders:  a.GetHolderCount(assetAddr),
+	}, nil
+}
+
+// GetAssets returns at most limit registered assets in registration order,
+// starting at offset.
+func (a *Asset) GetAssets(offset, limit uint64) ([]*RegisteredAsset, error) {
+	count := a.AssetCount()
+	assets := make([]*RegisteredAsset, 0)
+	for i := offset; i < count && uint64(len(assets)) < limit; i++ {
+		v := a.db.GetAccount(types.AssetRegistry, registryKey(registryIndex, strconv.FormatUint(i, 10)))
+		asset, err := a.GetAsset(common.BytesToAddress(v))
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+	return assets, nil
+}
+
+// GetAssetBySymbol looks up an asset by its symbol, ignoring case.
+func (a *Asset) GetAssetBySymbol(symbol string) (*RegisteredAsset, error) {
+	v := a.db.GetAccount(types.AssetRegistry, registryKey(registrySymbol, strings.ToUpper(symbol)))
+	if bytes.Equal(v, []byte{}) {
+		return nil, ErrAssetNotFound
+	}
+	return a.GetAsset(common.BytesToAddress(v))
+}
+
+// GetAssetsByName returns all assets registered under name.
+func (a *Asset) GetAssetsByName(name string) ([]*RegisteredAsset, error) {
+	v := a.db.GetAccount(types.AssetRegistry, registryKey(registryName, name))
+	if bytes.Equal(v, []byte{}) {
+		return nil, ErrAssetNotFound
+	}
+	var list []common.Address
+	if err := rlp.Decode(bytes.NewReader(v), &list); err != nil {
+		return nil, err
+	}
+	assets := make([]*RegisteredAsset, 0, len(list))
+	for _, assetAddr := range list {
+		asset, err := a.GetAsset(assetAddr)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+	return assets, nil
+}
+
+// GetHolderCount returns the number of accounts holding a positive balance
+// of the asset.
+func (a *Asset) GetHolderCount(assetAddr common.Address) uint64 {
+	return getUint64(a.db, assetAddr, assetAddr.String()+string(assetHolders))
+}
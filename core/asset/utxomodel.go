@@ -0,0 +1,279 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package asset
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/rlp"
+)
+
+var (
+	utxoPrefix = []byte("utxo")
+	utxoList   = []byte("ulist")
+	utxoIssued = []byte("uissue")
+)
+
+var (
+	// ErrUTXONotFound is returned if an outpoint is unknown or already spent.
+	ErrUTXONotFound = errors.New("utxo not found")
+	// ErrUTXOExist is returned if an output is created twice.
+	ErrUTXOExist = errors.New("utxo already exist")
+	// ErrUTXOOwner is returned if an output is spent by an account not owning it.
+	ErrUTXOOwner = errors.New("utxo not owned by spender")
+	// ErrUTXOValue is returned if an output carries no positive amount.
+	ErrUTXOValue = errors.New("utxo value must be positive")
+	// ErrUTXOLocked is returned if an output is spent in a block numbered
+	// below its lock time.
+	ErrUTXOLocked = errors.New("utxo is locked")
+)
+
+// UTXO is an unspent output of an utxo model asset. Every output is stored on
+// the asset account under its outpoint, the owner account keeps the list of
+// outpoints it owns together with their sum as balance.
+type UTXO struct {
+	OutPoint types.OutPoint
+	Owner    common.Address
+	Value    *big.Int
+	LockTime uint64
+}
+
+func utxoKey(assetAddr common.Address, op types.OutPoint) string {
+	return assetAddr.String() + string(utxoPrefix) + op.String()
+}
+
+func utxoListKey(owner common.Address, assetAddr common.Address) string {
+	return owner.String() + assetAddr.String() + string(utxoList)
+}
+
+func registerUtxoAsset(db StateDB, accountAddr common.Address, nonce uint64, desc string) (common.Address, error) {
+	var info AccountAssetInfo
+	err := json.Unmarshal([]byte(desc), &info)
+	if err != nil {
+		return common.Address{}, err
+	}
+	total := info.Total
+	if total == nil {
+		total = new(big.Int)
+	}
+	if info.exceedsMaxSupply(total) {
+		return common.Address{}, ErrMaxSupply
+	}
+	info.Total = new(big.Int)
+
+	//save asset info
+	b := new(bytes.Buffer)
+	err = rlp.Encode(b, &info)
+	if err != nil {
+		return common.Address{}, err
+	}
+	assetAddr := crypto.CreateAssetAddress(accountAddr, nonce, info.Name)
+	if err := registerAsset(db, assetAddr, &info); err != nil {
+		return common.Address{}, err
+	}
+	db.SetAccount(assetAddr, assetAddr.String(), b.Bytes())
+	//save base type
+	assetTypeKey := assetAddr.String() + string(assetType)
+	b = new(bytes.Buffer)
+	err = rlp.Encode(b, uint64(UtxoModel))
+	if err != nil {
+		return common.Address{}, err
+	}
+	db.SetAccount(assetAddr, assetTypeKey, b.Bytes())
+	//issue the initial supply to owner as a single output
+	if total.Sign() > 0 {
+		if err := issueUtxoAsset(db, info.Owner, assetAddr, total); err != nil {
+			return common.Address{}, err
+		}
+	}
+	return assetAddr, nil
+}
+
+// issueUtxoAsset creates a new output owned by the asset owner. Issued outputs
+// are not created by a transaction, their outpoint hashes the asset address
+// with the sequence number of the issuance instead.
+func issueUtxoAsset(db StateDB, ownerAddr common.Address, assetAddr common.Address, value *big.Int) error {
+	if value.Sign() <= 0 {
+		return ErrUTXOValue
+	}
+	info, err := getAccountAssetInfo(db, assetAddr)
+	if err != nil {
+		return err
+	}
+	if strings.Compare(info.Owner.String(), ownerAddr.String()) != 0 {
+		return fmt.Errorf("Owner error ")
+	}
+
+	//save asset info
+	info.Total = new(big.Int).Add(info.Total, value)
+	if info.exceedsMaxSupply(info.Total) {
+		return ErrMaxSupply
+	}
+	b := new(bytes.Buffer)
+	err = rlp.Encode(b, &info)
+	if err != nil {
+		return err
+	}
+	db.SetAccount(assetAddr, assetAddr.String(), b.Bytes())
+
+	//bump issuance sequence
+	var seq uint64
+	key := assetAddr.String() + string(utxoIssued)
+	if v := db.GetAccount(assetAddr, key); !bytes.Equal(v, []byte{}) {
+		if err := rlp.Decode(bytes.NewReader(v), &seq); err != nil {
+			return err
+		}
+	}
+	b = new(bytes.Buffer)
+	err = rlp.Encode(b, seq+1)
+	if err != nil {
+		return err
+	}
+	db.SetAccount(assetAddr, key, b.Bytes())
+
+	seqBytes, _ := rlp.EncodeToBytes(seq)
+	utxo := &UTXO{
+		OutPoint: types.OutPoint{TxHash: crypto.Keccak256Hash(assetAddr.Bytes(), seqBytes)},
+		Owner:    ownerAddr,
+		Value:    value,
+	}
+	return addUtxo(db, assetAddr, utxo)
+}
+
+func getUtxo(db StateDB, assetAddr common.Address, op types.OutPoint) (*UTXO, error) {
+	v := db.GetAccount(assetAddr, utxoKey(assetAddr, op))
+	if bytes.Equal(v, []byte{}) {
+		return nil, ErrUTXONotFound
+	}
+	utxo := new(UTXO)
+	if err := rlp.Decode(bytes.NewReader(v), utxo); err != nil {
+		return nil, err
+	}
+	return utxo, nil
+}
+
+func getUtxoList(db StateDB, owner common.Address, assetAddr common.Address) ([]types.OutPoint, error) {
+	var list []types.OutPoint
+	v := db.GetAccount(owner, utxoListKey(owner, assetAddr))
+	if !bytes.Equal(v, []byte{}) {
+		if err := rlp.Decode(bytes.NewReader(v), &list); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+func setUtxoList(db StateDB, owner common.Address, assetAddr common.Address, list []types.OutPoint) error {
+	if len(list) == 0 {
+		db.SetAccount(owner, utxoListKey(owner, assetAddr), []byte{})
+		return nil
+	}
+	b := new(bytes.Buffer)
+	if err := rlp.Encode(b, list); err != nil {
+		return err
+	}
+	db.SetAccount(owner, utxoListKey(owner, assetAddr), b.Bytes())
+	return nil
+}
+
+func getUtxos(db StateDB, owner common.Address, assetAddr common.Address) ([]*UTXO, error) {
+	list, err := getUtxoList(db, owner, assetAddr)
+	if err != nil {
+		return nil, err
+	}
+	utxos := make([]*UTXO, 0, len(list))
+	for _, op := range list {
+		utxo, err := getUtxo(db, assetAddr, op)
+		if err != nil {
+			return nil, err
+		}
+		utxos = append(utxos, utxo)
+	}
+	return utxos, nil
+}
+
+// addUtxo stores a new output and credits it to the owner balance.
+func addUtxo(db StateDB, assetAddr common.Address, utxo *UTXO) error {
+	if utxo.Value == nil || utxo.Value.Sign() <= 0 {
+		return ErrUTXOValue
+	}
+	key := utxoKey(assetAddr, utxo.OutPoint)
+	if !bytes.Equal(db.GetAccount(assetAddr, key), []byte{}) {
+		return ErrUTXOExist
+	}
+	b := new(bytes.Buffer)
+	if err := rlp.Encode(b, utxo); err != nil {
+		return err
+	}
+	db.SetAccount(assetAddr, key, b.Bytes())
+
+	list, err := getUtxoList(db, utxo.Owner, assetAddr)
+	if err != nil {
+		return err
+	}
+	if err := setUtxoList(db, utxo.Owner, assetAddr, append(list, utxo.OutPoint)); err != nil {
+		return err
+	}
+	setAccountList(UtxoModel, db, utxo.Owner, assetAddr)
+	return addAccountlBalance(db, utxo.Owner, assetAddr, utxo.Value)
+}
+
+// spendUtxo removes the output from the set and debits it from the owner balance.
+func spendUtxo(db StateDB, owner common.Address, assetAddr common.Address, op types.OutPoint) error {
+	utxo, err := getUtxo(db, assetAddr, op)
+	if err != nil {
+		return err
+	}
+	if utxo.Owner != owner {
+		return ErrUTXOOwner
+	}
+	db.SetAccount(assetAddr, utxoKey(assetAddr, op), []byte{})
+
+	list, err := getUtxoList(db, owner, assetAddr)
+	if err != nil {
+		return err
+	}
+	for i, o := range list {
+		if o == op {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if err := setUtxoList(db, owner, assetAddr, list); err != nil {
+		return err
+	}
+	return subAccountBalance(db, owner, assetAddr, utxo.Value)
+}
+
+func burnUtxoAsset(db StateDB, holderAddr common.Address, assetAddr common.Address, op types.OutPoint) error {
+	utxo, err := getUtxo(db, assetAddr, op)
+	if err != nil {
+		return err
+	}
+	if err := spendUtxo(db, holderAddr, assetAddr, op); err != nil {
+		return err
+	}
+	return subAssetTotal(db, assetAddr, utxo.Value)
+}
@@ -29,6 +29,7 @@ import (
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/zipper-project/z0/common"
 	"github.com/zipper-project/z0/consensus"
+	"github.com/zipper-project/z0/core/scip"
 	"github.com/zipper-project/z0/core/vm"
 	event "github.com/zipper-project/z0/feed"
 	"github.com/zipper-project/z0/params"
@@ -90,9 +91,10 @@ type BlockChain struct {
 	procInterrupt int32          // interrupt signaler for block processing
 	wg            sync.WaitGroup // chain processing wait group for shutting down
 
-	engine consensus.Engine
-	// processor Processor // block processor interface
-	validator *BlockValidator // block and state validator interface
+	engine    consensus.Engine
+	engines   scip.EngineFactory // consensus engines verifying relayed headers
+	processor Processor          // block processor interface
+	validator *BlockValidator    // block and state validator interface
 	vmConfig  vm.Config
 
 	badBlocks *lru.Cache // Bad block cache
@@ -101,7 +103,7 @@ type BlockChain struct {
 // NewBlockChain returns a fully initialised block chain using information
 // available in the database. It initialises the default  Validator and
 // Processor.
-func NewBlockChain(db zdb.Database, cacheConfig *CacheConfig, chainConfig *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config) (*BlockChain, error) {
+func NewBlockChain(db zdb.Database, cacheConfig *CacheConfig, chainConfig *params.ChainConfig, engine consensus.Engine, engines scip.EngineFactory, vmConfig vm.Config) (*BlockChain, error) {
 	if cacheConfig == nil {
 		cacheConfig = &CacheConfig{
 			TrieNodeLimit: 256 * 1024 * 1024,
@@ -126,12 +128,12 @@ func NewBlockChain(db zdb.Database, cacheConfig *CacheConfig, chainConfig *param
 		blockCache:   blockCache,
 		futureBlocks: futureBlocks,
 		engine:       engine,
+		engines:      engines,
 		vmConfig:     vmConfig,
 		badBlocks:    badBlocks,
 	}
 	bc.validator = NewBlockValidator(chainConfig, bc, engine)
-
-	// bc.SetProcessor(NewStateProcessor(chainConfig, bc, engine))
+	bc.processor = NewStateProcessor(chainConfig, bc, engine, engines)
 
 	var err error
 	bc.hc, err = NewHeaderChain(db, chainConfig, engine, bc.validator, bc.getProcInterrupt)
@@ -305,11 +307,11 @@ func (bc *BlockChain) CurrentFastBlock() *types.Block {
 }
 
 // SetProcessor sets the processor required for making state modifications.
-// func (bc *BlockChain) SetProcessor(processor Processor) {
-// 	bc.procmu.Lock()
-// 	defer bc.procmu.Unlock()
-// 	bc.processor = processor
-// }
+func (bc *BlockChain) SetProcessor(processor Processor) {
+	bc.procmu.Lock()
+	defer bc.procmu.Unlock()
+	bc.processor = processor
+}
 
 // Validator returns the current validator.
 func (bc *BlockChain) Validator() *BlockValidator {
@@ -319,11 +321,11 @@ func (bc *BlockChain) Validator() *BlockValidator {
 }
 
 // Processor returns the current processor.
-// func (bc *BlockChain) Processor() Processor {
-// 	bc.procmu.RLock()
-// 	defer bc.procmu.RUnlock()
-// 	return bc.processor
-// }
+func (bc *BlockChain) Processor() Processor {
+	bc.procmu.RLock()
+	defer bc.procmu.RUnlock()
+	return bc.processor
+}
 
 // State returns a new mutable state based on the current HEAD block.
 func (bc *BlockChain) State() (*state.StateDB, error) {
@@ -1035,17 +1037,13 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			return i, events, coalescedLogs, err
 		}
 		// Process block using the parent state as reference point.
-		// receipts, logs, usedGas, err := bc.processor.Process(block, state, bc.vmConfig)
-		// if err != nil {
-		// 	bc.reportBlock(block, receipts, err)
-		// 	return i, events, coalescedLogs, err
-		// }
-
-		var receipts types.Receipts
-		var usedGas uint64
-		var logs []*types.Log
+		receipts, logs, usedGas, err := bc.Processor().Process(block, state, bc.vmConfig)
+		if err != nil {
+			bc.reportBlock(block, receipts, err)
+			return i, events, coalescedLogs, err
+		}
 		// Validate the state using the default validator
-		err = bc.validator.ValidateState(block, parent, state, receipts, usedGas, true)
+		err = bc.Validator().ValidateState(block, parent, state, receipts, usedGas, true)
 		if err != nil {
 			bc.reportBlock(block, receipts, err)
 			return i, events, coalescedLogs, err
@@ -1082,7 +1080,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 	}
 	// Append a single chain head event if we've progressed the chain
 	if lastCanon != nil && bc.CurrentBlock().Hash() == lastCanon.Hash() {
-		events = append(events, txpool.ChainHeadEvent{lastCanon})
+		events = append(events, txpool.ChainHeadEvent{Block: lastCanon})
 	}
 	return 0, events, coalescedLogs, nil
 }
@@ -1208,6 +1206,15 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 			return fmt.Errorf("Invalid new chain")
 		}
 	}
+	// Finalized blocks are irreversible, refuse to drop any of them
+	if finality, ok := bc.engine.(consensus.Finality); ok {
+		for _, block := range oldChain {
+			if finality.Finalized(bc, block.Header()) {
+				log.Error("Refusing to reorg finalized block", "number", block.Number(), "hash", block.Hash())
+				return ErrFinalizedReorg
+			}
+		}
+	}
 	// Ensure the user sees large reorgs
 	if len(oldChain) > 0 && len(newChain) > 0 {
 		logFn := log.Debug
@@ -1443,6 +1450,16 @@ func (bc *BlockChain) Config() *params.ChainConfig { return bc.chainConfig }
 // Engine retrieves the blockchain's consensus engine.
 func (bc *BlockChain) Engine() consensus.Engine { return bc.engine }
 
+// EngineFactory retrieves the factory of the consensus engines verifying the
+// headers relayed from other chains.
+func (bc *BlockChain) EngineFactory() scip.EngineFactory { return bc.engines }
+
+// CalcBaseFee returns the base fee of the block following parent under the
+// chain configuration, see CalcBaseFee.
+func (bc *BlockChain) CalcBaseFee(parent *types.Header) *big.Int {
+	return CalcBaseFee(bc.chainConfig, parent)
+}
+
 // SubscribeRemovedLogsEvent registers a subscription of RemovedLogsEvent.
 func (bc *BlockChain) SubscribeRemovedLogsEvent(ch chan<- RemovedLogsEvent) event.Subscription {
 	return bc.scope.Track(bc.rmLogsFeed.Subscribe(ch))
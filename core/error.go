@@ -21,6 +21,7 @@ import (
 	"fmt"
 
 	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core/asset"
 )
 
 var (
@@ -41,10 +42,22 @@ var (
 	// plus one.
 	ErrInvalidNumber = errors.New("invalid block number")
 
+	// ErrFinalizedReorg is returned when a reorganisation would drop a block the
+	// consensus engine has finalized.
+	ErrFinalizedReorg = errors.New("reorg of finalized block")
+
+	// ErrNoEngine is returned when importing blocks of a chain whose config
+	// doesn't select a consensus engine, such a chain has only its genesis.
+	ErrNoEngine = errors.New("no consensus engine to verify the block")
+
 	ErrNoGenesis = errors.New("Genesis not found in chain")
 
 	errGenesisNoConfig = errors.New("genesis has no chain configuration")
 
+	errGenesisNoChainID = errors.New("genesis chain configuration has no chain id")
+
+	errGenesisBaseFee = errors.New("genesis base fee needs a positive initial fee, elasticity and denominator")
+
 	// ErrKnownBlock is returned when a block to import is already known locally.
 	ErrKnownBlock = errors.New("block already known")
 
@@ -59,6 +72,46 @@ var (
 	// next one expected based on the local chain.
 	ErrNonceTooHigh = errors.New("nonce too high")
 
+	// ErrNonceTooLow is returned if the nonce of a transaction is lower than the
+	// one present in the local chain.
+	ErrNonceTooLow = errors.New("nonce too low")
+
+	// ErrInsufficientFundsForGas is returned if the sender can't pay for the
+	// gas limit of a transaction at the given gas price.
+	ErrInsufficientFundsForGas = errors.New("insufficient ZIP balance to pay for gas")
+
+	// ErrIntrinsicGas is returned if the transaction is specified to use less gas
+	// than required to start the invocation.
+	ErrIntrinsicGas = errors.New("intrinsic gas too low")
+
+	// ErrNegativeValue is returned if a transaction output carries a negative
+	// amount of an asset.
+	ErrNegativeValue = errors.New("negative value")
+
+	// ErrUTXOLocked is returned if a transaction spends an unspent output in a
+	// block numbered below the lock time of the output.
+	ErrUTXOLocked = asset.ErrUTXOLocked
+
+	// ErrUTXOUnbalanced is returned if the outputs of an utxo model asset don't
+	// add up to the amount spent by the inputs of the same asset.
+	ErrUTXOUnbalanced = errors.New("utxo inputs and outputs unbalanced")
+
+	// ErrTxNotYetValid is returned if a transaction is included into a block
+	// before its validity window opened.
+	ErrTxNotYetValid = errors.New("transaction not yet valid")
+
+	// ErrTxExpired is returned if a transaction is included into a block after
+	// its validity window closed.
+	ErrTxExpired = errors.New("transaction expired")
+
+	// ErrTipAboveFeeCap is returned if the tip of a transaction is higher than
+	// its gas price, which caps the base fee and the tip together.
+	ErrTipAboveFeeCap = errors.New("tip higher than gas price")
+
+	// ErrFeeCapTooLow is returned if the gas price of a transaction doesn't
+	// cover the base fee of the block it is included into.
+	ErrFeeCapTooLow = errors.New("gas price below base fee")
+
 	errZeroBlockTime = errors.New("timestamp equals parent's")
 )
 
@@ -66,8 +119,19 @@ var (
 // genesis block with an incompatible one.
 type GenesisMismatchError struct {
 	Stored, New common.Hash
+
+	// StoredGenesis and NewGenesis are the specifications of both blocks, the
+	// error lists the fields they differ in if both are known.
+	StoredGenesis, NewGenesis *Genesis
 }
 
 func (e *GenesisMismatchError) Error() string {
-	return fmt.Sprintf("database already contains an incompatible genesis block (have %x, new %x)", e.Stored[:8], e.New[:8])
+	msg := fmt.Sprintf("database already contains an incompatible genesis block (have %x, new %x)", e.Stored[:8], e.New[:8])
+	if e.StoredGenesis == nil || e.NewGenesis == nil {
+		return msg
+	}
+	for _, line := range genesisDiff(e.StoredGenesis, e.NewGenesis) {
+		msg += "\n  " + line
+	}
+	return msg
 }
@@ -0,0 +1,104 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/consensus"
+	"github.com/zipper-project/z0/core/asset"
+	"github.com/zipper-project/z0/core/vm"
+	"github.com/zipper-project/z0/types"
+)
+
+// ChainContext supports retrieving headers and consensus parameters from the
+// current blockchain to be used during transaction processing.
+type ChainContext interface {
+	// Engine retrieves the chain's consensus engine.
+	Engine() consensus.Engine
+
+	// GetHeader returns the hash corresponding to their hash.
+	GetHeader(common.Hash, uint64) *types.Header
+}
+
+// NewEVMContext creates a new context for use in the EVM.
+func NewEVMContext(origin common.Address, gasPrice *big.Int, header *types.Header, chain ChainContext) vm.Context {
+	var baseFee *big.Int
+	if header.BaseFee != nil && header.BaseFee.Sign() > 0 {
+		baseFee = new(big.Int).Set(header.BaseFee)
+	}
+	return vm.Context{
+		CanTransfer: CanTransfer,
+		Transfer:    Transfer,
+		GetHash:     GetHashFn(header, chain),
+		Origin:      origin,
+		Coinbase:    header.Coinbase,
+		BlockNumber: new(big.Int).Set(header.Number),
+		Time:        new(big.Int).Set(header.Time),
+		Difficulty:  new(big.Int).Set(header.Difficulty),
+		GasLimit:    header.GasLimit,
+		GasPrice:    new(big.Int).Set(gasPrice),
+		BaseFee:     baseFee,
+	}
+}
+
+// GetHashFn returns a GetHashFunc which retrieves header hashes by number
+func GetHashFn(ref *types.Header, chain ChainContext) func(n uint64) common.Hash {
+	var cache map[uint64]common.Hash
+
+	return func(n uint64) common.Hash {
+		// If there's no hash cache yet, make one
+		if cache == nil {
+			cache = map[uint64]common.Hash{
+				ref.Number.Uint64() - 1: ref.ParentHash,
+			}
+		}
+		// Try to fulfill the request from the cache
+		if hash, ok := cache[n]; ok {
+			return hash
+		}
+		// Not cached, iterate the blocks and cache the hashes
+		for header := chain.GetHeader(ref.ParentHash, ref.Number.Uint64()-1); header != nil; header = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1) {
+			cache[header.Number.Uint64()-1] = header.ParentHash
+			if n == header.Number.Uint64()-1 {
+				return header.ParentHash
+			}
+		}
+		return common.Hash{}
+	}
+}
+
+// CanTransfer checks whether there are enough funds in the address' account
+// to make a transfer of the asset. Moving nothing is always possible.
+func CanTransfer(db vm.StateDB, addr, assetID common.Address, amount *big.Int) bool {
+	if amount == nil || amount.Sign() == 0 {
+		return true
+	}
+	enough, err := asset.NewAsset(db).EnoughBalance(addr, assetID, amount)
+	return err == nil && enough
+}
+
+// Transfer subtracts amount of the asset from sender and adds amount to
+// recipient using the given Db
+func Transfer(db vm.StateDB, sender, recipient, assetID common.Address, amount *big.Int) error {
+	a := asset.NewAsset(db)
+	if err := a.SubBalance(sender, assetID, amount); err != nil {
+		return err
+	}
+	return a.AddBalance(recipient, assetID, amount)
+}
@@ -17,12 +17,18 @@
 package core
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"math/big"
+	"sort"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core/asset"
+	"github.com/zipper-project/z0/crypto"
 	"github.com/zipper-project/z0/params"
 	"github.com/zipper-project/z0/rawdb"
 	"github.com/zipper-project/z0/state"
@@ -41,6 +47,45 @@ type Genesis struct {
 	Difficulty *big.Int            `json:"difficulty" `
 	Mixhash    common.Hash         `json:"mixHash"`
 	Coinbase   common.Address      `json:"coinbase"`
+	Zip        *GenesisZip         `json:"zip"`
+	Assets     []GenesisAsset      `json:"assets"`
+	Alloc      GenesisAlloc        `json:"alloc"`
+}
+
+// GenesisZip configures the native ZIP asset. The total is credited to the
+// owner, the ZIP allocations are paid out of it.
+type GenesisZip struct {
+	Total    *big.Int       `json:"total"`
+	Decimals uint64         `json:"decimals"`
+	Owner    common.Address `json:"owner"`
+}
+
+// GenesisAsset is an asset registered in the genesis state. The total is
+// credited to the owner, the allocations of the asset are paid out of it.
+// Only account model assets can be allocated.
+type GenesisAsset struct {
+	BaseType  int            `json:"baseType"`
+	Name      string         `json:"name"`
+	Symbol    string         `json:"symbol"`
+	Total     *big.Int       `json:"total"`
+	Decimals  uint64         `json:"decimals"`
+	Owner     common.Address `json:"owner"`
+	MaxSupply *big.Int       `json:"maxSupply"`
+}
+
+// Address returns the address of the asset, it is registered by the owner at
+// nonce zero.
+func (ga *GenesisAsset) Address() common.Address {
+	return crypto.CreateAssetAddress(ga.Owner, 0, ga.Name)
+}
+
+// GenesisAlloc specifies the balances that are part of the genesis block.
+type GenesisAlloc map[common.Address]GenesisAccount
+
+// GenesisAccount is an account in the genesis state, its balances are keyed by
+// asset symbol, ZIP included.
+type GenesisAccount struct {
+	Balances map[string]*big.Int `json:"balances"`
 }
 
 // The returned chain configuration is never nil.
@@ -59,14 +104,22 @@ func SetupGenesisBlock(db zdb.Database, genesis *Genesis) (*params.ChainConfig,
 			log.Info("Writing custom genesis block")
 		}
 		block, err := genesis.Commit(db)
-		return genesis.Config, block.Hash(), err
+		if err != nil {
+			return genesis.Config, common.Hash{}, err
+		}
+		return genesis.Config, block.Hash(), nil
 	}
 
 	// Check whether the genesis block is already written.
 	if genesis != nil {
-		hash := genesis.ToBlock(nil).Hash()
+		block, err := genesis.ToBlock(nil)
+		if err != nil {
+			return genesis.Config, common.Hash{}, err
+		}
+		hash := block.Hash()
 		if hash != stored {
-			return genesis.Config, hash, &GenesisMismatchError{stored, hash}
+			storedGenesis, _ := readGenesis(db, stored)
+			return genesis.Config, hash, &GenesisMismatchError{Stored: stored, New: hash, StoredGenesis: storedGenesis, NewGenesis: genesis}
 		}
 	}
 
@@ -91,18 +144,83 @@ func SetupGenesisBlock(db zdb.Database, genesis *Genesis) (*params.ChainConfig,
 	if height == nil {
 		return newcfg, stored, fmt.Errorf("missing block number for head header hash")
 	}
-
+	compatErr := storedcfg.CheckCompatible(newcfg, *height)
+	if compatErr != nil && *height != 0 {
+		return newcfg, stored, compatErr
+	}
 	rawdb.WriteChainConfig(db, stored, newcfg)
 	return newcfg, stored, nil
 }
 
+// Validate checks a genesis specification before it is committed. The chain
+// configuration needs a chain id, the gas limit has to reach
+// params.MinGasLimit and the allocations have to be paid in known account
+// model assets out of their total supply.
+func (g *Genesis) Validate() error {
+	if g.Config == nil {
+		return errGenesisNoConfig
+	}
+	if g.Config.ChainID == nil || g.Config.ChainID.Sign() <= 0 {
+		return errGenesisNoChainID
+	}
+	if g.GasLimit < params.MinGasLimit {
+		return fmt.Errorf("genesis gas limit %d below minimum %d", g.GasLimit, params.MinGasLimit)
+	}
+	if fee := g.Config.BaseFee; fee != nil {
+		if fee.Initial == nil || fee.Initial.Sign() <= 0 || fee.Elasticity == 0 || fee.Denominator == 0 {
+			return errGenesisBaseFee
+		}
+	}
+
+	baseTypes := make(map[string]int)
+	if g.Zip != nil {
+		baseTypes["ZIP"] = asset.AccountModel
+	}
+	for _, ga := range g.Assets {
+		symbol := strings.ToUpper(ga.Symbol)
+		if symbol == "" {
+			return fmt.Errorf("genesis asset %s: %v", ga.Name, asset.ErrEmptySymbol)
+		}
+		if _, ok := baseTypes[symbol]; ok {
+			return fmt.Errorf("genesis asset %s: %v", ga.Symbol, asset.ErrSymbolExist)
+		}
+		if ga.Total != nil && ga.Total.Sign() < 0 {
+			return fmt.Errorf("genesis asset %s: %v", ga.Symbol, ErrNegativeValue)
+		}
+		baseTypes[symbol] = ga.BaseType
+	}
+	for addr, account := range g.Alloc {
+		for symbol, value := range account.Balances {
+			if value == nil || value.Sign() < 0 {
+				return fmt.Errorf("genesis alloc of %s to %x: %v", symbol, addr, ErrNegativeValue)
+			}
+			baseType, ok := baseTypes[strings.ToUpper(symbol)]
+			if !ok {
+				return fmt.Errorf("genesis alloc of %s to %x: %v", symbol, addr, asset.ErrAssetNotFound)
+			}
+			if baseType != asset.AccountModel {
+				return fmt.Errorf("genesis alloc of %s to %x: only account model assets can be allocated", symbol, addr)
+			}
+		}
+	}
+	// Supply caps and over allocations are caught writing the state
+	_, err := g.ToBlock(nil)
+	return err
+}
+
 // ToBlock creates the genesis block and writes state of a genesis specification
 // to the given database (or discards it if nil).
-func (g *Genesis) ToBlock(db zdb.Database) *types.Block {
+func (g *Genesis) ToBlock(db zdb.Database) (*types.Block, error) {
 	if db == nil {
 		db = zdb.NewMemDatabase()
 	}
-	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(db))
+	if err != nil {
+		return nil, err
+	}
+	if err := g.writeAssets(statedb); err != nil {
+		return nil, err
+	}
 
 	root := statedb.IntermediateRoot(false)
 	head := &types.Header{
@@ -118,17 +236,96 @@ func (g *Genesis) ToBlock(db zdb.Database) *types.Block {
 		Coinbase:   g.Coinbase,
 		Root:       root,
 	}
+	if g.Config != nil && g.Config.IsBaseFee(head.Number) {
+		head.BaseFee = new(big.Int).Set(g.Config.BaseFeeParams().Initial)
+	}
 
 	statedb.Commit(false)
 	statedb.Database().TrieDB().Commit(root, true)
 
-	return types.NewBlock(head, nil, nil, nil)
+	return types.NewBlock(head, nil, nil, nil), nil
+}
+
+// writeAssets writes ZIP, the pre-registered assets and the allocations into
+// the genesis state. Accounts and balances are written in a fixed order so
+// the state root doesn't depend on map iteration.
+func (g *Genesis) writeAssets(statedb *state.StateDB) error {
+	if g.Zip != nil {
+		total := g.Zip.Total
+		if total == nil {
+			total = new(big.Int)
+		}
+		if err := asset.InitZip(statedb, total, g.Zip.Decimals, g.Zip.Owner); err != nil {
+			return fmt.Errorf("genesis ZIP: %v", err)
+		}
+	}
+
+	a := asset.NewAsset(statedb)
+	for _, ga := range g.Assets {
+		if ga.BaseType != asset.AccountModel && ga.BaseType != asset.UtxoModel {
+			return fmt.Errorf("genesis asset %s: unknown base type %d", ga.Symbol, ga.BaseType)
+		}
+		desc, err := json.Marshal(&asset.AccountAssetInfo{
+			Name:      ga.Name,
+			Symbol:    ga.Symbol,
+			Total:     ga.Total,
+			Decimals:  ga.Decimals,
+			Owner:     ga.Owner,
+			MaxSupply: ga.MaxSupply,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := a.RegisterAsset(ga.BaseType, ga.Owner, string(desc)); err != nil {
+			return fmt.Errorf("genesis asset %s: %v", ga.Symbol, err)
+		}
+	}
+
+	addrs := make([]common.Address, 0, len(g.Alloc))
+	for addr := range g.Alloc {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+	for _, addr := range addrs {
+		balances := g.Alloc[addr].Balances
+		symbols := make([]string, 0, len(balances))
+		for symbol := range balances {
+			symbols = append(symbols, symbol)
+		}
+		sort.Strings(symbols)
+		for _, symbol := range symbols {
+			value := balances[symbol]
+			if value == nil || value.Sign() == 0 {
+				continue
+			}
+			if value.Sign() < 0 {
+				return fmt.Errorf("genesis alloc of %s to %x: %v", symbol, addr, ErrNegativeValue)
+			}
+			registered, err := a.GetAssetBySymbol(symbol)
+			if err != nil {
+				return fmt.Errorf("genesis alloc of %s to %x: %v", symbol, addr, err)
+			}
+			if registered.BaseType != asset.AccountModel {
+				return fmt.Errorf("genesis alloc of %s to %x: only account model assets can be allocated", symbol, addr)
+			}
+			if err := a.SubBalance(registered.Info.Owner, registered.Address, value); err != nil {
+				return fmt.Errorf("genesis alloc of %s to %x: %v", symbol, addr, err)
+			}
+			if err := a.AddBalance(addr, registered.Address, value); err != nil {
+				return fmt.Errorf("genesis alloc of %s to %x: %v", symbol, addr, err)
+			}
+		}
+	}
+	return nil
 }
 
 // Commit writes the block and state of a genesis specification to the database.
 // The block is committed as the canonical head block.
 func (g *Genesis) Commit(db zdb.Database) (*types.Block, error) {
-	block := g.ToBlock(db)
+	block, err := g.ToBlock(db)
+	if err != nil {
+		return nil, err
+	}
 	if block.Number().Sign() != 0 {
 		return nil, fmt.Errorf("can't commit genesis block with number > 0")
 	}
@@ -144,9 +341,124 @@ func (g *Genesis) Commit(db zdb.Database) (*types.Block, error) {
 		config = params.DefaultChainconfig
 	}
 	rawdb.WriteChainConfig(db, block.Hash(), config)
+
+	spec, err := json.Marshal(g)
+	if err != nil {
+		return nil, err
+	}
+	rawdb.WriteGenesis(db, block.Hash(), spec)
 	return block, nil
 }
 
+// ReadGenesis returns the genesis specification of the chain stored in db.
+func ReadGenesis(db zdb.Database) (*Genesis, error) {
+	stored := rawdb.ReadCanonicalHash(db, 0)
+	if (stored == common.Hash{}) {
+		return nil, ErrNoGenesis
+	}
+	return readGenesis(db, stored)
+}
+
+// readGenesis returns the genesis specification of the genesis block with the
+// given hash along with the current chain configuration. Blocks committed
+// without their specification are described by their header only.
+func readGenesis(db zdb.Database, hash common.Hash) (*Genesis, error) {
+	genesis := new(Genesis)
+	if spec := rawdb.ReadGenesis(db, hash); len(spec) > 0 {
+		if err := json.Unmarshal(spec, genesis); err != nil {
+			return nil, err
+		}
+	} else {
+		header := rawdb.ReadHeader(db, hash, 0)
+		if header == nil {
+			return nil, ErrNoGenesis
+		}
+		genesis = &Genesis{
+			Nonce:      header.Nonce.Uint64(),
+			Timestamp:  header.Time.Uint64(),
+			ExtraData:  header.Extra,
+			GasLimit:   header.GasLimit,
+			Difficulty: header.Difficulty,
+			Mixhash:    header.MixDigest,
+			Coinbase:   header.Coinbase,
+		}
+	}
+	if config := rawdb.ReadChainConfig(db, hash); config != nil {
+		genesis.Config = config
+	}
+	return genesis, nil
+}
+
+// genesisDiff lists the JSON fields two genesis specifications differ in, in
+// the form "path: have <stored>, new <new>".
+func genesisDiff(stored, next *Genesis) []string {
+	have, want := flattenGenesis(stored), flattenGenesis(next)
+	paths := make([]string, 0, len(have)+len(want))
+	for path := range have {
+		paths = append(paths, path)
+	}
+	for path := range want {
+		if _, ok := have[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var diff []string
+	for _, path := range paths {
+		a, aok := have[path]
+		b, bok := want[path]
+		if aok && bok && a == b {
+			continue
+		}
+		if !aok {
+			a = "<missing>"
+		}
+		if !bok {
+			b = "<missing>"
+		}
+		diff = append(diff, fmt.Sprintf("%s: have %s, new %s", path, a, b))
+	}
+	return diff
+}
+
+// flattenGenesis maps the path of every JSON leaf value of the genesis to its
+// encoding.
+func flattenGenesis(g *Genesis) map[string]string {
+	leaves := make(map[string]string)
+	enc, err := json.Marshal(g)
+	if err != nil {
+		return leaves
+	}
+	dec := json.NewDecoder(bytes.NewReader(enc))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return leaves
+	}
+	var flatten func(path string, v interface{})
+	flatten = func(path string, v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for key, child := range v {
+				if path != "" {
+					key = path + "." + key
+				}
+				flatten(key, child)
+			}
+		case []interface{}:
+			for i, child := range v {
+				flatten(fmt.Sprintf("%s[%d]", path, i), child)
+			}
+		default:
+			b, _ := json.Marshal(v)
+			leaves[path] = string(b)
+		}
+	}
+	flatten("", v)
+	return leaves
+}
+
 func (g *Genesis) configOrDefault(ghash common.Hash) *params.ChainConfig {
 	if g != nil {
 		return g.Config
@@ -162,5 +474,39 @@ func DefaultGenesisBlock() *Genesis {
 		ExtraData:  hexutil.MustDecode(hexutil.Encode([]byte("Z0 Genesis Block"))),
 		GasLimit:   5000,
 		Difficulty: big.NewInt(0),
+		Zip: &GenesisZip{
+			Total:    new(big.Int).Mul(big.NewInt(1e9), big.NewInt(params.Ziper)),
+			Decimals: 18,
+			Owner:    types.ZipAccount,
+		},
+	}
+}
+
+// DeveloperGenesisBlock returns the genesis block of an ephemeral developer
+// chain. The faucet is the only proof-of-authority signer, sealing a block
+// every period seconds or, with a zero period, for every transaction. It owns
+// the ZIP supply and the sample assets GLD and SLV.
+func DeveloperGenesisBlock(period uint64, faucet common.Address) *Genesis {
+	// The signer list sits between the 32 byte vanity and the 65 byte seal
+	extra := append(make([]byte, 32), faucet[:]...)
+	extra = append(extra, make([]byte, 65)...)
+
+	return &Genesis{
+		Config: &params.ChainConfig{
+			ChainID: big.NewInt(1337),
+			PoA:     &params.PoAConfig{Period: period, Epoch: 30000},
+		},
+		ExtraData:  extra,
+		GasLimit:   params.GenesisGasLimit,
+		Difficulty: big.NewInt(1),
+		Zip: &GenesisZip{
+			Total:    new(big.Int).Mul(big.NewInt(1e9), big.NewInt(params.Ziper)),
+			Decimals: 18,
+			Owner:    faucet,
+		},
+		Assets: []GenesisAsset{
+			{Name: "gold", Symbol: "GLD", Total: new(big.Int).Mul(big.NewInt(1e6), big.NewInt(1e8)), Decimals: 8, Owner: faucet},
+			{Name: "silver", Symbol: "SLV", Total: new(big.Int).Mul(big.NewInt(1e9), big.NewInt(1e8)), Decimals: 8, Owner: faucet},
+		},
 	}
 }
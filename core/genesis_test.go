@@ -19,24 +19,49 @@ package core
 import (
 	"math/big"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core/asset"
 	"github.com/zipper-project/z0/params"
 	"github.com/zipper-project/z0/rawdb"
+	"github.com/zipper-project/z0/state"
+	"github.com/zipper-project/z0/types"
 	"github.com/zipper-project/z0/utils/zdb"
 )
 
-var defaultgenesisBlockHash = common.HexToHash("0x2d822ba40ca236335bf1d662742da2f6e1fc6fc6794802f107a94b915f9d8a03")
+var defaultgenesisBlockHash = common.HexToHash("0x603e108a01a0f4abaab7a5f2b749b1a168adac0682ef41edbcbc71d49ae5dbfd")
 
 func TestDefaultGenesisBlock(t *testing.T) {
-	block := DefaultGenesisBlock().ToBlock(nil)
+	block, err := DefaultGenesisBlock().ToBlock(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if block.Hash() != defaultgenesisBlockHash {
 		t.Errorf("wrong mainnet genesis hash, got %v, want %v", block.Hash(), defaultgenesisBlockHash)
 	}
 }
 
+func TestGenesisBaseFee(t *testing.T) {
+	genesis := DefaultGenesisBlock()
+	config := *genesis.Config
+	config.BaseFeeBlock = big.NewInt(0)
+	genesis.Config = &config
+
+	block, err := genesis.ToBlock(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee := block.BaseFee(); fee == nil || fee.Int64() != params.InitialBaseFee {
+		t.Errorf("genesis base fee mismatch: have %v, want %d", fee, params.InitialBaseFee)
+	}
+	if block.Hash() == defaultgenesisBlockHash {
+		t.Errorf("base fee not part of the genesis hash")
+	}
+}
+
 func TestSetupGenesis(t *testing.T) {
 	var (
 		customghash = common.HexToHash("0x7b48798ea589c2889ece8070030c30a1efec1e95a9e6aaaab8bd0ec655cfad9d")
@@ -44,8 +69,12 @@ func TestSetupGenesis(t *testing.T) {
 			Config: &params.ChainConfig{ChainID: big.NewInt(3)},
 		}
 		oldcustomg = customg
+		forkedg    = customg
+		sealedg    = customg
 	)
 	oldcustomg.Config = &params.ChainConfig{ChainID: big.NewInt(2)}
+	forkedg.Config = &params.ChainConfig{ChainID: big.NewInt(3), RepricingBlock: big.NewInt(2)}
+	sealedg.Config = &params.ChainConfig{ChainID: big.NewInt(3), PoA: &params.PoAConfig{Period: 5, Epoch: 100}}
 
 	tests := []struct {
 		name       string
@@ -97,6 +126,46 @@ func TestSetupGenesis(t *testing.T) {
 			wantHash:   customghash,
 			wantConfig: customg.Config,
 		},
+		{
+			name: "incompatible config in DB",
+			fn: func(db zdb.Database) (*params.ChainConfig, common.Hash, error) {
+				// Commit the 'old' genesis block with no forks and pretend
+				// the chain is at block 4, past the new repricing fork.
+				oldcustomg.Commit(db)
+				head := &types.Header{Number: big.NewInt(4), Difficulty: big.NewInt(1), Time: big.NewInt(0)}
+				rawdb.WriteHeader(db, head)
+				rawdb.WriteHeadHeaderHash(db, head.Hash())
+				return SetupGenesisBlock(db, &forkedg)
+			},
+			wantHash:   customghash,
+			wantConfig: forkedg.Config,
+			wantErr: &params.ConfigCompatError{
+				What:         "Repricing fork block",
+				StoredConfig: nil,
+				NewConfig:    big.NewInt(2),
+				RewindTo:     1,
+			},
+		},
+		{
+			name: "changed consensus engine in DB",
+			fn: func(db zdb.Database) (*params.ChainConfig, common.Hash, error) {
+				// The blocks of the chain weren't sealed by the new engine,
+				// it can only start over from the genesis.
+				oldcustomg.Commit(db)
+				head := &types.Header{Number: big.NewInt(4), Difficulty: big.NewInt(1), Time: big.NewInt(0)}
+				rawdb.WriteHeader(db, head)
+				rawdb.WriteHeadHeaderHash(db, head.Hash())
+				return SetupGenesisBlock(db, &sealedg)
+			},
+			wantHash:   customghash,
+			wantConfig: sealedg.Config,
+			wantErr: &params.ConfigCompatError{
+				What:         "Consensus engine config",
+				StoredConfig: big.NewInt(1),
+				NewConfig:    big.NewInt(1),
+				RewindTo:     0,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -121,3 +190,168 @@ func TestSetupGenesis(t *testing.T) {
 		}
 	}
 }
+
+var allocGenesisHash = common.HexToHash("0xb184088177eb9ff98a510f80c0c2506090382ac8879e6c963a9e820d7297956a")
+
+func TestGenesisAlloc(t *testing.T) {
+	var (
+		owner  = common.Address{0x10}
+		funded = common.Address{0x11}
+		other  = common.Address{0x12}
+	)
+	genesis := &Genesis{
+		Config: &params.ChainConfig{ChainID: big.NewInt(3)},
+		Zip:    &GenesisZip{Total: big.NewInt(1000000), Decimals: 8, Owner: owner},
+		Assets: []GenesisAsset{
+			{Name: "gold", Symbol: "GLD", Total: big.NewInt(500), Decimals: 2, Owner: owner, MaxSupply: big.NewInt(1000)},
+			{BaseType: asset.UtxoModel, Name: "coin", Symbol: "CON", Total: big.NewInt(100), Owner: other},
+		},
+		Alloc: GenesisAlloc{
+			funded: {Balances: map[string]*big.Int{"ZIP": big.NewInt(1000), "gld": big.NewInt(200)}},
+			other:  {Balances: map[string]*big.Int{"ZIP": big.NewInt(10)}},
+		},
+	}
+	db := zdb.NewMemDatabase()
+	block, err := genesis.Commit(db)
+	if err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+	// The allocations are part of the state root
+	empty, _ := (&Genesis{Config: genesis.Config}).ToBlock(nil)
+	if block.Root() == empty.Root() {
+		t.Fatalf("allocations missing from the state root")
+	}
+	if block.Hash() != allocGenesisHash {
+		t.Fatalf("wrong genesis hash, got %s, want %s", block.Hash().Hex(), allocGenesisHash.Hex())
+	}
+	for i := 0; i < 5; i++ {
+		again, err := genesis.ToBlock(nil)
+		if err != nil || again.Hash() != block.Hash() {
+			t.Fatalf("genesis hash not deterministic: %x != %x, %v", again.Hash(), block.Hash(), err)
+		}
+	}
+
+	statedb, err := state.New(block.Root(), state.NewDatabase(db))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := asset.NewAsset(statedb)
+	gld := genesis.Assets[0].Address()
+	checkBalance(t, a, owner, types.ZipAssetID, big.NewInt(1000000-1010))
+	checkBalance(t, a, funded, types.ZipAssetID, big.NewInt(1000))
+	checkBalance(t, a, other, types.ZipAssetID, big.NewInt(10))
+	checkBalance(t, a, owner, gld, big.NewInt(300))
+	checkBalance(t, a, funded, gld, big.NewInt(200))
+	if registered, err := a.GetAssetBySymbol("GLD"); err != nil || registered.Address != gld || registered.Info.MaxSupply.Cmp(big.NewInt(1000)) != 0 {
+		t.Fatalf("registered asset mismatch: %v, %v", registered, err)
+	}
+	if utxos, err := a.GetUTXOs(other, genesis.Assets[1].Address()); err != nil || len(utxos) != 1 || utxos[0].Value.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("utxo asset mismatch: %v, %v", utxos, err)
+	}
+
+	// Invalid allocations are rejected
+	for i, alloc := range []GenesisAlloc{
+		{funded: {Balances: map[string]*big.Int{"BTC": big.NewInt(1)}}},
+		{funded: {Balances: map[string]*big.Int{"GLD": big.NewInt(501)}}},
+		{funded: {Balances: map[string]*big.Int{"CON": big.NewInt(1)}}},
+		{funded: {Balances: map[string]*big.Int{"ZIP": big.NewInt(-1)}}},
+	} {
+		invalid := *genesis
+		invalid.Alloc = alloc
+		if _, err := invalid.ToBlock(nil); err == nil {
+			t.Errorf("invalid alloc %d accepted", i)
+		}
+	}
+}
+
+func TestGenesisValidate(t *testing.T) {
+	owner := common.Address{0x10}
+	valid := func() *Genesis {
+		return &Genesis{
+			Config:   &params.ChainConfig{ChainID: big.NewInt(3)},
+			GasLimit: params.MinGasLimit,
+			Zip:      &GenesisZip{Total: big.NewInt(1000), Owner: owner},
+			Assets:   []GenesisAsset{{Name: "gold", Symbol: "GLD", Total: big.NewInt(100), Owner: owner}},
+			Alloc:    GenesisAlloc{common.Address{0x11}: {Balances: map[string]*big.Int{"ZIP": big.NewInt(10), "GLD": big.NewInt(10)}}},
+		}
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("valid genesis rejected: %v", err)
+	}
+	if err := DefaultGenesisBlock().Validate(); err != nil {
+		t.Fatalf("default genesis rejected: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(g *Genesis)
+	}{
+		{"no config", func(g *Genesis) { g.Config = nil }},
+		{"no chain id", func(g *Genesis) { g.Config.ChainID = nil }},
+		{"low gas limit", func(g *Genesis) { g.GasLimit = params.MinGasLimit - 1 }},
+		{"zero base fee elasticity", func(g *Genesis) {
+			g.Config.BaseFee = &params.BaseFeeConfig{Initial: big.NewInt(1), Denominator: 8}
+		}},
+		{"duplicate symbol", func(g *Genesis) {
+			g.Assets = append(g.Assets, GenesisAsset{Name: "gold2", Symbol: "gld", Owner: owner})
+		}},
+		{"empty symbol", func(g *Genesis) { g.Assets[0].Symbol = "" }},
+		{"unknown asset", func(g *Genesis) {
+			g.Alloc[common.Address{0x12}] = GenesisAccount{Balances: map[string]*big.Int{"BTC": big.NewInt(1)}}
+		}},
+		{"missing value", func(g *Genesis) {
+			g.Alloc[common.Address{0x12}] = GenesisAccount{Balances: map[string]*big.Int{"ZIP": nil}}
+		}},
+		{"no zip", func(g *Genesis) { g.Zip = nil }},
+		{"over allocated", func(g *Genesis) {
+			g.Alloc[common.Address{0x12}] = GenesisAccount{Balances: map[string]*big.Int{"GLD": big.NewInt(91)}}
+		}},
+		{"utxo alloc", func(g *Genesis) { g.Assets[0].BaseType = asset.UtxoModel }},
+	}
+	for _, test := range tests {
+		g := valid()
+		test.modify(g)
+		if err := g.Validate(); err == nil {
+			t.Errorf("%s: invalid genesis accepted", test.name)
+		}
+	}
+}
+
+func TestGenesisMismatch(t *testing.T) {
+	db := zdb.NewMemDatabase()
+	stored := DefaultGenesisBlock()
+	stored.Alloc = GenesisAlloc{common.Address{0x11}: {Balances: map[string]*big.Int{"ZIP": big.NewInt(10)}}}
+	block, err := stored.Commit(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The stored specification can be read back
+	read, err := ReadGenesis(db)
+	if err != nil {
+		t.Fatalf("failed to read genesis: %v", err)
+	}
+	if again, err := read.ToBlock(nil); err != nil || again.Hash() != block.Hash() {
+		t.Fatalf("read genesis hash mismatch: %v", err)
+	}
+
+	next := DefaultGenesisBlock()
+	next.GasLimit = 6000
+	next.Alloc = GenesisAlloc{common.Address{0x11}: {Balances: map[string]*big.Int{"ZIP": big.NewInt(20)}}}
+	_, _, err = SetupGenesisBlock(db, next)
+	mismatch, ok := err.(*GenesisMismatchError)
+	if !ok || mismatch.Stored != block.Hash() {
+		t.Fatalf("mismatch error expected, got %v", err)
+	}
+	want := []string{
+		"alloc.0x1100000000000000000000000000000000000000.balances.ZIP: have 10, new 20",
+		"gasLimit: have 5000, new 6000",
+	}
+	if diff := genesisDiff(mismatch.StoredGenesis, mismatch.NewGenesis); !reflect.DeepEqual(diff, want) {
+		t.Fatalf("diff mismatch:\nhave %q\nwant %q", diff, want)
+	}
+	for _, line := range want {
+		if !strings.Contains(err.Error(), line) {
+			t.Errorf("error misses diff line %q: %v", line, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package scip
+
+import (
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/rlp"
+	"github.com/zipper-project/z0/utils/trie"
+	"github.com/zipper-project/z0/utils/zdb"
+)
+
+// proofList collects the trie nodes of a proof.
+type proofList [][]byte
+
+func (l *proofList) Put(key []byte, value []byte) error {
+	*l = append(*l, common.CopyBytes(value))
+	return nil
+}
+
+// receiptKey is the key of the receipt at index in the receipt trie, like
+// types.DeriveSha keys it.
+func receiptKey(index uint64) []byte {
+	key, _ := rlp.EncodeToBytes(uint(index))
+	return key
+}
+
+// ProveReceipt returns the nodes of the receipt trie of a block proving its
+// receipt at index, for relayers to submit with types.SCIPReceiveParams.
+func ProveReceipt(receipts types.Receipts, index int) ([][]byte, error) {
+	tr := new(trie.Trie)
+	for i := 0; i < receipts.Len(); i++ {
+		tr.Update(receiptKey(uint64(i)), receipts.GetRlp(i))
+	}
+	var proof proofList
+	if err := tr.Prove(receiptKey(uint64(index)), 0, &proof); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// VerifyReceipt checks the proof of the receipt at index against the receipt
+// root of a block and returns the consensus fields of the receipt.
+func VerifyReceipt(root common.Hash, index uint64, proof [][]byte) (*types.Receipt, error) {
+	db := zdb.NewMemDatabase()
+	for _, node := range proof {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	value, _, err := trie.VerifyProof(root, receiptKey(index), db)
+	if err != nil || len(value) == 0 {
+		return nil, ErrInvalidProof
+	}
+	receipt, err := types.DecodeConsensusReceipt(value)
+	if err != nil {
+		return nil, ErrInvalidProof
+	}
+	return receipt, nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package scip
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/rawdb"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/rlp"
+	"github.com/zipper-project/z0/utils/zdb"
+)
+
+// HeaderStore returns the header store of a registered chain.
+func (b *Bridge) HeaderStore(chainID *big.Int) (*HeaderStore, error) {
+	if chainID == nil {
+		return nil, ErrUnknownChain
+	}
+	addr := RelayAddress(chainID)
+	hs := &HeaderStore{db: b.db, addr: addr}
+	v := b.db.GetAccount(addr, hs.key(sourceKey, ""))
+	if bytes.Equal(v, []byte{}) {
+		return nil, ErrUnknownChain
+	}
+	hs.source = new(Source)
+	if err := rlp.DecodeBytes(v, hs.source); err != nil {
+		return nil, err
+	}
+	return hs, nil
+}
+
+// Register starts tracking the headers of the source chain from the trusted
+// checkpoint. Only the governance, the owner of ZIP, may register chains and
+// every chain is registered once.
+func (b *Bridge) Register(from common.Address, cosigners []common.Address, source *Source, checkpoint *types.Header) error {
+	if err := b.asset.AuthorizeGovernance(from, cosigners...); err != nil {
+		return err
+	}
+	if source.ChainID == nil || source.ChainID.Sign() <= 0 || source.ChainID.Cmp(b.chainID) == 0 {
+		return ErrInvalidSource
+	}
+	if _, err := b.HeaderStore(source.ChainID); err != ErrUnknownChain {
+		return ErrChainRegistered
+	}
+	if err := source.validate(checkpoint); err != nil {
+		return err
+	}
+	enc, err := rlp.EncodeToBytes(source)
+	if err != nil {
+		return err
+	}
+	hs := &HeaderStore{db: b.db, source: source, addr: RelayAddress(source.ChainID)}
+	b.db.SetAccount(hs.addr, hs.key(sourceKey, ""), enc)
+	return hs.writeHeader(checkpoint, checkpoint.Difficulty)
+}
+
+// Relay verifies headers of a registered chain with its consensus engine and
+// adds them to its header store. Known headers are skipped, the others need
+// their parent to be known already. Anyone may relay headers, the seals are
+// what they are trusted for.
+func (b *Bridge) Relay(chainID *big.Int, headers []*types.Header, commits [][]byte) error {
+	hs, err := b.HeaderStore(chainID)
+	if err != nil {
+		return err
+	}
+	// The engine caches snapshots and reads commits from its database, none
+	// of which outlives the batch.
+	db := zdb.NewMemDatabase()
+	engine, err := hs.source.newEngine(b.engines, db)
+	if err != nil {
+		return err
+	}
+
+	for i, header := range headers {
+		if header == nil || header.Number == nil || header.Time == nil || header.Difficulty == nil {
+			return ErrInvalidHeader
+		}
+		hash := header.Hash()
+		if hs.GetHeaderByHash(hash) != nil {
+			continue
+		}
+		number := header.Number.Uint64()
+		if number == 0 {
+			return ErrUnknownParent
+		}
+		parent := hs.GetHeader(header.ParentHash, number-1)
+		if parent == nil {
+			return ErrUnknownParent
+		}
+		if i < len(commits) && len(commits[i]) > 0 {
+			rawdb.WriteCommitRLP(db, hash, number, commits[i])
+		}
+		if err := engine.VerifyHeader(hs, header, parent); err != nil {
+			return err
+		}
+		if err := engine.VerifySeal(hs, header); err != nil {
+			return err
+		}
+		td := new(big.Int).Add(hs.GetTd(parent.Hash()), header.Difficulty)
+		if err := hs.writeHeader(header, td); err != nil {
+			return err
+		}
+	}
+	return nil
+}
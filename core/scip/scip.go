@@ -0,0 +1,130 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package scip implements the cross-ledger bridge between z0 chains. Every
+// chain tracks the headers of the chains it is bridged to like a light client,
+// verifying them with the consensus engine of the other chain, and accepts the
+// messages the bridge of the other chain emitted once their receipt is proven
+// against a relayed header.
+package scip
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core/asset"
+	"github.com/zipper-project/z0/types"
+)
+
+var (
+	// ErrUnknownChain is returned if a chain isn't registered.
+	ErrUnknownChain = errors.New("unknown scip chain")
+	// ErrChainRegistered is returned if a chain is registered twice.
+	ErrChainRegistered = errors.New("scip chain already registered")
+	// ErrInvalidSource is returned if the settings of a registered chain are
+	// invalid.
+	ErrInvalidSource = errors.New("invalid scip chain settings")
+	// ErrNoEngine is returned if headers are relayed to a bridge without a
+	// consensus engine factory.
+	ErrNoEngine = errors.New("no consensus engine for scip chains")
+	// ErrInvalidCheckpoint is returned if a checkpoint can't start the header
+	// store of a chain.
+	ErrInvalidCheckpoint = errors.New("invalid scip checkpoint")
+	// ErrInvalidHeader is returned if a relayed header misses fields.
+	ErrInvalidHeader = errors.New("invalid relayed header")
+	// ErrUnknownParent is returned if the parent of a relayed header isn't
+	// known.
+	ErrUnknownParent = errors.New("unknown parent header")
+	// ErrUnconfirmed is returned if a message is proven against a header that
+	// isn't canonical or not buried deep enough.
+	ErrUnconfirmed = errors.New("header not confirmed")
+	// ErrInvalidProof is returned if a receipt proof doesn't verify.
+	ErrInvalidProof = errors.New("invalid receipt proof")
+	// ErrFailedReceipt is returned if a message is proven by the receipt of a
+	// failed transaction.
+	ErrFailedReceipt = errors.New("receipt of a failed transaction")
+	// ErrNotMessage is returned if a proven log isn't a bridge message.
+	ErrNotMessage = errors.New("not a scip message")
+	// ErrMessageRoute is returned if a message isn't sent from the proving
+	// chain to this one.
+	ErrMessageRoute = errors.New("scip message not addressed to this chain")
+	// ErrMessageNonce is returned if a message is received twice or out of
+	// order.
+	ErrMessageNonce = errors.New("unexpected scip message nonce")
+	// ErrAssetModel is returned if an utxo model asset is sent.
+	ErrAssetModel = errors.New("only account model assets can be bridged")
+	// ErrWrappedRoute is returned if a wrapped asset is sent to another chain
+	// than the one it came from.
+	ErrWrappedRoute = errors.New("wrapped asset can only return to its origin chain")
+)
+
+// StateDB is the state the bridge keeps its data in.
+type StateDB interface {
+	asset.StateDB
+	AddLog(*types.Log)
+}
+
+// Bridge applies the cross-ledger actions of the chain chainID.
+type Bridge struct {
+	db      StateDB
+	asset   *asset.Asset
+	chainID *big.Int
+	engines EngineFactory
+}
+
+// NewBridge creates the bridge of the chain. The relayed headers of other
+// chains are verified by the engines of the factory.
+func NewBridge(db StateDB, chainID *big.Int, engines EngineFactory) *Bridge {
+	if chainID == nil {
+		chainID = new(big.Int)
+	}
+	return &Bridge{db: db, asset: asset.NewAsset(db), chainID: chainID, engines: engines}
+}
+
+// ApplyAction routes a cross-ledger action to the matching Bridge method. It
+// returns the asset the action applied to, which is the credited asset for
+// types.ActionSCIPReceive.
+func (b *Bridge) ApplyAction(from common.Address, cosigners []common.Address, action *types.Action) (common.Address, error) {
+	params, err := action.DecodeParams()
+	if err != nil {
+		return common.Address{}, err
+	}
+	switch p := params.(type) {
+	case *types.SCIPRegisterParams:
+		source := &Source{
+			ChainID:       p.ChainID,
+			Engine:        p.Engine,
+			Period:        p.Period,
+			Epoch:         p.Epoch,
+			Validators:    p.Validators,
+			Confirmations: p.Confirmations,
+		}
+		return action.AssetID, b.Register(from, cosigners, source, p.Checkpoint)
+
+	case *types.SCIPRelayParams:
+		return action.AssetID, b.Relay(p.ChainID, p.Headers, p.Commits)
+
+	case *types.SCIPSendParams:
+		_, err := b.Send(from, action.AssetID, p.DestChainID, p.Recipient, p.Value)
+		return action.AssetID, err
+
+	case *types.SCIPReceiveParams:
+		_, assetID, err := b.Receive(p)
+		return assetID, err
+	}
+	return action.AssetID, types.ErrActionType
+}
@@ -0,0 +1,280 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package scip
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/consensus"
+	"github.com/zipper-project/z0/consensus/poa"
+	"github.com/zipper-project/z0/core/asset"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/params"
+	"github.com/zipper-project/z0/state"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/zdb"
+)
+
+// testChain is a proof-of-authority header chain with a single signer.
+type testChain struct {
+	t       *testing.T
+	config  *params.ChainConfig
+	engine  *poa.PoA
+	key     *ecdsa.PrivateKey
+	headers []*types.Header
+}
+
+func newTestChain(t *testing.T, chainID int64) *testChain {
+	key, _ := crypto.GenerateKey()
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+	config := &params.ChainConfig{ChainID: big.NewInt(chainID), PoA: &params.PoAConfig{Period: 1, Epoch: 30000}}
+	engine := poa.New(config.PoA, zdb.NewMemDatabase())
+	engine.Authorize(signer, func(addr common.Address, hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, key)
+	})
+	genesis := &types.Header{
+		Number:      new(big.Int),
+		Time:        big.NewInt(1000),
+		Difficulty:  big.NewInt(1),
+		Extra:       poa.GenesisExtra(nil, []common.Address{signer}),
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.EmptyRootHash,
+	}
+	return &testChain{t: t, config: config, engine: engine, key: key, headers: []*types.Header{genesis}}
+}
+
+func (tc *testChain) Config() *params.ChainConfig  { return tc.config }
+func (tc *testChain) CurrentHeader() *types.Header { return tc.headers[len(tc.headers)-1] }
+func (tc *testChain) GetHeaderByNumber(number uint64) *types.Header {
+	if number >= uint64(len(tc.headers)) {
+		return nil
+	}
+	return tc.headers[number]
+}
+func (tc *testChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := tc.GetHeaderByNumber(number); header != nil && header.Hash() == hash {
+		return header
+	}
+	return nil
+}
+func (tc *testChain) GetBlock(hash common.Hash, number uint64) *types.Block { return nil }
+
+func (tc *testChain) source(confirmations uint64) *Source {
+	return &Source{ChainID: tc.config.ChainID, Engine: EnginePoA, Period: 1, Epoch: 30000, Confirmations: confirmations}
+}
+
+// seal appends a sealed header with the receipts of a block.
+func (tc *testChain) seal(receipts types.Receipts) *types.Header {
+	parent := tc.CurrentHeader()
+	header := &types.Header{
+		ParentHash:  parent.Hash(),
+		Number:      new(big.Int).Add(parent.Number, common.Big1),
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.DeriveSha(receipts),
+	}
+	if err := tc.engine.Prepare(tc, header); err != nil {
+		tc.t.Fatalf("failed to prepare header: %v", err)
+	}
+	// Keep the timestamps in the past so sealing doesn't wait for the slot
+	header.Time = new(big.Int).Add(parent.Time, common.Big1)
+	block, err := tc.engine.Seal(tc, types.NewBlockWithHeader(header), nil)
+	if err != nil {
+		tc.t.Fatalf("failed to seal header: %v", err)
+	}
+	tc.headers = append(tc.headers, block.Header())
+	return block.Header()
+}
+
+func newTestBridge(t *testing.T, chainID int64, gov common.Address) (*state.StateDB, *Bridge) {
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(zdb.NewMemDatabase()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := asset.InitZip(statedb, big.NewInt(1000000), 18, gov); err != nil {
+		t.Fatal(err)
+	}
+	return statedb, NewBridge(statedb, big.NewInt(chainID), testEngines)
+}
+
+func testEngines(config *params.ChainConfig, db zdb.Database) (consensus.Engine, error) {
+	return poa.New(config.PoA, db), nil
+}
+
+// messageReceipts returns the receipts of a block whose second transaction
+// emitted the logs of the transaction hash.
+func messageReceipts(statedb *state.StateDB, txHash common.Hash) types.Receipts {
+	r := types.NewReceipt(nil, false, 42000)
+	r.Logs = statedb.GetLogs(txHash)
+	return types.Receipts{types.NewReceipt(nil, false, 21000), r}
+}
+
+func checkBalance(t *testing.T, a *asset.Asset, addr, assetID common.Address, want int64) {
+	t.Helper()
+	if balance, _ := a.GetBalance(addr, assetID).(*big.Int); balance == nil || balance.Cmp(big.NewInt(want)) != 0 {
+		t.Errorf("balance of %x mismatch: have %v, want %d", addr, balance, want)
+	}
+}
+
+func TestBridge(t *testing.T) {
+	var (
+		gov, alice, bob, carol = common.Address{0x10}, common.Address{0x11}, common.Address{0x12}, common.Address{0x13}
+		chainA, chainB         = newTestChain(t, 1), newTestChain(t, 2)
+		stateA, bridgeA        = newTestBridge(t, 1, gov)
+		stateB, bridgeB        = newTestBridge(t, 2, gov)
+		assetA, assetB         = asset.NewAsset(stateA), asset.NewAsset(stateB)
+	)
+	desc, _ := json.Marshal(&asset.AccountAssetInfo{Name: "test", Symbol: "TST", Total: big.NewInt(1000), Decimals: 8, Owner: alice})
+	tst, err := assetA.RegisterAsset(asset.AccountModel, alice, string(desc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the governance registers chains, and only once
+	if err := bridgeB.Register(alice, nil, chainA.source(1), chainA.headers[0]); err != asset.ErrNotOwner {
+		t.Fatalf("registration by a non governance account: have %v, want %v", err, asset.ErrNotOwner)
+	}
+	if err := bridgeB.Register(gov, nil, chainA.source(1), chainA.headers[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := bridgeB.Register(gov, nil, chainA.source(1), chainA.headers[0]); err != ErrChainRegistered {
+		t.Fatalf("second registration: have %v, want %v", err, ErrChainRegistered)
+	}
+	if err := bridgeA.Register(gov, nil, chainB.source(0), chainB.headers[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bridgeA.Send(alice, tst, big.NewInt(3), bob, big.NewInt(1)); err != ErrUnknownChain {
+		t.Fatalf("send to an unknown chain: have %v, want %v", err, ErrUnknownChain)
+	}
+
+	// Alice locks 300 TST on A for bob on B
+	txHash := common.Hash{0x01}
+	stateA.Prepare(txHash, common.Hash{}, 1)
+	msg, err := bridgeA.Send(alice, tst, big.NewInt(2), bob, big.NewInt(300))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Kind != MessageMint || msg.Nonce != 0 || bridgeA.SentMessages(big.NewInt(2)) != 1 {
+		t.Fatalf("message mismatch: %+v", msg)
+	}
+	checkBalance(t, assetA, alice, tst, 700)
+	checkBalance(t, assetA, EscrowAddress(big.NewInt(2)), tst, 300)
+
+	receipts := messageReceipts(stateA, txHash)
+	header := chainA.seal(receipts)
+	proof, err := ProveReceipt(receipts, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive := &types.SCIPReceiveParams{ChainID: big.NewInt(1), BlockHash: header.Hash(), TxIndex: 1, LogIndex: 0, Proof: proof}
+	if _, _, err := bridgeB.Receive(receive); err != ErrUnconfirmed {
+		t.Fatalf("receive before relay: have %v, want %v", err, ErrUnconfirmed)
+	}
+	if err := NewBridge(stateB, big.NewInt(2), nil).Relay(big.NewInt(1), []*types.Header{header}, nil); err != ErrNoEngine {
+		t.Fatalf("relay without engines: have %v, want %v", err, ErrNoEngine)
+	}
+	if err := bridgeB.Relay(big.NewInt(1), []*types.Header{header}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := bridgeB.Receive(receive); err != ErrUnconfirmed {
+		t.Fatalf("receive without confirmation: have %v, want %v", err, ErrUnconfirmed)
+	}
+
+	// A header altered after sealing isn't relayed
+	forged := types.CopyHeader(chainA.seal(nil))
+	forged.GasLimit++
+	if err := bridgeB.Relay(big.NewInt(1), []*types.Header{forged}, nil); err == nil {
+		t.Fatalf("relayed a header with an invalid seal")
+	}
+	if err := bridgeB.Relay(big.NewInt(1), chainA.headers[1:], nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The proof has to match the receipt root
+	bad := &types.SCIPReceiveParams{ChainID: big.NewInt(1), BlockHash: header.Hash(), TxIndex: 0, Proof: proof}
+	if _, _, err := bridgeB.Receive(bad); err != ErrInvalidProof {
+		t.Fatalf("proof of another index: have %v, want %v", err, ErrInvalidProof)
+	}
+	tampered := append([][]byte{}, proof...)
+	tampered[len(tampered)-1] = append([]byte{}, tampered[len(tampered)-1]...)
+	tampered[len(tampered)-1][len(tampered[len(tampered)-1])-1]++
+	bad = &types.SCIPReceiveParams{ChainID: big.NewInt(1), BlockHash: header.Hash(), TxIndex: 1, Proof: tampered}
+	if _, _, err := bridgeB.Receive(bad); err != ErrInvalidProof {
+		t.Fatalf("tampered proof: have %v, want %v", err, ErrInvalidProof)
+	}
+
+	// Bob is minted the wrapped asset, once
+	_, wrapped, err := bridgeB.Receive(receive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, ok := bridgeB.WrappedAsset(big.NewInt(1), tst); !ok || have != wrapped {
+		t.Fatalf("wrapped asset mismatch: have %x, want %x", have, wrapped)
+	}
+	if chainID, assetID, ok := bridgeB.Origin(wrapped); !ok || chainID.Int64() != 1 || assetID != tst {
+		t.Fatalf("origin mismatch: %v %x", chainID, assetID)
+	}
+	if registered, err := assetB.GetAsset(wrapped); err != nil || registered.Info.Symbol != "TST.1" {
+		t.Fatalf("wrapped asset registration mismatch: %v", err)
+	}
+	checkBalance(t, assetB, bob, wrapped, 300)
+	if _, _, err := bridgeB.Receive(receive); err != ErrMessageNonce {
+		t.Fatalf("replayed message: have %v, want %v", err, ErrMessageNonce)
+	}
+
+	// The wrapped asset only returns to its origin chain
+	if err := bridgeB.Register(gov, nil, newTestChain(t, 3).source(0), chainA.headers[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bridgeB.Send(bob, wrapped, big.NewInt(3), carol, big.NewInt(100)); err != ErrWrappedRoute {
+		t.Fatalf("send of a wrapped asset to another chain: have %v, want %v", err, ErrWrappedRoute)
+	}
+
+	// Bob burns 100 of it on B, which releases 100 TST to carol on A
+	txHash = common.Hash{0x02}
+	stateB.Prepare(txHash, common.Hash{}, 1)
+	msg, err = bridgeB.Send(bob, wrapped, big.NewInt(1), carol, big.NewInt(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Kind != MessageRelease || msg.AssetID != tst {
+		t.Fatalf("message mismatch: %+v", msg)
+	}
+	checkBalance(t, assetB, bob, wrapped, 200)
+
+	receipts = messageReceipts(stateB, txHash)
+	header = chainB.seal(receipts)
+	if proof, err = ProveReceipt(receipts, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := bridgeA.Relay(big.NewInt(2), []*types.Header{header}, nil); err != nil {
+		t.Fatal(err)
+	}
+	receive = &types.SCIPReceiveParams{ChainID: big.NewInt(2), BlockHash: header.Hash(), TxIndex: 1, Proof: proof}
+	if _, assetID, err := bridgeA.Receive(receive); err != nil || assetID != tst {
+		t.Fatalf("release failed: %v", err)
+	}
+	checkBalance(t, assetA, carol, tst, 100)
+	checkBalance(t, assetA, EscrowAddress(big.NewInt(2)), tst, 200)
+
+	if bridgeA.ReceivedMessages(big.NewInt(2)) != 1 || bridgeB.ReceivedMessages(big.NewInt(1)) != 1 {
+		t.Errorf("received message counts mismatch")
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package scip
+
+import (
+	"bytes"
+	"math/big"
+	"strconv"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/consensus"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/params"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/rlp"
+	"github.com/zipper-project/z0/utils/zdb"
+)
+
+// Consensus engines of registered chains
+const (
+	EnginePoA uint8 = iota + 1
+	EngineBFT
+)
+
+var (
+	relayKey     = []byte("sciprelay")
+	sourceKey    = []byte("source")
+	headerKey    = []byte("header")
+	tdKey        = []byte("td")
+	canonicalKey = []byte("canon")
+	headKey      = []byte("head")
+)
+
+// EngineFactory creates the consensus engine of a chain config over a
+// database. The engines live above core, so the node passes the factory it
+// creates its own engine with.
+type EngineFactory func(config *params.ChainConfig, db zdb.Database) (consensus.Engine, error)
+
+// RelayAddress returns the account the headers of the chain are stored in.
+func RelayAddress(chainID *big.Int) common.Address {
+	return common.BytesToAddress(crypto.Keccak256(relayKey, chainID.Bytes())[12:])
+}
+
+// Source describes a registered chain and the consensus engine sealing it.
+type Source struct {
+	ChainID       *big.Int
+	Engine        uint8
+	Period        uint64
+	Epoch         uint64
+	Validators    []common.Address
+	Confirmations uint64
+}
+
+// validate checks the engine settings and that the checkpoint can start the
+// header store.
+func (s *Source) validate(checkpoint *types.Header) error {
+	if checkpoint == nil || checkpoint.Number == nil || checkpoint.Difficulty == nil || checkpoint.Time == nil {
+		return ErrInvalidCheckpoint
+	}
+	switch s.Engine {
+	case EnginePoA:
+		// The snapshot of the signers starts from an epoch header
+		if s.Epoch == 0 {
+			return ErrInvalidSource
+		}
+		if checkpoint.Number.Uint64()%s.Epoch != 0 {
+			return ErrInvalidCheckpoint
+		}
+	case EngineBFT:
+		if len(s.Validators) == 0 {
+			return ErrInvalidSource
+		}
+	default:
+		return ErrInvalidSource
+	}
+	return nil
+}
+
+// Config returns the chain config of the source as far as its headers are
+// concerned.
+func (s *Source) Config() *params.ChainConfig {
+	config := &params.ChainConfig{ChainID: s.ChainID}
+	switch s.Engine {
+	case EnginePoA:
+		config.PoA = &params.PoAConfig{Period: s.Period, Epoch: s.Epoch}
+	case EngineBFT:
+		config.BFT = &params.BFTConfig{Validators: s.Validators, Period: s.Period}
+	}
+	return config
+}
+
+// newEngine creates the consensus engine of the source over db, which only
+// lives as long as the verification of a batch of headers.
+func (s *Source) newEngine(factory EngineFactory, db zdb.Database) (consensus.Engine, error) {
+	if factory == nil {
+		return nil, ErrNoEngine
+	}
+	return factory(s.Config(), db)
+}
+
+// HeaderStore keeps the headers of a registered chain in the state, like a
+// HeaderChain keeps the headers of the local chain in its database. It
+// implements consensus.ChainReader so the engine of the chain verifies the
+// relayed headers against it.
+type HeaderStore struct {
+	db     StateDB
+	source *Source
+	addr   common.Address
+}
+
+func (hs *HeaderStore) key(prefix []byte, suffix string) string {
+	return hs.addr.String() + string(prefix) + suffix
+}
+
+// Source returns the registration of the chain.
+func (hs *HeaderStore) Source() *Source {
+	return hs.source
+}
+
+// Config implements consensus.ChainReader.
+func (hs *HeaderStore) Config() *params.ChainConfig {
+	return hs.source.Config()
+}
+
+// CurrentHeader implements consensus.ChainReader, returning the head of the
+// heaviest known chain.
+func (hs *HeaderStore) CurrentHeader() *types.Header {
+	return hs.GetHeaderByHash(common.BytesToHash(hs.db.GetAccount(hs.addr, hs.key(headKey, ""))))
+}
+
+// GetHeader implements consensus.ChainReader.
+func (hs *HeaderStore) GetHeader(hash common.Hash, number uint64) *types.Header {
+	header := hs.GetHeaderByHash(hash)
+	if header == nil || header.Number.Uint64() != number {
+		return nil
+	}
+	return header
+}
+
+// GetHeaderByHash retrieves a header by hash.
+func (hs *HeaderStore) GetHeaderByHash(hash common.Hash) *types.Header {
+	v := hs.db.GetAccount(hs.addr, hs.key(headerKey, hash.Hex()))
+	if bytes.Equal(v, []byte{}) {
+		return nil
+	}
+	header := new(types.Header)
+	if err := rlp.DecodeBytes(v, header); err != nil {
+		return nil
+	}
+	return header
+}
+
+// GetHeaderByNumber implements consensus.ChainReader, returning the canonical
+// header of the number.
+func (hs *HeaderStore) GetHeaderByNumber(number uint64) *types.Header {
+	hash := hs.GetCanonicalHash(number)
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	return hs.GetHeaderByHash(hash)
+}
+
+// GetBlock implements consensus.ChainReader, the store only knows headers.
+func (hs *HeaderStore) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return nil
+}
+
+// GetCanonicalHash returns the hash of the canonical header of the number.
+func (hs *HeaderStore) GetCanonicalHash(number uint64) common.Hash {
+	return common.BytesToHash(hs.db.GetAccount(hs.addr, hs.key(canonicalKey, strconv.FormatUint(number, 10))))
+}
+
+// GetTd returns the total difficulty of the header since the checkpoint.
+func (hs *HeaderStore) GetTd(hash common.Hash) *big.Int {
+	v := hs.db.GetAccount(hs.addr, hs.key(tdKey, hash.Hex()))
+	if bytes.Equal(v, []byte{}) {
+		return nil
+	}
+	td := new(big.Int)
+	if err := rlp.DecodeBytes(v, td); err != nil {
+		return nil
+	}
+	return td
+}
+
+// Confirmed returns the header of the hash if it is canonical and buried
+// under the confirmations required for the chain.
+func (hs *HeaderStore) Confirmed(hash common.Hash) (*types.Header, error) {
+	header := hs.GetHeaderByHash(hash)
+	if header == nil {
+		return nil, ErrUnconfirmed
+	}
+	number := header.Number.Uint64()
+	if hs.GetCanonicalHash(number) != hash {
+		return nil, ErrUnconfirmed
+	}
+	if head := hs.CurrentHeader(); head.Number.Uint64() < number+hs.source.Confirmations {
+		return nil, ErrUnconfirmed
+	}
+	return header, nil
+}
+
+// writeHeader stores a verified header. If its total difficulty is higher
+// than the one of the head it becomes the new head and the canonical numbers
+// are rewritten to lead to it.
+func (hs *HeaderStore) writeHeader(header *types.Header, td *big.Int) error {
+	enc, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		return err
+	}
+	tdEnc, err := rlp.EncodeToBytes(td)
+	if err != nil {
+		return err
+	}
+	hash := header.Hash()
+	hs.db.SetAccount(hs.addr, hs.key(headerKey, hash.Hex()), enc)
+	hs.db.SetAccount(hs.addr, hs.key(tdKey, hash.Hex()), tdEnc)
+
+	if head := hs.CurrentHeader(); head != nil && hs.GetTd(head.Hash()).Cmp(td) >= 0 {
+		return nil
+	}
+	hs.db.SetAccount(hs.addr, hs.key(headKey, ""), hash.Bytes())
+
+	// Drop the canonical numbers above a shorter but heavier chain
+	for n := header.Number.Uint64() + 1; hs.GetCanonicalHash(n) != (common.Hash{}); n++ {
+		hs.db.SetAccount(hs.addr, hs.key(canonicalKey, strconv.FormatUint(n, 10)), []byte{})
+	}
+	// Rewrite the canonical numbers back to the common ancestor
+	for header != nil {
+		number := header.Number.Uint64()
+		if hs.GetCanonicalHash(number) == header.Hash() {
+			break
+		}
+		hs.db.SetAccount(hs.addr, hs.key(canonicalKey, strconv.FormatUint(number, 10)), header.Hash().Bytes())
+		if number == 0 {
+			break
+		}
+		header = hs.GetHeader(header.ParentHash, number-1)
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package scip
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core/asset"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/rlp"
+)
+
+// Message kinds
+const (
+	// MessageMint mints the wrapped asset of a native asset locked on the
+	// sending chain.
+	MessageMint uint8 = iota
+	// MessageRelease releases a native asset locked for the sending chain
+	// after its wrapped asset was burnt there.
+	MessageRelease
+)
+
+var (
+	escrowKey = []byte("scipescrow")
+	wrapKey   = []byte("scipwrap")
+	originKey = []byte("origin")
+	outKey    = []byte("outnonce")
+	inKey     = []byte("innonce")
+)
+
+// MessageTopic is the first topic of the log a message is sent with. The
+// remaining topics are the destination chain and the nonce, the data is the
+// RLP encoded Message.
+var MessageTopic = crypto.Keccak256Hash([]byte("SCIPMessage(uint256,uint64,bytes)"))
+
+// Message moves Value of an asset from Sender on the chain Source to
+// Recipient on the chain Dest. Nonce counts the messages from Source to Dest.
+// AssetID is the asset on Source for MessageMint, which also describes it by
+// Name, Symbol and Decimals, and the native asset on Dest for MessageRelease.
+type Message struct {
+	Kind      uint8
+	Source    *big.Int
+	Dest      *big.Int
+	Nonce     uint64
+	Sender    common.Address
+	Recipient common.Address
+	AssetID   common.Address
+	Name      string
+	Symbol    string
+	Decimals  uint64
+	Value     *big.Int
+}
+
+// DecodeMessage decodes the message emitted in a log of the bridge.
+func DecodeMessage(log *types.Log) (*Message, error) {
+	if log.Address != types.SCIPBridge || len(log.Topics) == 0 || log.Topics[0] != MessageTopic {
+		return nil, ErrNotMessage
+	}
+	msg := new(Message)
+	if err := rlp.DecodeBytes(log.Data, msg); err != nil {
+		return nil, ErrNotMessage
+	}
+	if msg.Source == nil || msg.Dest == nil || msg.Value == nil || msg.Value.Sign() <= 0 {
+		return nil, ErrNotMessage
+	}
+	return msg, nil
+}
+
+// EscrowAddress returns the address holding the native assets sent to the
+// chain. No key controls it, only messages of the chain release its balance.
+func EscrowAddress(chainID *big.Int) common.Address {
+	return common.BytesToAddress(crypto.Keccak256(escrowKey, chainID.Bytes())[12:])
+}
+
+// wrapOwner returns the owner of the wrapped asset of an asset of the chain.
+// No key controls it, so only the bridge issues the wrapped asset.
+func wrapOwner(chainID *big.Int, assetID common.Address) common.Address {
+	return common.BytesToAddress(crypto.Keccak256(wrapKey, chainID.Bytes(), assetID[:])[12:])
+}
+
+// origin is the chain and asset a wrapped asset stands for.
+type origin struct {
+	ChainID *big.Int
+	AssetID common.Address
+}
+
+func (b *Bridge) key(prefix []byte, suffix string) string {
+	return types.SCIPBridge.String() + string(prefix) + suffix
+}
+
+func (b *Bridge) nonce(prefix []byte, chainID *big.Int) uint64 {
+	var n uint64
+	if v := b.db.GetAccount(types.SCIPBridge, b.key(prefix, chainID.String())); !bytes.Equal(v, []byte{}) {
+		if err := rlp.DecodeBytes(v, &n); err != nil {
+			return 0
+		}
+	}
+	return n
+}
+
+func (b *Bridge) setNonce(prefix []byte, chainID *big.Int, n uint64) {
+	enc, _ := rlp.EncodeToBytes(n)
+	b.db.SetAccount(types.SCIPBridge, b.key(prefix, chainID.String()), enc)
+}
+
+// SentMessages returns the number of messages sent to the chain, which is
+// the nonce of the next one.
+func (b *Bridge) SentMessages(chainID *big.Int) uint64 {
+	return b.nonce(outKey, chainID)
+}
+
+// ReceivedMessages returns the number of messages received from the chain,
+// which is the nonce expected next.
+func (b *Bridge) ReceivedMessages(chainID *big.Int) uint64 {
+	return b.nonce(inKey, chainID)
+}
+
+// WrappedAsset returns the wrapped asset of an asset of the chain, false if
+// none was minted yet.
+func (b *Bridge) WrappedAsset(chainID *big.Int, assetID common.Address) (common.Address, bool) {
+	v := b.db.GetAccount(types.SCIPBridge, b.key(wrapKey, chainID.String()+assetID.Hex()))
+	if bytes.Equal(v, []byte{}) {
+		return common.Address{}, false
+	}
+	return common.BytesToAddress(v), true
+}
+
+// Origin returns the chain and the asset the wrapped asset stands for, false
+// if the asset is native to this chain.
+func (b *Bridge) Origin(wrapped common.Address) (*big.Int, common.Address, bool) {
+	v := b.db.GetAccount(types.SCIPBridge, b.key(originKey, wrapped.Hex()))
+	if bytes.Equal(v, []byte{}) {
+		return nil, common.Address{}, false
+	}
+	var o origin
+	if err := rlp.DecodeBytes(v, &o); err != nil {
+		return nil, common.Address{}, false
+	}
+	return o.ChainID, o.AssetID, true
+}
+
+// Send moves value of the asset from the sender to the recipient on the
+// registered chain dest. Native assets are locked in the escrow of dest and
+// minted as wrapped asset there, wrapped assets are burnt and released from
+// the escrow on their origin chain. The message is emitted as log of the
+// bridge for the relayer to prove on dest.
+func (b *Bridge) Send(from, assetID common.Address, dest *big.Int, recipient common.Address, value *big.Int) (*Message, error) {
+	if value == nil || value.Sign() <= 0 {
+		return nil, asset.ErrNegativeValue
+	}
+	if _, err := b.HeaderStore(dest); err != nil {
+		return nil, err
+	}
+	registered, err := b.asset.GetAsset(assetID)
+	if err != nil {
+		return nil, err
+	}
+	if registered.BaseType != asset.AccountModel {
+		return nil, ErrAssetModel
+	}
+	msg := &Message{Source: b.chainID, Dest: dest, Sender: from, Recipient: recipient, Value: value}
+	if chainID, originID, ok := b.Origin(assetID); ok {
+		if chainID.Cmp(dest) != 0 {
+			return nil, ErrWrappedRoute
+		}
+		if err := b.asset.BurnAsset(from, assetID, value); err != nil {
+			return nil, err
+		}
+		msg.Kind, msg.AssetID = MessageRelease, originID
+	} else {
+		if err := b.asset.SubBalance(from, assetID, value); err != nil {
+			return nil, err
+		}
+		if err := b.asset.AddBalance(EscrowAddress(dest), assetID, value); err != nil {
+			return nil, err
+		}
+		msg.Kind, msg.AssetID = MessageMint, assetID
+		msg.Name, msg.Symbol, msg.Decimals = registered.Info.Name, registered.Info.Symbol, registered.Info.Decimals
+	}
+	msg.Nonce = b.nonce(outKey, dest)
+	b.setNonce(outKey, dest, msg.Nonce+1)
+
+	data, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		return nil, err
+	}
+	b.db.AddLog(&types.Log{
+		Address: types.SCIPBridge,
+		Topics:  []common.Hash{MessageTopic, common.BigToHash(dest), common.BigToHash(new(big.Int).SetUint64(msg.Nonce))},
+		Data:    data,
+	})
+	return msg, nil
+}
+
+// Receive verifies the proof of a message sent to this chain against a
+// confirmed header of the sending chain and credits its recipient. Messages
+// of a chain are received in the order of their nonces and only once. It
+// returns the message and the credited asset.
+func (b *Bridge) Receive(p *types.SCIPReceiveParams) (*Message, common.Address, error) {
+	hs, err := b.HeaderStore(p.ChainID)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	header, err := hs.Confirmed(p.BlockHash)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	receipt, err := VerifyReceipt(header.ReceiptHash, p.TxIndex, p.Proof)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, common.Address{}, ErrFailedReceipt
+	}
+	if p.LogIndex >= uint64(len(receipt.Logs)) {
+		return nil, common.Address{}, ErrNotMessage
+	}
+	msg, err := DecodeMessage(receipt.Logs[p.LogIndex])
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	if msg.Source.Cmp(p.ChainID) != 0 || msg.Dest.Cmp(b.chainID) != 0 {
+		return nil, common.Address{}, ErrMessageRoute
+	}
+	if msg.Nonce != b.nonce(inKey, msg.Source) {
+		return nil, common.Address{}, ErrMessageNonce
+	}
+	b.setNonce(inKey, msg.Source, msg.Nonce+1)
+
+	var assetID common.Address
+	switch msg.Kind {
+	case MessageMint:
+		assetID, err = b.mint(msg)
+	case MessageRelease:
+		assetID, err = msg.AssetID, b.release(msg)
+	default:
+		err = ErrNotMessage
+	}
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	return msg, assetID, nil
+}
+
+// mint issues the wrapped asset of the message to its recipient, registering
+// the wrapped asset with the first message of the asset. Its symbol is the
+// one of the asset suffixed with the sending chain.
+func (b *Bridge) mint(msg *Message) (common.Address, error) {
+	owner := wrapOwner(msg.Source, msg.AssetID)
+	wrapped, ok := b.WrappedAsset(msg.Source, msg.AssetID)
+	if !ok {
+		info := &asset.AccountAssetInfo{
+			Name:     msg.Name,
+			Symbol:   fmt.Sprintf("%s.%s", msg.Symbol, msg.Source),
+			Decimals: msg.Decimals,
+			Owner:    owner,
+		}
+		desc, err := json.Marshal(info)
+		if err != nil {
+			return common.Address{}, err
+		}
+		// The owner never sends a transaction, so its nonce and with it the
+		// address of the wrapped asset are fixed.
+		if wrapped, err = b.asset.RegisterAsset(asset.AccountModel, owner, string(desc)); err != nil {
+			return common.Address{}, err
+		}
+		enc, err := rlp.EncodeToBytes(&origin{ChainID: msg.Source, AssetID: msg.AssetID})
+		if err != nil {
+			return common.Address{}, err
+		}
+		b.db.SetAccount(types.SCIPBridge, b.key(wrapKey, msg.Source.String()+msg.AssetID.Hex()), wrapped.Bytes())
+		b.db.SetAccount(types.SCIPBridge, b.key(originKey, wrapped.Hex()), enc)
+	}
+	if err := b.asset.IssueAsset(owner, wrapped, msg.Value); err != nil {
+		return common.Address{}, err
+	}
+	if err := b.asset.SubBalance(owner, wrapped, msg.Value); err != nil {
+		return common.Address{}, err
+	}
+	return wrapped, b.asset.AddBalance(msg.Recipient, wrapped, msg.Value)
+}
+
+// release pays the native asset of the message out of the escrow of the
+// sending chain.
+func (b *Bridge) release(msg *Message) error {
+	if err := b.asset.SubBalance(EscrowAddress(msg.Source), msg.AssetID, msg.Value); err != nil {
+		return err
+	}
+	return b.asset.AddBalance(msg.Recipient, msg.AssetID, msg.Value)
+}
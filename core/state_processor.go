@@ -0,0 +1,128 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/consensus"
+	"github.com/zipper-project/z0/core/scip"
+	"github.com/zipper-project/z0/core/vm"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/params"
+	"github.com/zipper-project/z0/state"
+	"github.com/zipper-project/z0/types"
+)
+
+// StateProcessor is a basic Processor, which takes care of transitioning
+// state from one point to another.
+//
+// StateProcessor implements Processor.
+type StateProcessor struct {
+	config  *params.ChainConfig // Chain configuration options
+	bc      *BlockChain         // Canonical block chain
+	engine  consensus.Engine    // Consensus engine used for block rewards
+	engines scip.EngineFactory  // Consensus engines verifying relayed headers
+}
+
+// NewStateProcessor initialises a new StateProcessor.
+func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consensus.Engine, engines scip.EngineFactory) *StateProcessor {
+	return &StateProcessor{
+		config:  config,
+		bc:      bc,
+		engine:  engine,
+		engines: engines,
+	}
+}
+
+// Process processes the state changes according to the z0 rules by running
+// the transaction messages using the statedb and applying any rewards to
+// the processor (coinbase).
+//
+// Process returns the receipts and logs accumulated during the process and
+// returns the amount of gas that was used in the process. If any of the
+// transactions failed to execute due to insufficient gas it will return an error.
+func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
+	var (
+		receipts types.Receipts
+		usedGas  = new(uint64)
+		header   = block.Header()
+		allLogs  []*types.Log
+		gp       = new(GasPool).AddGas(block.GasLimit())
+	)
+	// Iterate over and process the individual transactions
+	for i, tx := range block.Transactions() {
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+		receipt, _, err := ApplyTransaction(p.config, p.bc, p.engines, &header.Coinbase, gp, statedb, header, tx, usedGas, cfg)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		receipts = append(receipts, receipt)
+		allLogs = append(allLogs, receipt.Logs...)
+	}
+	return receipts, allLogs, *usedGas, nil
+}
+
+// ApplyTransaction attempts to apply a transaction to the given state database
+// and uses the input parameters for its environment. It returns the receipt
+// for the transaction, gas used and an error if the transaction failed,
+// indicating the block was invalid. The headers relayed from other chains are
+// verified by the consensus engines of the factory.
+func ApplyTransaction(config *params.ChainConfig, bc ChainContext, engines scip.EngineFactory, author *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64, cfg vm.Config) (*types.Receipt, uint64, error) {
+	signer := types.MakeSigner(config.ChainID)
+	from, err := types.Sender(signer, tx)
+	if err != nil {
+		return nil, 0, err
+	}
+	// Co-signatures authorize privileged asset operations, a bad one
+	// invalidates the whole transaction
+	cosigners, err := types.CoSigners(signer, tx)
+	if err != nil {
+		return nil, 0, err
+	}
+	env := types.CopyHeader(header)
+	if author != nil {
+		env.Coinbase = *author
+	}
+	// Create a new environment which holds all relevant information
+	// about the transaction and calling mechanisms.
+	vmenv := vm.NewEVM(NewEVMContext(from, tx.GasPrice(), env, bc), statedb, config, cfg)
+	// Apply the transaction to the current state (included in the env)
+	internal, actions, gas, failed, err := ApplyTransition(vmenv, engines, tx, from, cosigners, gp)
+	if err != nil {
+		return nil, 0, err
+	}
+	// Update the state with pending changes
+	statedb.Finalise(true)
+	*usedGas += gas
+
+	// Create a new receipt for the transaction, storing the status and gas used by the tx.
+	receipt := types.NewReceipt(nil, failed, *usedGas)
+	receipt.TxHash = tx.Hash()
+	receipt.GasUsed = gas
+	receipt.Internal = internal
+	receipt.Actions = actions
+	// if the transaction created a contract, store the creation address in the receipt.
+	if tx.CreatesContract() {
+		receipt.ContractAddress = crypto.CreateAddress(from, tx.Nonce())
+	}
+
+	// Set the receipt logs and create a bloom for filtering
+	receipt.Logs = statedb.GetLogs(tx.Hash())
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+
+	return receipt, gas, err
+}
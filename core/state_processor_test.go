@@ -0,0 +1,710 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core/asset"
+	"github.com/zipper-project/z0/core/vm"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/params"
+	"github.com/zipper-project/z0/state"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/zdb"
+)
+
+func newProcessorTestState(t *testing.T, funded common.Address) (*state.StateDB, common.Address) {
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(zdb.NewMemDatabase()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := asset.InitZip(statedb, big.NewInt(1000000000), 8, types.ZipAccount); err != nil {
+		t.Fatal(err)
+	}
+	a := asset.NewAsset(statedb)
+	if err := a.SubBalance(types.ZipAccount, types.ZipAssetID, big.NewInt(10000000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddBalance(funded, types.ZipAssetID, big.NewInt(10000000)); err != nil {
+		t.Fatal(err)
+	}
+	desc, _ := json.Marshal(&asset.AccountAssetInfo{Name: "bitcoin", Symbol: "BTC", Total: big.NewInt(500), Decimals: 8, Owner: funded})
+	btc, err := a.RegisterAsset(asset.AccountModel, funded, string(desc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return statedb, btc
+}
+
+func newProcessorTestTx(t *testing.T, key *ecdsa.PrivateKey, nonce uint64, outputs ...interface{}) *types.Transaction {
+	tx := types.NewTransaction(nonce, 100000, big.NewInt(2), nil)
+	inputs := make([]interface{}, 0, len(outputs))
+	for _, o := range outputs {
+		inputs = append(inputs, types.AMInput{AssertID: o.(types.AMOutput).AssertID})
+	}
+	tx.WithInput(inputs...)
+	tx.WithOutput(outputs...)
+	signed, err := types.SignTx(tx, types.MakeSigner(params.DefaultChainconfig.ChainID), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestStateProcessorTransfer(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var (
+		from     = crypto.PubkeyToAddress(key.PublicKey)
+		to       = common.Address{0x10}
+		coinbase = common.Address{0x20}
+	)
+	statedb, btc := newProcessorTestState(t, from)
+
+	zip := types.ZipAssetID
+	tx := newProcessorTestTx(t, key, 0,
+		types.AMOutput{AssertID: &zip, Address: &to, Value: big.NewInt(1000)},
+		types.AMOutput{AssertID: &btc, Address: &to, Value: big.NewInt(200)},
+	)
+	header := &types.Header{Number: big.NewInt(1), GasLimit: params.GenesisGasLimit, Coinbase: coinbase}
+	block := types.NewBlock(header, []*types.Transaction{tx}, nil, nil)
+
+	receipts, logs, usedGas, err := NewStateProcessor(params.DefaultChainconfig, nil, nil, nil).Process(block, statedb, vm.Config{})
+	if err != nil {
+		t.Fatalf("process failed: %v", err)
+	}
+	if want := 2 * params.TxGas; usedGas != want {
+		t.Errorf("used gas mismatch: have %d, want %d", usedGas, want)
+	}
+	if len(receipts) != 1 || receipts[0].Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("unexpected receipts: %v", receipts)
+	}
+	if len(logs) != 2 || len(receipts[0].Internal) != 2 {
+		t.Errorf("unexpected logs/internal count: %d/%d", len(logs), len(receipts[0].Internal))
+	}
+	if logs[1].Address != btc || logs[1].Topics[0] != TransferTopic {
+		t.Errorf("unexpected transfer log: %v", logs[1])
+	}
+
+	a := asset.NewAsset(statedb)
+	fee := new(big.Int).SetUint64(2 * 2 * params.TxGas)
+	checkBalance(t, a, from, zip, new(big.Int).Sub(big.NewInt(10000000-1000), fee))
+	checkBalance(t, a, to, zip, big.NewInt(1000))
+	checkBalance(t, a, from, btc, big.NewInt(300))
+	checkBalance(t, a, to, btc, big.NewInt(200))
+	checkBalance(t, a, coinbase, zip, fee)
+	if nonce := a.GetNonce(from); nonce != 1 {
+		t.Errorf("nonce mismatch: have %d, want 1", nonce)
+	}
+}
+
+func TestStateProcessorFailedTransfer(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var (
+		from     = crypto.PubkeyToAddress(key.PublicKey)
+		to       = common.Address{0x10}
+		coinbase = common.Address{0x20}
+	)
+	statedb, btc := newProcessorTestState(t, from)
+
+	zip := types.ZipAssetID
+	tx := newProcessorTestTx(t, key, 0,
+		types.AMOutput{AssertID: &zip, Address: &to, Value: big.NewInt(1000)},
+		types.AMOutput{AssertID: &btc, Address: &to, Value: big.NewInt(501)},
+	)
+	header := &types.Header{Number: big.NewInt(1), GasLimit: params.GenesisGasLimit, Coinbase: coinbase}
+	block := types.NewBlock(header, []*types.Transaction{tx}, nil, nil)
+
+	receipts, logs, _, err := NewStateProcessor(params.DefaultChainconfig, nil, nil, nil).Process(block, statedb, vm.Config{})
+	if err != nil {
+		t.Fatalf("process failed: %v", err)
+	}
+	if receipts[0].Status != types.ReceiptStatusFailed {
+		t.Errorf("expected failed receipt")
+	}
+	if len(logs) != 0 || len(receipts[0].Internal) != 0 {
+		t.Errorf("failed transaction left logs/internal: %d/%d", len(logs), len(receipts[0].Internal))
+	}
+
+	a := asset.NewAsset(statedb)
+	fee := new(big.Int).SetUint64(2 * 2 * params.TxGas)
+	checkBalance(t, a, from, zip, new(big.Int).Sub(big.NewInt(10000000), fee))
+	checkBalance(t, a, to, zip, big.NewInt(0))
+	checkBalance(t, a, from, btc, big.NewInt(500))
+	checkBalance(t, a, coinbase, zip, fee)
+	if nonce := a.GetNonce(from); nonce != 1 {
+		t.Errorf("nonce mismatch: have %d, want 1", nonce)
+	}
+
+	// Replaying the same nonce must invalidate the block.
+	if _, _, _, err := NewStateProcessor(params.DefaultChainconfig, nil, nil, nil).Process(block, statedb, vm.Config{}); err != ErrNonceTooLow {
+		t.Errorf("replay error mismatch: have %v, want %v", err, ErrNonceTooLow)
+	}
+}
+
+func TestStateProcessorUTXOTransfer(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var (
+		from     = crypto.PubkeyToAddress(key.PublicKey)
+		to       = common.Address{0x10}
+		coinbase = common.Address{0x20}
+	)
+	statedb, _ := newProcessorTestState(t, from)
+	a := asset.NewAsset(statedb)
+	desc, _ := json.Marshal(&asset.AccountAssetInfo{Name: "utxo bitcoin", Symbol: "UBTC", Total: big.NewInt(500), Decimals: 8, Owner: from})
+	ubtc, err := a.RegisterAsset(asset.UtxoModel, from, string(desc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	utxos, err := a.GetUTXOs(from, ubtc)
+	if err != nil || len(utxos) != 1 {
+		t.Fatalf("issued outputs mismatch: %v, %v", utxos, err)
+	}
+	issued := utxos[0].OutPoint
+
+	newTx := func(nonce uint64, op types.OutPoint, outputs ...interface{}) *types.Transaction {
+		tx := types.NewTransaction(nonce, 100000, big.NewInt(2), nil)
+		tx.WithInput(types.UTXOInput{AssertID: &ubtc, TxHash: op.TxHash, Index: op.Index})
+		tx.WithOutput(outputs...)
+		signed, err := types.SignTx(tx, types.MakeSigner(params.DefaultChainconfig.ChainID), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	process := func(number int64, txs ...*types.Transaction) types.Receipts {
+		header := &types.Header{Number: big.NewInt(number), GasLimit: params.GenesisGasLimit, Coinbase: coinbase}
+		receipts, _, _, err := NewStateProcessor(params.DefaultChainconfig, nil, nil, nil).Process(types.NewBlock(header, txs, nil, nil), statedb, vm.Config{})
+		if err != nil {
+			t.Fatalf("process failed: %v", err)
+		}
+		return receipts
+	}
+
+	// Pay 200 and return the locked change to the sender
+	pay := newTx(0, issued,
+		types.UTXOOutput{AssertID: &ubtc, Address: &to, Value: big.NewInt(200)},
+		types.UTXOOutput{AssertID: &ubtc, Address: &from, Value: big.NewInt(300), LockTime: 3},
+	)
+	if receipts := process(1, pay); receipts[0].Status != types.ReceiptStatusSuccessful || len(receipts[0].Internal) != 2 {
+		t.Fatalf("payment failed: %v", receipts[0])
+	}
+	change := types.OutPoint{TxHash: pay.Hash(), Index: 1}
+	checkBalance(t, a, from, ubtc, big.NewInt(300))
+	checkBalance(t, a, to, ubtc, big.NewInt(200))
+	if utxo, err := a.GetUTXO(ubtc, types.OutPoint{TxHash: pay.Hash(), Index: 0}); err != nil || utxo.Owner != to {
+		t.Fatalf("payment output mismatch: %v, %v", utxo, err)
+	}
+
+	// Double spending the issued output and spending the locked change fail
+	receipts := process(2,
+		newTx(1, issued, types.UTXOOutput{AssertID: &ubtc, Address: &to, Value: big.NewInt(500)}),
+		newTx(2, change, types.UTXOOutput{AssertID: &ubtc, Address: &to, Value: big.NewInt(300)}),
+	)
+	for i, receipt := range receipts {
+		if receipt.Status != types.ReceiptStatusFailed {
+			t.Errorf("tx %d: expected failed receipt", i)
+		}
+	}
+	// Outputs not adding up to the inputs fail, balanced ones go through
+	receipts = process(3,
+		newTx(3, change, types.UTXOOutput{AssertID: &ubtc, Address: &to, Value: big.NewInt(299)}),
+		newTx(4, change, types.UTXOOutput{AssertID: &ubtc, Address: &to, Value: big.NewInt(300)}),
+	)
+	if receipts[0].Status != types.ReceiptStatusFailed || receipts[1].Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("unexpected receipt status: %d, %d", receipts[0].Status, receipts[1].Status)
+	}
+	checkBalance(t, a, from, ubtc, big.NewInt(0))
+	checkBalance(t, a, to, ubtc, big.NewInt(500))
+	if utxos, _ := a.GetUTXOs(to, ubtc); len(utxos) != 2 {
+		t.Errorf("receiver outputs mismatch: have %d, want 2", len(utxos))
+	}
+}
+
+func TestStateProcessorAssetActions(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	cokey, _ := crypto.GenerateKey()
+	var (
+		from     = crypto.PubkeyToAddress(key.PublicKey)
+		cosigner = crypto.PubkeyToAddress(cokey.PublicKey)
+		holder   = common.Address{0x10}
+		coinbase = common.Address{0x20}
+		signer   = types.MakeSigner(params.DefaultChainconfig.ChainID)
+	)
+	statedb, _ := newProcessorTestState(t, from)
+	a := asset.NewAsset(statedb)
+
+	newTx := func(nonce uint64, inputs []interface{}, outputs ...interface{}) *types.Transaction {
+		tx := types.NewTransaction(nonce, 100000, big.NewInt(2), nil)
+		tx.WithInput(inputs...)
+		tx.WithOutput(outputs...)
+		signed, err := types.SignTx(tx, signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	action := func(assetID common.Address, typ types.ActionType, params interface{}) interface{} {
+		input, err := types.NewActionInput(assetID, typ, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return input
+	}
+	process := func(number int64, txs ...*types.Transaction) types.Receipts {
+		header := &types.Header{Number: big.NewInt(number), GasLimit: params.GenesisGasLimit, Coinbase: coinbase}
+		receipts, _, _, err := NewStateProcessor(params.DefaultChainconfig, nil, nil, nil).Process(types.NewBlock(header, txs, nil, nil), statedb, vm.Config{})
+		if err != nil {
+			t.Fatalf("process failed: %v", err)
+		}
+		return receipts
+	}
+
+	// Register an asset and issue more of it in the same transaction
+	receipts := process(1, newTx(0, []interface{}{
+		action(common.Address{}, types.ActionRegister, &types.RegisterParams{Name: "gold", Symbol: "GLD", Total: big.NewInt(100), MaxSupply: big.NewInt(1000)}),
+	}))
+	if receipts[0].Status != types.ReceiptStatusSuccessful || len(receipts[0].Actions) != 1 {
+		t.Fatalf("register failed: %v", receipts[0].Actions)
+	}
+	gld := receipts[0].Actions[0].AssetID
+	if info, err := a.GetAssetBySymbol("GLD"); err != nil || info.Address != gld || info.Info.Owner != from {
+		t.Fatalf("registered asset mismatch: %v, %v", info, err)
+	}
+
+	receipts = process(2, newTx(1, []interface{}{
+		action(gld, types.ActionIssue, &types.IssueParams{Value: big.NewInt(50)}),
+		action(gld, types.ActionBurn, &types.BurnParams{Value: big.NewInt(30)}),
+		action(gld, types.ActionFreezeAccount, &types.FreezeParams{Holder: holder, Frozen: true}),
+	}, types.AMOutput{AssertID: &gld, Address: &holder, Value: big.NewInt(20)}))
+	if receipts[0].Status != types.ReceiptStatusSuccessful || len(receipts[0].Actions) != 3 {
+		t.Fatalf("actions failed: %v", receipts[0].Actions)
+	}
+	for i, want := range []types.ActionType{types.ActionIssue, types.ActionBurn, types.ActionFreezeAccount} {
+		if result := receipts[0].Actions[i]; result.Type != want || result.AssetID != gld || result.Error != "" {
+			t.Errorf("action %d result mismatch: %v", i, result)
+		}
+	}
+	checkBalance(t, a, from, gld, big.NewInt(100))
+	checkBalance(t, a, holder, gld, big.NewInt(20))
+	if !a.IsFrozen(gld, holder) {
+		t.Errorf("holder not frozen")
+	}
+
+	// A failing action reverts the transaction and reports its error
+	receipts = process(3, newTx(2, []interface{}{
+		action(gld, types.ActionIssue, &types.IssueParams{Value: big.NewInt(10)}),
+		action(gld, types.ActionIssue, &types.IssueParams{Value: big.NewInt(1000)}),
+		action(gld, types.ActionBurn, &types.BurnParams{Value: big.NewInt(1)}),
+	}))
+	if receipts[0].Status != types.ReceiptStatusFailed || len(receipts[0].Actions) != 2 {
+		t.Fatalf("unexpected receipt: %v", receipts[0].Actions)
+	}
+	if result := receipts[0].Actions[1]; result.Status != types.ReceiptStatusFailed || result.Error != asset.ErrMaxSupply.Error() {
+		t.Errorf("failed action result mismatch: %v", result)
+	}
+	checkBalance(t, a, from, gld, big.NewInt(100))
+
+	// Hand the asset to a 2-of-2 policy, issuing then needs the co-signer
+	receipts = process(4, newTx(3, []interface{}{
+		action(gld, types.ActionSetPolicy, &types.PolicyParams{Keys: []types.PolicyKey{{Address: from}, {Address: cosigner}}, Threshold: 2}),
+	}))
+	if receipts[0].Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("policy change failed: %v", receipts[0].Actions)
+	}
+	issue := []interface{}{action(gld, types.ActionIssue, &types.IssueParams{Value: big.NewInt(1)})}
+	cosigned, err := types.CoSignTx(newTx(5, issue), signer, cokey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipts = process(5, newTx(4, issue), cosigned)
+	if receipts[0].Status != types.ReceiptStatusFailed || receipts[0].Actions[0].Error != asset.ErrNotOwner.Error() {
+		t.Errorf("single signed issue mismatch: %v", receipts[0].Actions)
+	}
+	if receipts[1].Status != types.ReceiptStatusSuccessful {
+		t.Errorf("co-signed issue failed: %v", receipts[1].Actions)
+	}
+	checkBalance(t, a, from, gld, big.NewInt(101))
+}
+
+func TestStateProcessorAccountPolicy(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	cokey, _ := crypto.GenerateKey()
+	var (
+		from     = crypto.PubkeyToAddress(key.PublicKey)
+		cosigner = crypto.PubkeyToAddress(cokey.PublicKey)
+		to       = common.Address{0x10}
+		coinbase = common.Address{0x20}
+		signer   = types.MakeSigner(params.DefaultChainconfig.ChainID)
+		zip      = types.ZipAssetID
+	)
+	statedb, _ := newProcessorTestState(t, from)
+	a := asset.NewAsset(statedb)
+
+	process := func(number int64, tx *types.Transaction) (types.Receipts, error) {
+		header := &types.Header{Number: big.NewInt(number), GasLimit: params.GenesisGasLimit, Coinbase: coinbase}
+		receipts, _, _, err := NewStateProcessor(params.DefaultChainconfig, nil, nil, nil).Process(types.NewBlock(header, []*types.Transaction{tx}, nil, nil), statedb, vm.Config{})
+		return receipts, err
+	}
+
+	// Require both keys for the transactions of the account
+	setPolicy, err := types.NewActionInput(common.Address{}, types.ActionSetAccountPolicy, &types.PolicyParams{
+		Keys: []types.PolicyKey{{Address: from}, {Address: cosigner}}, Threshold: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := types.NewTransaction(0, 100000, big.NewInt(2), nil)
+	tx.WithInput(setPolicy)
+	tx, _ = types.SignTx(tx, signer, key)
+	if receipts, err := process(1, tx); err != nil || receipts[0].Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("policy change failed: %v, %v", err, receipts)
+	}
+	if policy, _ := a.GetAccountPolicy(from); policy == nil || policy.Threshold != 2 {
+		t.Fatalf("account policy mismatch: %v", policy)
+	}
+
+	// A transfer signed by the account key alone invalidates the block
+	single := newProcessorTestTx(t, key, 1, types.AMOutput{AssertID: &zip, Address: &to, Value: big.NewInt(1000)})
+	if _, err := process(2, single); err != asset.ErrAccountSigners {
+		t.Fatalf("single signature error mismatch: have %v, want %v", err, asset.ErrAccountSigners)
+	}
+	cosigned, err := types.CoSignTx(single, signer, cokey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipts, err := process(2, cosigned); err != nil || receipts[0].Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("co-signed transfer failed: %v, %v", err, receipts)
+	}
+	checkBalance(t, a, to, zip, big.NewInt(1000))
+}
+
+func TestStateProcessorValidity(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var (
+		from     = crypto.PubkeyToAddress(key.PublicKey)
+		to       = common.Address{0x10}
+		coinbase = common.Address{0x20}
+		zip      = types.ZipAssetID
+		deadline = uint64(types.ValidityTimeThreshold) + 100
+	)
+	statedb, _ := newProcessorTestState(t, from)
+	a := asset.NewAsset(statedb)
+
+	newTx := func(nonce, after, until uint64) *types.Transaction {
+		tx := types.NewTransaction(nonce, 100000, big.NewInt(2), nil)
+		tx.WithInput(types.AMInput{AssertID: &zip})
+		tx.WithOutput(types.AMOutput{AssertID: &zip, Address: &to, Value: big.NewInt(1000)})
+		tx.WithValidity(after, until)
+		signed, err := types.SignTx(tx, types.MakeSigner(params.DefaultChainconfig.ChainID), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	process := func(number, time int64, tx *types.Transaction) error {
+		header := &types.Header{Number: big.NewInt(number), Time: big.NewInt(time), GasLimit: params.GenesisGasLimit, Coinbase: coinbase}
+		_, _, _, err := NewStateProcessor(params.DefaultChainconfig, nil, nil, nil).Process(types.NewBlock(header, []*types.Transaction{tx}, nil, nil), statedb, vm.Config{})
+		return err
+	}
+
+	tests := []struct {
+		number, time int64
+		tx           *types.Transaction
+		err          error
+	}{
+		{2, 0, newTx(0, 2, 3), ErrTxNotYetValid},
+		{4, 0, newTx(0, 2, 3), ErrTxExpired},
+		{3, 0, newTx(0, 2, 3), nil},
+		{4, int64(deadline) + 1, newTx(1, 0, deadline), ErrTxExpired},
+		{4, int64(deadline), newTx(1, 0, deadline), nil},
+	}
+	for i, tt := range tests {
+		if err := process(tt.number, tt.time, tt.tx); err != tt.err {
+			t.Fatalf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	checkBalance(t, a, to, zip, big.NewInt(2000))
+}
+
+func TestStateProcessorHTLC(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var (
+		from      = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.Address{0x10}
+		coinbase  = common.Address{0x20}
+		signer    = types.MakeSigner(params.DefaultChainconfig.ChainID)
+		zip       = types.ZipAssetID
+		preimage  = []byte("swap secret")
+		hashLock  = types.HTLCHash(preimage)
+	)
+	statedb, _ := newProcessorTestState(t, from)
+	a := asset.NewAsset(statedb)
+
+	actionTx := func(nonce uint64, typ types.ActionType, params interface{}) *types.Transaction {
+		input, err := types.NewActionInput(zip, typ, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := types.NewTransaction(nonce, 100000, big.NewInt(2), nil)
+		tx.WithInput(input)
+		tx, _ = types.SignTx(tx, signer, key)
+		return tx
+	}
+	process := func(number int64, tx *types.Transaction) *types.ActionResult {
+		header := &types.Header{Number: big.NewInt(number), GasLimit: params.GenesisGasLimit, Coinbase: coinbase}
+		receipts, _, _, err := NewStateProcessor(params.DefaultChainconfig, nil, nil, nil).Process(types.NewBlock(header, []*types.Transaction{tx}, nil, nil), statedb, vm.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return receipts[0].Actions[0]
+	}
+
+	lock := process(1, actionTx(0, types.ActionHTLCLock, &types.HTLCLockParams{
+		Recipient: recipient, Value: big.NewInt(1000), HashLock: hashLock, Timeout: 5,
+	}))
+	if lock.Status != types.ReceiptStatusSuccessful || lock.HashLock != hashLock {
+		t.Fatalf("lock result mismatch: %+v", lock)
+	}
+	checkBalance(t, a, asset.EscrowAddress(hashLock), zip, big.NewInt(1000))
+
+	// a refund before the timeout fails, the claim reveals the preimage
+	refund := process(2, actionTx(1, types.ActionHTLCRefund, &types.HTLCRefundParams{HashLock: hashLock}))
+	if refund.Status != types.ReceiptStatusFailed || refund.Error != asset.ErrHTLCNotTimedOut.Error() {
+		t.Fatalf("refund result mismatch: %+v", refund)
+	}
+	claim := process(3, actionTx(2, types.ActionHTLCClaim, &types.HTLCClaimParams{Preimage: preimage}))
+	if claim.Status != types.ReceiptStatusSuccessful || claim.HashLock != hashLock || string(claim.Preimage) != string(preimage) {
+		t.Fatalf("claim result mismatch: %+v", claim)
+	}
+	checkBalance(t, a, recipient, zip, big.NewInt(1000))
+	checkBalance(t, a, asset.EscrowAddress(hashLock), zip, big.NewInt(0))
+}
+
+func TestStateProcessorFeeAsset(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var (
+		from     = crypto.PubkeyToAddress(key.PublicKey)
+		to       = common.Address{0x10}
+		coinbase = common.Address{0x20}
+		zip      = types.ZipAssetID
+	)
+	statedb, btc := newProcessorTestState(t, from)
+	a := asset.NewAsset(statedb)
+	if err := a.IssueAsset(from, btc, big.NewInt(1000000)); err != nil {
+		t.Fatal(err)
+	}
+
+	newTx := func(nonce uint64, feeAsset common.Address) *types.Transaction {
+		tx := types.NewTransaction(nonce, 100000, big.NewInt(2), nil)
+		tx.WithInput(types.AMInput{AssertID: &zip})
+		tx.WithOutput(types.AMOutput{AssertID: &zip, Address: &to, Value: big.NewInt(1000)})
+		tx.WithFeeAsset(feeAsset)
+		signed, err := types.SignTx(tx, types.MakeSigner(params.DefaultChainconfig.ChainID), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	process := func(tx *types.Transaction) (types.Receipts, error) {
+		header := &types.Header{Number: big.NewInt(1), GasLimit: params.GenesisGasLimit, Coinbase: coinbase}
+		receipts, _, _, err := NewStateProcessor(params.DefaultChainconfig, nil, nil, nil).Process(types.NewBlock(header, []*types.Transaction{tx}, nil, nil), statedb, vm.Config{})
+		return receipts, err
+	}
+
+	// Fees can't be paid in an asset without a fee rate
+	if _, err := process(newTx(0, btc)); err != asset.ErrNoFeeRate {
+		t.Fatalf("fee asset error mismatch: have %v, want %v", err, asset.ErrNoFeeRate)
+	}
+	if err := a.SetFeeRate(from, btc, &asset.FeeRate{Num: big.NewInt(1), Denom: big.NewInt(10)}); err != nil {
+		t.Fatal(err)
+	}
+	receipts, err := process(newTx(0, btc))
+	if err != nil {
+		t.Fatalf("process failed: %v", err)
+	}
+	if receipts[0].Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("unexpected receipt: %v", receipts[0])
+	}
+
+	// The gas is charged in the fee asset and credited to the coinbase
+	fee := new(big.Int).SetUint64(2 * params.TxGas)
+	checkBalance(t, a, from, zip, big.NewInt(10000000-1000))
+	checkBalance(t, a, from, btc, new(big.Int).Sub(big.NewInt(1000500), fee))
+	checkBalance(t, a, coinbase, btc, fee)
+	checkBalance(t, a, coinbase, zip, new(big.Int))
+}
+
+func TestStateProcessorBaseFee(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var (
+		from     = crypto.PubkeyToAddress(key.PublicKey)
+		to       = common.Address{0x10}
+		coinbase = common.Address{0x20}
+		treasury = common.Address{0x30}
+		zip      = types.ZipAssetID
+	)
+	statedb, _ := newProcessorTestState(t, from)
+	a := asset.NewAsset(statedb)
+	config := &params.ChainConfig{
+		ChainID:      params.DefaultChainconfig.ChainID,
+		BaseFeeBlock: big.NewInt(0),
+		BaseFee:      &params.BaseFeeConfig{Initial: big.NewInt(5), Elasticity: 2, Denominator: 8},
+	}
+
+	newTx := func(nonce uint64, gasprice, tip int64) *types.Transaction {
+		tx := types.NewTransaction(nonce, 100000, big.NewInt(gasprice), nil)
+		tx.WithTip(big.NewInt(tip))
+		tx.WithInput(types.AMInput{AssertID: &zip})
+		tx.WithOutput(types.AMOutput{AssertID: &zip, Address: &to, Value: big.NewInt(1000)})
+		signed, err := types.SignTx(tx, types.MakeSigner(config.ChainID), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	process := func(tx *types.Transaction) error {
+		header := &types.Header{Number: big.NewInt(1), GasLimit: params.GenesisGasLimit, Coinbase: coinbase, BaseFee: big.NewInt(5)}
+		_, _, _, err := NewStateProcessor(config, nil, nil, nil).Process(types.NewBlock(header, []*types.Transaction{tx}, nil, nil), statedb, vm.Config{})
+		return err
+	}
+	supply := func() *big.Int {
+		info, err := a.GetAsset(zip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Info.Total
+	}
+
+	if err := process(newTx(0, 10, 11)); err != ErrTipAboveFeeCap {
+		t.Fatalf("tip error mismatch: have %v, want %v", err, ErrTipAboveFeeCap)
+	}
+	if err := process(newTx(0, 4, 4)); err != ErrFeeCapTooLow {
+		t.Fatalf("fee cap error mismatch: have %v, want %v", err, ErrFeeCapTooLow)
+	}
+
+	// The coinbase receives the tip, the base fee is burnt
+	total := supply()
+	if err := process(newTx(0, 10, 3)); err != nil {
+		t.Fatalf("process failed: %v", err)
+	}
+	gas := new(big.Int).SetUint64(params.TxGas)
+	checkBalance(t, a, from, zip, new(big.Int).Sub(big.NewInt(10000000-1000), new(big.Int).Mul(gas, big.NewInt(8))))
+	checkBalance(t, a, coinbase, zip, new(big.Int).Mul(gas, big.NewInt(3)))
+	if burnt := new(big.Int).Sub(total, supply()); burnt.Cmp(new(big.Int).Mul(gas, big.NewInt(5))) != 0 {
+		t.Errorf("burnt base fee mismatch: have %v, want %v", burnt, new(big.Int).Mul(gas, big.NewInt(5)))
+	}
+
+	// A chain with a treasury collects the base fee instead
+	config.BaseFee.Treasury = treasury
+	total = supply()
+	if err := process(newTx(1, 6, 3)); err != nil {
+		t.Fatalf("process failed: %v", err)
+	}
+	checkBalance(t, a, coinbase, zip, new(big.Int).Mul(gas, big.NewInt(3+1)))
+	checkBalance(t, a, treasury, zip, new(big.Int).Mul(gas, big.NewInt(5)))
+	if supply().Cmp(total) != 0 {
+		t.Errorf("supply changed with treasury: have %v, want %v", supply(), total)
+	}
+}
+
+func TestStateProcessorContract(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var (
+		from     = crypto.PubkeyToAddress(key.PublicKey)
+		payee    = common.BytesToAddress([]byte{0x30})
+		coinbase = common.Address{0x20}
+		zip      = types.ZipAssetID
+	)
+	statedb, _ := newProcessorTestState(t, from)
+	a := asset.NewAsset(statedb)
+
+	// The runtime code stores its first input word and pays 1 ZIP to payee
+	runtime := common.Hex2Bytes("600035600055" + "600060006000600060016030" + "5af15000")
+	initcode := append(common.Hex2Bytes("6016600c60003960166000f3"), runtime...)
+
+	newTx := func(nonce uint64, to *common.Address, value int64, data []byte) *types.Transaction {
+		tx := types.NewTransaction(nonce, 200000, big.NewInt(2), data)
+		tx.WithInput(types.AMInput{AssertID: &zip})
+		tx.WithOutput(types.AMOutput{AssertID: &zip, Address: to, Value: big.NewInt(value)})
+		signed, err := types.SignTx(tx, types.MakeSigner(params.DefaultChainconfig.ChainID), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	process := func(number int64, txs ...*types.Transaction) types.Receipts {
+		header := &types.Header{Number: big.NewInt(number), GasLimit: params.GenesisGasLimit, Coinbase: coinbase, Time: big.NewInt(0), Difficulty: big.NewInt(1)}
+		receipts, _, _, err := NewStateProcessor(params.DefaultChainconfig, nil, nil, nil).Process(types.NewBlock(header, txs, nil, nil), statedb, vm.Config{})
+		if err != nil {
+			t.Fatalf("process failed: %v", err)
+		}
+		return receipts
+	}
+
+	// Deploy the contract with an endowment
+	receipts := process(1, newTx(0, nil, 10, initcode))
+	contract := crypto.CreateAddress(from, 0)
+	if receipts[0].Status != types.ReceiptStatusSuccessful || receipts[0].ContractAddress != contract {
+		t.Fatalf("deployment failed: %v", receipts[0])
+	}
+	if code := statedb.GetCode(contract); string(code) != string(runtime) {
+		t.Fatalf("deployed code mismatch: have %x, want %x", code, runtime)
+	}
+	checkBalance(t, a, contract, zip, big.NewInt(10))
+
+	// Call it, the payment it makes is reported as a nested internal transfer
+	input := common.LeftPadBytes([]byte{0x2a}, 32)
+	receipts = process(2, newTx(1, &contract, 0, input))
+	if receipts[0].Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("call failed: %v", receipts[0])
+	}
+	if value := statedb.GetState(contract, common.Hash{}); value != common.BytesToHash(input) {
+		t.Errorf("stored value mismatch: have %x", value)
+	}
+	internal := receipts[0].Internal
+	if len(internal) != 2 {
+		t.Fatalf("internal transfer count mismatch: have %d, want 2", len(internal))
+	}
+	if tx := internal[1]; tx.OpCode != byte(vm.CALL) || tx.From != contract || tx.To != payee || tx.Value.Int64() != 1 {
+		t.Errorf("nested transfer mismatch: %+v", tx)
+	}
+	checkBalance(t, a, contract, zip, big.NewInt(9))
+	checkBalance(t, a, payee, zip, big.NewInt(1))
+
+	// A transaction creating two contracts fails
+	tx := types.NewTransaction(2, 200000, big.NewInt(2), initcode)
+	tx.WithInput(types.AMInput{AssertID: &zip})
+	tx.WithOutput(types.AMOutput{AssertID: &zip, Value: big.NewInt(0)}, types.AMOutput{AssertID: &zip, Value: big.NewInt(0)})
+	tx, err := types.SignTx(tx, types.MakeSigner(params.DefaultChainconfig.ChainID), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipts = process(3, tx); receipts[0].Status != types.ReceiptStatusFailed {
+		t.Errorf("expected failed receipt")
+	}
+}
+
+func checkBalance(t *testing.T, a *asset.Asset, addr, assetID common.Address, want *big.Int) {
+	if have := a.GetBalance(addr, assetID).(*big.Int); have.Cmp(want) != 0 {
+		t.Errorf("balance mismatch for %x of %x: have %v, want %v", addr, assetID, have, want)
+	}
+}
@@ -0,0 +1,432 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core/asset"
+	"github.com/zipper-project/z0/core/scip"
+	"github.com/zipper-project/z0/core/vm"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/txpool"
+	"github.com/zipper-project/z0/types"
+)
+
+// TransferTopic is the first topic of the log emitted for every asset moved by
+// a transaction output. The remaining topics are the sender and the recipient,
+// the data is the transferred amount.
+var TransferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+var errMultipleCreations = errors.New("more than one contract creation")
+
+// StateTransition represents a single transaction being applied to the
+// current world state.
+//
+// A state transition:
+//
+// 1) Nonce handling
+// 2) Pre pay gas in the fee asset
+// 3) Apply the asset actions of the inputs
+// 4) Spend the utxo inputs and move every output from the sender to its recipient,
+// calling the contract it is addressed to or creating one if it has none
+// 5) Refund the unused gas, pay the tip to the coinbase and the base fee to
+// the treasury of the chain or burn it
+//
+// The extra data of the transaction is the input of the called contracts or
+// the code of the created one, a transaction creates at most one contract.
+type StateTransition struct {
+	gp         *GasPool
+	tx         *types.Transaction
+	inputs     []interface{}
+	outputs    []interface{}
+	from       common.Address
+	cosigners  []common.Address
+	gas        uint64
+	initialGas uint64
+	gasPrice   *big.Int
+	baseFee    *big.Int // Base fee in the fee asset, nil if the block has none
+	feeAsset   common.Address
+	asset      *asset.Asset
+	bridge     *scip.Bridge
+	evm        *vm.EVM
+	internal   []*types.InternalTx
+	actions    []*types.ActionResult
+}
+
+// NewStateTransition initialises and returns a new state transition object.
+func NewStateTransition(evm *vm.EVM, engines scip.EngineFactory, tx *types.Transaction, from common.Address, cosigners []common.Address, gp *GasPool) *StateTransition {
+	return &StateTransition{
+		gp:        gp,
+		tx:        tx,
+		from:      from,
+		cosigners: cosigners,
+		gasPrice:  tx.GasPrice(),
+		feeAsset:  tx.FeeAsset(),
+		asset:     asset.NewAsset(evm.StateDB),
+		bridge:    scip.NewBridge(evm.StateDB, evm.ChainConfig().ChainID, engines),
+		evm:       evm,
+	}
+}
+
+// ApplyTransition computes the new state by applying the given transaction
+// against the old state within the environment.
+//
+// ApplyTransition returns the internal transfers, the results of the asset
+// actions, the gas used (which includes gas refunds) and an error if it
+// failed. An error always indicates a core error meaning that the transaction
+// would never be accepted within a block.
+func ApplyTransition(evm *vm.EVM, engines scip.EngineFactory, tx *types.Transaction, from common.Address, cosigners []common.Address, gp *GasPool) ([]*types.InternalTx, []*types.ActionResult, uint64, bool, error) {
+	return NewStateTransition(evm, engines, tx, from, cosigners, gp).TransitionDb()
+}
+
+func (st *StateTransition) useGas(amount uint64) error {
+	if st.gas < amount {
+		return ErrIntrinsicGas
+	}
+	st.gas -= amount
+	return nil
+}
+
+func (st *StateTransition) buyGas() error {
+	// Fees can only be paid in ZIP or in assets with a fee rate.
+	rate, err := st.asset.GetFeeRate(st.feeAsset)
+	if err != nil {
+		return err
+	}
+	if err := st.priceGas(rate); err != nil {
+		return err
+	}
+	mgval := new(big.Int).Mul(new(big.Int).SetUint64(st.tx.Gas()), st.gasPrice)
+	balance, ok := st.asset.GetBalance(st.from, st.feeAsset).(*big.Int)
+	if !ok || balance.Cmp(mgval) < 0 {
+		return ErrInsufficientFundsForGas
+	}
+	if err := st.gp.SubGas(st.tx.Gas()); err != nil {
+		return err
+	}
+	st.gas += st.tx.Gas()
+	st.initialGas = st.tx.Gas()
+	if mgval.Sign() > 0 {
+		return st.asset.SubBalance(st.from, st.feeAsset, mgval)
+	}
+	return nil
+}
+
+// priceGas settles the price per gas in a block with a base fee, the base fee
+// converted into the fee asset plus the tip the gas price leaves on top of it.
+func (st *StateTransition) priceGas(rate *asset.FeeRate) error {
+	if st.evm.BaseFee == nil {
+		return nil
+	}
+	if st.tx.Tip().Cmp(st.tx.GasPrice()) > 0 {
+		return ErrTipAboveFeeCap
+	}
+	st.baseFee = rate.FromZip(st.evm.BaseFee)
+	if st.tx.GasPrice().Cmp(st.baseFee) < 0 {
+		return ErrFeeCapTooLow
+	}
+	st.gasPrice = st.tx.EffectivePrice(st.baseFee)
+	st.evm.GasPrice = new(big.Int).Set(st.gasPrice)
+	return nil
+}
+
+func (st *StateTransition) preCheck() error {
+	// Make sure the inputs and outputs decode.
+	var err error
+	if st.inputs, err = st.tx.GetInputs(); err != nil {
+		return err
+	}
+	if st.outputs, err = st.tx.GetOutputs(); err != nil {
+		return err
+	}
+	// Make sure the block is within the validity window of the transaction.
+	number, time := st.evm.BlockNumber.Uint64(), st.evm.Time.Uint64()
+	if st.tx.NotYetValid(number, time) {
+		return ErrTxNotYetValid
+	}
+	if st.tx.Expired(number, time) {
+		return ErrTxExpired
+	}
+	// Make sure the signers meet the key set of the sender.
+	if err := st.asset.AuthorizeAccount(st.from, st.cosigners); err != nil {
+		return err
+	}
+	// Make sure this transaction's nonce is correct.
+	nonce := st.asset.GetNonce(st.from)
+	if nonce < st.tx.Nonce() {
+		return ErrNonceTooHigh
+	} else if nonce > st.tx.Nonce() {
+		return ErrNonceTooLow
+	}
+	for _, assets := range st.tx.Value() {
+		for _, value := range assets {
+			if value.Sign() < 0 {
+				return ErrNegativeValue
+			}
+		}
+	}
+	return st.buyGas()
+}
+
+// TransitionDb will transition the state by applying the current transaction
+// and returning the result including the used gas. It returns an error if it
+// failed. An error indicates a consensus issue.
+func (st *StateTransition) TransitionDb() (internal []*types.InternalTx, actions []*types.ActionResult, usedGas uint64, failed bool, err error) {
+	if err = st.preCheck(); err != nil {
+		return
+	}
+	intrinsicGas, err := txpool.IntrinsicGas(st.evm.ChainConfig().FeeSchedule(st.evm.BlockNumber), st.tx.Extra(), st.inputs, st.outputs)
+	if err != nil {
+		return nil, nil, 0, false, err
+	}
+	if err = st.useGas(intrinsicGas); err != nil {
+		return nil, nil, 0, false, err
+	}
+
+	// Increment the nonce for the next transaction
+	if !st.asset.Exist(st.from) {
+		if err = st.asset.CreateAccount(st.from); err != nil {
+			return nil, nil, 0, false, err
+		}
+	}
+	if err = st.asset.SetNonce(st.from, st.tx.Nonce()+1); err != nil {
+		return nil, nil, 0, false, err
+	}
+
+	// A failing action or output reverts all the changes of the transaction,
+	// the gas is still charged.
+	snapshot := st.evm.StateDB.Snapshot()
+	vmerr := st.applyActions()
+	if vmerr == nil {
+		vmerr = st.transfer()
+	}
+	if vmerr != nil {
+		st.evm.StateDB.RevertToSnapshot(snapshot)
+		st.internal = nil
+		failed = true
+	}
+	if err = st.refundGas(); err != nil {
+		return nil, nil, 0, false, err
+	}
+	if err = st.payFees(); err != nil {
+		return nil, nil, 0, false, err
+	}
+	return st.internal, st.actions, st.gasUsed(), failed, nil
+}
+
+// applyActions runs the asset actions carried by the inputs in order, the
+// bridge runs the cross-ledger ones. The result of every action run is
+// recorded, execution stops at the first failing one.
+func (st *StateTransition) applyActions() error {
+	actions, err := st.tx.Actions()
+	if err != nil {
+		st.actions = append(st.actions, &types.ActionResult{Status: types.ReceiptStatusFailed, Error: err.Error()})
+		return err
+	}
+	for _, action := range actions {
+		result := &types.ActionResult{Type: action.Type, AssetID: action.AssetID, Status: types.ReceiptStatusSuccessful}
+		st.actions = append(st.actions, result)
+
+		result.HashLock, result.Preimage = action.HTLC()
+
+		var assetID common.Address
+		if action.Type.IsSCIP() {
+			assetID, err = st.bridge.ApplyAction(st.from, st.cosigners, action)
+		} else {
+			assetID, err = st.asset.ApplyAction(st.from, st.cosigners, st.evm.BlockNumber.Uint64(), action)
+		}
+		if err != nil {
+			result.Status = types.ReceiptStatusFailed
+			result.Error = err.Error()
+			return err
+		}
+		result.AssetID = assetID
+	}
+	return nil
+}
+
+// transfer spends the utxo inputs and moves the value of every output from
+// the sender to the recipient. The outputs of an utxo model asset must add up
+// to its spent inputs, change is returned to the sender by an explicit output.
+// Account model outputs run the code of their recipient, an output without
+// recipient creates a contract.
+func (st *StateTransition) transfer() error {
+	utxoIn := make(map[common.Address]*big.Int)
+	utxoOut := make(map[common.Address]*big.Int)
+	for _, v := range st.inputs {
+		input, ok := v.(types.UTXOInput)
+		if !ok {
+			continue
+		}
+		if input.AssertID == nil {
+			return asset.ErrUTXONotFound
+		}
+		op := input.OutPoint()
+		utxo, err := st.asset.GetUTXO(*input.AssertID, op)
+		if err != nil {
+			return err
+		}
+		if utxo.LockTime > st.evm.BlockNumber.Uint64() {
+			return ErrUTXOLocked
+		}
+		if err := st.asset.SubBalance(st.from, *input.AssertID, op); err != nil {
+			return err
+		}
+		if utxoIn[*input.AssertID] == nil {
+			utxoIn[*input.AssertID] = new(big.Int)
+		}
+		utxoIn[*input.AssertID].Add(utxoIn[*input.AssertID], utxo.Value)
+	}
+
+	var created bool
+	for i, v := range st.outputs {
+		var assetID, to *common.Address
+		var value *big.Int
+		var run func() error
+		switch output := v.(type) {
+		case types.AMOutput:
+			assetID, to, value = output.AssertID, output.Address, output.Value
+			if assetID == nil || value == nil {
+				return ErrNegativeValue
+			}
+			if to == nil {
+				if created {
+					return errMultipleCreations
+				}
+				created = true
+				contractAddr := crypto.CreateAddress(st.from, st.tx.Nonce())
+				to = &contractAddr
+				run = func() (err error) {
+					_, st.gas, err = st.evm.CreateAt(vm.AccountRef(st.from), st.tx.Extra(), st.gas, *assetID, value, contractAddr)
+					return err
+				}
+			} else {
+				run = func() (err error) {
+					_, st.gas, err = st.evm.Call(vm.AccountRef(st.from), *to, st.tx.Extra(), st.gas, *assetID, value)
+					return err
+				}
+			}
+		case types.UTXOOutput:
+			assetID, to, value = output.AssertID, output.Address, output.Value
+			if assetID == nil || to == nil || value == nil {
+				return asset.ErrUTXOValue
+			}
+			utxo := &asset.UTXO{
+				OutPoint: types.OutPoint{TxHash: st.tx.Hash(), Index: uint32(i)},
+				Value:    value,
+				LockTime: output.LockTime,
+			}
+			if err := st.asset.AddBalance(*to, *assetID, utxo); err != nil {
+				return err
+			}
+			if utxoOut[*assetID] == nil {
+				utxoOut[*assetID] = new(big.Int)
+			}
+			utxoOut[*assetID].Add(utxoOut[*assetID], value)
+		default:
+			continue
+		}
+		st.internal = append(st.internal, &types.InternalTx{
+			From:    st.from,
+			To:      *to,
+			AssetID: *assetID,
+			Value:   new(big.Int).Set(value),
+		})
+		st.evm.StateDB.AddLog(&types.Log{
+			Address:     *assetID,
+			Topics:      []common.Hash{TransferTopic, st.from.Hash(), to.Hash()},
+			Data:        common.CopyBytes(value.Bytes()),
+			BlockNumber: st.evm.BlockNumber.Uint64(),
+		})
+		if run != nil {
+			// The value moved by the called contracts follows the output
+			internal := len(st.evm.InternalTxs())
+			if err := run(); err != nil {
+				return err
+			}
+			st.internal = append(st.internal, st.evm.InternalTxs()[internal:]...)
+		}
+	}
+
+	for assetID, in := range utxoIn {
+		if out := utxoOut[assetID]; out == nil || out.Cmp(in) != 0 {
+			return ErrUTXOUnbalanced
+		}
+	}
+	for assetID := range utxoOut {
+		if utxoIn[assetID] == nil {
+			return ErrUTXOUnbalanced
+		}
+	}
+	return nil
+}
+
+func (st *StateTransition) refundGas() error {
+	// Apply refund counter, capped to half of the used gas.
+	refund := st.gasUsed() / 2
+	if refund > st.evm.StateDB.GetRefund() {
+		refund = st.evm.StateDB.GetRefund()
+	}
+	st.gas += refund
+
+	// Return the fee asset for remaining gas, exchanged at the original rate.
+	remaining := new(big.Int).Mul(new(big.Int).SetUint64(st.gas), st.gasPrice)
+	if remaining.Sign() > 0 {
+		if err := st.asset.AddBalance(st.from, st.feeAsset, remaining); err != nil {
+			return err
+		}
+	}
+	// Also return remaining gas to the block gas counter so it is
+	// available for the next transaction.
+	st.gp.AddGas(st.gas)
+	return nil
+}
+
+// payFees credits the fee to the coinbase in the asset it was paid in. In a
+// block with a base fee the coinbase only receives the tip, the base fee goes
+// to the treasury of the chain or is burnt if it has none.
+func (st *StateTransition) payFees() error {
+	used := new(big.Int).SetUint64(st.gasUsed())
+	fee := new(big.Int).Mul(used, st.gasPrice)
+	if st.baseFee != nil {
+		base := new(big.Int).Mul(used, st.baseFee)
+		fee.Sub(fee, base)
+
+		treasury := st.evm.ChainConfig().BaseFeeParams().Treasury
+		if treasury == (common.Address{}) {
+			if err := st.asset.BurnFee(st.feeAsset, base); err != nil {
+				return err
+			}
+		} else if base.Sign() > 0 {
+			if err := st.asset.AddBalance(treasury, st.feeAsset, base); err != nil {
+				return err
+			}
+		}
+	}
+	if fee.Sign() > 0 {
+		return st.asset.AddBalance(st.evm.Coinbase, st.feeAsset, fee)
+	}
+	return nil
+}
+
+// gasUsed returns the amount of gas used up by the state transition.
+func (st *StateTransition) gasUsed() uint64 {
+	return st.initialGas - st.gas
+}
@@ -0,0 +1,274 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core/asset"
+	"github.com/zipper-project/z0/core/vm"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/params"
+	"github.com/zipper-project/z0/state"
+	"github.com/zipper-project/z0/types"
+)
+
+const (
+	transitionTestGas   = 100000
+	transitionTestPrice = 2
+)
+
+// transitionTest runs contract transactions of a funded sender, one block
+// each.
+type transitionTest struct {
+	t       *testing.T
+	key     *ecdsa.PrivateKey
+	from    common.Address
+	statedb *state.StateDB
+	asset   *asset.Asset
+	nonce   uint64
+}
+
+func newTransitionTest(t *testing.T) *transitionTest {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	statedb, _ := newProcessorTestState(t, from)
+	return &transitionTest{t: t, key: key, from: from, statedb: statedb, asset: asset.NewAsset(statedb)}
+}
+
+// deploy places the runtime code at addr.
+func (tt *transitionTest) deploy(addr common.Address, code []byte) {
+	if !tt.asset.Exist(addr) {
+		if err := tt.asset.CreateAccount(addr); err != nil {
+			tt.t.Fatal(err)
+		}
+	}
+	tt.statedb.SetCode(addr, code)
+}
+
+// send moves value ZIP to the contract at to, or creates a contract with the
+// data as init code if to is nil.
+func (tt *transitionTest) send(cfg vm.Config, to *common.Address, value int64, data []byte) *types.Receipt {
+	zip := types.ZipAssetID
+	tx := types.NewTransaction(tt.nonce, transitionTestGas, big.NewInt(transitionTestPrice), data)
+	tx.WithInput(types.AMInput{AssertID: &zip})
+	tx.WithOutput(types.AMOutput{AssertID: &zip, Address: to, Value: big.NewInt(value)})
+	tx, err := types.SignTx(tx, types.MakeSigner(params.DefaultChainconfig.ChainID), tt.key)
+	if err != nil {
+		tt.t.Fatal(err)
+	}
+	tt.nonce++
+
+	header := &types.Header{Number: big.NewInt(int64(tt.nonce)), GasLimit: params.GenesisGasLimit, Coinbase: common.Address{0x20}, Time: big.NewInt(0), Difficulty: big.NewInt(1)}
+	receipts, _, _, err := NewStateProcessor(params.DefaultChainconfig, nil, nil, nil).Process(types.NewBlock(header, []*types.Transaction{tx}, nil, nil), tt.statedb, cfg)
+	if err != nil {
+		tt.t.Fatalf("process failed: %v", err)
+	}
+	return receipts[0]
+}
+
+func (tt *transitionTest) balance(addr common.Address) *big.Int {
+	return tt.asset.GetBalance(addr, types.ZipAssetID).(*big.Int)
+}
+
+// checkCharged checks the sender paid the gas used by the receipt and value.
+func (tt *transitionTest) checkCharged(before *big.Int, receipt *types.Receipt, value int64) {
+	tt.t.Helper()
+	want := new(big.Int).Sub(before, big.NewInt(int64(receipt.GasUsed)*transitionTestPrice+value))
+	if have := tt.balance(tt.from); have.Cmp(want) != 0 {
+		tt.t.Errorf("sender balance mismatch: have %v, want %v", have, want)
+	}
+}
+
+func TestStateTransitionRefund(t *testing.T) {
+	tt := newTransitionTest(t)
+
+	// Both contracts store zero in their first slot, the first one clears it
+	code := common.Hex2Bytes("600060005500")
+	cleared, unchanged := common.Address{0x31}, common.Address{0x32}
+	tt.deploy(cleared, code)
+	tt.deploy(unchanged, code)
+	tt.statedb.SetState(cleared, common.Hash{}, common.Hash{0x01})
+
+	receipt := tt.send(vm.Config{}, &unchanged, 0, nil)
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("call failed")
+	}
+	full := receipt.GasUsed
+
+	// The refund of clearing the slot is capped to half of the gas used
+	before := tt.balance(tt.from)
+	receipt = tt.send(vm.Config{}, &cleared, 0, nil)
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("call failed")
+	}
+	refund := full / 2
+	if refund > params.SstoreRefundGas {
+		refund = params.SstoreRefundGas
+	}
+	if receipt.GasUsed != full-refund {
+		t.Errorf("gas used mismatch: have %d, want %d", receipt.GasUsed, full-refund)
+	}
+	if value := tt.statedb.GetState(cleared, common.Hash{}); value != (common.Hash{}) {
+		t.Errorf("slot not cleared: %x", value)
+	}
+	tt.checkCharged(before, receipt, 0)
+}
+
+func TestStateTransitionOutOfGas(t *testing.T) {
+	tt := newTransitionTest(t)
+
+	// The contract sets its first slot and loops forever
+	loop := common.Address{0x31}
+	tt.deploy(loop, common.Hex2Bytes("60016000555b600556"))
+
+	before := tt.balance(tt.from)
+	coinbase := tt.balance(common.Address{0x20})
+	receipt := tt.send(vm.Config{}, &loop, 5, nil)
+	if receipt.Status != types.ReceiptStatusFailed {
+		t.Fatalf("expected failed receipt")
+	}
+	// All the gas is used, the store and the value transfer are reverted
+	if receipt.GasUsed != transitionTestGas {
+		t.Errorf("gas used mismatch: have %d, want %d", receipt.GasUsed, transitionTestGas)
+	}
+	if value := tt.statedb.GetState(loop, common.Hash{}); value != (common.Hash{}) {
+		t.Errorf("store not reverted: %x", value)
+	}
+	if len(receipt.Internal) != 0 {
+		t.Errorf("internal transfers of a failed transaction: %v", receipt.Internal)
+	}
+	checkBalance(t, tt.asset, loop, types.ZipAssetID, new(big.Int))
+	tt.checkCharged(before, receipt, 0)
+	checkBalance(t, tt.asset, common.Address{0x20}, types.ZipAssetID, new(big.Int).Add(coinbase, big.NewInt(transitionTestGas*transitionTestPrice)))
+	if nonce := tt.asset.GetNonce(tt.from); nonce != 1 {
+		t.Errorf("nonce mismatch: have %d, want 1", nonce)
+	}
+}
+
+func TestStateTransitionRevert(t *testing.T) {
+	tt := newTransitionTest(t)
+	revert := common.Hex2Bytes("60006000fd")
+
+	// A reverting creation keeps neither code nor endowment, the gas left is
+	// returned
+	before := tt.balance(tt.from)
+	receipt := tt.send(vm.Config{}, nil, 10, revert)
+	if receipt.Status != types.ReceiptStatusFailed {
+		t.Fatalf("expected failed creation")
+	}
+	contract := crypto.CreateAddress(tt.from, 0)
+	if code := tt.statedb.GetCode(contract); len(code) != 0 {
+		t.Errorf("code of a reverted creation: %x", code)
+	}
+	if receipt.GasUsed >= transitionTestGas {
+		t.Errorf("gas left not returned: used %d", receipt.GasUsed)
+	}
+	checkBalance(t, tt.asset, contract, types.ZipAssetID, new(big.Int))
+	tt.checkCharged(before, receipt, 0)
+
+	// A reverting call doesn't receive the value
+	reverter := common.Address{0x31}
+	tt.deploy(reverter, revert)
+	before = tt.balance(tt.from)
+	if receipt = tt.send(vm.Config{}, &reverter, 5, nil); receipt.Status != types.ReceiptStatusFailed {
+		t.Fatalf("expected failed call")
+	}
+	if receipt.GasUsed >= transitionTestGas {
+		t.Errorf("gas left not returned: used %d", receipt.GasUsed)
+	}
+	checkBalance(t, tt.asset, reverter, types.ZipAssetID, new(big.Int))
+	tt.checkCharged(before, receipt, 0)
+}
+
+func TestStateTransitionNestedFailure(t *testing.T) {
+	tt := newTransitionTest(t)
+	payee := common.BytesToAddress([]byte{0x30})
+
+	// The contract pays 5 ZIP to payee and stores the success of the call
+	// plus one in its first slot
+	contract := common.Address{0x31}
+	tt.deploy(contract, common.Hex2Bytes("6000600060006000600560305af160010160005500"))
+
+	// Receiving 3 ZIP it can't pay, the nested call fails on its own
+	before := tt.balance(tt.from)
+	receipt := tt.send(vm.Config{}, &contract, 3, nil)
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("call failed")
+	}
+	if value := tt.statedb.GetState(contract, common.Hash{}); value != common.BigToHash(big.NewInt(1)) {
+		t.Errorf("nested call result mismatch: %x", value)
+	}
+	if len(receipt.Internal) != 1 {
+		t.Errorf("internal transfer count mismatch: have %d, want 1", len(receipt.Internal))
+	}
+	checkBalance(t, tt.asset, contract, types.ZipAssetID, big.NewInt(3))
+	checkBalance(t, tt.asset, payee, types.ZipAssetID, new(big.Int))
+	tt.checkCharged(before, receipt, 3)
+
+	// With 3 more it pays
+	if receipt = tt.send(vm.Config{}, &contract, 3, nil); receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("call failed")
+	}
+	if value := tt.statedb.GetState(contract, common.Hash{}); value != common.BigToHash(big.NewInt(2)) {
+		t.Errorf("nested call result mismatch: %x", value)
+	}
+	checkBalance(t, tt.asset, contract, types.ZipAssetID, big.NewInt(1))
+	checkBalance(t, tt.asset, payee, types.ZipAssetID, big.NewInt(5))
+}
+
+func TestStateTransitionVMConfig(t *testing.T) {
+	tt := newTransitionTest(t)
+	payee := common.BytesToAddress([]byte{0x30})
+
+	// NoRecursion skips the calls made by contracts
+	pay := common.Address{0x31}
+	tt.deploy(pay, common.Hex2Bytes("600060006000600060016030"+"5af15000"))
+	if receipt := tt.send(vm.Config{NoRecursion: true}, &pay, 1, nil); receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("call failed")
+	}
+	checkBalance(t, tt.asset, pay, types.ZipAssetID, big.NewInt(1))
+	checkBalance(t, tt.asset, payee, types.ZipAssetID, new(big.Int))
+
+	// The tracer sees every step in debug mode
+	tracer := vm.NewStructLogger(nil)
+	if receipt := tt.send(vm.Config{Debug: true, Tracer: tracer}, &pay, 0, nil); receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("call failed")
+	}
+	logs := tracer.StructLogs()
+	if len(logs) != 10 || logs[0].Op != vm.PUSH1 || logs[7].Op != vm.CALL || logs[9].Op != vm.STOP {
+		t.Errorf("trace mismatch: %v", logs)
+	}
+	checkBalance(t, tt.asset, payee, types.ZipAssetID, big.NewInt(1))
+
+	// The preimages of SHA3 are only recorded if enabled
+	word := common.LeftPadBytes([]byte{0x2a}, 32)
+	hash := crypto.Keccak256Hash(word)
+	sha3 := common.Address{0x32}
+	tt.deploy(sha3, common.Hex2Bytes("602a600052602060002050"+"00"))
+	tt.send(vm.Config{}, &sha3, 0, nil)
+	if _, ok := tt.statedb.Preimages()[hash]; ok {
+		t.Errorf("preimage recorded without being enabled")
+	}
+	tt.send(vm.Config{EnablePreimageRecording: true}, &sha3, 0, nil)
+	if preimage := tt.statedb.Preimages()[hash]; string(preimage) != string(word) {
+		t.Errorf("preimage mismatch: have %x, want %x", preimage, word)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"math/big"
 	"time"
 
+	"github.com/zipper-project/z0/common"
 	"github.com/zipper-project/z0/consensus"
 	"github.com/zipper-project/z0/params"
 	"github.com/zipper-project/z0/state"
@@ -49,8 +50,8 @@ func NewBlockValidator(config *params.ChainConfig, blockchain *BlockChain, engin
 	return validator
 }
 
-// ValidateHeader checks whether a header conforms to the consensus rules of the
-// stock  ethash engine.
+// ValidateHeader checks whether a header conforms to the generic chain rules and
+// to the consensus rules of the configured engine.
 func (v *BlockValidator) ValidateHeader(header *types.Header, seal bool) error {
 
 	// Short circuit if the header is known, or it's parent not
@@ -64,11 +65,6 @@ func (v *BlockValidator) ValidateHeader(header *types.Header, seal bool) error {
 		return ErrUnknownAncestor
 	}
 
-	// Ensure that the header's extra-data section is of a reasonable size
-	if uint64(len(header.Extra)) > params.MaximumExtraDataSize {
-		return fmt.Errorf("extra-data too long: %d > %d", len(header.Extra), params.MaximumExtraDataSize)
-	}
-
 	if header.Time.Cmp(big.NewInt(time.Now().Add(allowedFutureBlockTime).Unix())) > 0 {
 		return ErrFutureBlock
 	}
@@ -76,12 +72,6 @@ func (v *BlockValidator) ValidateHeader(header *types.Header, seal bool) error {
 	if header.Time.Cmp(parent.Time) <= 0 {
 		return errZeroBlockTime
 	}
-	// Verify the block's difficulty based in it's timestamp and parent's difficulty
-	expected := v.engine.CalcDifficulty(v.bc, header.Time.Uint64(), parent)
-
-	if expected.Cmp(header.Difficulty) != 0 {
-		return fmt.Errorf("invalid difficulty: have %v, want %v", header.Difficulty, expected)
-	}
 	// Verify that the gas limit is <= 2^63-1
 	cap := uint64(0x7fffffffffffffff)
 	if header.GasLimit > cap {
@@ -106,6 +96,17 @@ func (v *BlockValidator) ValidateHeader(header *types.Header, seal bool) error {
 	if diff := new(big.Int).Sub(header.Number, parent.Number); diff.Cmp(big.NewInt(1)) != 0 {
 		return ErrInvalidNumber
 	}
+	// Verify that the base fee follows the gas usage of the parent
+	if want := CalcBaseFee(v.config, parent); !sameBaseFee(header.BaseFee, want) {
+		return fmt.Errorf("invalid baseFee: have %v, want %v", header.BaseFee, want)
+	}
+	// Verify the engine specific fields, extra-data and difficulty included
+	if v.engine == nil {
+		return ErrNoEngine
+	}
+	if err := v.engine.VerifyHeader(v.bc, header, parent); err != nil {
+		return err
+	}
 	// Verify the engine specific seal securing the block
 	if seal {
 		if err := v.engine.VerifySeal(v.bc, header); err != nil {
@@ -196,3 +197,49 @@ func CalcGasLimit(parent *types.Block) uint64 {
 	}
 	return limit
 }
+
+// CalcBaseFee returns the base fee of the block following parent, nil before
+// the base fee fork. The base fee rises while blocks use more gas than the
+// target and falls while they use less, by at most 1/Denominator per block.
+// It never drops below 1.
+func CalcBaseFee(config *params.ChainConfig, parent *types.Header) *big.Int {
+	if !config.IsBaseFee(new(big.Int).Add(parent.Number, common.Big1)) {
+		return nil
+	}
+	fee := config.BaseFeeParams()
+	if parent.BaseFee == nil || parent.BaseFee.Sign() == 0 {
+		return new(big.Int).Set(fee.Initial)
+	}
+	target := parent.GasLimit / fee.Elasticity
+	if target == 0 || parent.GasUsed == target {
+		return new(big.Int).Set(parent.BaseFee)
+	}
+	var delta *big.Int
+	if parent.GasUsed > target {
+		delta = new(big.Int).SetUint64(parent.GasUsed - target)
+	} else {
+		delta = new(big.Int).SetUint64(target - parent.GasUsed)
+	}
+	delta.Mul(delta, parent.BaseFee)
+	delta.Div(delta, new(big.Int).SetUint64(target))
+	delta.Div(delta, new(big.Int).SetUint64(fee.Denominator))
+
+	if parent.GasUsed > target {
+		if delta.Sign() == 0 {
+			delta.SetUint64(1)
+		}
+		return delta.Add(parent.BaseFee, delta)
+	}
+	if delta.Sub(parent.BaseFee, delta); delta.Sign() <= 0 {
+		delta.SetUint64(1)
+	}
+	return delta
+}
+
+// sameBaseFee reports whether two base fees are equal, nil standing for none.
+func sameBaseFee(a, b *big.Int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Cmp(b) == 0
+}
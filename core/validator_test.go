@@ -15,7 +15,17 @@
 // along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
 package core
 
-import "testing"
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/params"
+	"github.com/zipper-project/z0/state"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/zdb"
+)
 
 func TestValidateHeader(t *testing.T) {
 
@@ -26,5 +36,83 @@ func TestValidateTxs(t *testing.T) {
 }
 
 func TestValidateState(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(zdb.NewMemDatabase()))
+	statedb.SetState(common.Address{0x01}, common.Hash{0x01}, common.Hash{0x02})
+	receipt := types.NewReceipt(nil, false, 21000)
+	receipt.Logs = []*types.Log{{Address: common.Address{0x01}, Topics: []common.Hash{{0x03}}}}
+	receipts := types.Receipts{receipt}
+
+	block := types.NewBlockWithHeader(&types.Header{
+		Number:      big.NewInt(1),
+		GasUsed:     21000,
+		Bloom:       types.CreateBloom(receipts),
+		ReceiptHash: types.DeriveSha(receipts),
+		Root:        statedb.IntermediateRoot(false),
+	})
+	validator := NewBlockValidator(params.DefaultChainconfig, nil, nil)
+	if err := validator.ValidateState(block, nil, statedb, receipts, 21000, false); err != nil {
+		t.Fatalf("valid block rejected: %v", err)
+	}
+
+	// Every difference between the block and its local execution is caught
+	failed := types.NewReceipt(nil, true, 21000)
+	failed.Logs = receipt.Logs
+	changed := statedb.Copy()
+	changed.SetState(common.Address{0x01}, common.Hash{0x01}, common.Hash{0x03})
+	tests := []struct {
+		name     string
+		statedb  *state.StateDB
+		receipts types.Receipts
+		usedGas  uint64
+		want     string
+	}{
+		{"gas used", statedb, receipts, 42000, "invalid gas used"},
+		{"bloom", statedb, types.Receipts{types.NewReceipt(nil, false, 21000)}, 21000, "invalid bloom"},
+		{"receipt root", statedb, types.Receipts{failed}, 21000, "invalid receipt root hash"},
+		{"state root", changed, receipts, 21000, "invalid merkle root"},
+	}
+	for _, tt := range tests {
+		err := validator.ValidateState(block, nil, tt.statedb, tt.receipts, tt.usedGas, false)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error mismatch: have %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
 
+func TestCalcBaseFee(t *testing.T) {
+	config := &params.ChainConfig{
+		BaseFeeBlock: big.NewInt(5),
+		BaseFee:      &params.BaseFeeConfig{Initial: big.NewInt(1000), Elasticity: 2, Denominator: 8},
+	}
+	tests := []struct {
+		number            int64
+		baseFee           int64
+		gasLimit, gasUsed uint64
+		want              int64 // zero for none
+	}{
+		{3, 0, 1000000, 1000000, 0},       // before the fork
+		{4, 0, 1000000, 1000000, 1000},    // the fork block starts at the initial fee
+		{5, 1000, 1000000, 500000, 1000},  // at the target
+		{5, 1000, 1000000, 1000000, 1125}, // full block
+		{5, 1000, 1000000, 0, 875},        // empty block
+		{5, 1000, 1000000, 750000, 1062},  // half way above the target
+		{5, 7, 1000000, 500001, 8},        // rises by at least one
+		{5, 1, 1000000, 0, 1},             // never drops to zero
+	}
+	for i, tt := range tests {
+		parent := &types.Header{Number: big.NewInt(tt.number), GasLimit: tt.gasLimit, GasUsed: tt.gasUsed}
+		if tt.baseFee != 0 {
+			parent.BaseFee = big.NewInt(tt.baseFee)
+		}
+		have := CalcBaseFee(config, parent)
+		if tt.want == 0 {
+			if have != nil {
+				t.Errorf("test %d: base fee before the fork: %v", i, have)
+			}
+			continue
+		}
+		if have == nil || have.Int64() != tt.want {
+			t.Errorf("test %d: base fee mismatch: have %v, want %d", i, have, tt.want)
+		}
+	}
 }
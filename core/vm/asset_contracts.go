@@ -0,0 +1,226 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core/asset"
+	"github.com/zipper-project/z0/params"
+)
+
+var (
+	errAssetContractValue    = errors.New("asset contracts don't accept value")
+	errAssetContractDelegate = errors.New("asset contracts can't be delegate called")
+)
+
+// AssetContract is a native Go contract giving contracts access to the assets
+// of the ledger. Unlike a PrecompiledContract it runs against the state of the
+// EVM, on behalf of the contract calling it.
+//
+// The input and output are made of 32 byte words laid out like the static
+// arguments of a contract ABI call, strings are passed as bytes32.
+type AssetContract interface {
+	RequiredGas(input []byte) uint64                                // RequiredGas calculates the contract gas use
+	Run(evm *EVM, contract *Contract, input []byte) ([]byte, error) // Run runs the asset contract
+}
+
+// AssetContracts contains the asset contracts callable at the addresses
+// following the precompiled contracts.
+var AssetContracts = map[common.Address]AssetContract{
+	common.BytesToAddress([]byte{1, 0}): &assetBalance{},
+	common.BytesToAddress([]byte{1, 1}): &assetTransfer{},
+	common.BytesToAddress([]byte{1, 2}): &assetRegister{},
+	common.BytesToAddress([]byte{1, 3}): &assetIssue{},
+	common.BytesToAddress([]byte{1, 4}): &assetInfo{},
+	common.BytesToAddress([]byte{1, 5}): &assetCallValue{},
+}
+
+// RunAssetContract runs and evaluates the output of an asset contract. Asset
+// contracts act on behalf of the caller, they can't hold value nor be called
+// with the context of another contract.
+func RunAssetContract(p AssetContract, evm *EVM, contract *Contract, input []byte) (ret []byte, err error) {
+	if contract.value != nil && contract.value.Sign() > 0 {
+		return nil, errAssetContractValue
+	}
+	if contract.DelegateCall {
+		return nil, errAssetContractDelegate
+	}
+	gas := p.RequiredGas(input)
+	if contract.UseGas(gas) {
+		return p.Run(evm, contract, input)
+	}
+	return nil, ErrOutOfGas
+}
+
+// word returns the n'th 32 byte word of the input, zero padded.
+func word(input []byte, n uint64) []byte {
+	return getData(input, n*32, 32)
+}
+
+func wordAddress(input []byte, n uint64) common.Address {
+	return common.BytesToAddress(word(input, n))
+}
+
+func wordBig(input []byte, n uint64) *big.Int {
+	return new(big.Int).SetBytes(word(input, n))
+}
+
+func wordString(input []byte, n uint64) string {
+	return string(bytes.TrimRight(word(input, n), "\x00"))
+}
+
+func stringWord(s string) []byte {
+	return common.RightPadBytes([]byte(s), 32)[:32]
+}
+
+func bigWord(x *big.Int) []byte {
+	if x == nil {
+		return make([]byte, 32)
+	}
+	return common.LeftPadBytes(x.Bytes(), 32)
+}
+
+// assetBalance returns the balance of (address holder, address asset).
+type assetBalance struct{}
+
+func (c *assetBalance) RequiredGas(input []byte) uint64 {
+	return params.AssetBalanceGas
+}
+
+func (c *assetBalance) Run(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
+	return bigWord(evm.GetBalance(wordAddress(input, 0), wordAddress(input, 1))), nil
+}
+
+// assetTransfer moves (address asset, address to, uint256 value) from the
+// caller to the recipient.
+type assetTransfer struct{}
+
+func (c *assetTransfer) RequiredGas(input []byte) uint64 {
+	return params.AssetTransferGas
+}
+
+func (c *assetTransfer) Run(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
+	if evm.interpreter.readOnly {
+		return nil, errWriteProtection
+	}
+	assetID, to, value := wordAddress(input, 0), wordAddress(input, 1), wordBig(input, 2)
+	if !evm.CanTransfer(evm.StateDB, contract.Caller(), assetID, value) {
+		return nil, ErrInsufficientBalance
+	}
+	if err := evm.transfer(CALL, contract.Caller(), to, assetID, value); err != nil {
+		return nil, err
+	}
+	return bigWord(common.Big1), nil
+}
+
+// assetRegister registers an account model asset owned by the caller from
+// (bytes32 name, bytes32 symbol, uint256 total, uint256 decimals,
+// uint256 maxSupply) and returns its address.
+type assetRegister struct{}
+
+func (c *assetRegister) RequiredGas(input []byte) uint64 {
+	return params.AssetRegisterGas
+}
+
+func (c *assetRegister) Run(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
+	if evm.interpreter.readOnly {
+		return nil, errWriteProtection
+	}
+	decimals := wordBig(input, 3)
+	if !decimals.IsUint64() {
+		return nil, errGasUintOverflow
+	}
+	desc, err := json.Marshal(&asset.AccountAssetInfo{
+		Name:      wordString(input, 0),
+		Symbol:    wordString(input, 1),
+		Total:     wordBig(input, 2),
+		Decimals:  decimals.Uint64(),
+		Owner:     contract.Caller(),
+		MaxSupply: wordBig(input, 4),
+	})
+	if err != nil {
+		return nil, err
+	}
+	assetID, err := evm.asset.RegisterAsset(asset.AccountModel, contract.Caller(), string(desc))
+	if err != nil {
+		return nil, err
+	}
+	return common.LeftPadBytes(assetID.Bytes(), 32), nil
+}
+
+// assetIssue issues (address asset, uint256 value) to the caller, which must
+// own the asset.
+type assetIssue struct{}
+
+func (c *assetIssue) RequiredGas(input []byte) uint64 {
+	return params.AssetIssueGas
+}
+
+func (c *assetIssue) Run(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
+	if evm.interpreter.readOnly {
+		return nil, errWriteProtection
+	}
+	if err := evm.asset.IssueAsset(contract.Caller(), wordAddress(input, 0), wordBig(input, 1)); err != nil {
+		return nil, err
+	}
+	return bigWord(common.Big1), nil
+}
+
+// assetInfo returns (address owner, uint256 total, uint256 decimals,
+// uint256 maxSupply, bytes32 name, bytes32 symbol) of (address asset).
+type assetInfo struct{}
+
+func (c *assetInfo) RequiredGas(input []byte) uint64 {
+	return params.AssetInfoGas
+}
+
+func (c *assetInfo) Run(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
+	registered, err := evm.asset.GetAsset(wordAddress(input, 0))
+	if err != nil {
+		return nil, err
+	}
+	info := registered.Info
+	ret := make([]byte, 0, 6*32)
+	ret = append(ret, common.LeftPadBytes(info.Owner.Bytes(), 32)...)
+	ret = append(ret, bigWord(info.Total)...)
+	ret = append(ret, bigWord(new(big.Int).SetUint64(info.Decimals))...)
+	ret = append(ret, bigWord(info.MaxSupply)...)
+	ret = append(ret, stringWord(info.Name)...)
+	ret = append(ret, stringWord(info.Symbol)...)
+	return ret, nil
+}
+
+// assetCallValue returns (address asset, uint256 value) attached to the call
+// of the caller, CALLVALUE alone doesn't tell the asset of the value.
+type assetCallValue struct{}
+
+func (c *assetCallValue) RequiredGas(input []byte) uint64 {
+	return GasQuickStep
+}
+
+func (c *assetCallValue) Run(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
+	caller, ok := contract.caller.(*Contract)
+	if !ok {
+		return make([]byte, 64), nil
+	}
+	return append(common.LeftPadBytes(caller.assetID.Bytes(), 32), bigWord(caller.value)...), nil
+}
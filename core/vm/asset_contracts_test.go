@@ -0,0 +1,130 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core/asset"
+	"github.com/zipper-project/z0/params"
+	"github.com/zipper-project/z0/state"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/zdb"
+)
+
+func newAssetTestEVM(t *testing.T, funded common.Address) *EVM {
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(zdb.NewMemDatabase()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := asset.InitZip(statedb, big.NewInt(1000000), 8, funded); err != nil {
+		t.Fatal(err)
+	}
+	ctx := Context{
+		CanTransfer: func(db StateDB, addr, assetID common.Address, amount *big.Int) bool {
+			ok, err := asset.NewAsset(db).EnoughBalance(addr, assetID, amount)
+			return err == nil && ok
+		},
+		Transfer: func(db StateDB, sender, recipient, assetID common.Address, amount *big.Int) error {
+			a := asset.NewAsset(db)
+			if err := a.SubBalance(sender, assetID, amount); err != nil {
+				return err
+			}
+			return a.AddBalance(recipient, assetID, amount)
+		},
+		BlockNumber: big.NewInt(1),
+		Time:        big.NewInt(0),
+		Difficulty:  big.NewInt(1),
+		GasPrice:    big.NewInt(1),
+	}
+	return NewEVM(ctx, statedb, params.DefaultChainconfig, Config{})
+}
+
+func words(ws ...[]byte) []byte {
+	var input []byte
+	for _, w := range ws {
+		input = append(input, common.LeftPadBytes(w, 32)...)
+	}
+	return input
+}
+
+func TestAssetContracts(t *testing.T) {
+	var (
+		owner    = common.Address{0x10}
+		to       = common.Address{0x20}
+		balance  = common.BytesToAddress([]byte{1, 0})
+		transfer = common.BytesToAddress([]byte{1, 1})
+		register = common.BytesToAddress([]byte{1, 2})
+		issue    = common.BytesToAddress([]byte{1, 3})
+		info     = common.BytesToAddress([]byte{1, 4})
+		value    = common.BytesToAddress([]byte{1, 5})
+		zero     = new(big.Int)
+	)
+	evm := newAssetTestEVM(t, owner)
+	call := func(caller ContractRef, addr common.Address, input []byte) []byte {
+		ret, _, err := evm.Call(caller, addr, input, 100000, types.ZipAssetID, zero)
+		if err != nil {
+			t.Fatalf("call to %x failed: %v", addr, err)
+		}
+		return ret
+	}
+	checkBalance := func(addr, assetID common.Address, want int64) {
+		ret := call(AccountRef(owner), balance, words(addr.Bytes(), assetID.Bytes()))
+		if have := new(big.Int).SetBytes(ret); have.Int64() != want {
+			t.Errorf("balance mismatch for %x: have %v, want %d", addr, have, want)
+		}
+	}
+
+	// Register an asset owned by the caller and move some of it
+	input := append(stringWord("gold"), stringWord("GLD")...)
+	input = append(input, words(big.NewInt(100).Bytes(), big.NewInt(2).Bytes(), big.NewInt(1000).Bytes())...)
+	gld := common.BytesToAddress(call(AccountRef(owner), register, input))
+	checkBalance(owner, gld, 100)
+
+	call(AccountRef(owner), transfer, words(gld.Bytes(), to.Bytes(), big.NewInt(30).Bytes()))
+	checkBalance(owner, gld, 70)
+	checkBalance(to, gld, 30)
+
+	call(AccountRef(owner), issue, words(gld.Bytes(), big.NewInt(50).Bytes()))
+	checkBalance(owner, gld, 120)
+	if _, _, err := evm.Call(AccountRef(to), issue, words(gld.Bytes(), big.NewInt(50).Bytes()), 100000, types.ZipAssetID, zero); err != asset.ErrNotOwner {
+		t.Errorf("issue error mismatch: have %v, want %v", err, asset.ErrNotOwner)
+	}
+
+	ret := call(AccountRef(owner), info, words(gld.Bytes()))
+	if len(ret) != 6*32 || common.BytesToAddress(ret[:32]) != owner || new(big.Int).SetBytes(ret[32:64]).Int64() != 150 || wordString(ret, 5) != "GLD" {
+		t.Errorf("asset info mismatch: %x", ret)
+	}
+
+	// State changes are refused in static calls and value can't be attached
+	if _, _, err := evm.StaticCall(AccountRef(owner), transfer, words(gld.Bytes(), to.Bytes(), big.NewInt(1).Bytes()), 100000); err != errWriteProtection {
+		t.Errorf("static transfer error mismatch: have %v, want %v", err, errWriteProtection)
+	}
+	if _, _, err := evm.Call(AccountRef(owner), balance, nil, 100000, types.ZipAssetID, big.NewInt(1)); err != errAssetContractValue {
+		t.Errorf("value error mismatch: have %v, want %v", err, errAssetContractValue)
+	}
+	checkBalance(owner, gld, 120)
+
+	// The asset of the value attached to the calling contract is reported
+	caller := NewContract(AccountRef(owner), AccountRef(to), gld, big.NewInt(7), 100000)
+	ret = call(caller, value, nil)
+	if common.BytesToAddress(ret[:32]) != gld || new(big.Int).SetBytes(ret[32:]).Int64() != 7 {
+		t.Errorf("call value mismatch: %x", ret)
+	}
+}
@@ -0,0 +1,466 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core/asset"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/params"
+	"github.com/zipper-project/z0/types"
+)
+
+// emptyCodeHash is used by create to ensure deployment is disallowed to already
+// deployed contract addresses (relevant after the account abstraction).
+var emptyCodeHash = crypto.Keccak256Hash(nil)
+
+type (
+	// CanTransferFunc is the signature of a transfer guard function
+	CanTransferFunc func(StateDB, common.Address, common.Address, *big.Int) bool
+	// TransferFunc is the signature of a transfer function
+	TransferFunc func(StateDB, common.Address, common.Address, common.Address, *big.Int) error
+	// GetHashFunc returns the nth block hash in the blockchain
+	// and is used by the BLOCKHASH EVM op code.
+	GetHashFunc func(uint64) common.Hash
+)
+
+// run runs the given contract and takes care of running precompiles with a fallback to the byte code interpreter.
+func run(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
+	if contract.CodeAddr != nil {
+		if p := PrecompiledContracts[*contract.CodeAddr]; p != nil {
+			return RunPrecompiledContract(p, input, contract)
+		}
+		if p := AssetContracts[*contract.CodeAddr]; p != nil {
+			return RunAssetContract(p, evm, contract, input)
+		}
+	}
+	return evm.interpreter.Run(contract, input)
+}
+
+// Context provides the EVM with auxiliary information. Once provided
+// it shouldn't be modified.
+type Context struct {
+	// CanTransfer returns whether the account contains
+	// sufficient balance of the asset to transfer the value
+	CanTransfer CanTransferFunc
+	// Transfer transfers the value of the asset from one account to the other
+	Transfer TransferFunc
+	// GetHash returns the hash corresponding to n
+	GetHash GetHashFunc
+
+	// Message information
+	Origin   common.Address // Provides information for ORIGIN
+	GasPrice *big.Int       // Provides information for GASPRICE
+
+	// Block information
+	Coinbase    common.Address // Provides information for COINBASE
+	GasLimit    uint64         // Provides information for GASLIMIT
+	BlockNumber *big.Int       // Provides information for NUMBER
+	Time        *big.Int       // Provides information for TIME
+	Difficulty  *big.Int       // Provides information for DIFFICULTY
+	BaseFee     *big.Int       // Base fee of the block in ZIP per gas, nil if it has none
+}
+
+// EVM is the z0 Virtual Machine base object and provides
+// the necessary tools to run a contract on the given state with
+// the provided context. It should be noted that any error
+// generated through any of the calls should be considered a
+// revert-state-and-consume-all-gas operation, no checks on
+// specific errors should ever be performed. The interpreter makes
+// sure that any errors generated are to be considered faulty code.
+//
+// The value moved by a call is denominated in one asset. Transactions call
+// contracts with the asset of their output, the CALL, CALLCODE, CREATE and
+// SELFDESTRUCT instructions move ZIP.
+//
+// The EVM should never be reused and is not thread safe.
+type EVM struct {
+	// Context provides auxiliary blockchain related information
+	Context
+	// StateDB gives access to the underlying state
+	StateDB StateDB
+	// Depth is the current call stack
+	depth int
+
+	// asset reads and writes the balances and nonces of the state
+	asset *asset.Asset
+
+	// chainConfig contains information about the current chain
+	chainConfig *params.ChainConfig
+	// virtual machine configuration options used to initialise the
+	// evm.
+	vmConfig Config
+	// global (to this context) z0 virtual machine
+	// used throughout the execution of the tx.
+	interpreter *Interpreter
+	// abort is used to abort the EVM calling operations
+	// NOTE: must be set atomically
+	abort int32
+	// callGasTemp holds the gas available for the current call. This is needed because the
+	// available gas is calculated in gasCall* according to the 63/64 rule and later
+	// applied in opCall*.
+	callGasTemp uint64
+	// internal records the value moved by the calls of contracts
+	internal []*types.InternalTx
+}
+
+// NewEVM returns a new EVM. The returned EVM is not thread safe and should
+// only ever be used *once*.
+func NewEVM(ctx Context, statedb StateDB, chainConfig *params.ChainConfig, vmConfig Config) *EVM {
+	evm := &EVM{
+		Context:     ctx,
+		StateDB:     statedb,
+		asset:       asset.NewAsset(statedb),
+		vmConfig:    vmConfig,
+		chainConfig: chainConfig,
+	}
+
+	evm.interpreter = NewInterpreter(evm, vmConfig)
+	return evm
+}
+
+// Cancel cancels any running EVM operation. This may be called concurrently and
+// it's safe to be called multiple times.
+func (evm *EVM) Cancel() {
+	atomic.StoreInt32(&evm.abort, 1)
+}
+
+// Call executes the contract associated with the addr with the given input as
+// parameters. It also handles any necessary value transfer required and takes
+// the necessary steps to create accounts and reverses the state in case of an
+// execution error or failed value transfer.
+func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas uint64, assetID common.Address, value *big.Int) (ret []byte, leftOverGas uint64, err error) {
+	if evm.vmConfig.NoRecursion && evm.depth > 0 {
+		return nil, gas, nil
+	}
+
+	// Fail if we're trying to execute above the call depth limit
+	if evm.depth > int(params.CallCreateDepth) {
+		return nil, gas, ErrDepth
+	}
+	// Fail if we're trying to transfer more than the available balance
+	if !evm.Context.CanTransfer(evm.StateDB, caller.Address(), assetID, value) {
+		return nil, gas, ErrInsufficientBalance
+	}
+
+	var (
+		to       = AccountRef(addr)
+		snapshot = evm.StateDB.Snapshot()
+		internal = len(evm.internal)
+	)
+	if err := evm.transfer(CALL, caller.Address(), to.Address(), assetID, value); err != nil {
+		evm.StateDB.RevertToSnapshot(snapshot)
+		return nil, gas, err
+	}
+
+	// Initialise a new contract and set the code that is to be used by the EVM.
+	// The contract is a scoped environment for this execution context only.
+	contract := NewContract(caller, to, assetID, value, gas)
+	contract.SetCallCode(&addr, evm.StateDB.GetCodeHash(addr), evm.StateDB.GetCode(addr))
+
+	start := time.Now()
+
+	// Capture the tracer start/end events in debug mode
+	if evm.vmConfig.Debug && evm.depth == 0 {
+		evm.vmConfig.Tracer.CaptureStart(caller.Address(), addr, false, input, gas, value)
+
+		defer func() { // Lazy evaluation of the parameters
+			evm.vmConfig.Tracer.CaptureEnd(ret, gas-contract.Gas, time.Since(start), err)
+		}()
+	}
+	ret, err = run(evm, contract, input)
+
+	// When an error was returned by the EVM or when setting the creation code
+	// above we revert to the snapshot and consume any gas remaining.
+	if err != nil {
+		evm.StateDB.RevertToSnapshot(snapshot)
+		evm.internal = evm.internal[:internal]
+		if err != errExecutionReverted {
+			contract.UseGas(contract.Gas)
+		}
+	}
+	return ret, contract.Gas, err
+}
+
+// CallCode executes the contract associated with the addr with the given input
+// as parameters. It also handles any necessary value transfer required and takes
+// the necessary steps to create accounts and reverses the state in case of an
+// execution error or failed value transfer.
+//
+// CallCode differs from Call in the sense that it executes the given address'
+// code with the caller as context.
+func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte, gas uint64, assetID common.Address, value *big.Int) (ret []byte, leftOverGas uint64, err error) {
+	if evm.vmConfig.NoRecursion && evm.depth > 0 {
+		return nil, gas, nil
+	}
+
+	// Fail if we're trying to execute above the call depth limit
+	if evm.depth > int(params.CallCreateDepth) {
+		return nil, gas, ErrDepth
+	}
+	// Fail if we're trying to transfer more than the available balance
+	if !evm.CanTransfer(evm.StateDB, caller.Address(), assetID, value) {
+		return nil, gas, ErrInsufficientBalance
+	}
+
+	var (
+		snapshot = evm.StateDB.Snapshot()
+		internal = len(evm.internal)
+		to       = AccountRef(caller.Address())
+	)
+	// initialise a new contract and set the code that is to be used by the
+	// EVM. The contract is a scoped environment for this execution context
+	// only.
+	contract := NewContract(caller, to, assetID, value, gas)
+	contract.SetCallCode(&addr, evm.StateDB.GetCodeHash(addr), evm.StateDB.GetCode(addr))
+
+	ret, err = run(evm, contract, input)
+	if err != nil {
+		evm.StateDB.RevertToSnapshot(snapshot)
+		evm.internal = evm.internal[:internal]
+		if err != errExecutionReverted {
+			contract.UseGas(contract.Gas)
+		}
+	}
+	return ret, contract.Gas, err
+}
+
+// DelegateCall executes the contract associated with the addr with the given input
+// as parameters. It reverses the state in case of an execution error.
+//
+// DelegateCall differs from CallCode in the sense that it executes the given address'
+// code with the caller as context and the caller is set to the caller of the caller.
+func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []byte, gas uint64) (ret []byte, leftOverGas uint64, err error) {
+	if evm.vmConfig.NoRecursion && evm.depth > 0 {
+		return nil, gas, nil
+	}
+	// Fail if we're trying to execute above the call depth limit
+	if evm.depth > int(params.CallCreateDepth) {
+		return nil, gas, ErrDepth
+	}
+
+	var (
+		snapshot = evm.StateDB.Snapshot()
+		internal = len(evm.internal)
+		to       = AccountRef(caller.Address())
+	)
+
+	// Initialise a new contract and make initialise the delegate values
+	contract := NewContract(caller, to, common.Address{}, nil, gas).AsDelegate()
+	contract.SetCallCode(&addr, evm.StateDB.GetCodeHash(addr), evm.StateDB.GetCode(addr))
+
+	ret, err = run(evm, contract, input)
+	if err != nil {
+		evm.StateDB.RevertToSnapshot(snapshot)
+		evm.internal = evm.internal[:internal]
+		if err != errExecutionReverted {
+			contract.UseGas(contract.Gas)
+		}
+	}
+	return ret, contract.Gas, err
+}
+
+// StaticCall executes the contract associated with the addr with the given input
+// as parameters while disallowing any modifications to the state during the call.
+// Opcodes that attempt to perform such modifications will result in exceptions
+// instead of performing the modifications.
+func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte, gas uint64) (ret []byte, leftOverGas uint64, err error) {
+	if evm.vmConfig.NoRecursion && evm.depth > 0 {
+		return nil, gas, nil
+	}
+	// Fail if we're trying to execute above the call depth limit
+	if evm.depth > int(params.CallCreateDepth) {
+		return nil, gas, ErrDepth
+	}
+	// Make sure the readonly is only set if we aren't in readonly yet
+	// this makes also sure that the readonly flag isn't removed for
+	// child calls.
+	if !evm.interpreter.readOnly {
+		evm.interpreter.readOnly = true
+		defer func() { evm.interpreter.readOnly = false }()
+	}
+
+	var (
+		to       = AccountRef(addr)
+		snapshot = evm.StateDB.Snapshot()
+	)
+	// Initialise a new contract and set the code that is to be used by the
+	// EVM. The contract is a scoped environment for this execution context
+	// only.
+	contract := NewContract(caller, to, types.ZipAssetID, new(big.Int), gas)
+	contract.SetCallCode(&addr, evm.StateDB.GetCodeHash(addr), evm.StateDB.GetCode(addr))
+
+	// When an error was returned by the EVM or when setting the creation code
+	// above we revert to the snapshot and consume any gas remaining.
+	ret, err = run(evm, contract, input)
+	if err != nil {
+		evm.StateDB.RevertToSnapshot(snapshot)
+		if err != errExecutionReverted {
+			contract.UseGas(contract.Gas)
+		}
+	}
+	return ret, contract.Gas, err
+}
+
+// create creates a new contract at the address using code as deployment code.
+func (evm *EVM) create(caller ContractRef, code []byte, gas uint64, assetID common.Address, value *big.Int, address common.Address) ([]byte, common.Address, uint64, error) {
+	// Depth check execution. Fail if we're trying to execute above the
+	// limit.
+	if evm.depth > int(params.CallCreateDepth) {
+		return nil, common.Address{}, gas, ErrDepth
+	}
+	if !evm.CanTransfer(evm.StateDB, caller.Address(), assetID, value) {
+		return nil, common.Address{}, gas, ErrInsufficientBalance
+	}
+
+	// Ensure there's no existing contract already at the designated address
+	contractHash := evm.StateDB.GetCodeHash(address)
+	if evm.asset.GetNonce(address) != 0 || (contractHash != (common.Hash{}) && contractHash != emptyCodeHash) {
+		return nil, common.Address{}, 0, ErrContractAddressCollision
+	}
+	// Create a new account on the state
+	var (
+		snapshot = evm.StateDB.Snapshot()
+		internal = len(evm.internal)
+	)
+	if !evm.asset.Exist(address) {
+		if err := evm.asset.CreateAccount(address); err != nil {
+			return nil, common.Address{}, gas, err
+		}
+	}
+	if err := evm.asset.SetNonce(address, 1); err != nil {
+		evm.StateDB.RevertToSnapshot(snapshot)
+		return nil, common.Address{}, gas, err
+	}
+	if err := evm.transfer(CREATE, caller.Address(), address, assetID, value); err != nil {
+		evm.StateDB.RevertToSnapshot(snapshot)
+		return nil, common.Address{}, gas, err
+	}
+
+	// initialise a new contract and set the code that is to be used by the
+	// EVM. The contract is a scoped environment for this execution context
+	// only.
+	contract := NewContract(caller, AccountRef(address), assetID, value, gas)
+	contract.SetCallCode(&address, crypto.Keccak256Hash(code), code)
+
+	if evm.vmConfig.NoRecursion && evm.depth > 0 {
+		return nil, address, gas, nil
+	}
+
+	if evm.vmConfig.Debug && evm.depth == 0 {
+		evm.vmConfig.Tracer.CaptureStart(caller.Address(), address, true, code, gas, value)
+	}
+	start := time.Now()
+
+	ret, err := run(evm, contract, nil)
+
+	// check whether the max code size has been exceeded
+	maxCodeSizeExceeded := len(ret) > params.MaxCodeSize
+	// if the contract creation ran successfully and no errors were returned
+	// calculate the gas required to store the code. If the code could not
+	// be stored due to not enough gas set an error and let it be handled
+	// by the error checking condition below.
+	if err == nil && !maxCodeSizeExceeded {
+		createDataGas := uint64(len(ret)) * params.CreateDataGas
+		if contract.UseGas(createDataGas) {
+			evm.StateDB.SetCode(address, ret)
+		} else {
+			err = ErrCodeStoreOutOfGas
+		}
+	}
+
+	// When an error was returned by the EVM or when setting the creation code
+	// above we revert to the snapshot and consume any gas remaining.
+	if maxCodeSizeExceeded || err != nil {
+		evm.StateDB.RevertToSnapshot(snapshot)
+		evm.internal = evm.internal[:internal]
+		if err != errExecutionReverted {
+			contract.UseGas(contract.Gas)
+		}
+	}
+	// Assign err if contract code size exceeds the max while the err is still empty.
+	if maxCodeSizeExceeded && err == nil {
+		err = errMaxCodeSizeExceeded
+	}
+	if evm.vmConfig.Debug && evm.depth == 0 {
+		evm.vmConfig.Tracer.CaptureEnd(ret, gas-contract.Gas, time.Since(start), err)
+	}
+	return ret, address, contract.Gas, err
+}
+
+// Create creates a new contract using code as deployment code. The address of
+// the contract is derived from the caller and its nonce, which is increased.
+func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, assetID common.Address, value *big.Int) (ret []byte, contractAddr common.Address, leftOverGas uint64, err error) {
+	nonce := evm.asset.GetNonce(caller.Address())
+	if err := evm.asset.SetNonce(caller.Address(), nonce+1); err != nil {
+		return nil, common.Address{}, gas, err
+	}
+	contractAddr = crypto.CreateAddress(caller.Address(), nonce)
+	return evm.create(caller, code, gas, assetID, value, contractAddr)
+}
+
+// CreateAt creates a new contract at the given address using code as
+// deployment code, the nonce of the caller is left untouched. Transactions
+// deploy their contract at the address derived from the transaction nonce.
+func (evm *EVM) CreateAt(caller ContractRef, code []byte, gas uint64, assetID common.Address, value *big.Int, address common.Address) (ret []byte, leftOverGas uint64, err error) {
+	ret, _, leftOverGas, err = evm.create(caller, code, gas, assetID, value, address)
+	return ret, leftOverGas, err
+}
+
+// transfer moves the value between the accounts with the Transfer function of
+// the context. Value moved by contracts is recorded as internal transaction,
+// the value of the transaction outputs is left to the caller.
+func (evm *EVM) transfer(op OpCode, from, to, assetID common.Address, value *big.Int) error {
+	if value == nil || value.Sign() == 0 {
+		return nil
+	}
+	if err := evm.Context.Transfer(evm.StateDB, from, to, assetID, value); err != nil {
+		return err
+	}
+	if evm.depth > 0 {
+		evm.internal = append(evm.internal, &types.InternalTx{
+			OpCode:  byte(op),
+			From:    from,
+			To:      to,
+			AssetID: assetID,
+			Value:   new(big.Int).Set(value),
+		})
+	}
+	return nil
+}
+
+// GetBalance returns the balance of the account in the asset, zero if the
+// asset doesn't exist.
+func (evm *EVM) GetBalance(addr, assetID common.Address) *big.Int {
+	if _, err := evm.asset.EnoughBalance(addr, assetID, common.Big0); err != nil {
+		return new(big.Int)
+	}
+	return evm.asset.GetBalance(addr, assetID).(*big.Int)
+}
+
+// InternalTxs returns the value moved by contracts so far.
+func (evm *EVM) InternalTxs() []*types.InternalTx { return evm.internal }
+
+// ChainConfig returns the environment's chain configuration
+func (evm *EVM) ChainConfig() *params.ChainConfig { return evm.chainConfig }
+
+// Interpreter returns the EVM interpreter
+func (evm *EVM) Interpreter() *Interpreter { return evm.interpreter }
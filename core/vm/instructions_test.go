@@ -0,0 +1,84 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/zipper-project/z0/common"
+)
+
+type twoOperandTest struct {
+	x        string
+	y        string
+	expected string
+}
+
+func testTwoOperandOp(t *testing.T, tests []twoOperandTest, opFn func(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error)) {
+	var (
+		stack = newstack()
+		pc    = uint64(0)
+	)
+	for i, test := range tests {
+		x := new(big.Int).SetBytes(common.Hex2Bytes(test.x))
+		y := new(big.Int).SetBytes(common.Hex2Bytes(test.y))
+		expected := new(big.Int).SetBytes(common.Hex2Bytes(test.expected))
+		stack.push(x)
+		stack.push(y)
+		opFn(&pc, nil, nil, nil, stack)
+		if actual := stack.pop(); actual.Cmp(expected) != 0 {
+			t.Errorf("testcase %d: expected %x, got %x", i, expected, actual)
+		}
+	}
+}
+
+func TestByteOp(t *testing.T) {
+	tests := []twoOperandTest{
+		{"ABCDEF0908070605040302010000000000000000000000000000000000000000", "00", "AB"},
+		{"ABCDEF0908070605040302010000000000000000000000000000000000000000", "01", "CD"},
+		{"00CDEF090807060504030201ffffffffffffffffffffffffffffffffffffffff", "00", "00"},
+		{"00CDEF090807060504030201ffffffffffffffffffffffffffffffffffffffff", "01", "CD"},
+		{"0000000000000000000000000000000000000000000000000000000000102030", "1F", "30"},
+		{"0000000000000000000000000000000000000000000000000000000000102030", "1E", "20"},
+		{"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", "20", "00"},
+		{"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", "FFFFFFFFFFFFFFFF", "00"},
+	}
+	testTwoOperandOp(t, tests, opByte)
+}
+
+func TestSdivOp(t *testing.T) {
+	tests := []twoOperandTest{
+		// -10 / 2 = -5
+		{"02", "fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff6", "fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffb"},
+		// 10 / -2 = -5
+		{"fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffe", "0a", "fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffb"},
+		// division by zero yields zero
+		{"00", "0a", "00"},
+	}
+	testTwoOperandOp(t, tests, opSdiv)
+}
+
+func TestSignExtendOp(t *testing.T) {
+	tests := []twoOperandTest{
+		{"ff", "00", "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"},
+		{"7f", "00", "7f"},
+		{"ff7f", "00", "7f"},
+		{"80ff", "01", "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff80ff"},
+	}
+	testTwoOperandOp(t, tests, opSignExtend)
+}
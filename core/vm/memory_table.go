@@ -0,0 +1,93 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+func memorySha3(stack *Stack) *big.Int {
+	return calcMemSize(stack.Back(0), stack.Back(1))
+}
+
+func memoryCallDataCopy(stack *Stack) *big.Int {
+	return calcMemSize(stack.Back(0), stack.Back(2))
+}
+
+func memoryReturnDataCopy(stack *Stack) *big.Int {
+	return calcMemSize(stack.Back(0), stack.Back(2))
+}
+
+func memoryCodeCopy(stack *Stack) *big.Int {
+	return calcMemSize(stack.Back(0), stack.Back(2))
+}
+
+func memoryExtCodeCopy(stack *Stack) *big.Int {
+	return calcMemSize(stack.Back(1), stack.Back(3))
+}
+
+func memoryMLoad(stack *Stack) *big.Int {
+	return calcMemSize(stack.Back(0), big.NewInt(32))
+}
+
+func memoryMStore8(stack *Stack) *big.Int {
+	return calcMemSize(stack.Back(0), big.NewInt(1))
+}
+
+func memoryMStore(stack *Stack) *big.Int {
+	return calcMemSize(stack.Back(0), big.NewInt(32))
+}
+
+func memoryCreate(stack *Stack) *big.Int {
+	return calcMemSize(stack.Back(1), stack.Back(2))
+}
+
+func memoryCall(stack *Stack) *big.Int {
+	x := calcMemSize(stack.Back(5), stack.Back(6))
+	y := calcMemSize(stack.Back(3), stack.Back(4))
+
+	return math.BigMax(x, y)
+}
+
+func memoryDelegateCall(stack *Stack) *big.Int {
+	x := calcMemSize(stack.Back(4), stack.Back(5))
+	y := calcMemSize(stack.Back(2), stack.Back(3))
+
+	return math.BigMax(x, y)
+}
+
+func memoryStaticCall(stack *Stack) *big.Int {
+	x := calcMemSize(stack.Back(4), stack.Back(5))
+	y := calcMemSize(stack.Back(2), stack.Back(3))
+
+	return math.BigMax(x, y)
+}
+
+func memoryReturn(stack *Stack) *big.Int {
+	return calcMemSize(stack.Back(0), stack.Back(1))
+}
+
+func memoryRevert(stack *Stack) *big.Int {
+	return calcMemSize(stack.Back(0), stack.Back(1))
+}
+
+func memoryLog(stack *Stack) *big.Int {
+	mSize, mStart := stack.Back(1), stack.Back(0)
+	return calcMemSize(mStart, mSize)
+}
@@ -14,6 +14,26 @@
 // You should have received a copy of the GNU Lesser General Public License
 // along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
 
+// Package vm implements the virtual machine executing the smart contracts of
+// the z0 chain. Contracts are EVM bytecode, the value they move is taken from
+// the multi-asset balances kept by core/asset. The asset contracts, see
+// AssetContracts, let contracts read and move any asset and register and
+// issue assets of their own.
 package vm
 
-type Config struct{}
+// Config are the configuration options for the Interpreter
+type Config struct {
+	// Debug enabled debugging Interpreter options
+	Debug bool
+	// Tracer is the op code logger
+	Tracer Tracer
+	// NoRecursion disabled Interpreter call, callcode,
+	// delegate call and create.
+	NoRecursion bool
+	// Enable recording of SHA3/keccak preimages
+	EnablePreimageRecording bool
+	// JumpTable contains the EVM instruction table. This
+	// may be left uninitialised and will be set to the default
+	// table.
+	JumpTable [256]operation
+}
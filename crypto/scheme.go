@@ -0,0 +1,303 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/zipper-project/z0/common"
+)
+
+// SigScheme identifies the algorithm of a transaction signature.
+type SigScheme uint8
+
+const (
+	// Secp256k1 is ECDSA over secp256k1 with public key recovery, see Sign
+	// and Ecrecover.
+	Secp256k1 SigScheme = iota
+	// Secp256r1 is ECDSA over NIST P-256 with low S values, public keys are
+	// carried compressed.
+	Secp256r1
+	// Ed25519 is EdDSA over edwards25519 as of RFC 8032.
+	Ed25519
+)
+
+func (id SigScheme) String() string {
+	switch id {
+	case Secp256k1:
+		return "secp256k1"
+	case Secp256r1:
+		return "secp256r1"
+	case Ed25519:
+		return "ed25519"
+	}
+	return fmt.Sprintf("scheme(%d)", uint8(id))
+}
+
+var (
+	// ErrUnknownScheme is returned for a signature scheme that isn't registered.
+	ErrUnknownScheme = errors.New("unknown signature scheme")
+	// ErrSchemePubkey is returned if a public key doesn't fit its scheme.
+	ErrSchemePubkey = errors.New("invalid public key for signature scheme")
+)
+
+// Scheme verifies the signatures of a signature scheme and derives the
+// addresses of its public keys. Schemes are used from several goroutines at
+// once and have to be stateless.
+type Scheme interface {
+	// Verify reports whether the 64 byte signature sig of hash was made by
+	// the key of the encoded public key pub.
+	Verify(pub, hash, sig []byte) bool
+	// Address derives the account address of the encoded public key.
+	Address(pub []byte) (common.Address, error)
+}
+
+// SchemeKey is a private key of a signature scheme.
+type SchemeKey interface {
+	// Scheme returns the signature scheme of the key.
+	Scheme() SigScheme
+	// PublicKey returns the public key, encoded as carried in transactions.
+	PublicKey() []byte
+	// Sign signs the hash, secp256k1 keys return the 65 byte recoverable
+	// signature of Sign, the other schemes a 64 byte signature.
+	Sign(hash []byte) ([]byte, error)
+}
+
+var (
+	schemesMu sync.RWMutex
+	schemes   = make(map[SigScheme]Scheme)
+)
+
+func init() {
+	RegisterScheme(Secp256k1, secp256k1Scheme{})
+	RegisterScheme(Secp256r1, secp256r1Scheme{})
+	RegisterScheme(Ed25519, ed25519Scheme{})
+}
+
+// RegisterScheme makes a signature scheme available under the identifier, it
+// panics if the identifier is taken.
+func RegisterScheme(id SigScheme, s Scheme) {
+	schemesMu.Lock()
+	defer schemesMu.Unlock()
+
+	if _, ok := schemes[id]; ok {
+		panic(fmt.Sprintf("signature scheme %v registered twice", id))
+	}
+	schemes[id] = s
+}
+
+// LookupScheme returns the signature scheme registered under the identifier.
+func LookupScheme(id SigScheme) (Scheme, error) {
+	schemesMu.RLock()
+	defer schemesMu.RUnlock()
+
+	s, ok := schemes[id]
+	if !ok {
+		return nil, ErrUnknownScheme
+	}
+	return s, nil
+}
+
+// SchemeAddress derives the address of an encoded public key of the scheme.
+func SchemeAddress(id SigScheme, pub []byte) (common.Address, error) {
+	s, err := LookupScheme(id)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return s.Address(pub)
+}
+
+// KeyAddress derives the address of the private key.
+func KeyAddress(key SchemeKey) (common.Address, error) {
+	return SchemeAddress(key.Scheme(), key.PublicKey())
+}
+
+// GenerateSchemeKey generates a new private key of the scheme.
+func GenerateSchemeKey(id SigScheme) (SchemeKey, error) {
+	switch id {
+	case Secp256k1:
+		key, err := GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		return SchemeKeyFromECDSA(key), nil
+	case Secp256r1:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return secp256r1Key{key}, nil
+	case Ed25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return ed25519Key(key), nil
+	}
+	return nil, ErrUnknownScheme
+}
+
+// ToSchemeKey creates a private key of the scheme from its 32 byte raw form,
+// the private scalar of the ECDSA schemes or the seed of Ed25519.
+func ToSchemeKey(id SigScheme, d []byte) (SchemeKey, error) {
+	switch id {
+	case Secp256k1:
+		key, err := ToECDSA(d)
+		if err != nil {
+			return nil, err
+		}
+		return SchemeKeyFromECDSA(key), nil
+	case Secp256r1:
+		if len(d) != 32 {
+			return nil, fmt.Errorf("invalid length, need 256 bits")
+		}
+		key := new(ecdsa.PrivateKey)
+		key.Curve = elliptic.P256()
+		key.D = new(big.Int).SetBytes(d)
+		if key.D.Sign() <= 0 || key.D.Cmp(key.Curve.Params().N) >= 0 {
+			return nil, fmt.Errorf("invalid private key")
+		}
+		key.X, key.Y = key.Curve.ScalarBaseMult(d)
+		return secp256r1Key{key}, nil
+	case Ed25519:
+		if len(d) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid length, need 256 bits")
+		}
+		return ed25519Key(ed25519.NewKeyFromSeed(d)), nil
+	}
+	return nil, ErrUnknownScheme
+}
+
+// SchemeKeyFromECDSA wraps a secp256k1 private key.
+func SchemeKeyFromECDSA(key *ecdsa.PrivateKey) SchemeKey {
+	return secp256k1Key{key}
+}
+
+// schemeHash derives the address of a public key of a scheme without key
+// recovery, the scheme is hashed along so that addresses don't collide
+// across schemes.
+func schemeHash(id SigScheme, pub []byte) common.Address {
+	return common.BytesToAddress(Keccak256([]byte{byte(id)}, pub)[12:])
+}
+
+type secp256k1Scheme struct{}
+
+func (secp256k1Scheme) Verify(pub, hash, sig []byte) bool {
+	return len(sig) == 64 && VerifySignature(pub, hash, sig)
+}
+
+func (secp256k1Scheme) Address(pub []byte) (common.Address, error) {
+	var key *ecdsa.PublicKey
+	var err error
+	if len(pub) == 33 {
+		key, err = DecompressPubkey(pub)
+	} else {
+		key, err = UnmarshalPubkey(pub)
+	}
+	if err != nil {
+		return common.Address{}, ErrSchemePubkey
+	}
+	return PubkeyToAddress(*key), nil
+}
+
+type secp256k1Key struct{ *ecdsa.PrivateKey }
+
+func (k secp256k1Key) Scheme() SigScheme                { return Secp256k1 }
+func (k secp256k1Key) PublicKey() []byte                { return FromECDSAPub(&k.PrivateKey.PublicKey) }
+func (k secp256k1Key) Sign(hash []byte) ([]byte, error) { return Sign(hash, k.PrivateKey) }
+
+var (
+	secp256r1N     = elliptic.P256().Params().N
+	secp256r1halfN = new(big.Int).Rsh(secp256r1N, 1)
+)
+
+type secp256r1Scheme struct{}
+
+func (secp256r1Scheme) Verify(pub, hash, sig []byte) bool {
+	if len(sig) != 64 {
+		return false
+	}
+	x, y := elliptic.UnmarshalCompressed(elliptic.P256(), pub)
+	if x == nil {
+		return false
+	}
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	// Reject malleable signatures, like secp256k1 transaction signatures
+	if s.Cmp(secp256r1halfN) > 0 {
+		return false
+	}
+	return ecdsa.Verify(&ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, hash, r, s)
+}
+
+func (secp256r1Scheme) Address(pub []byte) (common.Address, error) {
+	if x, _ := elliptic.UnmarshalCompressed(elliptic.P256(), pub); x == nil {
+		return common.Address{}, ErrSchemePubkey
+	}
+	return schemeHash(Secp256r1, pub), nil
+}
+
+type secp256r1Key struct{ *ecdsa.PrivateKey }
+
+func (k secp256r1Key) Scheme() SigScheme { return Secp256r1 }
+
+func (k secp256r1Key) PublicKey() []byte {
+	return elliptic.MarshalCompressed(elliptic.P256(), k.X, k.Y)
+}
+
+func (k secp256r1Key) Sign(hash []byte) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, k.PrivateKey, hash)
+	if err != nil {
+		return nil, err
+	}
+	if s.Cmp(secp256r1halfN) > 0 {
+		s.Sub(secp256r1N, s)
+	}
+	return append(math.PaddedBigBytes(r, 32), math.PaddedBigBytes(s, 32)...), nil
+}
+
+type ed25519Scheme struct{}
+
+func (ed25519Scheme) Verify(pub, hash, sig []byte) bool {
+	return len(pub) == ed25519.PublicKeySize && len(sig) == ed25519.SignatureSize && ed25519.Verify(ed25519.PublicKey(pub), hash, sig)
+}
+
+func (ed25519Scheme) Address(pub []byte) (common.Address, error) {
+	if len(pub) != ed25519.PublicKeySize {
+		return common.Address{}, ErrSchemePubkey
+	}
+	return schemeHash(Ed25519, pub), nil
+}
+
+type ed25519Key ed25519.PrivateKey
+
+func (k ed25519Key) Scheme() SigScheme { return Ed25519 }
+
+func (k ed25519Key) PublicKey() []byte {
+	return []byte(ed25519.PrivateKey(k).Public().(ed25519.PublicKey))
+}
+
+func (k ed25519Key) Sign(hash []byte) ([]byte, error) {
+	return ed25519.Sign(ed25519.PrivateKey(k), hash), nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package crypto
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/zipper-project/z0/common"
+)
+
+// Cross-scheme vectors, the private keys are the Keccak256 hashes of the
+// scheme names and every key signs schemeMsg.
+var (
+	schemeMsg     = hexutil.MustDecode("0x10059eb6bada2c613887f5ed493cf7c65c20822eef8409d24c9fd0c373b11e63")
+	schemeVectors = []struct {
+		id   SigScheme
+		priv string
+		pub  string
+		addr common.Address
+		sig  string // without the recovery id for secp256k1
+	}{
+		{
+			id:   Secp256k1,
+			priv: "0xd4fa99b1e08c4e5e6deb461846aa629344d95ff03ed04754c2053d54c756f439",
+			pub:  "0x043610c8e322555b0b71d84d7d67872f224c1949d5d31738587967cbb14a3f0058a3a7e7d20e03b181878977d19fb432019d870421ccc6ebd0e75fb1eaa8e29b66",
+			addr: common.HexToAddress("0x80f5f359f707ae3cD74d1F2532F1BD101e5D02Ee"),
+			sig:  "0x174f328fe4d1d29739074c7be42793cff45a6684421b41b2acc9871d9788536945c295c98625eb2844d20e3dd19751cef0961eed968ac05722e4dd01e93c183c",
+		},
+		{
+			id:   Secp256r1,
+			priv: "0x0b76e50bb7c1c3845c8cda345b91ac0e07eed5a0824b79c780f322439f040c54",
+			pub:  "0x0229bf557a1f1976a2006843a82d0c1afeeb182481996a654b843e2d08f7968614",
+			addr: common.HexToAddress("0x2636592b23E24729346Da861ee345b2BA9094463"),
+			sig:  "0x440dfc8506d875733fe64f873c07d7e903c282170d7a57fa97fedff849bb66e33244b58dec92199e926653e074959fa6ad29b0c2fd1212fa7a0ab471e51358e5",
+		},
+		{
+			id:   Ed25519,
+			priv: "0x8b7687509d30e184ec95e15d7753494a51cc1cd3bab7e95eed8d85a0d830db1e",
+			pub:  "0xad65015888a1f834c29e58e10f9ee9e87306fd352c3797a5ed8bd1564074d7ac",
+			addr: common.HexToAddress("0x078739F839BF675A497275a36a7d8353cFDEB801"),
+			sig:  "0x63345fe8b197873a1b71cb9a77efab0cb55027a77b3a469d46053609b8512ec6c7b1edf6c1e6ea458d9729dc44f87b33fe0d9ba31f8e63c030264dec9df71203",
+		},
+	}
+)
+
+func TestSchemeVectors(t *testing.T) {
+	for _, v := range schemeVectors {
+		key, err := ToSchemeKey(v.id, hexutil.MustDecode(v.priv))
+		if err != nil {
+			t.Fatalf("%v: %v", v.id, err)
+		}
+		pub, sig := hexutil.MustDecode(v.pub), hexutil.MustDecode(v.sig)
+		if !bytes.Equal(key.PublicKey(), pub) {
+			t.Errorf("%v: pubkey mismatch: want %x have %x", v.id, pub, key.PublicKey())
+		}
+		if addr, err := SchemeAddress(v.id, pub); err != nil || addr != v.addr {
+			t.Errorf("%v: address mismatch: want %x have %x (%v)", v.id, v.addr, addr, err)
+		}
+		scheme, err := LookupScheme(v.id)
+		if err != nil {
+			t.Fatalf("%v: %v", v.id, err)
+		}
+		if !scheme.Verify(pub, schemeMsg, sig) {
+			t.Errorf("%v: vector signature doesn't verify", v.id)
+		}
+		// fresh signatures verify too, secp256r1 signatures are randomized
+		fresh, err := key.Sign(schemeMsg)
+		if err != nil {
+			t.Fatalf("%v: %v", v.id, err)
+		}
+		if !scheme.Verify(pub, schemeMsg, fresh[:64]) {
+			t.Errorf("%v: fresh signature doesn't verify", v.id)
+		}
+		if scheme.Verify(pub, schemeMsg[1:], sig) {
+			t.Errorf("%v: signature valid for another message", v.id)
+		}
+		// no signature verifies under another scheme
+		for _, w := range schemeVectors {
+			if w.id == v.id {
+				continue
+			}
+			other, _ := LookupScheme(w.id)
+			if other.Verify(pub, schemeMsg, sig) || other.Verify(hexutil.MustDecode(w.pub), schemeMsg, sig) {
+				t.Errorf("%v signature verifies as %v", v.id, w.id)
+			}
+		}
+	}
+}
+
+func TestSchemeAddressSeparation(t *testing.T) {
+	// the compressed secp256k1 key resolves to the same account
+	k1 := schemeVectors[0]
+	pub, _ := UnmarshalPubkey(hexutil.MustDecode(k1.pub))
+	if addr, _ := SchemeAddress(Secp256k1, CompressPubkey(pub)); addr != k1.addr {
+		t.Errorf("compressed key address mismatch: want %x have %x", k1.addr, addr)
+	}
+	// a 32 byte key is hashed along with its scheme
+	r1 := hexutil.MustDecode(schemeVectors[1].pub)
+	if _, err := SchemeAddress(Ed25519, r1[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if addr, _ := SchemeAddress(Ed25519, r1[1:]); addr == schemeHash(Secp256r1, r1[1:]) {
+		t.Errorf("addresses collide across schemes")
+	}
+	if _, err := SchemeAddress(Secp256r1, r1[1:]); err != ErrSchemePubkey {
+		t.Errorf("expected %v for a short secp256r1 key, got %v", ErrSchemePubkey, err)
+	}
+	if _, err := SchemeAddress(SigScheme(9), r1); err != ErrUnknownScheme {
+		t.Errorf("expected %v, got %v", ErrUnknownScheme, err)
+	}
+}
+
+func TestSecp256r1LowS(t *testing.T) {
+	v := schemeVectors[1]
+	pub, sig := hexutil.MustDecode(v.pub), hexutil.MustDecode(v.sig)
+	scheme, _ := LookupScheme(Secp256r1)
+
+	// the high S twin of a valid signature is rejected
+	s := new(big.Int).Sub(secp256r1N, new(big.Int).SetBytes(sig[32:]))
+	twin := append(common.CopyBytes(sig[:32]), math.PaddedBigBytes(s, 32)...)
+	if scheme.Verify(pub, schemeMsg, twin) {
+		t.Errorf("high S signature verified")
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package miner implements the block producer, assembling blocks out of the
+// transaction pool and sealing them through the consensus engine.
+package miner
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/consensus"
+	"github.com/zipper-project/z0/core"
+	"github.com/zipper-project/z0/feed"
+	"github.com/zipper-project/z0/params"
+	"github.com/zipper-project/z0/txpool"
+	"github.com/zipper-project/z0/types"
+)
+
+const (
+	// txChanSize is the size of channel listening to NewTxsEvent.
+	txChanSize = 4096
+	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
+	chainHeadChanSize = 10
+)
+
+var errNoAuthorizer = errors.New("consensus engine does not sign blocks")
+
+// sealResult is the outcome of sealing an assembled block.
+type sealResult struct {
+	block *types.Block
+	err   error
+}
+
+// Miner produces blocks on top of the local chain, filling them with the
+// executable transactions of the pool and sealing them through the consensus
+// engine.
+type Miner struct {
+	chainConfig *params.ChainConfig
+	engine      consensus.Engine
+	chain       *core.BlockChain
+	txPool      *txpool.TxPool
+	signer      types.Signer
+	minedFeed   feed.Feed
+
+	mu       sync.RWMutex // Protects the coinbase and interval
+	coinbase common.Address
+	interval time.Duration
+
+	startMu sync.Mutex // Serialises starting and stopping
+	running int32      // Whether blocks are being produced (atomic)
+	quit    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// New creates a block producer, which is stopped until Start is called.
+func New(config *Config, chainConfig *params.ChainConfig, engine consensus.Engine, chain *core.BlockChain, txPool *txpool.TxPool) *Miner {
+	return &Miner{
+		chainConfig: chainConfig,
+		engine:      engine,
+		chain:       chain,
+		txPool:      txPool,
+		signer:      types.MakeSigner(chainConfig.ChainID),
+		coinbase:    config.Coinbase,
+		interval:    config.Interval,
+	}
+}
+
+// Start starts producing blocks, it is a noop if the miner is already running.
+func (m *Miner) Start() {
+	m.startMu.Lock()
+	defer m.startMu.Unlock()
+
+	if m.engine == nil {
+		log.Warn("Block production needs a consensus engine in the chain config")
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&m.running, 0, 1) {
+		return
+	}
+	m.quit = make(chan struct{})
+	m.wg.Add(1)
+	go m.loop(m.quit)
+	log.Info("Block production started", "coinbase", m.Coinbase(), "interval", m.Interval())
+}
+
+// Stop stops producing blocks, aborting the block being sealed if any.
+func (m *Miner) Stop() {
+	m.startMu.Lock()
+	defer m.startMu.Unlock()
+
+	if !atomic.CompareAndSwapInt32(&m.running, 1, 0) {
+		return
+	}
+	close(m.quit)
+	m.wg.Wait()
+	log.Info("Block production stopped")
+}
+
+// Mining reports whether blocks are being produced.
+func (m *Miner) Mining() bool {
+	return atomic.LoadInt32(&m.running) == 1
+}
+
+// Coinbase returns the address credited with the fees of produced blocks.
+func (m *Miner) Coinbase() common.Address {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.coinbase
+}
+
+// SetCoinbase sets the address credited with the fees of the next blocks.
+func (m *Miner) SetCoinbase(addr common.Address) {
+	m.mu.Lock()
+	m.coinbase = addr
+	m.mu.Unlock()
+}
+
+// Interval returns the time between two produced blocks.
+func (m *Miner) Interval() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.interval
+}
+
+// SetInterval sets the time between two produced blocks, a zero interval
+// produces a block as soon as transactions are pending. The change takes
+// effect from the next block on.
+func (m *Miner) SetInterval(interval time.Duration) {
+	m.mu.Lock()
+	m.interval = interval
+	m.mu.Unlock()
+}
+
+// Authorize injects the key signing the produced blocks into the consensus
+// engine. It fails if the engine doesn't sign the blocks it seals.
+func (m *Miner) Authorize(signer common.Address, signFn consensus.SignerFn) error {
+	authorizer, ok := m.engine.(consensus.Authorizer)
+	if !ok {
+		return errNoAuthorizer
+	}
+	authorizer.Authorize(signer, signFn)
+	return nil
+}
+
+// SubscribeNewMinedBlockEvent registers a subscription of NewMinedBlockEvent,
+// posted for every produced block once it is inserted into the chain.
+func (m *Miner) SubscribeNewMinedBlockEvent(ch chan<- core.NewMinedBlockEvent) feed.Subscription {
+	return m.minedFeed.Subscribe(ch)
+}
+
+// loop is the block production loop. It assembles a block whenever the
+// interval elapses, or as soon as transactions are pending if there's no
+// interval, and seals one block at a time. Sealing is aborted if the height
+// is filled by another producer in the meantime.
+func (m *Miner) loop(quit chan struct{}) {
+	defer m.wg.Done()
+
+	txsCh := make(chan txpool.NewTxsEvent, txChanSize)
+	txsSub := m.txPool.SubscribeNewTxsEvent(txsCh)
+	defer txsSub.Unsubscribe()
+
+	headCh := make(chan txpool.ChainHeadEvent, chainHeadChanSize)
+	headSub := m.chain.SubscribeChainHeadEvent(headCh)
+	defer headSub.Unsubscribe()
+
+	var (
+		timer   = time.NewTimer(0)
+		results = make(chan sealResult, 1)
+		sealing *types.Block  // Block currently being sealed
+		abort   chan struct{} // Aborts sealing, nil once closed
+	)
+	defer timer.Stop()
+
+	// commit assembles a new block and hands it to the engine for sealing
+	commit := func() {
+		block, err := m.commitNewWork()
+		if err != nil {
+			log.Error("Failed to assemble block", "err", err)
+			return
+		}
+		// Without an interval blocks are only produced for transactions
+		if len(block.Transactions()) == 0 && m.Interval() == 0 {
+			return
+		}
+		sealing, abort = block, make(chan struct{})
+		go func() {
+			sealed, err := m.engine.Seal(m.chain, block, abort)
+			results <- sealResult{sealed, err}
+		}()
+	}
+	// next schedules the block following the last sealing attempt
+	next := func() {
+		if interval := m.Interval(); interval > 0 {
+			timer.Reset(interval)
+		} else if m.pending() {
+			commit()
+		}
+	}
+
+	for {
+		select {
+		case <-timer.C:
+			if sealing != nil {
+				continue
+			}
+			if m.Interval() > 0 || m.pending() {
+				commit()
+			}
+			if sealing == nil {
+				next()
+			}
+
+		case <-txsCh:
+			if sealing == nil && m.Interval() == 0 {
+				commit()
+			}
+
+		case ev := <-headCh:
+			if sealing != nil && abort != nil && ev.Block.NumberU64() >= sealing.NumberU64() {
+				close(abort)
+				abort = nil
+			}
+			if sealing == nil && m.Interval() == 0 && m.pending() {
+				commit()
+			}
+
+		case res := <-results:
+			aborted := abort == nil
+			block := sealing
+			sealing, abort = nil, nil
+
+			switch {
+			case aborted:
+				log.Debug("Block sealing aborted", "number", block.Number())
+			case res.err != nil:
+				log.Debug("Failed to seal block", "number", block.Number(), "err", res.err)
+			case res.block == nil:
+			default:
+				m.insert(res.block)
+			}
+			next()
+
+		case <-quit:
+			if abort != nil {
+				close(abort)
+			}
+			return
+		}
+	}
+}
+
+// insert writes a sealed block into the chain and announces it.
+func (m *Miner) insert(block *types.Block) {
+	if _, err := m.chain.InsertChain(types.Blocks{block}); err != nil {
+		log.Error("Failed to insert produced block", "number", block.Number(), "hash", block.Hash(), "err", err)
+		return
+	}
+	log.Info("Produced new block", "number", block.Number(), "hash", block.Hash(), "txs", len(block.Transactions()), "gas", block.GasUsed())
+	m.minedFeed.Send(core.NewMinedBlockEvent{Block: block})
+}
+
+// pending reports whether the pool holds executable transactions.
+func (m *Miner) pending() bool {
+	pending, _ := m.txPool.Stats()
+	return pending > 0
+}
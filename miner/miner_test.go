@@ -0,0 +1,250 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/consensus/poa"
+	"github.com/zipper-project/z0/core"
+	"github.com/zipper-project/z0/core/asset"
+	"github.com/zipper-project/z0/core/vm"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/params"
+	"github.com/zipper-project/z0/txpool"
+	"github.com/zipper-project/z0/types"
+	"github.com/zipper-project/z0/utils/zdb"
+)
+
+var (
+	testConfig = &params.ChainConfig{ChainID: big.NewInt(1), PoA: &params.PoAConfig{Epoch: 30000}}
+	testPool   = txpool.Config{
+		Rejournal:    time.Hour,
+		PriceLimit:   1,
+		PriceBump:    10,
+		AccountSlots: 16,
+		GlobalSlots:  4096,
+		AccountQueue: 64,
+		GlobalQueue:  1024,
+		Lifetime:     time.Hour,
+	}
+	testCoinbase = common.Address{0x20}
+	testTo       = common.Address{0x10}
+)
+
+// testBackend is a single signer proof-of-authority chain with a funded
+// account, backed by an in-memory database.
+type testBackend struct {
+	chain  *core.BlockChain
+	txPool *txpool.TxPool
+	miner  *Miner
+	key    *ecdsa.PrivateKey
+}
+
+func newTestBackend(t *testing.T) *testBackend {
+	signerKey, _ := crypto.GenerateKey()
+	signer := crypto.PubkeyToAddress(signerKey.PublicKey)
+	key, _ := crypto.GenerateKey()
+	funded := crypto.PubkeyToAddress(key.PublicKey)
+
+	genesis := &core.Genesis{
+		Config:     testConfig,
+		ExtraData:  poa.GenesisExtra(nil, []common.Address{signer}),
+		GasLimit:   params.GenesisGasLimit,
+		Difficulty: big.NewInt(1),
+		Zip:        &core.GenesisZip{Total: big.NewInt(1000000000), Decimals: 8, Owner: signer},
+		Alloc:      core.GenesisAlloc{funded: {Balances: map[string]*big.Int{"ZIP": big.NewInt(10000000)}}},
+	}
+	db := zdb.NewMemDatabase()
+	if _, err := genesis.Commit(db); err != nil {
+		t.Fatalf("failed to commit genesis: %v", err)
+	}
+	engine := poa.New(testConfig.PoA, db)
+	chain, err := core.NewBlockChain(db, nil, testConfig, engine, nil, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	pool := txpool.New(testPool, testConfig, chain)
+	miner := New(&Config{Coinbase: testCoinbase}, testConfig, engine, chain, pool)
+	if err := miner.Authorize(signer, func(_ common.Address, hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, signerKey)
+	}); err != nil {
+		t.Fatalf("failed to authorize signer: %v", err)
+	}
+	return &testBackend{chain: chain, txPool: pool, miner: miner, key: key}
+}
+
+func (b *testBackend) close() {
+	b.miner.Stop()
+	b.txPool.Stop()
+	b.chain.Stop()
+}
+
+func (b *testBackend) transfer(t *testing.T, nonce uint64, price int64, value int64) *types.Transaction {
+	zip := types.ZipAssetID
+	tx := types.NewTransaction(nonce, 100000, big.NewInt(price), nil)
+	tx.WithInput(types.AMInput{AssertID: &zip})
+	tx.WithOutput(types.AMOutput{AssertID: &zip, Address: &testTo, Value: big.NewInt(value)})
+	signed, err := types.SignTx(tx, types.MakeSigner(testConfig.ChainID), b.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestMinerTransactions(t *testing.T) {
+	b := newTestBackend(t)
+	defer b.close()
+
+	minedCh := make(chan core.NewMinedBlockEvent, 10)
+	sub := b.miner.SubscribeNewMinedBlockEvent(minedCh)
+	defer sub.Unsubscribe()
+	b.miner.Start()
+
+	for i := uint64(0); i < 3; i++ {
+		if err := b.txPool.AddLocal(b.transfer(t, i, 2, 1000)); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	included := 0
+	timeout := time.After(5 * time.Second)
+	for included < 3 {
+		select {
+		case ev := <-minedCh:
+			if head := b.chain.CurrentBlock(); head.Hash() != ev.Block.Hash() {
+				t.Fatalf("mined block %x not the chain head %x", ev.Block.Hash(), head.Hash())
+			}
+			if ev.Block.Coinbase() != testCoinbase {
+				t.Fatalf("coinbase mismatch: have %x, want %x", ev.Block.Coinbase(), testCoinbase)
+			}
+			included += len(ev.Block.Transactions())
+		case <-timeout:
+			t.Fatalf("timeout, %d of 3 transactions included", included)
+		}
+	}
+	if included != 3 {
+		t.Fatalf("included transaction count mismatch: have %d, want 3", included)
+	}
+
+	statedb, err := b.chain.StateAt(b.chain.CurrentBlock().Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := asset.NewAsset(statedb)
+	if balance := a.GetBalance(testTo, types.ZipAssetID).(*big.Int); balance.Cmp(big.NewInt(3000)) != 0 {
+		t.Errorf("recipient balance mismatch: have %v, want 3000", balance)
+	}
+	fee := new(big.Int).SetUint64(3 * 2 * params.TxGas)
+	if balance := a.GetBalance(testCoinbase, types.ZipAssetID).(*big.Int); balance.Cmp(fee) != 0 {
+		t.Errorf("coinbase balance mismatch: have %v, want %v", balance, fee)
+	}
+}
+
+func TestMinerStop(t *testing.T) {
+	b := newTestBackend(t)
+	defer b.close()
+
+	b.miner.Start()
+	if !b.miner.Mining() {
+		t.Fatalf("miner not running after start")
+	}
+	b.miner.Stop()
+	if b.miner.Mining() {
+		t.Fatalf("miner running after stop")
+	}
+	if err := b.txPool.AddLocal(b.transfer(t, 0, 2, 1000)); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if number := b.chain.CurrentBlock().NumberU64(); number != 0 {
+		t.Fatalf("block produced while stopped: head %d", number)
+	}
+
+	// Restarting picks up the pending transaction
+	b.miner.Start()
+	deadline := time.Now().Add(5 * time.Second)
+	for b.chain.CurrentBlock().NumberU64() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("no block produced after restart")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTransactionPriceNonceSort(t *testing.T) {
+	signer := types.MakeSigner(testConfig.ChainID)
+	keys := make([]*ecdsa.PrivateKey, 5)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	// Every account gets transactions of mixed prices in nonce order
+	groups := make(map[common.Address]types.Transactions)
+	for start, key := range keys {
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		for i := 0; i < 5; i++ {
+			tx, _ := types.SignTx(types.NewTransaction(uint64(start+i), 100000, big.NewInt(int64(start+i)), nil), signer, key)
+			groups[addr] = append(groups[addr], tx)
+		}
+	}
+	// Prices are converted before the comparison
+	price := func(tx *types.Transaction) *big.Int { return new(big.Int).Mul(tx.GasPrice(), big.NewInt(10)) }
+	txset := newTxsByPriceAndNonce(signer, groups, price)
+
+	var txs types.Transactions
+	for tx := txset.Peek(); tx != nil; tx = txset.Peek() {
+		txs = append(txs, tx)
+		txset.Shift()
+	}
+	if len(txs) != 25 {
+		t.Fatalf("expected 25 transactions, found %d", len(txs))
+	}
+	for i, txi := range txs {
+		fromi, _ := types.Sender(signer, txi)
+
+		// Make sure the nonce order is valid
+		for j, txj := range txs[i+1:] {
+			fromj, _ := types.Sender(signer, txj)
+			if fromi == fromj && txi.Nonce() > txj.Nonce() {
+				t.Errorf("invalid nonce ordering: tx #%d (A=%x N=%v) < tx #%d (A=%x N=%v)", i, fromi[:4], txi.Nonce(), i+j, fromj[:4], txj.Nonce())
+			}
+		}
+		// If the next tx has different from account, the price must be lower than the current one
+		if i+1 < len(txs) {
+			next := txs[i+1]
+			fromNext, _ := types.Sender(signer, next)
+			if fromi != fromNext && txi.GasPrice().Cmp(next.GasPrice()) < 0 {
+				t.Errorf("invalid gasprice ordering: tx #%d (A=%x P=%v) < tx #%d (A=%x P=%v)", i, fromi[:4], txi.GasPrice(), i+1, fromNext[:4], next.GasPrice())
+			}
+		}
+	}
+	// Popping drops the remaining transactions of the account
+	groups = map[common.Address]types.Transactions{}
+	addr := crypto.PubkeyToAddress(keys[0].PublicKey)
+	for i := 0; i < 3; i++ {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), 100000, big.NewInt(1), nil), signer, keys[0])
+		groups[addr] = append(groups[addr], tx)
+	}
+	txset = newTxsByPriceAndNonce(signer, groups, price)
+	txset.Pop()
+	if tx := txset.Peek(); tx != nil {
+		t.Fatalf("transaction left after pop: nonce %d", tx.Nonce())
+	}
+}
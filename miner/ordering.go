@@ -0,0 +1,121 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"container/heap"
+	"math/big"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/types"
+)
+
+// priceHeap is a heap of the next transactions of every account, ordered by
+// their converted gas price.
+type priceHeap struct {
+	txs    []*types.Transaction
+	prices []*big.Int
+}
+
+func (h *priceHeap) Len() int           { return len(h.txs) }
+func (h *priceHeap) Less(i, j int) bool { return h.prices[i].Cmp(h.prices[j]) > 0 }
+func (h *priceHeap) Swap(i, j int) {
+	h.txs[i], h.txs[j] = h.txs[j], h.txs[i]
+	h.prices[i], h.prices[j] = h.prices[j], h.prices[i]
+}
+
+func (h *priceHeap) Push(x interface{}) {
+	entry := x.(priceEntry)
+	h.txs = append(h.txs, entry.tx)
+	h.prices = append(h.prices, entry.price)
+}
+
+func (h *priceHeap) Pop() interface{} {
+	n := len(h.txs)
+	entry := priceEntry{h.txs[n-1], h.prices[n-1]}
+	h.txs, h.prices = h.txs[:n-1], h.prices[:n-1]
+	return entry
+}
+
+type priceEntry struct {
+	tx    *types.Transaction
+	price *big.Int
+}
+
+// txsByPriceAndNonce represents a set of transactions that can return
+// transactions in a profit-maximizing sorted order, while supporting removing
+// entire batches of transactions for non-executable accounts.
+type txsByPriceAndNonce struct {
+	txs    map[common.Address]types.Transactions // Per account nonce-sorted list of transactions
+	heads  *priceHeap                            // Next transaction for each unique account (price heap)
+	signer types.Signer                          // Signer for the set of transactions
+	price  func(*types.Transaction) *big.Int     // Gas price of a transaction in a common unit
+}
+
+// newTxsByPriceAndNonce creates a transaction set that can retrieve price sorted
+// transactions in a nonce-honouring way. Prices are compared after converting
+// them with the price function, as transactions may pay fees in different
+// assets.
+//
+// Note, the input map is reowned so the caller should not interact any more
+// with it after providing it to the constructor.
+func newTxsByPriceAndNonce(signer types.Signer, txs map[common.Address]types.Transactions, price func(*types.Transaction) *big.Int) *txsByPriceAndNonce {
+	heads := new(priceHeap)
+	for from, accTxs := range txs {
+		if len(accTxs) == 0 {
+			delete(txs, from)
+			continue
+		}
+		heads.Push(priceEntry{accTxs[0], price(accTxs[0])})
+		txs[from] = accTxs[1:]
+	}
+	heap.Init(heads)
+
+	return &txsByPriceAndNonce{
+		txs:    txs,
+		heads:  heads,
+		signer: signer,
+		price:  price,
+	}
+}
+
+// Peek returns the next transaction by price.
+func (t *txsByPriceAndNonce) Peek() *types.Transaction {
+	if t.heads.Len() == 0 {
+		return nil
+	}
+	return t.heads.txs[0]
+}
+
+// Shift replaces the current best head with the next one from the same account.
+func (t *txsByPriceAndNonce) Shift() {
+	acc, _ := types.Sender(t.signer, t.heads.txs[0])
+	if txs, ok := t.txs[acc]; ok && len(txs) > 0 {
+		t.heads.txs[0], t.heads.prices[0] = txs[0], t.price(txs[0])
+		t.txs[acc] = txs[1:]
+		heap.Fix(t.heads, 0)
+		return
+	}
+	heap.Pop(t.heads)
+}
+
+// Pop removes the best transaction, *not* replacing it with the next one from
+// the same account. This should be used when a transaction cannot be executed
+// and hence all subsequent ones should be discarded from the same account.
+func (t *txsByPriceAndNonce) Pop() {
+	heap.Pop(t.heads)
+}
@@ -0,0 +1,162 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core"
+	"github.com/zipper-project/z0/core/asset"
+	"github.com/zipper-project/z0/core/vm"
+	"github.com/zipper-project/z0/params"
+	"github.com/zipper-project/z0/state"
+	"github.com/zipper-project/z0/types"
+)
+
+// environment is the state a block is assembled on.
+type environment struct {
+	header   *types.Header
+	state    *state.StateDB
+	gasPool  *core.GasPool
+	txs      []*types.Transaction
+	receipts []*types.Receipt
+}
+
+// commitNewWork assembles a new block on top of the current chain head,
+// filled with the executable transactions of the pool. The returned block
+// carries the resulting state root and receipts, but isn't sealed yet.
+func (m *Miner) commitNewWork() (*types.Block, error) {
+	parent := m.chain.CurrentBlock()
+
+	timestamp := time.Now().Unix()
+	if parent.Time().Int64() >= timestamp {
+		timestamp = parent.Time().Int64() + 1
+	}
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   core.CalcGasLimit(parent),
+		Coinbase:   m.Coinbase(),
+		Time:       big.NewInt(timestamp),
+		BaseFee:    core.CalcBaseFee(m.chainConfig, parent.Header()),
+	}
+	if err := m.engine.Prepare(m.chain, header); err != nil {
+		return nil, err
+	}
+	statedb, err := m.chain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	env := &environment{
+		header:  header,
+		state:   statedb,
+		gasPool: new(core.GasPool).AddGas(header.GasLimit),
+	}
+
+	pending, err := m.txPool.Pending()
+	if err != nil {
+		return nil, err
+	}
+	// Fees may be paid in any registered asset, order by the tip the block
+	// earns in ZIP
+	assets := asset.NewAsset(statedb)
+	price := func(tx *types.Transaction) *big.Int {
+		rate, err := assets.GetFeeRate(tx.FeeAsset())
+		if err != nil {
+			return new(big.Int)
+		}
+		var base *big.Int
+		if header.BaseFee != nil {
+			base = rate.FromZip(header.BaseFee)
+		}
+		return rate.ToZip(tx.EffectiveTip(base))
+	}
+	m.commitTransactions(env, newTxsByPriceAndNonce(m.signer, pending, price))
+
+	header.Root = statedb.IntermediateRoot(true)
+	return types.NewBlock(header, env.txs, nil, env.receipts), nil
+}
+
+// commitTransactions applies the transactions in price and nonce order until
+// the block gas limit is reached, skipping the ones that fail to apply.
+func (m *Miner) commitTransactions(env *environment, txs *txsByPriceAndNonce) {
+	for {
+		// If we don't have enough gas for any further transactions then we're done
+		if env.gasPool.Gas() < params.TxGas {
+			log.Trace("Not enough gas for further transactions", "have", env.gasPool, "want", params.TxGas)
+			break
+		}
+		tx := txs.Peek()
+		if tx == nil {
+			break
+		}
+		// Error may be ignored here. The error has already been checked
+		// during transaction acceptance is the transaction pool.
+		from, _ := types.Sender(m.signer, tx)
+
+		env.state.Prepare(tx.Hash(), common.Hash{}, len(env.txs))
+		snap := env.state.Snapshot()
+		receipt, _, err := core.ApplyTransaction(m.chainConfig, m.chain, m.chain.EngineFactory(), &env.header.Coinbase, env.gasPool, env.state, env.header, tx, &env.header.GasUsed, vm.Config{})
+		switch err {
+		case core.ErrGasLimitReached:
+			// Pop the current out-of-gas transaction without shifting in the next from the account
+			env.state.RevertToSnapshot(snap)
+			log.Trace("Gas limit exceeded for current block", "sender", from)
+			txs.Pop()
+
+		case core.ErrNonceTooLow:
+			// New head notification data race between the transaction pool and miner, shift
+			env.state.RevertToSnapshot(snap)
+			log.Trace("Skipping transaction with low nonce", "sender", from, "nonce", tx.Nonce())
+			txs.Shift()
+
+		case core.ErrNonceTooHigh:
+			// Reorg notification data race between the transaction pool and miner, skip account
+			env.state.RevertToSnapshot(snap)
+			log.Trace("Skipping account with high nonce", "sender", from, "nonce", tx.Nonce())
+			txs.Pop()
+
+		case core.ErrFeeCapTooLow:
+			// The base fee rose above the gas price, the later nonces of the account can't go either
+			env.state.RevertToSnapshot(snap)
+			log.Trace("Skipping account with gas price below base fee", "sender", from, "nonce", tx.Nonce())
+			txs.Pop()
+
+		case core.ErrTxNotYetValid, core.ErrTxExpired:
+			// Outside the validity window, the later nonces of the account can't go either
+			env.state.RevertToSnapshot(snap)
+			log.Trace("Skipping account with transaction outside validity window", "sender", from, "nonce", tx.Nonce())
+			txs.Pop()
+
+		case nil:
+			// Everything ok, collect the receipt and shift in the next transaction from the same account
+			env.txs = append(env.txs, tx)
+			env.receipts = append(env.receipts, receipt)
+			txs.Shift()
+
+		default:
+			// Strange error, discard the transaction and get the next in line (note, the
+			// nonce-too-high clause will prevent us from executing in vain).
+			env.state.RevertToSnapshot(snap)
+			log.Debug("Transaction failed, account skipped", "hash", tx.Hash(), "err", err)
+			txs.Shift()
+		}
+	}
+}
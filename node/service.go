@@ -38,7 +38,7 @@ func (ctx *ServiceContext) OpenDatabase(name string, cache int, handles int) (zd
 	if ctx.config.DataDir == "" {
 		return zdb.NewMemDatabase(), nil
 	}
-	db, err := zdb.NewLDBDatabase(ctx.config.resolvePath(name), cache, handles)
+	db, err := zdb.NewLDBDatabase(ctx.config.ResolvePath(name), cache, handles)
 	if err != nil {
 		return nil, err
 	}
@@ -49,7 +49,7 @@ func (ctx *ServiceContext) OpenDatabase(name string, cache int, handles int) (zd
 // and if the user actually uses persistent storage. It will return an empty string
 // for emphemeral storage and the user's own input for absolute paths.
 func (ctx *ServiceContext) ResolvePath(path string) string {
-	return ctx.config.resolvePath(path)
+	return ctx.config.ResolvePath(path)
 }
 
 // Service retrieves a currently running service registered of a specific type.
@@ -17,13 +17,229 @@
 package params
 
 import (
+	"fmt"
 	"math/big"
+
+	"github.com/zipper-project/z0/common"
 )
 
 // ChainConfig is the core config which determines the blockchain settings.
 // ChainConfig is stored in the database on a per block basis.
 type ChainConfig struct {
 	ChainID *big.Int `json:"chainId"` // chainId identifies the current chain and is used for replay protection
+
+	RepricingBlock *big.Int `json:"repricingBlock,omitempty"` // Repricing switch block (nil = no fork, 0 = already activated)
+	CheapDataBlock *big.Int `json:"cheapDataBlock,omitempty"` // Cheap data switch block (nil = no fork, 0 = already activated)
+	BaseFeeBlock   *big.Int `json:"baseFeeBlock,omitempty"`   // Base fee switch block (nil = no fork, 0 = already activated)
+
+	BaseFee *BaseFeeConfig `json:"baseFee,omitempty"` // base fee pricing settings, the defaults if nil
+
+	PoA *PoAConfig `json:"poa,omitempty"` // proof-of-authority consensus settings
+	BFT *BFTConfig `json:"bft,omitempty"` // byzantine fault tolerant consensus settings
+}
+
+// PoAConfig is the consensus engine config for proof-of-authority sealing.
+type PoAConfig struct {
+	Period uint64 `json:"period"` // Number of seconds between blocks to enforce
+	Epoch  uint64 `json:"epoch"`  // Epoch length to reset votes and checkpoint the signers
+}
+
+// BFTConfig is the consensus engine config for byzantine fault tolerant
+// sealing with deterministic finality.
+type BFTConfig struct {
+	Validators []common.Address `json:"validators"` // Validator set proposing and voting on blocks
+	Period     uint64           `json:"period"`     // Number of seconds between blocks to enforce
+	Timeout    uint64           `json:"timeout"`    // Base round step timeout in milliseconds
+}
+
+// BaseFeeConfig prices the gas of a block with a base fee in ZIP per gas,
+// following the gas used by its parent against a target.
+type BaseFeeConfig struct {
+	Initial     *big.Int       `json:"initial"`     // Base fee of the switch block
+	Elasticity  uint64         `json:"elasticity"`  // Ratio of the gas limit to the gas target
+	Denominator uint64         `json:"denominator"` // Bounds the change between blocks to 1/Denominator
+	Treasury    common.Address `json:"treasury"`    // Receives the base fees, which are burnt if zero
+}
+
+// DefaultBaseFeeConfig are the base fee settings of chains not configuring
+// their own.
+var DefaultBaseFeeConfig = BaseFeeConfig{
+	Initial:     big.NewInt(InitialBaseFee),
+	Elasticity:  BaseFeeElasticity,
+	Denominator: BaseFeeChangeDenominator,
+}
+
+// IsRepricing returns whether num is either equal to the repricing fork block or greater.
+func (c *ChainConfig) IsRepricing(num *big.Int) bool {
+	return isForked(c.RepricingBlock, num)
+}
+
+// IsCheapData returns whether num is either equal to the cheap data fork block or greater.
+func (c *ChainConfig) IsCheapData(num *big.Int) bool {
+	return isForked(c.CheapDataBlock, num)
+}
+
+// IsBaseFee returns whether num is either equal to the base fee fork block or greater.
+func (c *ChainConfig) IsBaseFee(num *big.Int) bool {
+	return isForked(c.BaseFeeBlock, num)
+}
+
+// BaseFeeParams returns the base fee settings of the chain.
+func (c *ChainConfig) BaseFeeParams() BaseFeeConfig {
+	if c.BaseFee == nil {
+		return DefaultBaseFeeConfig
+	}
+	return *c.BaseFee
+}
+
+// GasTable returns the gas table of the virtual machine for the block number.
+func (c *ChainConfig) GasTable(num *big.Int) GasTable {
+	if num == nil {
+		return DefaultGasTable
+	}
+	switch {
+	case c.IsRepricing(num):
+		return GasTableRepricing
+	default:
+		return DefaultGasTable
+	}
+}
+
+// FeeSchedule returns the intrinsic gas schedule of transactions for the block
+// number.
+func (c *ChainConfig) FeeSchedule(num *big.Int) FeeSchedule {
+	if num == nil {
+		return DefaultFeeSchedule
+	}
+	switch {
+	case c.IsCheapData(num):
+		return FeeScheduleCheapData
+	default:
+		return DefaultFeeSchedule
+	}
+}
+
+// CheckCompatible checks whether scheduled fork transitions have been imported
+// with a mismatching chain configuration.
+func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *ConfigCompatError {
+	bhead := new(big.Int).SetUint64(height)
+
+	// Iterate checkCompatible to find the lowest conflict.
+	var lasterr *ConfigCompatError
+	for {
+		err := c.checkCompatible(newcfg, bhead)
+		if err == nil || (lasterr != nil && err.RewindTo == lasterr.RewindTo) {
+			break
+		}
+		lasterr = err
+		bhead.SetUint64(err.RewindTo)
+	}
+	return lasterr
+}
+
+var big1 = big.NewInt(1)
+
+func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *ConfigCompatError {
+	if isForkIncompatible(c.RepricingBlock, newcfg.RepricingBlock, head) {
+		return newCompatError("Repricing fork block", c.RepricingBlock, newcfg.RepricingBlock)
+	}
+	if isForkIncompatible(c.CheapDataBlock, newcfg.CheapDataBlock, head) {
+		return newCompatError("Cheap data fork block", c.CheapDataBlock, newcfg.CheapDataBlock)
+	}
+	if isForkIncompatible(c.BaseFeeBlock, newcfg.BaseFeeBlock, head) {
+		return newCompatError("Base fee fork block", c.BaseFeeBlock, newcfg.BaseFeeBlock)
+	}
+	if isForked(c.BaseFeeBlock, head) && !c.BaseFeeParams().equal(newcfg.BaseFeeParams()) {
+		return newCompatError("Base fee parameters", c.BaseFeeBlock, newcfg.BaseFeeBlock)
+	}
+	// The engine seals every block after the genesis
+	if isForked(big1, head) && !c.sameEngine(newcfg) {
+		return newCompatError("Consensus engine config", big1, big1)
+	}
+	return nil
+}
+
+// equal returns whether both settings price the gas alike.
+func (b BaseFeeConfig) equal(o BaseFeeConfig) bool {
+	return configNumEqual(b.Initial, o.Initial) && b.Elasticity == o.Elasticity &&
+		b.Denominator == o.Denominator && b.Treasury == o.Treasury
+}
+
+// sameEngine returns whether both configs verify blocks with the same engine
+// and settings. The BFT timeout is left out, it only paces the rounds.
+func (c *ChainConfig) sameEngine(newcfg *ChainConfig) bool {
+	if (c.PoA == nil) != (newcfg.PoA == nil) || (c.BFT == nil) != (newcfg.BFT == nil) {
+		return false
+	}
+	if c.PoA != nil && *c.PoA != *newcfg.PoA {
+		return false
+	}
+	if c.BFT != nil {
+		if c.BFT.Period != newcfg.BFT.Period || len(c.BFT.Validators) != len(newcfg.BFT.Validators) {
+			return false
+		}
+		for i, validator := range c.BFT.Validators {
+			if validator != newcfg.BFT.Validators[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isForkIncompatible returns true if a fork scheduled at s1 cannot be rescheduled to
+// block s2 because head is already past the fork.
+func isForkIncompatible(s1, s2, head *big.Int) bool {
+	return (isForked(s1, head) || isForked(s2, head)) && !configNumEqual(s1, s2)
+}
+
+// isForked returns whether a fork scheduled at block s is active at the given head block.
+func isForked(s, head *big.Int) bool {
+	if s == nil || head == nil {
+		return false
+	}
+	return s.Cmp(head) <= 0
+}
+
+func configNumEqual(x, y *big.Int) bool {
+	if x == nil {
+		return y == nil
+	}
+	if y == nil {
+		return x == nil
+	}
+	return x.Cmp(y) == 0
+}
+
+// ConfigCompatError is raised if the locally-stored blockchain is initialised with a
+// ChainConfig that would alter the past.
+type ConfigCompatError struct {
+	What string
+	// block numbers of the stored and new configurations
+	StoredConfig, NewConfig *big.Int
+	// the block number to which the local chain must be rewound to correct the error
+	RewindTo uint64
+}
+
+func newCompatError(what string, storedblock, newblock *big.Int) *ConfigCompatError {
+	var rew *big.Int
+	switch {
+	case storedblock == nil:
+		rew = newblock
+	case newblock == nil || storedblock.Cmp(newblock) < 0:
+		rew = storedblock
+	default:
+		rew = newblock
+	}
+	err := &ConfigCompatError{what, storedblock, newblock, 0}
+	if rew != nil && rew.Sign() > 0 {
+		err.RewindTo = rew.Uint64() - 1
+	}
+	return err
+}
+
+func (err *ConfigCompatError) Error() string {
+	return fmt.Sprintf("mismatching %s in database (have %d, want %d, rewindto %d)", err.What, err.StoredConfig, err.NewConfig, err.RewindTo)
 }
 
 var DefaultChainconfig = &ChainConfig{ChainID: big.NewInt(1)}
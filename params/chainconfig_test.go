@@ -0,0 +1,155 @@
+// Copyright 2018 The zipper Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/zipper-project/z0/common"
+)
+
+func TestForkSchedules(t *testing.T) {
+	c := &ChainConfig{ChainID: big.NewInt(1), RepricingBlock: big.NewInt(10), CheapDataBlock: big.NewInt(20), BaseFeeBlock: big.NewInt(30)}
+	if c.IsRepricing(big.NewInt(9)) || !c.IsRepricing(big.NewInt(10)) {
+		t.Errorf("repricing activation mismatch")
+	}
+	if gt := c.GasTable(big.NewInt(9)); gt != DefaultGasTable {
+		t.Errorf("gas table before fork mismatch: %v", gt)
+	}
+	if gt := c.GasTable(big.NewInt(10)); gt != GasTableRepricing {
+		t.Errorf("gas table after fork mismatch: %v", gt)
+	}
+	if fees := c.FeeSchedule(big.NewInt(19)); fees != DefaultFeeSchedule {
+		t.Errorf("fee schedule before fork mismatch: %v", fees)
+	}
+	if fees := c.FeeSchedule(big.NewInt(20)); fees != FeeScheduleCheapData {
+		t.Errorf("fee schedule after fork mismatch: %v", fees)
+	}
+	if c.IsBaseFee(big.NewInt(29)) || !c.IsBaseFee(big.NewInt(30)) {
+		t.Errorf("base fee activation mismatch")
+	}
+	if fee := c.BaseFeeParams(); !reflect.DeepEqual(fee, DefaultBaseFeeConfig) {
+		t.Errorf("default base fee settings mismatch: %v", fee)
+	}
+	c.BaseFee = &BaseFeeConfig{Initial: big.NewInt(7), Elasticity: 4, Denominator: 16}
+	if fee := c.BaseFeeParams(); fee.Initial.Int64() != 7 || fee.Elasticity != 4 || fee.Denominator != 16 {
+		t.Errorf("base fee settings mismatch: %v", fee)
+	}
+	if DefaultChainconfig.IsRepricing(big.NewInt(1<<40)) || DefaultChainconfig.IsBaseFee(big.NewInt(1<<40)) {
+		t.Errorf("unscheduled fork active")
+	}
+}
+
+func TestCheckCompatible(t *testing.T) {
+	type test struct {
+		stored, new *ChainConfig
+		head        uint64
+		wantErr     *ConfigCompatError
+	}
+	tests := []test{
+		{stored: DefaultChainconfig, new: DefaultChainconfig, head: 0, wantErr: nil},
+		{stored: DefaultChainconfig, new: DefaultChainconfig, head: 100, wantErr: nil},
+		{
+			stored:  &ChainConfig{RepricingBlock: big.NewInt(10)},
+			new:     &ChainConfig{RepricingBlock: big.NewInt(20)},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored: DefaultChainconfig,
+			new:    &ChainConfig{RepricingBlock: big.NewInt(0)},
+			head:   3,
+			wantErr: &ConfigCompatError{
+				What:         "Repricing fork block",
+				StoredConfig: nil,
+				NewConfig:    big.NewInt(0),
+				RewindTo:     0,
+			},
+		},
+		{
+			stored: &ChainConfig{RepricingBlock: big.NewInt(30), CheapDataBlock: big.NewInt(10)},
+			new:    &ChainConfig{RepricingBlock: big.NewInt(25), CheapDataBlock: big.NewInt(20)},
+			head:   40,
+			wantErr: &ConfigCompatError{
+				What:         "Cheap data fork block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(20),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{BaseFeeBlock: big.NewInt(50)},
+			new:    &ChainConfig{},
+			head:   60,
+			wantErr: &ConfigCompatError{
+				What:         "Base fee fork block",
+				StoredConfig: big.NewInt(50),
+				NewConfig:    nil,
+				RewindTo:     49,
+			},
+		},
+	}
+
+	// The base fee settings can't change once the fork priced blocks
+	var (
+		baseFee = func(initial int64, elasticity, denominator uint64, treasury common.Address) *ChainConfig {
+			return &ChainConfig{BaseFeeBlock: big.NewInt(10), BaseFee: &BaseFeeConfig{Initial: big.NewInt(initial), Elasticity: elasticity, Denominator: denominator, Treasury: treasury}}
+		}
+		stored     = baseFee(7, 2, 8, common.Address{})
+		baseFeeErr = &ConfigCompatError{What: "Base fee parameters", StoredConfig: big.NewInt(10), NewConfig: big.NewInt(10), RewindTo: 9}
+	)
+	defaults := DefaultBaseFeeConfig
+	tests = append(tests, []test{
+		{stored: stored, new: baseFee(7, 2, 8, common.Address{}), head: 20, wantErr: nil},
+		{stored: stored, new: baseFee(9, 4, 16, common.Address{0x01}), head: 9, wantErr: nil},
+		{stored: stored, new: baseFee(9, 2, 8, common.Address{}), head: 20, wantErr: baseFeeErr},
+		{stored: stored, new: baseFee(7, 4, 8, common.Address{}), head: 20, wantErr: baseFeeErr},
+		{stored: stored, new: baseFee(7, 2, 16, common.Address{}), head: 10, wantErr: baseFeeErr},
+		{stored: stored, new: baseFee(7, 2, 8, common.Address{0x01}), head: 20, wantErr: baseFeeErr},
+		{stored: &ChainConfig{BaseFeeBlock: big.NewInt(10)}, new: &ChainConfig{BaseFeeBlock: big.NewInt(10), BaseFee: &defaults}, head: 20, wantErr: nil},
+		{stored: &ChainConfig{BaseFeeBlock: big.NewInt(10)}, new: stored, head: 20, wantErr: baseFeeErr},
+	}...)
+
+	// The engine settings verifying the blocks can't change once there are any
+	var (
+		poa        = &ChainConfig{PoA: &PoAConfig{Period: 5, Epoch: 100}}
+		validators = []common.Address{{0x01}, {0x02}}
+		bft        = &ChainConfig{BFT: &BFTConfig{Validators: validators, Period: 5, Timeout: 1000}}
+		engineErr  = &ConfigCompatError{What: "Consensus engine config", StoredConfig: big.NewInt(1), NewConfig: big.NewInt(1)}
+	)
+	tests = append(tests, []test{
+		{stored: poa, new: &ChainConfig{PoA: &PoAConfig{Period: 5, Epoch: 100}}, head: 10, wantErr: nil},
+		{stored: poa, new: &ChainConfig{PoA: &PoAConfig{Period: 3, Epoch: 100}}, head: 0, wantErr: nil},
+		{stored: poa, new: &ChainConfig{PoA: &PoAConfig{Period: 3, Epoch: 100}}, head: 10, wantErr: engineErr},
+		{stored: poa, new: &ChainConfig{PoA: &PoAConfig{Period: 5, Epoch: 50}}, head: 10, wantErr: engineErr},
+		{stored: poa, new: DefaultChainconfig, head: 10, wantErr: engineErr},
+		{stored: poa, new: bft, head: 1, wantErr: engineErr},
+		{stored: bft, new: &ChainConfig{BFT: &BFTConfig{Validators: validators, Period: 5, Timeout: 3000}}, head: 10, wantErr: nil},
+		{stored: bft, new: &ChainConfig{BFT: &BFTConfig{Validators: validators[:1], Period: 5, Timeout: 1000}}, head: 10, wantErr: engineErr},
+		{stored: bft, new: &ChainConfig{BFT: &BFTConfig{Validators: []common.Address{{0x02}, {0x01}}, Period: 5, Timeout: 1000}}, head: 10, wantErr: engineErr},
+		{stored: bft, new: &ChainConfig{BFT: &BFTConfig{Validators: validators, Period: 1, Timeout: 1000}}, head: 10, wantErr: engineErr},
+	}...)
+
+	for _, test := range tests {
+		err := test.stored.CheckCompatible(test.new, test.head)
+		if !reflect.DeepEqual(err, test.wantErr) {
+			t.Errorf("error mismatch:\nstored: %v\nnew: %v\nhead: %v\nerr: %v\nwant: %v", test.stored, test.new, test.head, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2018 The zipper Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+// GasTable organizes gas prices for the operations of the virtual machine
+// whose cost depends on accessing the state.
+type GasTable struct {
+	ExtcodeSize uint64
+	ExtcodeCopy uint64
+	Balance     uint64
+	SLoad       uint64
+	Calls       uint64
+	Suicide     uint64
+
+	ExpByte uint64
+
+	// CreateBySuicide occurs when the refunded account is one that does
+	// not exist. This logic is similar to call. May be left nil. Nil means
+	// not charged.
+	CreateBySuicide uint64
+}
+
+// DefaultGasTable contains the gas prices of the virtual machine, the state
+// accessing operations are priced to resist IO-heavy denial of service.
+var DefaultGasTable = GasTable{
+	ExtcodeSize: 700,
+	ExtcodeCopy: 700,
+	Balance:     400,
+	SLoad:       200,
+	Calls:       700,
+	Suicide:     5000,
+	ExpByte:     50,
+
+	CreateBySuicide: 25000,
+}
+
+// GasTableRepricing contains the gas prices of the virtual machine from the
+// repricing fork on, state reads are priced after their actual cost.
+var GasTableRepricing = GasTable{
+	ExtcodeSize: 700,
+	ExtcodeCopy: 700,
+	Balance:     700,
+	SLoad:       800,
+	Calls:       700,
+	Suicide:     5000,
+	ExpByte:     50,
+
+	CreateBySuicide: 25000,
+}
+
+// FeeSchedule organizes the intrinsic gas charged to a transaction before it
+// executes.
+type FeeSchedule struct {
+	TxGas                 uint64 // Per output not creating a contract
+	TxGasContractCreation uint64 // Per output creating a contract
+	TxDataNonZeroGas      uint64 // Per byte of data that is not equal to zero
+	TxDataZeroGas         uint64 // Per byte of data that equals zero
+}
+
+// DefaultFeeSchedule contains the intrinsic gas of the genesis rules.
+var DefaultFeeSchedule = FeeSchedule{
+	TxGas:                 TxGas,
+	TxGasContractCreation: TxGasContractCreation,
+	TxDataNonZeroGas:      TxDataNonZeroGas,
+	TxDataZeroGas:         TxDataZeroGas,
+}
+
+// FeeScheduleCheapData contains the intrinsic gas from the cheap data fork on,
+// non-zero data bytes are cheaper to leave room for contract calls.
+var FeeScheduleCheapData = FeeSchedule{
+	TxGas:                 TxGas,
+	TxGasContractCreation: TxGasContractCreation,
+	TxDataNonZeroGas:      16,
+	TxDataZeroGas:         TxDataZeroGas,
+}
@@ -33,4 +33,81 @@ const (
 	TxDataNonZeroGas uint64 = 68
 	// TxDataZeroGas Per byte of data attached to a transaction that equals zero. NOTE: Not payable on data of calls between transactions.
 	TxDataZeroGas uint64 = 4
+
+	// CallCreateDepth Maximum depth of call/create stack.
+	CallCreateDepth uint64 = 1024
+	// StackLimit Maximum size of VM stack allowed.
+	StackLimit uint64 = 1024
+	// MaxCodeSize Maximum bytecode to permit for a contract.
+	MaxCodeSize = 24576
+
+	// CallValueTransferGas Paid for CALL when the value transfer is non-zero.
+	CallValueTransferGas uint64 = 9000
+	// CallNewAccountGas Paid for CALL when the destination address didn't exist prior.
+	CallNewAccountGas uint64 = 25000
+	// QuadCoeffDiv Divisor for the quadratic particle of the memory cost equation.
+	QuadCoeffDiv uint64 = 512
+	// LogDataGas Per byte in a LOG* operation's data.
+	LogDataGas uint64 = 8
+	// CallStipend Free gas given at beginning of call.
+	CallStipend uint64 = 2300
+	// Sha3Gas Once per SHA3 operation.
+	Sha3Gas uint64 = 30
+	// Sha3WordGas Once per word of the SHA3 operation's data.
+	Sha3WordGas uint64 = 6
+	// SstoreSetGas Once per SSTORE operation from clean zero.
+	SstoreSetGas uint64 = 20000
+	// SstoreResetGas Once per SSTORE operation from clean non-zero.
+	SstoreResetGas uint64 = 5000
+	// SstoreClearGas Once per SSTORE operation from clean non-zero to zero.
+	SstoreClearGas uint64 = 5000
+	// SstoreRefundGas Once per SSTORE operation if the zeroness changes to zero.
+	SstoreRefundGas uint64 = 15000
+	// JumpdestGas Once per JUMPDEST operation.
+	JumpdestGas uint64 = 1
+	// CreateDataGas Paid per byte of the code stored by a contract creation.
+	CreateDataGas uint64 = 200
+	// ExpGas Once per EXP instruction
+	ExpGas uint64 = 10
+	// LogGas Per LOG* operation.
+	LogGas uint64 = 375
+	// CopyGas Multiplied by the number of 32-byte words that are copied (round up) for any *COPY operation and added.
+	CopyGas uint64 = 3
+	// LogTopicGas Multiplied by the * of the LOG*, per LOG transaction. e.g. LOG0 incurs 0 * c_txLogTopicGas, LOG4 incurs 4 * c_txLogTopicGas.
+	LogTopicGas uint64 = 375
+	// CreateGas Once per CREATE operation & contract-creation transaction.
+	CreateGas uint64 = 32000
+	// SuicideRefundGas Refunded following a suicide operation.
+	SuicideRefundGas uint64 = 24000
+	// MemoryGas Times the address of the (highest referenced byte in memory + 1). NOTE: That also applies to the memory use of a contract.
+	MemoryGas uint64 = 3
+
+	// EcrecoverGas Elliptic curve sender recovery gas price
+	EcrecoverGas uint64 = 3000
+	// Sha256BaseGas Base price for a SHA256 operation
+	Sha256BaseGas uint64 = 60
+	// Sha256PerWordGas Per-word price for a SHA256 operation
+	Sha256PerWordGas uint64 = 12
+	// IdentityBaseGas Base price for a data copy operation
+	IdentityBaseGas uint64 = 15
+	// IdentityPerWordGas Per-word price for a data copy operation
+	IdentityPerWordGas uint64 = 3
+
+	// AssetBalanceGas Price for reading a balance through the asset contracts
+	AssetBalanceGas uint64 = 400
+	// AssetInfoGas Price for reading the info of an asset through the asset contracts
+	AssetInfoGas uint64 = 700
+	// AssetTransferGas Price for moving an asset through the asset contracts
+	AssetTransferGas uint64 = 9000
+	// AssetIssueGas Price for issuing an asset through the asset contracts
+	AssetIssueGas uint64 = 20000
+	// AssetRegisterGas Price for registering an asset through the asset contracts
+	AssetRegisterGas uint64 = 32000
+
+	// InitialBaseFee Base fee of the base fee switch block, in ZIP per gas.
+	InitialBaseFee = 1
+	// BaseFeeElasticity Ratio of the gas limit to the gas a block targets.
+	BaseFeeElasticity uint64 = 2
+	// BaseFeeChangeDenominator Bounds the change of the base fee between blocks.
+	BaseFeeChangeDenominator uint64 = 8
 )
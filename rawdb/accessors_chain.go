@@ -269,6 +269,7 @@ func DeleteBlock(db DatabaseDeleter, hash common.Hash, number uint64) {
 	DeleteHeader(db, hash, number)
 	DeleteBody(db, hash, number)
 	DeleteTd(db, hash, number)
+	DeleteCommit(db, hash, number)
 }
 
 // ReadTd retrieves a block's total difficulty corresponding to the hash.
@@ -347,6 +348,26 @@ func DeleteReceipts(db DatabaseDeleter, hash common.Hash, number uint64) {
 	}
 }
 
+// ReadCommitRLP retrieves the RLP encoded commit signatures finalizing a block.
+func ReadCommitRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
+	data, _ := db.Get(blockCommitKey(number, hash))
+	return data
+}
+
+// WriteCommitRLP stores the RLP encoded commit signatures finalizing a block.
+func WriteCommitRLP(db DatabaseWriter, hash common.Hash, number uint64, rlp rlp.RawValue) {
+	if err := db.Put(blockCommitKey(number, hash), rlp); err != nil {
+		log.Crit("Failed to store block commit", "err", err)
+	}
+}
+
+// DeleteCommit removes the commit signatures finalizing a block.
+func DeleteCommit(db DatabaseDeleter, hash common.Hash, number uint64) {
+	if err := db.Delete(blockCommitKey(number, hash)); err != nil {
+		log.Crit("Failed to delete block commit", "err", err)
+	}
+}
+
 // FindCommonAncestor returns the last common ancestor of two block headers
 func FindCommonAncestor(db DatabaseReader, a, b *types.Header) *types.Header {
 	for bn := b.Number.Uint64(); a.Number.Uint64() > bn; {
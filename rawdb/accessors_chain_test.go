@@ -178,6 +178,26 @@ func TestTdStorage(t *testing.T) {
 	}
 }
 
+// Tests block commit storage and retrieval operations.
+func TestCommitStorage(t *testing.T) {
+	db := zdb.NewMemDatabase()
+
+	hash, commit := common.Hash{1}, []byte{0xc0}
+	if entry := ReadCommitRLP(db, hash, 1); len(entry) != 0 {
+		t.Fatalf("Non existent commit returned: %x", entry)
+	}
+	// Write and verify the commit in the database
+	WriteCommitRLP(db, hash, 1, commit)
+	if entry := ReadCommitRLP(db, hash, 1); !bytes.Equal(entry, commit) {
+		t.Fatalf("Retrieved commit mismatch: have %x, want %x", entry, commit)
+	}
+	// Delete the block and verify its commit is gone as well
+	DeleteBlock(db, hash, 1)
+	if entry := ReadCommitRLP(db, hash, 1); len(entry) != 0 {
+		t.Fatalf("Deleted commit returned: %x", entry)
+	}
+}
+
 // Tests that canonical numbers can be mapped to hashes and retrieved.
 func TestCanonicalMappingStorage(t *testing.T) {
 	db := zdb.NewMemDatabase()
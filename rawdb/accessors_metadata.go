@@ -71,6 +71,21 @@ func WriteChainConfig(db DatabaseWriter, hash common.Hash, cfg *params.ChainConf
 	}
 }
 
+// ReadGenesis retrieves the JSON encoded genesis specification the genesis
+// block of the given hash was created from.
+func ReadGenesis(db DatabaseReader, hash common.Hash) []byte {
+	data, _ := db.Get(genesisKey(hash))
+	return data
+}
+
+// WriteGenesis stores the JSON encoded genesis specification of the genesis
+// block.
+func WriteGenesis(db DatabaseWriter, hash common.Hash, data []byte) {
+	if err := db.Put(genesisKey(hash), data); err != nil {
+		log.Crit("Failed to store genesis specification", "err", err)
+	}
+}
+
 // ReadPreimage retrieves a single preimage of the provided hash.
 func ReadPreimage(db DatabaseReader, hash common.Hash) []byte {
 	data, _ := db.Get(preimageKey(hash))
@@ -22,4 +22,5 @@ type API struct {
 	Version   string      // api version for DApp's
 	Service   interface{} // receiver instance which holds the methods
 	Public    bool        // indication if the methods must be considered safe for public use
+	Ledger    string      // hosted ledger the methods of Service serve, empty for node wide APIs
 }
@@ -71,6 +71,10 @@ type (
 	resetObjectChange struct {
 		prev *stateObject
 	}
+	suicideChange struct {
+		account *common.Address
+		prev    bool // whether account had already suicided
+	}
 
 	storageChange struct {
 		account       *common.Address
@@ -114,6 +118,17 @@ func (ch resetObjectChange) dirtied() *common.Address {
 	return nil
 }
 
+func (ch suicideChange) revert(s *StateDB) {
+	obj := s.getStateObject(*ch.account)
+	if obj != nil {
+		obj.suicided = ch.prev
+	}
+}
+
+func (ch suicideChange) dirtied() *common.Address {
+	return ch.account
+}
+
 var ripemd = common.HexToAddress("0000000000000000000000000000000000000003")
 
 func (ch codeChange) revert(s *StateDB) {
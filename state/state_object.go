@@ -77,6 +77,7 @@ type stateObject struct {
 	cachedStorage map[common.Hash]common.Hash // Storage entry cache to avoid duplicate reads
 	dirtyStorage  map[common.Hash]common.Hash // Storage entries that need to be flushed to disk
 
+	suicided  bool // true if the contract destroyed itself, see clearContract
 	deleted   bool
 	dirtyCode bool // true if the code was updated
 }
@@ -256,6 +257,23 @@ func (self *stateObject) setCode(codeHash common.Hash, code []byte) {
 	self.dirtyCode = true
 }
 
+func (self *stateObject) markSuicided() {
+	self.suicided = true
+}
+
+// clearContract drops the code and the storage of a destroyed contract. The
+// account data, holding the asset balances and the nonce, is kept.
+func (self *stateObject) clearContract() {
+	self.data.StRoot = common.Hash{}
+	self.data.CodeHash = emptyCodeHash
+	self.sttrie = nil
+	self.code = nil
+	self.dirtyCode = false
+	self.cachedStorage = make(map[common.Hash]common.Hash)
+	self.dirtyStorage = make(map[common.Hash]common.Hash)
+	self.suicided = false
+}
+
 func (self *stateObject) CodeHash() []byte {
 	return self.data.CodeHash
 }
@@ -326,6 +344,7 @@ func (self *stateObject) deepCopy(db *StateDB) *stateObject {
 	stateObject.cachedStorage = StorageCopy(self.cachedStorage)
 	stateObject.dirtyAccount = CacheAccountCopy(self.dirtyAccount)
 	stateObject.cacheAccount = CacheAccountCopy(self.cacheAccount)
+	stateObject.suicided = self.suicided
 	stateObject.deleted = self.deleted
 	return stateObject
 }
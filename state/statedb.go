@@ -176,6 +176,18 @@ func (self *StateDB) SetCode(addr common.Address, code []byte) {
 	}
 }
 
+func (self *StateDB) GetCodeSize(addr common.Address) int {
+	return len(self.GetCode(addr))
+}
+
+func (self *StateDB) GetCodeHash(addr common.Address) common.Hash {
+	stateObject := self.getStateObject(addr)
+	if stateObject == nil {
+		return common.Hash{}
+	}
+	return common.BytesToHash(stateObject.CodeHash())
+}
+
 func (self *StateDB) GetState(addr common.Address, key common.Hash) common.Hash {
 	stateObject := self.getStateObject(addr)
 	if stateObject != nil {
@@ -213,6 +225,31 @@ func (self *StateDB) DeleteAccount(addr common.Address, key string) {
 	}
 }
 
+// Suicide marks the given contract as destroyed. Its code and storage are
+// dropped when the state is finalised, the account data stays untouched.
+//
+// The returned value reports whether the contract exists.
+func (self *StateDB) Suicide(addr common.Address) bool {
+	stateObject := self.getStateObject(addr)
+	if stateObject == nil {
+		return false
+	}
+	self.journal.append(suicideChange{
+		account: &addr,
+		prev:    stateObject.suicided,
+	})
+	stateObject.markSuicided()
+	return true
+}
+
+func (self *StateDB) HasSuicided(addr common.Address) bool {
+	stateObject := self.getStateObject(addr)
+	if stateObject != nil {
+		return stateObject.suicided
+	}
+	return false
+}
+
 func (self *StateDB) Database() Database {
 	return self.db
 }
@@ -387,6 +424,9 @@ func (s *StateDB) Finalise(deleteEmptyObjects bool) {
 			continue
 		}
 
+		if stateObject.suicided {
+			stateObject.clearContract()
+		}
 		if deleteEmptyObjects && stateObject.empty() {
 			s.deleteStateObject(stateObject)
 		} else {
@@ -24,14 +24,26 @@ var (
 	// ErrInvalidSender is returned if the transaction contains an invalid signature.
 	ErrInvalidSender = errors.New("invalid sender")
 
+	// ErrMissingSignatures is returned if the signatures of a transaction don't
+	// meet the key set of the sending account yet.
+	ErrMissingSignatures = errors.New("missing signatures of the account key set")
+
 	// ErrNonceTooLow is returned if the nonce of a transaction is lower than the
 	// one present in the local chain.
 	ErrNonceTooLow = errors.New("nonce too low")
 
+	// ErrTxExpired is returned if the validity window of a transaction closed
+	// before the pending block, so it can never be included.
+	ErrTxExpired = errors.New("transaction expired")
+
 	// ErrUnderpriced is returned if a transaction's gas price is below the minimum
 	// configured for the transaction pool.
 	ErrUnderpriced = errors.New("transaction underpriced")
 
+	// ErrTipAboveFeeCap is returned if the tip of a transaction is higher than
+	// its gas price, which caps the base fee and the tip together.
+	ErrTipAboveFeeCap = errors.New("tip higher than gas price")
+
 	// ErrReplaceUnderpriced is returned if a transaction is attempted to be replaced
 	// with a different one without the required price bump.
 	ErrReplaceUnderpriced = errors.New("replacement transaction underpriced")
@@ -56,4 +68,12 @@ var (
 	// than some meaningful limit a user might use. This is not a consensus error
 	// making the transaction invalid, rather a DOS protection.
 	ErrOversizedData = errors.New("oversized data")
+
+	// ErrDoubleSpend is returned if a transaction spends an unspent output that
+	// is already spent by another transaction in the pool.
+	ErrDoubleSpend = errors.New("utxo already spent by pooled transaction")
+
+	// ErrUTXOUnbalanced is returned if the outputs of an utxo model asset don't
+	// add up to the amount spent by the inputs of the same asset.
+	ErrUTXOUnbalanced = errors.New("utxo inputs and outputs unbalanced")
 )
@@ -20,6 +20,7 @@ import (
 	"math"
 	"math/big"
 
+	"github.com/zipper-project/z0/common"
 	"github.com/zipper-project/z0/types"
 )
 
@@ -32,15 +33,18 @@ type txList struct {
 	txs     *txSortedMap // Heap indexed sorted hash map of the transactions
 	costcap *big.Int     // Price of the highest costing transaction (reset only if exceeds balance)
 	gascap  uint64       // Gas limit of the highest spending transaction (reset only if exceeds block limit)
+
+	spentcap map[common.Address]*big.Int // Cumulative spend per non-ZIP asset (reset only if exceeds balance)
 }
 
 // newTxList create a new transaction list for maintaining nonce-indexable fast,
 // gapped, sortable transaction lists.
 func newTxList(strict bool) *txList {
 	return &txList{
-		strict:  strict,
-		txs:     newTxSortedMap(),
-		costcap: new(big.Int),
+		strict:   strict,
+		txs:      newTxSortedMap(),
+		costcap:  new(big.Int),
+		spentcap: make(map[common.Address]*big.Int),
 	}
 }
 
@@ -59,11 +63,14 @@ func (l *txList) Add(tx *types.Transaction, priceBump uint64) (bool, *types.Tran
 	// If there's an older better transaction, abort
 	old := l.txs.Get(tx.Nonce())
 	if old != nil {
-		threshold := new(big.Int).Div(new(big.Int).Mul(old.GasPrice(), big.NewInt(100+int64(priceBump))), big.NewInt(100))
-		// Have to ensure that the new gas price is higher than the old gas
-		// price as well as checking the percentage threshold to ensure that
-		// this is accurate for low (Wei-level) gas price replacements
-		if old.GasPrice().Cmp(tx.GasPrice()) >= 0 || threshold.Cmp(tx.GasPrice()) > 0 {
+		// Gas prices in different fee assets can't be compared directly, a
+		// replacement has to pay in the same asset.
+		if old.FeeAsset() != tx.FeeAsset() {
+			return false, nil
+		}
+		// Both the gas price and the tip have to be bumped, otherwise the
+		// replacement could pay the miner less
+		if !bumped(old.GasPrice(), tx.GasPrice(), priceBump) || !bumped(old.Tip(), tx.Tip(), priceBump) {
 			return false, nil
 		}
 	}
@@ -75,9 +82,19 @@ func (l *txList) Add(tx *types.Transaction, priceBump uint64) (bool, *types.Tran
 	if gas := tx.Gas(); l.gascap < gas {
 		l.gascap = gas
 	}
+	addSpend(l.spentcap, tx)
 	return true, old
 }
 
+// bumped reports whether the new price exceeds the old one by the percentage.
+func bumped(old, price *big.Int, priceBump uint64) bool {
+	threshold := new(big.Int).Div(new(big.Int).Mul(old, big.NewInt(100+int64(priceBump))), big.NewInt(100))
+	// Have to ensure that the new price is higher than the old price as well
+	// as checking the percentage threshold to ensure that this is accurate
+	// for low (Wei-level) price replacements
+	return old.Cmp(price) < 0 && threshold.Cmp(price) <= 0
+}
+
 // Forward removes all transactions from the list with a nonce lower than the
 // provided threshold. Every removed transaction is returned for any post-removal
 // maintenance.
@@ -105,7 +122,124 @@ func (l *txList) Filter(costLimit *big.Int, gasLimit uint64) (types.Transactions
 	// Filter out all the transactions above the account's funds
 	removed := l.txs.Filter(func(tx *types.Transaction) bool { return tx.Cost().Cmp(costLimit) > 0 || tx.Gas() > gasLimit })
 
-	// If the list was strict, filter anything above the lowest nonce
+	return removed, l.invalidated(removed)
+}
+
+// FilterSpend removes all transactions from the list with which the cumulative
+// spend of a non-ZIP asset, summed up in nonce order on top of the offset,
+// isn't covered by the account any more. Like Filter, strict-mode invalidated
+// transactions are also returned.
+//
+// This method uses the cached spentcap to quickly decide if the balances cover
+// all, the caps are reset to the spend of the remaining transactions otherwise.
+func (l *txList) FilterSpend(covered func(assetID common.Address, amount *big.Int) bool, offset map[common.Address]*big.Int) (types.Transactions, types.Transactions) {
+	total := func(spent map[common.Address]*big.Int, assetID common.Address, amount *big.Int) *big.Int {
+		if prev := spent[assetID]; prev != nil {
+			return new(big.Int).Add(prev, amount)
+		}
+		return amount
+	}
+	// If the balances cover all transactions, short circuit
+	short := true
+	for assetID, amount := range l.spentcap {
+		if !covered(assetID, total(offset, assetID, amount)) {
+			short = false
+			break
+		}
+	}
+	if short {
+		return nil, nil
+	}
+	// Sum up the spend in nonce order, dropping the transactions overdrawing
+	spent := make(map[common.Address]*big.Int)
+	for assetID, amount := range offset {
+		spent[assetID] = amount
+	}
+	drops := make(map[uint64]bool)
+	for _, tx := range l.txs.Flatten() {
+		costs := assetSpend(tx)
+		for assetID, cost := range costs {
+			if !covered(assetID, total(spent, assetID, cost)) {
+				drops[tx.Nonce()] = true
+				break
+			}
+		}
+		if !drops[tx.Nonce()] {
+			for assetID, cost := range costs {
+				spent[assetID] = total(spent, assetID, cost)
+			}
+		}
+	}
+	removed := l.txs.Filter(func(tx *types.Transaction) bool { return drops[tx.Nonce()] })
+	invalids := l.invalidated(removed)
+
+	l.spentcap = l.Spent()
+	return removed, invalids
+}
+
+// Spent returns the cumulative spend of the transactions in the list per
+// non-ZIP asset.
+func (l *txList) Spent() map[common.Address]*big.Int {
+	spent := make(map[common.Address]*big.Int)
+	for _, tx := range l.txs.Flatten() {
+		addSpend(spent, tx)
+	}
+	return spent
+}
+
+// assetSpend returns the amounts the transaction spends per non-ZIP asset, ZIP
+// is accounted for by the costcap.
+func assetSpend(tx *types.Transaction) map[common.Address]*big.Int {
+	costs := tx.AssetCost()
+	delete(costs, types.ZipAssetID)
+	return costs
+}
+
+// addSpend adds the non-ZIP spend of the transaction to the totals.
+func addSpend(totals map[common.Address]*big.Int, tx *types.Transaction) {
+	for assetID, cost := range assetSpend(tx) {
+		if prev := totals[assetID]; prev != nil {
+			cost = new(big.Int).Add(prev, cost)
+		}
+		totals[assetID] = cost
+	}
+}
+
+// FilterSpent removes all transactions from the list with an utxo input that
+// the unspent callback no longer reports as spendable, e.g. because another
+// transaction spending the same output got included into the chain. Like
+// Filter, strict-mode invalidated transactions are also returned.
+func (l *txList) FilterSpent(unspent func(assetID common.Address, op types.OutPoint) bool) (types.Transactions, types.Transactions) {
+	removed := l.txs.Filter(func(tx *types.Transaction) bool {
+		inputs, err := tx.GetInputs()
+		if err != nil {
+			return true
+		}
+		for _, v := range inputs {
+			input, ok := v.(types.UTXOInput)
+			if !ok {
+				continue
+			}
+			if input.AssertID == nil || !unspent(*input.AssertID, input.OutPoint()) {
+				return true
+			}
+		}
+		return false
+	})
+	return removed, l.invalidated(removed)
+}
+
+// FilterExpired removes all transactions from the list whose validity window
+// closed before a block of the number and timestamp. Like Filter,
+// strict-mode invalidated transactions are also returned.
+func (l *txList) FilterExpired(number, time uint64) (types.Transactions, types.Transactions) {
+	removed := l.txs.Filter(func(tx *types.Transaction) bool { return tx.Expired(number, time) })
+	return removed, l.invalidated(removed)
+}
+
+// invalidated filters anything above the lowest nonce of the removed
+// transactions if the list was strict.
+func (l *txList) invalidated(removed types.Transactions) types.Transactions {
 	var invalids types.Transactions
 
 	if l.strict && len(removed) > 0 {
@@ -117,7 +251,7 @@ func (l *txList) Filter(costLimit *big.Int, gasLimit uint64) (types.Transactions
 		}
 		invalids = l.txs.Filter(func(tx *types.Transaction) bool { return tx.Nonce() > lowest })
 	}
-	return removed, invalids
+	return invalids
 }
 
 // Cap places a hard limit on the number of items, returning all transactions
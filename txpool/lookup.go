@@ -33,14 +33,16 @@ import (
 // peeking into the pool in TxPool.Get without having to acquire the widely scoped
 // TxPool.mu mutex.
 type txLookup struct {
-	all  map[common.Hash]*types.Transaction
-	lock sync.RWMutex
+	all    map[common.Hash]*types.Transaction
+	spends map[types.OutPoint]common.Hash // Pooled transaction spending each outpoint
+	lock   sync.RWMutex
 }
 
 // newTxLookup returns a new txLookup structure.
 func newTxLookup() *txLookup {
 	return &txLookup{
-		all: make(map[common.Hash]*types.Transaction),
+		all:    make(map[common.Hash]*types.Transaction),
+		spends: make(map[types.OutPoint]common.Hash),
 	}
 }
 
@@ -78,6 +80,9 @@ func (t *txLookup) Add(tx *types.Transaction) {
 	defer t.lock.Unlock()
 
 	t.all[tx.Hash()] = tx
+	for _, op := range spentOutPoints(tx) {
+		t.spends[op] = tx.Hash()
+	}
 }
 
 // Remove removes a transaction from the lookup.
@@ -85,5 +90,34 @@ func (t *txLookup) Remove(hash common.Hash) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
+	if tx, ok := t.all[hash]; ok {
+		for _, op := range spentOutPoints(tx) {
+			if t.spends[op] == hash {
+				delete(t.spends, op)
+			}
+		}
+	}
 	delete(t.all, hash)
 }
+
+// Spender returns the hash of the pooled transaction spending the outpoint.
+func (t *txLookup) Spender(op types.OutPoint) (common.Hash, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	hash, ok := t.spends[op]
+	return hash, ok
+}
+
+// spentOutPoints returns the outpoints spent by the utxo inputs of tx. Pooled
+// transactions passed validation, so their inputs decode.
+func spentOutPoints(tx *types.Transaction) []types.OutPoint {
+	var ops []types.OutPoint
+	inputs, _ := tx.GetInputs()
+	for _, v := range inputs {
+		if input, ok := v.(types.UTXOInput); ok {
+			ops = append(ops, input.OutPoint())
+		}
+	}
+	return ops
+}
@@ -0,0 +1,318 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package txpool
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/types"
+)
+
+// PolicyReason classifies the rejection of a transaction by an admission
+// policy.
+type PolicyReason string
+
+const (
+	// ReasonDenied rejects senders on a deny-list.
+	ReasonDenied PolicyReason = "sender denied"
+	// ReasonNotAllowed rejects senders missing from an allow-list.
+	ReasonNotAllowed PolicyReason = "sender not allowed"
+	// ReasonAssetRestricted rejects senders moving an asset restricted to
+	// others.
+	ReasonAssetRestricted PolicyReason = "asset restricted"
+	// ReasonRateLimited rejects senders submitting faster than allowed.
+	ReasonRateLimited PolicyReason = "rate limited"
+)
+
+// PolicyError is returned if an admission policy rejects a transaction. It
+// names the policy and the reason, so callers can surface why.
+type PolicyError struct {
+	Policy string         // Name of the rejecting policy
+	Reason PolicyReason   // Why the transaction was rejected
+	Sender common.Address // Sender of the rejected transaction
+	Asset  common.Address // Restricted asset, zero for reasons other than ReasonAssetRestricted
+}
+
+func (e *PolicyError) Error() string {
+	if e.Reason == ReasonAssetRestricted {
+		return fmt.Sprintf("%s policy: %s: %x moving %x", e.Policy, e.Reason, e.Sender, e.Asset)
+	}
+	return fmt.Sprintf("%s policy: %s: %x", e.Policy, e.Reason, e.Sender)
+}
+
+// AdmissionPolicy decides whether a transaction may enter the pool. The pool
+// consults its policies once a new transaction passed all validity checks,
+// transactions reinjected after a reorg were admitted before.
+type AdmissionPolicy interface {
+	// Admit returns nil if the transaction of the sender is admitted and a
+	// *PolicyError otherwise. Local transactions are submitted by the node.
+	Admit(tx *types.Transaction, from common.Address, local bool) error
+}
+
+// AdmissionRecorder is implemented by admission policies keeping track of the
+// admitted transactions. The pool records a transaction once it accepted it,
+// transactions rejected after their admission aren't recorded.
+type AdmissionRecorder interface {
+	Admitted(tx *types.Transaction, from common.Address, local bool)
+}
+
+// PolicyConfig are the built-in admission policies of the pool.
+type PolicyConfig struct {
+	Deny      []common.Address   `toml:",omitempty"` // Senders whose transactions are rejected
+	Allow     []common.Address   `toml:",omitempty"` // Senders admitted exclusively, everyone if empty
+	Assets    []AssetRestriction `toml:",omitempty"` // Assets only the listed senders may move
+	RateLimit *RateLimitConfig   `toml:",omitempty"` // Submission rate limit of remote senders
+}
+
+// AssetRestriction restricts moving an asset to the listed senders.
+type AssetRestriction struct {
+	Asset   common.Address
+	Senders []common.Address
+}
+
+// RateLimitConfig limits the transactions every remote sender submits with a
+// token bucket.
+type RateLimitConfig struct {
+	Rate  float64 // Transactions per second the bucket is refilled with
+	Burst uint64  // Transactions the full bucket holds
+}
+
+// NewPolicies creates the built-in admission policies of the config.
+func NewPolicies(config PolicyConfig) []AdmissionPolicy {
+	var policies []AdmissionPolicy
+	if len(config.Deny) > 0 {
+		policies = append(policies, NewDenyList(config.Deny))
+	}
+	if len(config.Allow) > 0 {
+		policies = append(policies, NewAllowList(config.Allow))
+	}
+	if len(config.Assets) > 0 {
+		policies = append(policies, NewAssetPolicy(config.Assets))
+	}
+	if config.RateLimit != nil {
+		policies = append(policies, NewRateLimit(config.RateLimit.Rate, config.RateLimit.Burst))
+	}
+	return policies
+}
+
+func addressSet(addrs []common.Address) map[common.Address]bool {
+	set := make(map[common.Address]bool, len(addrs))
+	for _, addr := range addrs {
+		set[addr] = true
+	}
+	return set
+}
+
+// DenyList rejects the transactions of the listed senders.
+type DenyList struct {
+	denied map[common.Address]bool
+}
+
+// NewDenyList creates a deny-list of the senders.
+func NewDenyList(senders []common.Address) *DenyList {
+	return &DenyList{denied: addressSet(senders)}
+}
+
+// Admit implements AdmissionPolicy.
+func (l *DenyList) Admit(tx *types.Transaction, from common.Address, local bool) error {
+	if l.denied[from] {
+		return &PolicyError{Policy: "deny-list", Reason: ReasonDenied, Sender: from}
+	}
+	return nil
+}
+
+// AllowList only admits the transactions of the listed senders.
+type AllowList struct {
+	allowed map[common.Address]bool
+}
+
+// NewAllowList creates an allow-list of the senders.
+func NewAllowList(senders []common.Address) *AllowList {
+	return &AllowList{allowed: addressSet(senders)}
+}
+
+// Admit implements AdmissionPolicy.
+func (l *AllowList) Admit(tx *types.Transaction, from common.Address, local bool) error {
+	if !l.allowed[from] {
+		return &PolicyError{Policy: "allow-list", Reason: ReasonNotAllowed, Sender: from}
+	}
+	return nil
+}
+
+// AssetPolicy restricts moving assets to the senders listed for them. An
+// asset is moved by inputs, outputs and by paying the fee.
+type AssetPolicy struct {
+	senders map[common.Address]map[common.Address]bool
+}
+
+// NewAssetPolicy creates a policy of the asset restrictions, restrictions of
+// the same asset add up.
+func NewAssetPolicy(restrictions []AssetRestriction) *AssetPolicy {
+	p := &AssetPolicy{senders: make(map[common.Address]map[common.Address]bool)}
+	for _, r := range restrictions {
+		if p.senders[r.Asset] == nil {
+			p.senders[r.Asset] = make(map[common.Address]bool)
+		}
+		for _, addr := range r.Senders {
+			p.senders[r.Asset][addr] = true
+		}
+	}
+	return p
+}
+
+// Admit implements AdmissionPolicy.
+func (p *AssetPolicy) Admit(tx *types.Transaction, from common.Address, local bool) error {
+	check := func(assetID *common.Address) error {
+		if assetID == nil {
+			return nil
+		}
+		if senders, ok := p.senders[*assetID]; ok && !senders[from] {
+			return &PolicyError{Policy: "asset", Reason: ReasonAssetRestricted, Sender: from, Asset: *assetID}
+		}
+		return nil
+	}
+	feeAsset := tx.FeeAsset()
+	if err := check(&feeAsset); err != nil {
+		return err
+	}
+	inputs, err := tx.GetInputs()
+	if err != nil {
+		return err
+	}
+	outputs, err := tx.GetOutputs()
+	if err != nil {
+		return err
+	}
+	for _, v := range inputs {
+		var assetID *common.Address
+		switch input := v.(type) {
+		case types.AMInput:
+			assetID = input.AssertID
+		case types.UTXOInput:
+			assetID = input.AssertID
+		}
+		if err := check(assetID); err != nil {
+			return err
+		}
+	}
+	for _, v := range outputs {
+		var assetID *common.Address
+		switch output := v.(type) {
+		case types.AMOutput:
+			assetID = output.AssertID
+		case types.UTXOOutput:
+			assetID = output.AssertID
+		}
+		if err := check(assetID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rateLimitSweep is the number of tracked senders above which the buckets
+// refilled to the full burst are forgotten.
+const rateLimitSweep = 4096
+
+// RateLimit limits the transactions a remote sender submits with a token
+// bucket per sender. Local transactions are exempt, like from the price limit.
+type RateLimit struct {
+	rate  float64
+	burst float64
+	now   func() time.Time // Clock of the buckets, replaced in tests
+
+	buckets map[common.Address]*tokenBucket
+	mu      sync.Mutex
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimit creates a rate limit refilling rate transactions per second up
+// to burst.
+func NewRateLimit(rate float64, burst uint64) *RateLimit {
+	return &RateLimit{
+		rate:    rate,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[common.Address]*tokenBucket),
+	}
+}
+
+// Admit implements AdmissionPolicy, checking the sender's bucket holds a
+// token.
+func (r *RateLimit) Admit(tx *types.Transaction, from common.Address, local bool) error {
+	if local {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.bucket(from).tokens < 1 {
+		return &PolicyError{Policy: "rate-limit", Reason: ReasonRateLimited, Sender: from}
+	}
+	return nil
+}
+
+// Admitted implements AdmissionRecorder, taking a token of the sender's
+// bucket.
+func (r *RateLimit) Admitted(tx *types.Transaction, from common.Address, local bool) {
+	if local {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if bucket := r.bucket(from); bucket.tokens >= 1 {
+		bucket.tokens--
+	}
+}
+
+// bucket returns the refilled bucket of the sender, the lock is held.
+func (r *RateLimit) bucket(from common.Address) *tokenBucket {
+	now := r.now()
+	if len(r.buckets) > rateLimitSweep {
+		for addr, bucket := range r.buckets {
+			if r.refill(bucket, now) >= r.burst {
+				delete(r.buckets, addr)
+			}
+		}
+	}
+	bucket := r.buckets[from]
+	if bucket == nil {
+		bucket = &tokenBucket{tokens: r.burst, last: now}
+		r.buckets[from] = bucket
+	}
+	r.refill(bucket, now)
+	return bucket
+}
+
+// refill adds the tokens accrued since the last refill to the bucket,
+// returning the tokens it holds.
+func (r *RateLimit) refill(bucket *tokenBucket, now time.Time) float64 {
+	if elapsed := now.Sub(bucket.last).Seconds(); elapsed > 0 {
+		bucket.tokens = math.Min(r.burst, bucket.tokens+elapsed*r.rate)
+		bucket.last = now
+	}
+	return bucket.tokens
+}
@@ -0,0 +1,164 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package txpool
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/types"
+)
+
+// policyReason returns the reason of a policy rejection, empty for others.
+func policyReason(err error) PolicyReason {
+	if perr, ok := err.(*PolicyError); ok {
+		return perr.Reason
+	}
+	return ""
+}
+
+func TestAdmissionPolicies(t *testing.T) {
+	pool, key, assetID, issued := setupUTXOTxPool(t)
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	other := common.Address{0x10}
+
+	// Denied senders are rejected, local or not
+	pool.policies = NewPolicies(PolicyConfig{Deny: []common.Address{from}})
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(1), key)); policyReason(err) != ReasonDenied {
+		t.Fatalf("deny-list error mismatch: have %v, want %v", err, ReasonDenied)
+	}
+	if err := pool.AddLocal(pricedTransaction(0, 100000, big.NewInt(1), key)); policyReason(err) != ReasonDenied {
+		t.Fatalf("deny-list error mismatch: have %v, want %v", err, ReasonDenied)
+	}
+	// Allow-lists admit only their senders
+	pool.policies = NewPolicies(PolicyConfig{Allow: []common.Address{other}})
+	err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(1), key))
+	if perr, ok := err.(*PolicyError); !ok || perr.Reason != ReasonNotAllowed || perr.Sender != from || perr.Policy != "allow-list" {
+		t.Fatalf("allow-list error mismatch: have %v", err)
+	}
+	// Restricted assets can only be moved by the listed senders
+	pool.policies = NewPolicies(PolicyConfig{Assets: []AssetRestriction{{Asset: assetID, Senders: []common.Address{other}}}})
+	err = pool.AddRemote(utxoTransaction(0, big.NewInt(1), key, assetID, issued, 500))
+	if perr, ok := err.(*PolicyError); !ok || perr.Reason != ReasonAssetRestricted || perr.Asset != assetID {
+		t.Fatalf("asset restriction error mismatch: have %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(1), key)); err != nil {
+		t.Fatalf("failed to add transaction moving unrestricted assets: %v", err)
+	}
+	pool.policies = nil
+	pool.AddPolicy(NewAssetPolicy([]AssetRestriction{{Asset: assetID, Senders: []common.Address{from}}}))
+	if err := pool.AddRemote(utxoTransaction(1, big.NewInt(1), key, assetID, issued, 500)); err != nil {
+		t.Fatalf("failed to add transaction of a listed sender: %v", err)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	var (
+		limit = NewRateLimit(0.5, 2)
+		now   = time.Unix(1000, 0)
+		tx    = types.NewTransaction(0, 0, new(big.Int), nil)
+		from  = common.Address{0x01}
+	)
+	limit.now = func() time.Time { return now }
+
+	// admit admits and records a transaction like the pool accepting it
+	admit := func(from common.Address, local bool) error {
+		err := limit.Admit(tx, from, local)
+		if err == nil {
+			limit.Admitted(tx, from, local)
+		}
+		return err
+	}
+
+	// Only recorded transactions take a token
+	for i := 0; i < 3; i++ {
+		if err := limit.Admit(tx, from, false); err != nil {
+			t.Fatalf("unrecorded transaction %d rejected: %v", i, err)
+		}
+	}
+	// The full bucket admits a burst, then the sender has to wait
+	for i := 0; i < 2; i++ {
+		if err := admit(from, false); err != nil {
+			t.Fatalf("burst transaction %d rejected: %v", i, err)
+		}
+	}
+	if err := admit(from, false); policyReason(err) != ReasonRateLimited {
+		t.Fatalf("rate limit error mismatch: have %v, want %v", err, ReasonRateLimited)
+	}
+	// Other senders and local transactions have buckets of their own
+	if err := admit(common.Address{0x02}, false); err != nil {
+		t.Fatalf("other sender rejected: %v", err)
+	}
+	if err := admit(from, true); err != nil {
+		t.Fatalf("local transaction rejected: %v", err)
+	}
+	// Tokens refill at the rate, never above the burst
+	now = now.Add(time.Second)
+	if err := admit(from, false); policyReason(err) != ReasonRateLimited {
+		t.Fatalf("rate limit error mismatch: have %v, want %v", err, ReasonRateLimited)
+	}
+	now = now.Add(time.Second)
+	if err := admit(from, false); err != nil {
+		t.Fatalf("refilled transaction rejected: %v", err)
+	}
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		err := admit(from, false)
+		if (i < 2) != (err == nil) {
+			t.Fatalf("transaction %d after refilling: %v", i, err)
+		}
+	}
+}
+
+func TestRateLimitAdmission(t *testing.T) {
+	pool, key, _, _ := setupUTXOTxPool(t)
+	defer pool.Stop()
+
+	pool.policies = NewPolicies(PolicyConfig{RateLimit: &RateLimitConfig{Rate: 0, Burst: 2}})
+
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(1), key)); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	// Transactions rejected by the pool after their admission keep the token
+	if err := pool.AddRemote(pricedTransaction(0, 100001, big.NewInt(1), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.AddRemote(pricedTransaction(1, 100000, big.NewInt(1), key)); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(2, 100000, big.NewInt(1), key)); policyReason(err) != ReasonRateLimited {
+		t.Fatalf("rate limit error mismatch: have %v, want %v", err, ReasonRateLimited)
+	}
+	// Transactions reinjected after a reorg were admitted before
+	pool.mu.Lock()
+	errs := pool.addTxsLocked([]*types.Transaction{pricedTransaction(2, 100000, big.NewInt(1), key)}, false, false)
+	pool.mu.Unlock()
+	if errs[0] != nil {
+		t.Fatalf("failed to reinject transaction: %v", errs[0])
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
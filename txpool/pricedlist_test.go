@@ -25,7 +25,7 @@ import (
 
 func TestTxPriceList(t *testing.T) {
 	txlk := newTxLookup()
-	txpl := newTxPricedList(txlk)
+	txpl := newTxPricedList(txlk, (*types.Transaction).GasPrice)
 	txpl.Put(types.NewTransaction(2, 0, big.NewInt(200), nil))
 	txpl.Put(types.NewTransaction(1, 0, big.NewInt(200), nil))
 	txpl.Put(types.NewTransaction(4, 0, big.NewInt(400), nil))
@@ -26,24 +26,28 @@ import (
 )
 
 // txPricedList is a price-sorted heap to allow operating on transactions pool
-// contents in a price-incrementing way.
+// contents in a price-incrementing way. Transactions are priced by the tip they
+// pay the miner, which changes with the base fee.
 type txPricedList struct {
-	all    *txLookup  // Pointer to the map of all transactions
-	items  *priceHeap // Heap of prices of all the stored transactions
-	stales int        // Number of stale price points to (re-heap trigger)
+	all    *txLookup                            // Pointer to the map of all transactions
+	items  *priceHeap                           // Heap of prices of all the stored transactions
+	stales int                                  // Number of stale price points to (re-heap trigger)
+	price  func(tx *types.Transaction) *big.Int // Gas price of a transaction in the common unit
 }
 
-// newTxPricedList creates a new price-sorted transaction heap.
-func newTxPricedList(all *txLookup) *txPricedList {
+// newTxPricedList creates a new price-sorted transaction heap, transactions are
+// ordered by the gas price returned by price.
+func newTxPricedList(all *txLookup, price func(tx *types.Transaction) *big.Int) *txPricedList {
 	return &txPricedList{
 		all:   all,
 		items: new(priceHeap),
+		price: price,
 	}
 }
 
 // Put inserts a new transaction into the heap.
 func (l *txPricedList) Put(tx *types.Transaction) {
-	heap.Push(l.items, tx)
+	heap.Push(l.items, &pricedTx{tx: tx, price: l.price(tx)})
 }
 
 // Removed notifies the prices transaction list that an old transaction dropped
@@ -55,12 +59,18 @@ func (l *txPricedList) Removed() {
 	if l.stales <= len(*l.items)/4 {
 		return
 	}
-	// Seems we've reached a critical number of stale transactions, reheap
+	// Seems we've reached a critical number of stale transactions, reheap.
+	l.Reheap()
+}
+
+// Reheap drops the stale price points and prices all the transactions again,
+// as the fee rates or the base fee may have changed.
+func (l *txPricedList) Reheap() {
 	reheap := make(priceHeap, 0, l.all.Count())
 
 	l.stales, l.items = 0, &reheap
 	l.all.Range(func(hash common.Hash, tx *types.Transaction) bool {
-		*l.items = append(*l.items, tx)
+		*l.items = append(*l.items, &pricedTx{tx: tx, price: l.price(tx)})
 		return true
 	})
 	heap.Init(l.items)
@@ -70,29 +80,29 @@ func (l *txPricedList) Removed() {
 // from the priced list and returs them for further removal from the entire pool.
 func (l *txPricedList) Cap(threshold *big.Int, local *accountSet) types.Transactions {
 	drop := make(types.Transactions, 0, 128) // Remote underpriced transactions to drop
-	save := make(types.Transactions, 0, 64)  // Local underpriced transactions to keep
+	save := make([]*pricedTx, 0, 64)         // Local underpriced transactions to keep
 
 	for len(*l.items) > 0 {
 		// Discard stale transactions if found during cleanup
-		tx := heap.Pop(l.items).(*types.Transaction)
-		if l.all.Get(tx.Hash()) == nil {
+		item := heap.Pop(l.items).(*pricedTx)
+		if l.all.Get(item.tx.Hash()) == nil {
 			l.stales--
 			continue
 		}
 		// Stop the discards if we've reached the threshold
-		if tx.GasPrice().Cmp(threshold) >= 0 {
-			save = append(save, tx)
+		if item.price.Cmp(threshold) >= 0 {
+			save = append(save, item)
 			break
 		}
 		// Non stale transaction found, discard unless local
-		if local.containsTx(tx) {
-			save = append(save, tx)
+		if local.containsTx(item.tx) {
+			save = append(save, item)
 		} else {
-			drop = append(drop, tx)
+			drop = append(drop, item.tx)
 		}
 	}
-	for _, tx := range save {
-		heap.Push(l.items, tx)
+	for _, item := range save {
+		heap.Push(l.items, item)
 	}
 	return drop
 }
@@ -106,8 +116,8 @@ func (l *txPricedList) Underpriced(tx *types.Transaction, local *accountSet) boo
 	}
 	// Discard stale price points if found at the heap start
 	for len(*l.items) > 0 {
-		head := []*types.Transaction(*l.items)[0]
-		if l.all.Get(head.Hash()) == nil {
+		head := []*pricedTx(*l.items)[0]
+		if l.all.Get(head.tx.Hash()) == nil {
 			l.stales--
 			heap.Pop(l.items)
 			continue
@@ -119,33 +129,33 @@ func (l *txPricedList) Underpriced(tx *types.Transaction, local *accountSet) boo
 		log.Error("Pricing query for empty pool") // This cannot happen, print to catch programming errors
 		return false
 	}
-	cheapest := []*types.Transaction(*l.items)[0]
-	return cheapest.GasPrice().Cmp(tx.GasPrice()) >= 0
+	cheapest := []*pricedTx(*l.items)[0]
+	return cheapest.price.Cmp(l.price(tx)) >= 0
 }
 
 // Discard finds a number of most underpriced transactions, removes them from the
 // priced list and returns them for further removal from the entire pool.
 func (l *txPricedList) Discard(count int, local *accountSet) types.Transactions {
 	drop := make(types.Transactions, 0, count) // Remote underpriced transactions to drop
-	save := make(types.Transactions, 0, 64)    // Local underpriced transactions to keep
+	save := make([]*pricedTx, 0, 64)           // Local underpriced transactions to keep
 
 	for len(*l.items) > 0 && count > 0 {
 		// Discard stale transactions if found during cleanup
-		tx := heap.Pop(l.items).(*types.Transaction)
-		if l.all.Get(tx.Hash()) == nil {
+		item := heap.Pop(l.items).(*pricedTx)
+		if l.all.Get(item.tx.Hash()) == nil {
 			l.stales--
 			continue
 		}
 		// Non stale transaction found, discard unless local
-		if local.containsTx(tx) {
-			save = append(save, tx)
+		if local.containsTx(item.tx) {
+			save = append(save, item)
 		} else {
-			drop = append(drop, tx)
+			drop = append(drop, item.tx)
 			count--
 		}
 	}
-	for _, tx := range save {
-		heap.Push(l.items, tx)
+	for _, item := range save {
+		heap.Push(l.items, item)
 	}
 	return drop
 }
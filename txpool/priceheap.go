@@ -16,29 +16,40 @@
 
 package txpool
 
-import "github.com/zipper-project/z0/types"
+import (
+	"math/big"
+
+	"github.com/zipper-project/z0/types"
+)
+
+// pricedTx is a transaction along with its gas price converted to ZIP, so
+// transactions paying fees in different assets can be compared.
+type pricedTx struct {
+	tx    *types.Transaction
+	price *big.Int
+}
 
 // priceHeap is a heap.Interface implementation over transactions for retrieving
 // price-sorted transactions to discard when the pool fills up.
-type priceHeap []*types.Transaction
+type priceHeap []*pricedTx
 
 func (h priceHeap) Len() int      { return len(h) }
 func (h priceHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
 
 func (h priceHeap) Less(i, j int) bool {
 	// Sort primarily by price, returning the cheaper one
-	switch h[i].GasPrice().Cmp(h[j].GasPrice()) {
+	switch h[i].price.Cmp(h[j].price) {
 	case -1:
 		return true
 	case 1:
 		return false
 	}
 	// If the prices match, stabilize via nonces (high nonce is worse)
-	return h[i].Nonce() > h[j].Nonce()
+	return h[i].tx.Nonce() > h[j].tx.Nonce()
 }
 
 func (h *priceHeap) Push(x interface{}) {
-	*h = append(*h, x.(*types.Transaction))
+	*h = append(*h, x.(*pricedTx))
 }
 
 func (h *priceHeap) Pop() interface{} {
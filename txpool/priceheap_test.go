@@ -34,10 +34,10 @@ func TestPriceHeap(t *testing.T) {
 		types.NewTransaction(3, 0, big.NewInt(100), nil),
 	}
 	for _, v := range txs {
-		ph.Push(v)
+		ph.Push(&pricedTx{tx: v, price: v.GasPrice()})
 	}
 	for i := 0; i < 4; i++ {
-		common.AssertEquals(t, txs[3-i], ph.Pop().(*types.Transaction))
+		common.AssertEquals(t, txs[3-i], ph.Pop().(*pricedTx).tx)
 	}
 
 	//test sort,first sort by price,if the price is equal,sort by nonce,high nonce is worse.
@@ -49,10 +49,10 @@ func TestPriceHeap(t *testing.T) {
 	}
 
 	for _, v := range txs {
-		ph.Push(v)
+		ph.Push(&pricedTx{tx: v, price: v.GasPrice()})
 	}
 	sort.Sort(ph)
 	for i := 0; i < 4; i++ {
-		common.AssertEquals(t, sortTxs[i], ph.Pop().(*types.Transaction))
+		common.AssertEquals(t, sortTxs[i], ph.Pop().(*pricedTx).tx)
 	}
 }
@@ -22,7 +22,8 @@ import (
 	"github.com/zipper-project/z0/types"
 )
 
-// senderCacher is a concurrent tranaction sender recoverer anc cacher.
+// senderCacher is a concurrent tranaction sender recoverer anc cacher. The
+// co-signers of multi-signature transactions are recovered along the sender.
 var SenderCacher = newTxSenderCacher(runtime.NumCPU())
 
 // txSenderCacherRequest is a request for recovering transaction senders with a
@@ -37,7 +38,7 @@ type txSenderCacherRequest struct {
 	inc    int
 }
 
-// txSenderCacher is a helper structure to concurrently ecrecover transaction
+// txSenderCacher is a helper structure to concurrently recover transaction
 // senders from digital signatures on background threads.
 type txSenderCacher struct {
 	threads int
@@ -62,7 +63,7 @@ func newTxSenderCacher(threads int) *txSenderCacher {
 func (cacher *txSenderCacher) cache() {
 	for task := range cacher.tasks {
 		for i := 0; i < len(task.txs); i += task.inc {
-			types.Sender(task.signer, task.txs[i])
+			types.Signers(task.signer, task.txs[i])
 		}
 	}
 }
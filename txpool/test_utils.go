@@ -58,7 +58,10 @@ func init() {
 type testBlockChain struct {
 	statedb       *state.StateDB
 	gasLimit      uint64
+	baseFee       *big.Int // Base fee of every next block, nil for none
 	chainHeadFeed *feed.Feed
+
+	blocks map[common.Hash]*types.Block // Blocks of reorg tests, others are the current block
 }
 
 func (bc *testBlockChain) CurrentBlock() *types.Block {
@@ -68,6 +71,9 @@ func (bc *testBlockChain) CurrentBlock() *types.Block {
 }
 
 func (bc *testBlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	if block, ok := bc.blocks[hash]; ok {
+		return block
+	}
 	return bc.CurrentBlock()
 }
 
@@ -75,6 +81,13 @@ func (bc *testBlockChain) StateAt(common.Hash) (*state.StateDB, error) {
 	return bc.statedb, nil
 }
 
+func (bc *testBlockChain) CalcBaseFee(parent *types.Header) *big.Int {
+	if bc.baseFee == nil {
+		return nil
+	}
+	return new(big.Int).Set(bc.baseFee)
+}
+
 func (bc *testBlockChain) SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) feed.Subscription {
 	return bc.chainHeadFeed.Subscribe(ch)
 }
@@ -90,7 +103,7 @@ func pricedTransaction(nonce uint64, gaslimit uint64, gasprice *big.Int, key *ec
 
 func setupTxPool() (*TxPool, *ecdsa.PrivateKey) {
 	statedb, _ := state.New(common.Hash{}, state.NewDatabase(zdb.NewMemDatabase()))
-	blockchain := &testBlockChain{statedb, 1000000, new(feed.Feed)}
+	blockchain := &testBlockChain{statedb: statedb, gasLimit: 1000000, chainHeadFeed: new(feed.Feed)}
 
 	key, _ := crypto.GenerateKey()
 	pool := New(testTxPoolConfig, params.DefaultChainconfig, blockchain)
@@ -139,7 +152,7 @@ func validateEvents(events chan NewTxsEvent, count int) error {
 		case ev := <-events:
 			received = append(received, ev.Txs...)
 		case <-time.After(time.Second):
-			return fmt.Errorf("event #%d not fired", received)
+			return fmt.Errorf("event #%d not fired", len(received))
 		}
 	}
 	if len(received) > count {
@@ -55,23 +55,30 @@ type blockChain interface {
 	CurrentBlock() *types.Block
 	GetBlock(hash common.Hash, number uint64) *types.Block
 	StateAt(root common.Hash) (*state.StateDB, error)
+	CalcBaseFee(parent *types.Header) *big.Int
 	SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) feed.Subscription
 }
 
 // TxPool contains all currently known transactions.
 type TxPool struct {
-	config   Config
-	gasPrice *big.Int
-	chain    blockChain
-	signer   types.Signer
-	txFeed   feed.Feed
-	scope    feed.SubscriptionScope
+	config      Config
+	chainconfig *params.ChainConfig
+	gasPrice    *big.Int
+	policies    []AdmissionPolicy // Admission policies consulted for new transactions
+	chain       blockChain
+	signer      types.Signer
+	txFeed      feed.Feed
+	scope       feed.SubscriptionScope
 
 	chainHeadCh   chan ChainHeadEvent
 	chainHeadSub  feed.Subscription
 	currentAsset  *asset.Asset
 	pendingAsset  *asset.Asset
-	currentMaxGas uint64 // Current gas limit for transaction caps
+	currentMaxGas uint64             // Current gas limit for transaction caps
+	currentFees   params.FeeSchedule // Intrinsic gas schedule of the pending block
+	pendingNumber uint64             // Number of the pending block for validity windows
+	pendingTime   uint64             // Lowest timestamp of the pending block for validity windows
+	pendingBase   *big.Int           // Base fee of the pending block, nil before the fork
 
 	locals  *accountSet // Set of local transaction to exempt from eviction rules
 	journal *txJournal  // Journal of local transaction to back up to disk
@@ -92,6 +99,7 @@ func New(config Config, chainconfig *params.ChainConfig, bc blockChain) *TxPool
 	all := newTxLookup()
 	tp := &TxPool{
 		config:      config.check(),
+		chainconfig: chainconfig,
 		chain:       bc,
 		signer:      signer,
 		locals:      newAccountSet(signer),
@@ -100,9 +108,10 @@ func New(config Config, chainconfig *params.ChainConfig, bc blockChain) *TxPool
 		queue:       make(map[common.Address]*txList),
 		beats:       make(map[common.Address]time.Time),
 		all:         all,
-		priced:      newTxPricedList(all),
 		gasPrice:    new(big.Int).SetUint64(config.PriceLimit),
+		policies:    NewPolicies(config.Policies),
 	}
+	tp.priced = newTxPricedList(all, tp.zipTip)
 	tp.reset(nil, bc.CurrentBlock().Header())
 
 	// If local transactions and journaling is enabled, load from disk
@@ -272,11 +281,21 @@ func (tp *TxPool) reset(oldHead, newHead *types.Header) {
 	tp.currentAsset = asset.NewAsset(statedb)
 	tp.pendingAsset = asset.NewAsset(statedb.Copy())
 	tp.currentMaxGas = newHead.GasLimit
+	tp.currentFees = tp.chainconfig.FeeSchedule(new(big.Int).Add(newHead.Number, common.Big1))
+	tp.pendingNumber = newHead.Number.Uint64() + 1
+	tp.pendingTime = newHead.Time.Uint64() + 1
+
+	// The tips the transactions pay change with the base fee, which follows
+	// the gas usage of every block
+	if base := tp.chain.CalcBaseFee(newHead); base != nil || tp.pendingBase != nil {
+		tp.pendingBase = base
+		tp.priced.Reheap()
+	}
 
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
 	SenderCacher.recover(tp.signer, reinject)
-	tp.addTxsLocked(reinject, false)
+	tp.addTxsLocked(reinject, false, false)
 
 	// validate the pool of pending transactions, this will remove
 	// any transactions that have been included in the block or
@@ -419,6 +438,15 @@ func (tp *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if tx.Size() > 32*1024 {
 		return ErrOversizedData
 	}
+	// Inputs and outputs have to decode into their types
+	inputs, err := tx.GetInputs()
+	if err != nil {
+		return err
+	}
+	outputs, err := tx.GetOutputs()
+	if err != nil {
+		return err
+	}
 	// Transactions can't be negative. This may never happen using RLP decoded
 	// transactions but may occur if you create a transaction using the RPC.
 	for _, assertValue := range tx.Value() {
@@ -438,40 +466,68 @@ func (tp *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if err != nil {
 		return ErrInvalidSender
 	}
-	// Drop non-local transactions under our own minimal accepted gas price
+	cosigners, err := types.CoSigners(tp.signer, tx)
+	if err != nil {
+		return ErrInvalidSender
+	}
+	// Multi-signature accounts need enough of their keys to sign
+	if err := tp.currentAsset.AuthorizeAccount(from, cosigners); err != nil {
+		return ErrMissingSignatures
+	}
+	// Fees can only be paid in ZIP or in assets with a fee rate
+	if _, err := tp.currentAsset.GetFeeRate(tx.FeeAsset()); err != nil {
+		return err
+	}
+	// The tip is part of the gas price, which caps the base fee and the tip
+	if tx.Tip().Cmp(tx.GasPrice()) > 0 {
+		return ErrTipAboveFeeCap
+	}
+	// Drop non-local transactions tipping less than our own minimal accepted
+	// gas price, this includes the ones not covering the pending base fee
 	local = local || tp.locals.contains(from) // account may be local even if the transaction arrived from the network
-	if !local && tp.gasPrice.Cmp(tx.GasPrice()) > 0 {
+	if !local && tp.gasPrice.Cmp(tp.zipTip(tx)) > 0 {
 		return ErrUnderpriced
 	}
 	// Ensure the transaction adheres to nonce ordering
 	if tp.currentAsset.GetNonce(from) > tx.Nonce() {
 		return ErrNonceTooLow
 	}
-
-	// Transactor should have enough funds to cover the costs
-	if tp.currentAsset.GetBalance(from, types.ZipAssetID).(*big.Int).Cmp(tx.Cost()) < 0 {
-		return ErrInsufficientFunds
+	// Transactions past their validity window can never be included
+	if tx.Expired(tp.pendingNumber, tp.pendingTime) {
+		return ErrTxExpired
 	}
 
-	// check other asset balance
-
-	var txAssets = make(map[common.Address]*big.Int)
+	// Frozen balances can't be moved, neither to pay the gas nor as value
+	if tp.currentAsset.IsFrozen(tx.FeeAsset(), from) {
+		return asset.ErrAssetFrozen
+	}
 	for _, assets := range tx.Value() {
-		for assetID, value := range assets {
-			if assetID == types.ZipAssetID {
-				continue
+		for assetID := range assets {
+			if tp.currentAsset.IsFrozen(assetID, from) {
+				return asset.ErrAssetFrozen
 			}
-			txAssets[assetID].Add(txAssets[assetID], value)
 		}
 	}
 
-	for assetID, value := range txAssets {
-		if tp.currentAsset.GetBalance(from, assetID).(*big.Int).Cmp(value) < 0 {
-			return fmt.Errorf("asset: %v balance is not enough", assetID)
+	// Transactor should have enough funds to cover the costs
+	if tp.currentAsset.GetBalance(from, types.ZipAssetID).(*big.Int).Cmp(tx.Cost()) < 0 {
+		return ErrInsufficientFunds
+	}
+
+	// Spent outputs must be owned by the sender and not be spent twice
+	if err := tp.validateUTXO(from, tx, inputs, outputs); err != nil {
+		return err
+	}
+	// Together with the pooled transactions of the sender, the balances have
+	// to cover the spend of every other asset
+	covered := tp.covered(from)
+	for assetID, amount := range tp.pooledSpend(from, tx) {
+		if !covered(assetID, amount) {
+			return ErrInsufficientFunds
 		}
 	}
 
-	intrGas, err := IntrinsicGas(tx.Extra(), tx.GetInputs(), tx.GetOutputs())
+	intrGas, err := IntrinsicGas(tp.currentFees, tx.Extra(), inputs, outputs)
 	if err != nil {
 		return err
 	}
@@ -481,7 +537,147 @@ func (tp *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	return nil
 }
 
-func (tp *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
+// admit consults the admission policies about a valid new transaction.
+func (tp *TxPool) admit(tx *types.Transaction, from common.Address, local bool) error {
+	for _, policy := range tp.policies {
+		if err := policy.Admit(tx, from, local); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// admitted lets the policies keeping track of the admitted transactions record
+// one the pool accepted.
+func (tp *TxPool) admitted(tx *types.Transaction, from common.Address, local bool) {
+	for _, policy := range tp.policies {
+		if recorder, ok := policy.(AdmissionRecorder); ok {
+			recorder.Admitted(tx, from, local)
+		}
+	}
+}
+
+// AddPolicy adds an admission policy the pool consults for new transactions,
+// after the policies of the config.
+func (tp *TxPool) AddPolicy(policy AdmissionPolicy) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	tp.policies = append(tp.policies, policy)
+}
+
+// zipTip returns the tip per gas the transaction pays the miner of the pending
+// block converted to ZIP, the common unit transactions paying fees in
+// different assets are priced in. It is negative for transactions not covering
+// the base fee, the ones whose fee asset lost its rate are priced at zero.
+func (tp *TxPool) zipTip(tx *types.Transaction) *big.Int {
+	rate, err := tp.currentAsset.GetFeeRate(tx.FeeAsset())
+	if err != nil {
+		return new(big.Int)
+	}
+	var base *big.Int
+	if tp.pendingBase != nil {
+		base = rate.FromZip(tp.pendingBase)
+	}
+	return rate.ToZip(tx.EffectiveTip(base))
+}
+
+// pooledSpend returns the cumulative non-ZIP spend of the pooled transactions
+// of the sender with the transaction added, replacing the one with its nonce.
+func (tp *TxPool) pooledSpend(from common.Address, tx *types.Transaction) map[common.Address]*big.Int {
+	spent := make(map[common.Address]*big.Int)
+	for _, list := range []*txList{tp.pending[from], tp.queue[from]} {
+		if list == nil {
+			continue
+		}
+		for _, pooled := range list.Flatten() {
+			if pooled.Nonce() != tx.Nonce() {
+				addSpend(spent, pooled)
+			}
+		}
+	}
+	addSpend(spent, tx)
+	return spent
+}
+
+// covered returns whether the balance of the account covers an amount of an
+// asset, assets that don't exist cover nothing.
+func (tp *TxPool) covered(addr common.Address) func(common.Address, *big.Int) bool {
+	return func(assetID common.Address, amount *big.Int) bool {
+		enough, err := tp.currentAsset.EnoughBalance(addr, assetID, amount)
+		return err == nil && enough
+	}
+}
+
+// validateUTXO checks the utxo inputs and outputs of a transaction against the
+// current state and the pooled transactions. An outpoint may only be spent by
+// one pooled transaction, apart from a replacement with the same nonce.
+func (tp *TxPool) validateUTXO(from common.Address, tx *types.Transaction, inputs, outputs []interface{}) error {
+	utxoIn := make(map[common.Address]*big.Int)
+	utxoOut := make(map[common.Address]*big.Int)
+	spent := make(map[types.OutPoint]bool)
+	for _, v := range inputs {
+		input, ok := v.(types.UTXOInput)
+		if !ok {
+			continue
+		}
+		if input.AssertID == nil {
+			return asset.ErrUTXONotFound
+		}
+		op := input.OutPoint()
+		if spent[op] {
+			return ErrDoubleSpend
+		}
+		spent[op] = true
+
+		utxo, err := tp.currentAsset.GetUTXO(*input.AssertID, op)
+		if err != nil {
+			return err
+		}
+		if utxo.Owner != from {
+			return asset.ErrUTXOOwner
+		}
+		if hash, ok := tp.all.Spender(op); ok && hash != tx.Hash() {
+			other := tp.all.Get(hash)
+			if other == nil || other.Nonce() != tx.Nonce() {
+				return ErrDoubleSpend
+			}
+		}
+		if utxoIn[*input.AssertID] == nil {
+			utxoIn[*input.AssertID] = new(big.Int)
+		}
+		utxoIn[*input.AssertID].Add(utxoIn[*input.AssertID], utxo.Value)
+	}
+	for _, v := range outputs {
+		output, ok := v.(types.UTXOOutput)
+		if !ok {
+			continue
+		}
+		if output.AssertID == nil || output.Address == nil || output.Value == nil || output.Value.Sign() <= 0 {
+			return asset.ErrUTXOValue
+		}
+		if utxoOut[*output.AssertID] == nil {
+			utxoOut[*output.AssertID] = new(big.Int)
+		}
+		utxoOut[*output.AssertID].Add(utxoOut[*output.AssertID], output.Value)
+	}
+	for assetID, in := range utxoIn {
+		if out := utxoOut[assetID]; out == nil || out.Cmp(in) != 0 {
+			return ErrUTXOUnbalanced
+		}
+	}
+	for assetID := range utxoOut {
+		if utxoIn[assetID] == nil {
+			return ErrUTXOUnbalanced
+		}
+	}
+	return nil
+}
+
+// add validates a transaction and inserts it into the non-executable queue for
+// later pending promotion and execution. New transactions are subject to the
+// admission policies, the ones reinjected after a reorg were admitted before.
+func (tp *TxPool) add(tx *types.Transaction, local, admit bool) (bool, error) {
 	// If the transaction is already known, discard it
 	hash := tx.Hash()
 	if tp.all.Get(hash) != nil {
@@ -493,6 +689,16 @@ func (tp *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		log.Trace("Discarding invalid transaction", "hash", hash, "err", err)
 		return false, err
 	}
+	// New transactions have to pass the admission policies, which exempt the
+	// local senders like validateTx
+	from, _ := types.Sender(tp.signer, tx) // already validated
+	localSender := local || tp.locals.contains(from)
+	if admit {
+		if err := tp.admit(tx, from, localSender); err != nil {
+			log.Trace("Discarding rejected transaction", "hash", hash, "err", err)
+			return false, err
+		}
+	}
 	// If the transaction pool is full, discard underpriced transactions
 	if uint64(tp.all.Count()) >= tp.config.GlobalSlots+tp.config.GlobalQueue {
 		// If the new transaction is underpriced, don't accept it
@@ -508,7 +714,6 @@ func (tp *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		}
 	}
 	// If the transaction is replacing an already pending one, do directly
-	from, _ := types.Sender(tp.signer, tx) // already validated
 	if list := tp.pending[from]; list != nil && list.Overlaps(tx) {
 		// Nonce already pending, check if required price bump is met
 		inserted, old := list.Add(tx, tp.config.PriceBump)
@@ -523,6 +728,9 @@ func (tp *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		tp.all.Add(tx)
 		tp.priced.Put(tx)
 		tp.journalTx(from, tx)
+		if admit {
+			tp.admitted(tx, from, localSender)
+		}
 
 		log.Trace("Pooled new executable transaction", "hash", hash, "from", from)
 
@@ -541,6 +749,9 @@ func (tp *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		tp.locals.add(from)
 	}
 	tp.journalTx(from, tx)
+	if admit {
+		tp.admitted(tx, from, localSender)
+	}
 
 	log.Trace("Pooled new future transaction", "hash", hash, "from", from)
 	return replace, nil
@@ -654,7 +865,7 @@ func (tp *TxPool) addTx(tx *types.Transaction, local bool) error {
 	defer tp.mu.Unlock()
 
 	// Try to inject the transaction and update any state
-	replace, err := tp.add(tx, local)
+	replace, err := tp.add(tx, local, true)
 	if err != nil {
 		return err
 	}
@@ -671,19 +882,20 @@ func (tp *TxPool) addTxs(txs []*types.Transaction, local bool) []error {
 	tp.mu.Lock()
 	defer tp.mu.Unlock()
 
-	return tp.addTxsLocked(txs, local)
+	return tp.addTxsLocked(txs, local, true)
 }
 
 // addTxsLocked attempts to queue a batch of transactions if they are valid,
-// whilst assuming the transaction pool lock is already held.
-func (tp *TxPool) addTxsLocked(txs []*types.Transaction, local bool) []error {
+// whilst assuming the transaction pool lock is already held. Admit tells
+// whether the admission policies apply, see add.
+func (tp *TxPool) addTxsLocked(txs []*types.Transaction, local, admit bool) []error {
 	// Add the batch of transaction, tracking the accepted ones
 	dirty := make(map[common.Address]struct{})
 	errs := make([]error, len(txs))
 
 	for i, tx := range txs {
 		var replace bool
-		if replace, errs[i] = tp.add(tx, local); errs[i] == nil && !replace {
+		if replace, errs[i] = tp.add(tx, local, admit); errs[i] == nil && !replace {
 			from, _ := types.Sender(tp.signer, tx) // already validated
 			dirty[from] = struct{}{}
 		}
@@ -796,7 +1008,6 @@ func (tp *TxPool) promoteExecutables(accounts []common.Address) {
 			tp.priced.Removed()
 		}
 		// Drop all transactions that are too costly (low balance or out of gas)
-		// todo utxo
 		drops, _ := list.Filter(tp.currentAsset.GetBalance(addr, types.ZipAssetID).(*big.Int), tp.currentMaxGas)
 		for _, tx := range drops {
 			hash := tx.Hash()
@@ -804,6 +1015,34 @@ func (tp *TxPool) promoteExecutables(accounts []common.Address) {
 			tp.all.Remove(hash)
 			tp.priced.Removed()
 		}
+		// Drop all transactions overdrawing other assets after the pending ones
+		var pendingSpend map[common.Address]*big.Int
+		if pending := tp.pending[addr]; pending != nil {
+			pendingSpend = pending.Spent()
+		}
+		overdrawn, _ := list.FilterSpend(tp.covered(addr), pendingSpend)
+		for _, tx := range overdrawn {
+			hash := tx.Hash()
+			log.Trace("Removed overdrawing queued transaction", "hash", hash)
+			tp.all.Remove(hash)
+			tp.priced.Removed()
+		}
+		// Drop all transactions spending outputs that are gone
+		spents, _ := list.FilterSpent(tp.unspent(addr))
+		for _, tx := range spents {
+			hash := tx.Hash()
+			log.Trace("Removed double spending queued transaction", "hash", hash)
+			tp.all.Remove(hash)
+			tp.priced.Removed()
+		}
+		// Drop all transactions past their validity window
+		expired, _ := list.FilterExpired(tp.pendingNumber, tp.pendingTime)
+		for _, tx := range expired {
+			hash := tx.Hash()
+			log.Trace("Removed expired queued transaction", "hash", hash)
+			tp.all.Remove(hash)
+			tp.priced.Removed()
+		}
 		// Gather all executable transactions and promote them
 		for _, tx := range list.Ready(tp.pendingAsset.GetNonce(addr)) {
 			hash := tx.Hash()
@@ -939,6 +1178,15 @@ func (tp *TxPool) promoteExecutables(accounts []common.Address) {
 	}
 }
 
+// unspent returns a callback reporting whether an output is still unspent
+// and owned by addr in the current state.
+func (tp *TxPool) unspent(addr common.Address) func(common.Address, types.OutPoint) bool {
+	return func(assetID common.Address, op types.OutPoint) bool {
+		utxo, err := tp.currentAsset.GetUTXO(assetID, op)
+		return err == nil && utxo.Owner == addr
+	}
+}
+
 // demoteUnexecutables removes invalid and processed transactions from the pools
 // executable/pending queue and any subsequent transactions that become unexecutable
 // are moved back into the future queue.
@@ -962,6 +1210,34 @@ func (tp *TxPool) demoteUnexecutables() {
 			tp.all.Remove(hash)
 			tp.priced.Removed()
 		}
+		// Drop all transactions overdrawing other assets together
+		overdrawn, overdrawnInvalids := list.FilterSpend(tp.covered(addr), nil)
+		for _, tx := range overdrawn {
+			hash := tx.Hash()
+			log.Trace("Removed overdrawing pending transaction", "hash", hash)
+			tp.all.Remove(hash)
+			tp.priced.Removed()
+		}
+		invalids = append(invalids, overdrawnInvalids...)
+		// Drop all transactions spending outputs that are gone, e.g. spent by a
+		// conflicting transaction included into the chain
+		spents, spentInvalids := list.FilterSpent(tp.unspent(addr))
+		for _, tx := range spents {
+			hash := tx.Hash()
+			log.Trace("Removed double spending pending transaction", "hash", hash)
+			tp.all.Remove(hash)
+			tp.priced.Removed()
+		}
+		invalids = append(invalids, spentInvalids...)
+		// Drop all transactions past their validity window
+		expired, expiredInvalids := list.FilterExpired(tp.pendingNumber, tp.pendingTime)
+		for _, tx := range expired {
+			hash := tx.Hash()
+			log.Trace("Removed expired pending transaction", "hash", hash)
+			tp.all.Remove(hash)
+			tp.priced.Removed()
+		}
+		invalids = append(invalids, expiredInvalids...)
 		for _, tx := range invalids {
 			hash := tx.Hash()
 			log.Trace("Demoting pending transaction", "hash", hash)
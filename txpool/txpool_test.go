@@ -15,3 +15,432 @@
 // along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
 
 package txpool
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core/asset"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/params"
+	"github.com/zipper-project/z0/state"
+	"github.com/zipper-project/z0/types"
+)
+
+// setupUTXOTxPool funds the key with ZIP and issues an utxo model asset to it,
+// returning the asset and the outpoint of the issued output.
+func setupUTXOTxPool(t *testing.T) (*TxPool, *ecdsa.PrivateKey, common.Address, types.OutPoint) {
+	pool, key := setupTxPool()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+
+	statedb := pool.chain.(*testBlockChain).statedb
+	if err := asset.InitZip(statedb, new(big.Int).SetUint64(amount), 8, types.ZipAccount); err != nil {
+		t.Fatal(err)
+	}
+	a := asset.NewAsset(statedb)
+	a.CreateAccount(from)
+	a.SubBalance(types.ZipAccount, types.ZipAssetID, big.NewInt(1000000))
+	a.AddBalance(from, types.ZipAssetID, big.NewInt(1000000))
+
+	desc, _ := json.Marshal(&asset.AccountAssetInfo{Name: "utxo", Symbol: "UTXO", Total: big.NewInt(500), Owner: from})
+	assetID, err := a.RegisterAsset(asset.UtxoModel, from, string(desc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	utxos, err := a.GetUTXOs(from, assetID)
+	if err != nil || len(utxos) != 1 {
+		t.Fatalf("issued outputs mismatch: %v, %v", utxos, err)
+	}
+	pool.lockedReset(nil, nil)
+	return pool, key, assetID, utxos[0].OutPoint
+}
+
+func utxoTransaction(nonce uint64, gasprice *big.Int, key *ecdsa.PrivateKey, assetID common.Address, op types.OutPoint, values ...int64) *types.Transaction {
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	tx := types.NewTransaction(nonce, 100000, gasprice, nil)
+	tx.WithInput(types.UTXOInput{AssertID: &assetID, TxHash: op.TxHash, Index: op.Index})
+	outputs := make([]interface{}, len(values))
+	for i, value := range values {
+		outputs[i] = types.UTXOOutput{AssertID: &assetID, Address: &from, Value: big.NewInt(value)}
+	}
+	tx.WithOutput(outputs...)
+	signed, _ := types.SignTx(tx, types.NewSigner(params.DefaultChainconfig.ChainID), key)
+	return signed
+}
+
+func TestUTXODoubleSpend(t *testing.T) {
+	pool, key, assetID, issued := setupUTXOTxPool(t)
+	defer pool.Stop()
+
+	if err := pool.AddRemote(utxoTransaction(0, big.NewInt(1), key, assetID, issued, 200, 300)); err != nil {
+		t.Fatalf("failed to add spending transaction: %v", err)
+	}
+	// A second transaction spending the same output is rejected
+	if err := pool.AddRemote(utxoTransaction(1, big.NewInt(1), key, assetID, issued, 500)); err != ErrDoubleSpend {
+		t.Fatalf("double spend error mismatch: have %v, want %v", err, ErrDoubleSpend)
+	}
+	// Unless it replaces the spending transaction
+	replacement := utxoTransaction(0, big.NewInt(2), key, assetID, issued, 500)
+	if err := pool.AddRemote(replacement); err != nil {
+		t.Fatalf("failed to replace spending transaction: %v", err)
+	}
+	if hash, ok := pool.all.Spender(issued); !ok || hash != replacement.Hash() {
+		t.Fatalf("spender mismatch: have %x, want %x", hash, replacement.Hash())
+	}
+	// Unknown outputs and unbalanced outputs are rejected
+	if err := pool.AddRemote(utxoTransaction(1, big.NewInt(1), key, assetID, types.OutPoint{}, 500)); err != asset.ErrUTXONotFound {
+		t.Fatalf("unknown output error mismatch: have %v, want %v", err, asset.ErrUTXONotFound)
+	}
+	if err := pool.AddRemote(utxoTransaction(1, big.NewInt(1), key, assetID, issued, 499)); err != ErrDoubleSpend {
+		t.Fatalf("double spend error mismatch: have %v, want %v", err, ErrDoubleSpend)
+	}
+	if err := pool.AddRemote(utxoTransaction(0, big.NewInt(3), key, assetID, issued, 499)); err != ErrUTXOUnbalanced {
+		t.Fatalf("unbalanced error mismatch: have %v, want %v", err, ErrUTXOUnbalanced)
+	}
+	if pending, _ := pool.Stats(); pending != 1 {
+		t.Fatalf("pending transactions mismatch: have %d, want 1", pending)
+	}
+
+	// Spend the output on chain, the pooled spender must be dropped
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	if err := asset.NewAsset(pool.chain.(*testBlockChain).statedb).SubBalance(from, assetID, issued); err != nil {
+		t.Fatal(err)
+	}
+	pool.lockedReset(nil, nil)
+
+	if pending, queued := pool.Stats(); pending != 0 || queued != 0 {
+		t.Fatalf("pool not emptied: pending %d, queued %d", pending, queued)
+	}
+	if _, ok := pool.all.Spender(issued); ok {
+		t.Fatalf("spent output still tracked")
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+func TestFrozenTransaction(t *testing.T) {
+	pool, key, assetID, issued := setupUTXOTxPool(t)
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	a := asset.NewAsset(pool.chain.(*testBlockChain).statedb)
+	if err := a.FreezeAccount(from, assetID, from, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.AddRemote(utxoTransaction(0, big.NewInt(1), key, assetID, issued, 500)); err != asset.ErrAssetFrozen {
+		t.Fatalf("frozen asset error mismatch: have %v, want %v", err, asset.ErrAssetFrozen)
+	}
+	if err := a.FreezeAccount(from, assetID, from, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.AddRemote(utxoTransaction(0, big.NewInt(1), key, assetID, issued, 500)); err != nil {
+		t.Fatalf("failed to add unfrozen transaction: %v", err)
+	}
+
+	// A frozen ZIP balance can't pay for the gas
+	if err := a.FreezeAccount(types.ZipAccount, types.ZipAssetID, from, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.AddRemote(pricedTransaction(1, 100000, big.NewInt(1), key)); err != asset.ErrAssetFrozen {
+		t.Fatalf("frozen gas error mismatch: have %v, want %v", err, asset.ErrAssetFrozen)
+	}
+}
+
+func TestInvalidCoSignature(t *testing.T) {
+	pool, key, assetID, issued := setupUTXOTxPool(t)
+	defer pool.Stop()
+
+	tx := utxoTransaction(0, big.NewInt(1), key, assetID, issued, 500)
+	tx.Data.Signatures = append(tx.Data.Signatures, make([]byte, 65))
+	if err := pool.AddRemote(tx); err != ErrInvalidSender {
+		t.Fatalf("invalid co-signature error mismatch: have %v, want %v", err, ErrInvalidSender)
+	}
+	cosigner, _ := crypto.GenerateKey()
+	tx, _ = types.CoSignTx(utxoTransaction(0, big.NewInt(1), key, assetID, issued, 500), types.NewSigner(params.DefaultChainconfig.ChainID), cosigner)
+	if err := pool.AddRemote(tx); err != nil {
+		t.Fatalf("failed to add co-signed transaction: %v", err)
+	}
+}
+
+func TestMultiSigTransaction(t *testing.T) {
+	pool, key, assetID, issued := setupUTXOTxPool(t)
+	defer pool.Stop()
+
+	var (
+		signer    = types.NewSigner(params.DefaultChainconfig.ChainID)
+		from      = crypto.PubkeyToAddress(key.PublicKey)
+		cokey1, _ = crypto.GenerateKey()
+		cokey2, _ = crypto.GenerateKey()
+		policy    = &asset.OwnerPolicy{Threshold: 2, Keys: []asset.OwnerKey{
+			{Address: from},
+			{Address: crypto.PubkeyToAddress(cokey1.PublicKey)},
+			{Address: crypto.PubkeyToAddress(cokey2.PublicKey)},
+		}}
+	)
+	if err := asset.NewAsset(pool.chain.(*testBlockChain).statedb).SetAccountPolicy(from, policy); err != nil {
+		t.Fatal(err)
+	}
+	pool.lockedReset(nil, nil)
+
+	// The account key alone is no longer enough
+	tx := utxoTransaction(0, big.NewInt(1), key, assetID, issued, 500)
+	if err := pool.AddRemote(tx); err != ErrMissingSignatures {
+		t.Fatalf("single signature error mismatch: have %v, want %v", err, ErrMissingSignatures)
+	}
+	// A key outside the set doesn't count either
+	outsider, _ := crypto.GenerateKey()
+	cosigned, _ := types.CoSignTx(tx, signer, outsider)
+	if err := pool.AddRemote(cosigned); err != ErrMissingSignatures {
+		t.Fatalf("outsider signature error mismatch: have %v, want %v", err, ErrMissingSignatures)
+	}
+	cosigned, _ = types.CoSignTx(tx, signer, cokey2)
+	if err := pool.AddRemote(cosigned); err != nil {
+		t.Fatalf("failed to add co-signed transaction: %v", err)
+	}
+}
+
+func TestFeeAssetPricing(t *testing.T) {
+	pool, key, utxoID, _ := setupUTXOTxPool(t)
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	a := asset.NewAsset(pool.chain.(*testBlockChain).statedb)
+	desc, _ := json.Marshal(&asset.AccountAssetInfo{Name: "fee", Symbol: "FEE", Total: big.NewInt(10000000), Owner: from})
+	feeID, err := a.RegisterAsset(asset.AccountModel, from, string(desc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ten units of the asset are worth one ZIP
+	if err := a.SetFeeRate(from, feeID, &asset.FeeRate{Num: big.NewInt(1), Denom: big.NewInt(10)}); err != nil {
+		t.Fatal(err)
+	}
+	pool.lockedReset(nil, nil)
+	pool.SetGasPrice(big.NewInt(2))
+
+	feeTransaction := func(nonce uint64, gasprice int64, feeAsset common.Address) *types.Transaction {
+		tx := newTx(nonce, big.NewInt(100), 100000, big.NewInt(gasprice), nil)
+		tx.WithFeeAsset(feeAsset)
+		signed, _ := types.SignTx(tx, types.NewSigner(params.DefaultChainconfig.ChainID), key)
+		return signed
+	}
+
+	// Assets without a fee rate can't pay fees
+	if err := pool.AddRemote(feeTransaction(0, 20, utxoID)); err != asset.ErrNoFeeRate {
+		t.Fatalf("fee asset error mismatch: have %v, want %v", err, asset.ErrNoFeeRate)
+	}
+	// The minimum gas price applies to the price converted to ZIP
+	if err := pool.AddRemote(feeTransaction(0, 19, feeID)); err != ErrUnderpriced {
+		t.Fatalf("underpriced error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	if err := pool.AddRemote(feeTransaction(0, 200, feeID)); err != ErrInsufficientFunds {
+		t.Fatalf("insufficient funds error mismatch: have %v, want %v", err, ErrInsufficientFunds)
+	}
+	cheap := feeTransaction(0, 20, feeID)
+	if err := pool.AddRemote(cheap); err != nil {
+		t.Fatalf("failed to add transaction paying in asset: %v", err)
+	}
+	// A replacement has to pay in the same asset
+	if err := pool.AddRemote(feeTransaction(0, 5, types.ZipAssetID)); err != ErrReplaceUnderpriced {
+		t.Fatalf("replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.AddRemote(pricedTransaction(1, 100000, big.NewInt(3), key)); err != nil {
+		t.Fatalf("failed to add transaction paying in ZIP: %v", err)
+	}
+
+	// Despite its higher gas price the asset paying transaction is the cheapest
+	if head := []*pricedTx(*pool.priced.items)[0]; head.tx.Hash() != cheap.Hash() || head.price.Cmp(big.NewInt(2)) != 0 {
+		t.Fatalf("cheapest transaction mismatch: have %x at %v, want %x at 2", head.tx.Hash(), head.price, cheap.Hash())
+	}
+	other, _ := crypto.GenerateKey()
+	if !pool.priced.Underpriced(pricedTransaction(0, 100000, big.NewInt(2), other), pool.locals) {
+		t.Fatalf("transaction at the cheapest price not underpriced")
+	}
+	if pool.priced.Underpriced(pricedTransaction(0, 100000, big.NewInt(3), other), pool.locals) {
+		t.Fatalf("transaction above the cheapest price underpriced")
+	}
+	if drop := pool.priced.Discard(1, pool.locals); len(drop) != 1 || drop[0].Hash() != cheap.Hash() {
+		t.Fatalf("discarded transaction mismatch: %v", drop)
+	}
+}
+
+func TestTransactionExpiry(t *testing.T) {
+	pool, key, _, _ := setupUTXOTxPool(t)
+	defer pool.Stop()
+
+	windowed := func(nonce, until uint64) *types.Transaction {
+		tx := newTx(nonce, big.NewInt(100), 100000, big.NewInt(1), nil)
+		tx.WithValidity(0, until)
+		signed, _ := types.SignTx(tx, types.NewSigner(params.DefaultChainconfig.ChainID), key)
+		return signed
+	}
+	// One executable and one gapped transaction, both expiring after block 3
+	if err := pool.AddRemote(windowed(0, 3)); err != nil {
+		t.Fatalf("failed to add windowed transaction: %v", err)
+	}
+	if err := pool.AddRemote(windowed(2, 3)); err != nil {
+		t.Fatalf("failed to add windowed transaction: %v", err)
+	}
+	if pending, queued := pool.Stats(); pending != 1 || queued != 1 {
+		t.Fatalf("pool stats mismatch: pending %d queued %d", pending, queued)
+	}
+	// Once block 3 is the head, they can never be included
+	pool.lockedReset(nil, &types.Header{Number: big.NewInt(3), Time: big.NewInt(0), GasLimit: 1000000})
+	if pending, queued := pool.Stats(); pending != 0 || queued != 0 {
+		t.Fatalf("expired transactions kept: pending %d queued %d", pending, queued)
+	}
+	if err := pool.AddRemote(windowed(0, 3)); err != ErrTxExpired {
+		t.Fatalf("expired transaction error mismatch: have %v, want %v", err, ErrTxExpired)
+	}
+	if err := pool.AddRemote(windowed(0, 4)); err != nil {
+		t.Fatalf("failed to add transaction valid in the pending block: %v", err)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+func TestAssetSpendAccounting(t *testing.T) {
+	pool, key, _, _ := setupUTXOTxPool(t)
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	chain := pool.chain.(*testBlockChain)
+	desc, _ := json.Marshal(&asset.AccountAssetInfo{Name: "gold", Symbol: "GLD", Total: big.NewInt(100), Owner: from})
+	gld, err := asset.NewAsset(chain.statedb).RegisterAsset(asset.AccountModel, from, string(desc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spend := func(nonce uint64, gasprice, value int64) *types.Transaction {
+		tx := types.NewTransaction(nonce, 100000, big.NewInt(gasprice), nil)
+		tx.WithInput(types.AMInput{AssertID: &gld})
+		tx.WithOutput(types.AMOutput{AssertID: &gld, Address: &common.Address{0x01}, Value: big.NewInt(value)})
+		signed, _ := types.SignTx(tx, types.NewSigner(params.DefaultChainconfig.ChainID), key)
+		return signed
+	}
+	// Blocks of two branches forking off the genesis, mined elsewhere
+	chain.blocks = make(map[common.Hash]*types.Block)
+	block := func(parent common.Hash, number, time int64, txs ...*types.Transaction) *types.Header {
+		header := &types.Header{ParentHash: parent, Number: big.NewInt(number), Time: big.NewInt(time), GasLimit: 1000000}
+		block := types.NewBlock(header, txs, nil, nil)
+		chain.blocks[block.Hash()] = block
+		return block.Header()
+	}
+	genesis := block(common.Hash{}, 0, 0)
+	branch := func(time int64, txs ...*types.Transaction) *types.Header {
+		return block(genesis.Hash(), 1, time, txs...)
+	}
+	base := chain.statedb.Copy()
+	included := func(nonce uint64, spent int64) *state.StateDB {
+		statedb := base.Copy()
+		a := asset.NewAsset(statedb)
+		a.SetNonce(from, nonce)
+		a.SubBalance(from, gld, big.NewInt(spent))
+		return statedb
+	}
+	pool.lockedReset(nil, genesis)
+
+	// Pending and queued transactions can't overdraw the asset together
+	tx0, tx1, tx3 := spend(0, 1, 60), spend(1, 1, 30), spend(3, 1, 10)
+	for _, tx := range []*types.Transaction{tx0, tx1, tx3} {
+		if err := pool.AddRemote(tx); err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+	}
+	for i, tx := range []*types.Transaction{spend(2, 1, 20), spend(4, 1, 1), spend(1, 2, 40)} {
+		if err := pool.AddRemote(tx); err != ErrInsufficientFunds {
+			t.Fatalf("overdraw %d: error mismatch: have %v, want %v", i, err, ErrInsufficientFunds)
+		}
+	}
+	if pending, queued := pool.Stats(); pending != 2 || queued != 1 {
+		t.Fatalf("pool stats mismatch: pending %d queued %d", pending, queued)
+	}
+
+	// The first transaction is included, the others are still covered
+	headA := branch(1, tx0)
+	chain.statedb = included(1, 60)
+	pool.lockedReset(genesis, headA)
+	if pending, queued := pool.Stats(); pending != 1 || queued != 1 {
+		t.Fatalf("pool stats mismatch: pending %d queued %d", pending, queued)
+	}
+	// A reorg onto a branch spending 65 leaves the queued transaction uncovered
+	headB := branch(2, spend(0, 1, 65))
+	chain.statedb = included(1, 65)
+	pool.lockedReset(headA, headB)
+	if pending, queued := pool.Stats(); pending != 1 || queued != 0 {
+		t.Fatalf("pool stats mismatch: pending %d queued %d", pending, queued)
+	}
+	if pool.Get(tx3.Hash()) != nil {
+		t.Fatalf("overdrawing queued transaction kept")
+	}
+	// And one spending 80 the pending one
+	headC := branch(3, spend(0, 1, 80))
+	chain.statedb = included(1, 80)
+	pool.lockedReset(headB, headC)
+	if pending, queued := pool.Stats(); pending != 0 || queued != 0 {
+		t.Fatalf("pool stats mismatch: pending %d queued %d", pending, queued)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+func TestBaseFeePricing(t *testing.T) {
+	pool, key, _, _ := setupUTXOTxPool(t)
+	defer pool.Stop()
+
+	other, _ := crypto.GenerateKey()
+	a := asset.NewAsset(pool.chain.(*testBlockChain).statedb)
+	a.AddBalance(crypto.PubkeyToAddress(key.PublicKey), types.ZipAssetID, big.NewInt(100000000))
+	a.AddBalance(crypto.PubkeyToAddress(other.PublicKey), types.ZipAssetID, big.NewInt(100000000))
+
+	chain := pool.chain.(*testBlockChain)
+	chain.baseFee = big.NewInt(80)
+	pool.lockedReset(nil, &types.Header{Number: big.NewInt(0), Time: big.NewInt(0), GasLimit: 1000000})
+
+	tipped := func(nonce uint64, gasprice, tip int64, key *ecdsa.PrivateKey) *types.Transaction {
+		tx := newTx(nonce, big.NewInt(100), 100000, big.NewInt(gasprice), nil)
+		tx.WithTip(big.NewInt(tip))
+		signed, _ := types.SignTx(tx, types.NewSigner(params.DefaultChainconfig.ChainID), key)
+		return signed
+	}
+	// The tip is part of the gas price, which has to leave a tip above the base fee
+	if err := pool.AddRemote(tipped(0, 100, 101, key)); err != ErrTipAboveFeeCap {
+		t.Fatalf("tip error mismatch: have %v, want %v", err, ErrTipAboveFeeCap)
+	}
+	if err := pool.AddRemote(tipped(0, 80, 80, key)); err != ErrUnderpriced {
+		t.Fatalf("underpriced error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	capped := tipped(0, 200, 2, key)
+	if err := pool.AddRemote(capped); err != nil {
+		t.Fatalf("failed to add tipping transaction: %v", err)
+	}
+	// A replacement has to bump the tip as well
+	if err := pool.AddRemote(tipped(0, 300, 2, key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	legacy := pricedTransaction(0, 100000, big.NewInt(84), other)
+	if err := pool.AddRemote(legacy); err != nil {
+		t.Fatalf("failed to add legacy transaction: %v", err)
+	}
+	// Despite its higher gas price the capped tip is the cheapest
+	if head := []*pricedTx(*pool.priced.items)[0]; head.tx.Hash() != capped.Hash() || head.price.Cmp(big.NewInt(2)) != 0 {
+		t.Fatalf("cheapest transaction mismatch: have %x at %v, want %x at 2", head.tx.Hash(), head.price, capped.Hash())
+	}
+	// A full block raises the base fee to 90, the legacy transaction doesn't
+	// cover it any more
+	chain.baseFee = big.NewInt(90)
+	pool.lockedReset(nil, &types.Header{Number: big.NewInt(1), Time: big.NewInt(1), GasLimit: 1000000, GasUsed: 1000000, BaseFee: big.NewInt(80)})
+	if pool.pendingBase.Cmp(big.NewInt(90)) != 0 {
+		t.Fatalf("pending base fee mismatch: have %v, want 90", pool.pendingBase)
+	}
+	if head := []*pricedTx(*pool.priced.items)[0]; head.tx.Hash() != legacy.Hash() || head.price.Cmp(big.NewInt(-6)) != 0 {
+		t.Fatalf("cheapest transaction mismatch: have %x at %v, want %x at -6", head.tx.Hash(), head.price, legacy.Hash())
+	}
+	if drop := pool.priced.Cap(big.NewInt(1), pool.locals); len(drop) != 1 || drop[0].Hash() != legacy.Hash() {
+		t.Fatalf("capped transactions mismatch: %v", drop)
+	}
+}
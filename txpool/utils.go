@@ -43,17 +43,20 @@ func TxDifference(a, b types.Transactions) types.Transactions {
 	return keep
 }
 
-// IntrinsicGas computes the 'intrinsic gas' for a message with the given data.
-func IntrinsicGas(Extra []byte, inputs, outputs []interface{}) (uint64, error) {
+// IntrinsicGas computes the 'intrinsic gas' for a message with the given data,
+// priced by the fee schedule of the block it goes into.
+func IntrinsicGas(fees params.FeeSchedule, Extra []byte, inputs, outputs []interface{}) (uint64, error) {
 	var gas uint64
 	for _, v := range outputs {
 		if reflect.TypeOf(v) == types.AMOutputType {
 			output := v.(types.AMOutput)
 			if output.Address == nil {
-				gas += params.TxGasContractCreation
+				gas += fees.TxGasContractCreation
 			} else {
-				gas += params.TxGas
+				gas += fees.TxGas
 			}
+		} else if reflect.TypeOf(v) == types.UTXOOutputType {
+			gas += fees.TxGas
 		}
 	}
 
@@ -69,16 +72,16 @@ func IntrinsicGas(Extra []byte, inputs, outputs []interface{}) (uint64, error) {
 				}
 			}
 			// Make sure we don't exceed uint64 for all data combinations
-			if (math.MaxUint64-gas)/params.TxDataNonZeroGas < nz {
+			if (math.MaxUint64-gas)/fees.TxDataNonZeroGas < nz {
 				return 0, ErrOutOfGas
 			}
-			gas += nz * params.TxDataNonZeroGas
+			gas += nz * fees.TxDataNonZeroGas
 
 			z := uint64(len(data)) - nz
-			if (math.MaxUint64-gas)/params.TxDataZeroGas < z {
+			if (math.MaxUint64-gas)/fees.TxDataZeroGas < z {
 				return 0, ErrOutOfGas
 			}
-			gas += z * params.TxDataZeroGas
+			gas += z * fees.TxDataZeroGas
 		}
 		return gas, nil
 	}
@@ -93,6 +96,12 @@ func IntrinsicGas(Extra []byte, inputs, outputs []interface{}) (uint64, error) {
 			gas += dataGas
 		}
 	}
+	// The extra data is the contract code or call input
+	dataGas, err := dataGasFunc(Extra)
+	if err != nil {
+		return 0, err
+	}
+	gas += dataGas
 
 	return gas, nil
 }
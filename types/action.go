@@ -0,0 +1,397 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/utils/rlp"
+)
+
+// ActionVersion is the encoding version of the asset actions built by this
+// package.
+const ActionVersion uint8 = 1
+
+var (
+	// ErrActionVersion is returned if an action was encoded with an unknown version.
+	ErrActionVersion = errors.New("unsupported action version")
+	// ErrActionType is returned if an action type is unknown.
+	ErrActionType = errors.New("unknown action type")
+)
+
+// ActionType selects the asset operation of an action.
+type ActionType uint8
+
+const (
+	// ActionRegister registers a new asset, params RegisterParams
+	ActionRegister ActionType = iota + 1
+	// ActionIssue issues new supply to the asset owner, params IssueParams
+	ActionIssue
+	// ActionTransferOwner hands the asset to a new owner, params TransferOwnerParams
+	ActionTransferOwner
+	// ActionBurn destroys balance of the sender, params BurnParams
+	ActionBurn
+	// ActionFreezeAsset freezes all balances of the asset, params FreezeParams
+	ActionFreezeAsset
+	// ActionFreezeAccount freezes the balance of a holder, params FreezeParams
+	ActionFreezeAccount
+	// ActionSetPolicy replaces the owner policy of the asset, params PolicyParams
+	ActionSetPolicy
+	// ActionSetFeeRate sets the rate fees paid in the asset convert to ZIP
+	// with, params FeeRateParams
+	ActionSetFeeRate
+	// ActionSetAccountPolicy replaces the key set signing for the sender, the
+	// asset of the carrying input is ignored, params PolicyParams
+	ActionSetAccountPolicy
+	// ActionHTLCLock locks an amount of the asset for a recipient under a
+	// hashlock and a timeout, params HTLCLockParams
+	ActionHTLCLock
+	// ActionHTLCClaim pays a lock out to its recipient, the asset of the
+	// carrying input is ignored, params HTLCClaimParams
+	ActionHTLCClaim
+	// ActionHTLCRefund returns a timed out lock to its sender, the asset of the
+	// carrying input is ignored, params HTLCRefundParams
+	ActionHTLCRefund
+	// ActionSCIPRegister starts tracking the headers of another chain from a
+	// trusted checkpoint, the asset of the carrying input is ignored, params
+	// SCIPRegisterParams
+	ActionSCIPRegister
+	// ActionSCIPRelay adds headers of a registered chain, the asset of the
+	// carrying input is ignored, params SCIPRelayParams
+	ActionSCIPRelay
+	// ActionSCIPSend moves an amount of the asset to another chain, params
+	// SCIPSendParams
+	ActionSCIPSend
+	// ActionSCIPReceive credits a message proven against a relayed header, the
+	// asset of the carrying input is ignored, params SCIPReceiveParams
+	ActionSCIPReceive
+)
+
+func (t ActionType) String() string {
+	switch t {
+	case ActionRegister:
+		return "register"
+	case ActionIssue:
+		return "issue"
+	case ActionTransferOwner:
+		return "transferowner"
+	case ActionBurn:
+		return "burn"
+	case ActionFreezeAsset:
+		return "freezeasset"
+	case ActionFreezeAccount:
+		return "freezeaccount"
+	case ActionSetPolicy:
+		return "setpolicy"
+	case ActionSetFeeRate:
+		return "setfeerate"
+	case ActionSetAccountPolicy:
+		return "setaccountpolicy"
+	case ActionHTLCLock:
+		return "htlclock"
+	case ActionHTLCClaim:
+		return "htlcclaim"
+	case ActionHTLCRefund:
+		return "htlcrefund"
+	case ActionSCIPRegister:
+		return "scipregister"
+	case ActionSCIPRelay:
+		return "sciprelay"
+	case ActionSCIPSend:
+		return "scipsend"
+	case ActionSCIPReceive:
+		return "scipreceive"
+	}
+	return "unknown"
+}
+
+// Action is an asset operation carried in the Payload of an AMInput, the
+// AssertID of the input names the asset it applies to.
+type Action struct {
+	Version uint8
+	Type    ActionType
+	Params  []byte
+
+	// AssetID is filled from the carrying input by Transaction.Actions.
+	AssetID common.Address `rlp:"-"`
+}
+
+// RegisterParams describes a new asset. A zero owner makes the sender own it.
+type RegisterParams struct {
+	BaseType  uint8
+	Name      string
+	Symbol    string
+	Total     *big.Int
+	Decimals  uint64
+	Owner     common.Address
+	MaxSupply *big.Int
+}
+
+// IssueParams is the amount issued.
+type IssueParams struct {
+	Value *big.Int
+}
+
+// TransferOwnerParams names the new owner.
+type TransferOwnerParams struct {
+	NewOwner common.Address
+}
+
+// BurnParams is the amount burnt of an account model asset, utxo model assets
+// burn the output at OutPoint instead.
+type BurnParams struct {
+	Value    *big.Int
+	OutPoint OutPoint
+}
+
+// FreezeParams freezes or unfreezes, Holder is only used by ActionFreezeAccount.
+type FreezeParams struct {
+	Holder common.Address
+	Frozen bool
+}
+
+// PolicyKey is a weighted key of an owner policy.
+type PolicyKey struct {
+	Address common.Address
+	Weight  uint64
+}
+
+// PolicyParams is the new owner policy or account key set, no keys remove the
+// policy.
+type PolicyParams struct {
+	Keys      []PolicyKey
+	Threshold uint64
+}
+
+// FeeRateParams is the new fee rate, Denom units of the asset are worth Num
+// units of ZIP. A zero rate stops the asset from paying fees.
+type FeeRateParams struct {
+	Num   *big.Int
+	Denom *big.Int
+}
+
+// HTLCLockParams locks Value of an account model asset, or the output at
+// OutPoint of an utxo model asset, until block Timeout. The recipient can
+// claim it with the preimage of HashLock before, the sender gets it back from
+// then on.
+type HTLCLockParams struct {
+	Recipient common.Address
+	Value     *big.Int
+	OutPoint  OutPoint
+	HashLock  common.Hash
+	Timeout   uint64
+}
+
+// HTLCClaimParams reveals the preimage of the hashlock of the lock claimed.
+type HTLCClaimParams struct {
+	Preimage []byte
+}
+
+// HTLCRefundParams names the hashlock of the lock refunded.
+type HTLCRefundParams struct {
+	HashLock common.Hash
+}
+
+// SCIPRegisterParams registers the chain ChainID sealed by the consensus
+// engine Engine with its settings. Checkpoint is the trusted header tracking
+// starts from, a proof-of-authority checkpoint has to be an epoch header
+// listing the signers. Messages are accepted once their header is buried
+// under Confirmations headers.
+type SCIPRegisterParams struct {
+	ChainID       *big.Int
+	Engine        uint8
+	Period        uint64
+	Epoch         uint64
+	Validators    []common.Address
+	Checkpoint    *Header
+	Confirmations uint64
+}
+
+// SCIPRelayParams are headers of the chain ChainID in ascending order. Chains
+// with byzantine fault tolerant finality need the RLP encoded commit of every
+// header in Commits.
+type SCIPRelayParams struct {
+	ChainID *big.Int
+	Headers []*Header
+	Commits [][]byte
+}
+
+// SCIPSendParams moves Value of the asset to Recipient on the chain
+// DestChainID.
+type SCIPSendParams struct {
+	DestChainID *big.Int
+	Recipient   common.Address
+	Value       *big.Int
+}
+
+// SCIPReceiveParams proves the message in log LogIndex of the receipt at
+// TxIndex of block BlockHash of the chain ChainID. Proof are the receipt trie
+// nodes from the root to the receipt.
+type SCIPReceiveParams struct {
+	ChainID   *big.Int
+	BlockHash common.Hash
+	TxIndex   uint64
+	LogIndex  uint64
+	Proof     [][]byte
+}
+
+// HTLCHash returns the hashlock of a preimage. It is SHA-256, like the
+// hashlocks of Bitcoin and Ethereum swaps, so both legs of a swap can share
+// one secret.
+func HTLCHash(preimage []byte) common.Hash {
+	return common.Hash(sha256.Sum256(preimage))
+}
+
+// IsSCIP returns whether the action belongs to the cross-ledger bridge.
+func (t ActionType) IsSCIP() bool {
+	return t >= ActionSCIPRegister && t <= ActionSCIPReceive
+}
+
+// NewActionInput builds an AMInput carrying the action of type typ with the
+// RLP encoded params.
+func NewActionInput(assetID common.Address, typ ActionType, params interface{}) (AMInput, error) {
+	enc, err := rlp.EncodeToBytes(params)
+	if err != nil {
+		return AMInput{}, err
+	}
+	payload, err := rlp.EncodeToBytes(&Action{Version: ActionVersion, Type: typ, Params: enc})
+	if err != nil {
+		return AMInput{}, err
+	}
+	return AMInput{AssertID: &assetID, Payload: payload}, nil
+}
+
+// DecodeAction decodes the action in the payload of an input, nil is returned
+// for an empty payload.
+func DecodeAction(payload []byte) (*Action, error) {
+	if len(payload) == 0 {
+		return nil, nil
+	}
+	action := new(Action)
+	if err := rlp.DecodeBytes(payload, action); err != nil {
+		return nil, err
+	}
+	if action.Version != ActionVersion {
+		return nil, ErrActionVersion
+	}
+	return action, nil
+}
+
+// DecodeParams decodes the params of the action into the params struct of
+// its type.
+func (a *Action) DecodeParams() (interface{}, error) {
+	var params interface{}
+	switch a.Type {
+	case ActionRegister:
+		params = new(RegisterParams)
+	case ActionIssue:
+		params = new(IssueParams)
+	case ActionTransferOwner:
+		params = new(TransferOwnerParams)
+	case ActionBurn:
+		params = new(BurnParams)
+	case ActionFreezeAsset, ActionFreezeAccount:
+		params = new(FreezeParams)
+	case ActionSetPolicy, ActionSetAccountPolicy:
+		params = new(PolicyParams)
+	case ActionSetFeeRate:
+		params = new(FeeRateParams)
+	case ActionHTLCLock:
+		params = new(HTLCLockParams)
+	case ActionHTLCClaim:
+		params = new(HTLCClaimParams)
+	case ActionHTLCRefund:
+		params = new(HTLCRefundParams)
+	case ActionSCIPRegister:
+		params = new(SCIPRegisterParams)
+	case ActionSCIPRelay:
+		params = new(SCIPRelayParams)
+	case ActionSCIPSend:
+		params = new(SCIPSendParams)
+	case ActionSCIPReceive:
+		params = new(SCIPReceiveParams)
+	default:
+		return nil, ErrActionType
+	}
+	if err := rlp.DecodeBytes(a.Params, params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// Actions returns the actions carried by the inputs of the transaction in
+// input order.
+func (tx *Transaction) Actions() ([]*Action, error) {
+	inputs, err := tx.GetInputs()
+	if err != nil {
+		return nil, err
+	}
+	var actions []*Action
+	for _, v := range inputs {
+		input, ok := v.(AMInput)
+		if !ok {
+			continue
+		}
+		action, err := DecodeAction(input.Payload)
+		if err != nil {
+			return nil, err
+		}
+		if action == nil {
+			continue
+		}
+		if input.AssertID != nil {
+			action.AssetID = *input.AssertID
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// ActionResult reports the outcome of an asset action of a transaction.
+type ActionResult struct {
+	Type    ActionType     `json:"type"`
+	AssetID common.Address `json:"assetid"`
+	Status  uint64         `json:"status"`
+	Error   string         `json:"error"`
+
+	// HashLock and Preimage of the HTLC actions, so the relayer of the
+	// counterparty of a swap learns the secret from the claim.
+	HashLock common.Hash   `json:"hashlock"`
+	Preimage hexutil.Bytes `json:"preimage"`
+}
+
+// HTLC returns the hashlock and, for claims, the preimage of an HTLC action,
+// both are empty for the other actions.
+func (a *Action) HTLC() (common.Hash, []byte) {
+	params, err := a.DecodeParams()
+	if err != nil {
+		return common.Hash{}, nil
+	}
+	switch p := params.(type) {
+	case *HTLCLockParams:
+		return p.HashLock, nil
+	case *HTLCClaimParams:
+		return HTLCHash(p.Preimage), common.CopyBytes(p.Preimage)
+	case *HTLCRefundParams:
+		return p.HashLock, nil
+	}
+	return common.Hash{}, nil
+}
@@ -18,6 +18,7 @@
 package types
 
 import (
+	"fmt"
 	"math/big"
 	"reflect"
 
@@ -29,17 +30,27 @@ var (
 	ZipAssetID = common.Address{1}
 	//ZipAccount chain asset
 	ZipAccount = common.Address{2}
+	//AssetRegistry keeps the chain level registry of all assets
+	AssetRegistry = common.Address{3}
+	//SCIPBridge keeps the cross-ledger bridge state and emits its messages
+	SCIPBridge = common.Address{4}
 )
 
 var (
 	AMInputType  = reflect.TypeOf(AMInput{})
 	AMOutputType = reflect.TypeOf(AMOutput{})
-	DefaultType  = reflect.TypeOf([]interface{}{})
+	// UTXOInputType spends an unspent output of an utxo model asset
+	UTXOInputType = reflect.TypeOf(UTXOInput{})
+	// UTXOOutputType creates an unspent output of an utxo model asset
+	UTXOOutputType = reflect.TypeOf(UTXOOutput{})
+	DefaultType    = reflect.TypeOf([]interface{}{})
 )
 
 const (
 	// AccountModelType asset based account model
 	AccountModelType uint8 = iota
+	// UtxoModelType asset based utxo model
+	UtxoModelType
 )
 
 type AccountModel struct {
@@ -52,6 +63,39 @@ type AMInput struct {
 
 type AMOutput struct {
 	AssertID *common.Address `json:"assertid"`
-	Address  *common.Address `json:"to"`
+	Address  *common.Address `json:"to" rlp:"nil"` // nil means contract creation
 	Value    *big.Int        `josn:"value"`
 }
+
+// OutPoint identifies an unspent output by the hash of the transaction that
+// created it and the position of the output in that transaction.
+type OutPoint struct {
+	TxHash common.Hash `json:"txhash"`
+	Index  uint32      `json:"index"`
+}
+
+func (op OutPoint) String() string {
+	return fmt.Sprintf("%x:%d", op.TxHash, op.Index)
+}
+
+// UTXOInput references the unspent output it spends. The fields are kept
+// flat so that the encoding can be told apart from AMInput by its length.
+type UTXOInput struct {
+	AssertID *common.Address `json:"assertid"`
+	TxHash   common.Hash     `json:"txhash"`
+	Index    uint32          `json:"index"`
+}
+
+// OutPoint returns the outpoint spent by the input.
+func (in UTXOInput) OutPoint() OutPoint {
+	return OutPoint{TxHash: in.TxHash, Index: in.Index}
+}
+
+// UTXOOutput creates a new unspent output owned by Address. The output can
+// not be spent by a block numbered below LockTime.
+type UTXOOutput struct {
+	AssertID *common.Address `json:"assertid"`
+	Address  *common.Address `json:"to"`
+	Value    *big.Int        `json:"value"`
+	LockTime uint64          `json:"locktime"`
+}
@@ -80,6 +80,11 @@ type Header struct {
 	Extra       []byte         `json:"extraData"       `
 	MixDigest   common.Hash    `json:"mixHash"         `
 	Nonce       BlockNonce     `json:"nonce"           `
+
+	// BaseFee is the price in ZIP per gas every transaction of the block
+	// pays at least, nil or zero before the base fee fork. Headers without
+	// base fee encode as before.
+	BaseFee *big.Int `json:"baseFee" rlp:"optional"`
 }
 
 // Hash returns the block hash of the header, which is simply the keccak256 hash of its
@@ -175,6 +180,14 @@ func (b *Block) Extra() []byte              { return common.CopyBytes(b.Head.Ext
 func (b *Block) Header() *Header            { return CopyHeader(b.Head) }
 func (b *Block) Body() *Body                { return &Body{b.Txs} }
 
+// BaseFee returns the base fee of the block, nil if it has none.
+func (b *Block) BaseFee() *big.Int {
+	if b.Head.BaseFee == nil || b.Head.BaseFee.Sign() == 0 {
+		return nil
+	}
+	return new(big.Int).Set(b.Head.BaseFee)
+}
+
 // EncodeRLP serializes b into the RLP block format.
 func (b *Block) EncodeRLP() ([]byte, error) {
 	return rlp.EncodeToBytes(b)
@@ -212,6 +225,15 @@ func (b *Block) Hash() common.Hash {
 	return v
 }
 
+// WithSeal returns a new block with the data from b but the header replaced with
+// the sealed one.
+func (b *Block) WithSeal(header *Header) *Block {
+	return &Block{
+		Head: CopyHeader(header),
+		Txs:  b.Txs,
+	}
+}
+
 // WithBody returns a new block with the given transaction and uncle contents.
 func (b *Block) WithBody(transactions []*Transaction) *Block {
 	block := &Block{
@@ -235,6 +257,9 @@ func CopyHeader(h *Header) *Header {
 	if cpy.Number = new(big.Int); h.Number != nil {
 		cpy.Number.Set(h.Number)
 	}
+	if h.BaseFee != nil {
+		cpy.BaseFee = new(big.Int).Set(h.BaseFee)
+	}
 	if len(h.Extra) > 0 {
 		cpy.Extra = make([]byte, len(h.Extra))
 		copy(cpy.Extra, h.Extra)
@@ -70,3 +70,28 @@ func TestBlockHeaderEncodeRLPAndDecodeRLP(t *testing.T) {
 	tmpBytes, _ := newHeader.EncodeRLP()
 	common.AssertEquals(t, bytes, tmpBytes)
 }
+
+func TestHeaderBaseFeeEncoding(t *testing.T) {
+	// headers without base fee encode as before the field was added
+	enc, _ := th.EncodeRLP()
+	dec := &Header{}
+	if err := dec.DecodeRLP(enc); err != nil {
+		t.Fatal(err)
+	}
+	if dec.BaseFee != nil || dec.Hash() != th.Hash() {
+		t.Fatalf("header without base fee changed: base fee %v", dec.BaseFee)
+	}
+	withFee := CopyHeader(th)
+	withFee.BaseFee = big.NewInt(1000)
+	if withFee.Hash() == th.Hash() {
+		t.Fatalf("base fee not part of the header hash")
+	}
+	enc, _ = withFee.EncodeRLP()
+	dec = &Header{}
+	if err := dec.DecodeRLP(enc); err != nil {
+		t.Fatal(err)
+	}
+	if dec.BaseFee == nil || dec.BaseFee.Int64() != 1000 {
+		t.Fatalf("base fee mismatch: have %v, want 1000", dec.BaseFee)
+	}
+}
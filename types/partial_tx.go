@@ -0,0 +1,175 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"crypto/ecdsa"
+	"errors"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/utils/rlp"
+)
+
+// ErrNotAccountKey is returned if a key outside the key set of the sender
+// signs a partially signed transaction.
+var ErrNotAccountKey = errors.New("key not in the account key set")
+
+// PartialTx is a transaction of a multi-signature account on its way between
+// offline signers. It carries the key set of the sending account, so every
+// signer can tell which signatures are still missing. The account key signs
+// the transaction, the other keys co-sign it, in any order.
+type PartialTx struct {
+	Tx        *Transaction
+	From      common.Address
+	Keys      []PolicyKey
+	Threshold uint64
+}
+
+type partialTxRLP struct {
+	Tx        []byte
+	From      common.Address
+	Keys      []PolicyKey
+	Threshold uint64
+}
+
+// NewPartialTx starts collecting the signatures of the key set for an
+// unsigned transaction of the account from.
+func NewPartialTx(tx *Transaction, from common.Address, keys []PolicyKey, threshold uint64) *PartialTx {
+	return &PartialTx{Tx: tx, From: from, Keys: keys, Threshold: threshold}
+}
+
+// DecodePartialTx decodes a partially signed transaction encoded by Encode.
+func DecodePartialTx(b []byte) (*PartialTx, error) {
+	var dec partialTxRLP
+	if err := rlp.DecodeBytes(b, &dec); err != nil {
+		return nil, err
+	}
+	tx := new(Transaction)
+	if err := tx.DecodeRLP(dec.Tx); err != nil {
+		return nil, err
+	}
+	return &PartialTx{Tx: tx, From: dec.From, Keys: dec.Keys, Threshold: dec.Threshold}, nil
+}
+
+// Encode returns the RLP encoding passed between the signers.
+func (p *PartialTx) Encode() ([]byte, error) {
+	tx, err := p.Tx.EncodeRLP()
+	if err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(&partialTxRLP{Tx: tx, From: p.From, Keys: p.Keys, Threshold: p.Threshold})
+}
+
+// Sign adds the signature of the private key, the account key signs the
+// transaction and the other keys of the set co-sign it.
+func (p *PartialTx) Sign(s Signer, prv *ecdsa.PrivateKey) error {
+	return p.SignWithKey(s, crypto.SchemeKeyFromECDSA(prv))
+}
+
+// SignWithKey is Sign for a private key of any registered signature scheme.
+func (p *PartialTx) SignWithKey(s Signer, key crypto.SchemeKey) error {
+	addr, err := crypto.KeyAddress(key)
+	if err != nil {
+		return err
+	}
+	var tx *Transaction
+	switch {
+	case addr == p.From:
+		tx, err = SignTxWithKey(p.Tx, s, key)
+	case p.weight(addr) > 0:
+		tx, err = CoSignTxWithKey(p.Tx, s, key)
+	default:
+		return ErrNotAccountKey
+	}
+	if err != nil {
+		return err
+	}
+	p.Tx = tx
+	return nil
+}
+
+// Missing returns the keys of the set that haven't signed yet.
+func (p *PartialTx) Missing(s Signer) ([]common.Address, error) {
+	signed, err := p.signed(s)
+	if err != nil {
+		return nil, err
+	}
+	var missing []common.Address
+	for _, k := range p.Keys {
+		if !signed[k.Address] {
+			missing = append(missing, k.Address)
+		}
+	}
+	return missing, nil
+}
+
+// Complete returns whether the account key signed and the weights of the
+// signing keys reach the threshold, the transaction can then be sent.
+func (p *PartialTx) Complete(s Signer) (bool, error) {
+	signed, err := p.signed(s)
+	if err != nil {
+		return false, err
+	}
+	if !signed[p.From] {
+		return false, nil
+	}
+	if len(p.Keys) == 0 {
+		return true, nil
+	}
+	var weight uint64
+	for _, k := range p.Keys {
+		if signed[k.Address] {
+			weight += p.weight(k.Address)
+		}
+	}
+	return weight >= p.Threshold, nil
+}
+
+// weight returns the weight of the key in the set, a zero weight counts as one.
+func (p *PartialTx) weight(addr common.Address) uint64 {
+	for _, k := range p.Keys {
+		if k.Address == addr {
+			if k.Weight == 0 {
+				return 1
+			}
+			return k.Weight
+		}
+	}
+	return 0
+}
+
+// signed returns the keys that signed so far, the sender only once the
+// transaction carries its signature.
+func (p *PartialTx) signed(s Signer) (map[common.Address]bool, error) {
+	signed := make(map[common.Address]bool)
+	if p.Tx.Data.R.Sign() != 0 {
+		from, err := Sender(s, p.Tx)
+		if err != nil {
+			return nil, err
+		}
+		signed[from] = true
+	}
+	cosigners, err := CoSigners(s, p.Tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range cosigners {
+		signed[addr] = true
+	}
+	return signed, nil
+}
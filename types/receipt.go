@@ -38,21 +38,23 @@ const (
 
 // InternalTx represents the results of a contract internal transaction.
 type InternalTx struct {
-	OpCode byte           `json:"opcode"        `
-	From   common.Address `json:"from"        `
-	To     common.Address `json:"to"        `
-	Value  *big.Int       `json:"value"        `
+	OpCode  byte           `json:"opcode"        `
+	From    common.Address `json:"from"        `
+	To      common.Address `json:"to"        `
+	AssetID common.Address `json:"assetid"     `
+	Value   *big.Int       `json:"value"        `
 }
 
 // Receipt represents the results of a transaction.
 type Receipt struct {
 	// Consensus fields
-	PostState         []byte        `json:"root"`
-	Status            uint64        `json:"status"`
-	Internal          []*InternalTx `json:"internal" `
-	CumulativeGasUsed uint64        `json:"cumulativeGasUsed"`
-	Bloom             Bloom         `json:"logsBloom"        `
-	Logs              []*Log        `json:"logs"             `
+	PostState         []byte          `json:"root"`
+	Status            uint64          `json:"status"`
+	Internal          []*InternalTx   `json:"internal" `
+	Actions           []*ActionResult `json:"actions"`
+	CumulativeGasUsed uint64          `json:"cumulativeGasUsed"`
+	Bloom             Bloom           `json:"logsBloom"        `
+	Logs              []*Log          `json:"logs"             `
 
 	// Implementation fields (don't reorder!)
 	TxHash          common.Hash    `json:"transactionHash"`
@@ -87,17 +89,71 @@ func (r *Receipt) Size() common.StorageSize {
 	return common.StorageSize(len(bytes))
 }
 
+// consensusReceipt is the part of a receipt secured by the receipt root.
+type consensusReceipt struct {
+	PostState         []byte
+	Status            uint64
+	Internal          []*InternalTx
+	Actions           []*ActionResult
+	CumulativeGasUsed uint64
+	Bloom             Bloom
+	Logs              []*consensusLog
+}
+
+// consensusLog is the part of a log secured by the receipt root.
+type consensusLog struct {
+	Address common.Address
+	Topics  []common.Hash
+	Data    []byte
+}
+
 // Receipts is a wrapper around a Receipt array to implement DerivableList.
 type Receipts []*Receipt
 
 // Len returns the number of receipts in this list.
 func (r Receipts) Len() int { return len(r) }
 
-// GetRlp returns the RLP encoding of one receipt from the list.
+// GetRlp returns the RLP encoding of the consensus fields of one receipt from
+// the list. The derived fields of the logs refer to the block hash, which
+// can't be known when the receipt root is computed.
 func (r Receipts) GetRlp(i int) []byte {
-	bytes, err := rlp.EncodeToBytes(r[i])
+	logs := make([]*consensusLog, len(r[i].Logs))
+	for j, log := range r[i].Logs {
+		logs[j] = &consensusLog{Address: log.Address, Topics: log.Topics, Data: log.Data}
+	}
+	bytes, err := rlp.EncodeToBytes(&consensusReceipt{
+		PostState:         r[i].PostState,
+		Status:            r[i].Status,
+		Internal:          r[i].Internal,
+		Actions:           r[i].Actions,
+		CumulativeGasUsed: r[i].CumulativeGasUsed,
+		Bloom:             r[i].Bloom,
+		Logs:              logs,
+	})
 	if err != nil {
 		panic(err)
 	}
 	return bytes
 }
+
+// DecodeConsensusReceipt decodes a receipt encoded by Receipts.GetRlp, the
+// implementation fields and the derived fields of the logs are left empty.
+func DecodeConsensusReceipt(data []byte) (*Receipt, error) {
+	var dec consensusReceipt
+	if err := rlp.DecodeBytes(data, &dec); err != nil {
+		return nil, err
+	}
+	r := &Receipt{
+		PostState:         dec.PostState,
+		Status:            dec.Status,
+		Internal:          dec.Internal,
+		Actions:           dec.Actions,
+		CumulativeGasUsed: dec.CumulativeGasUsed,
+		Bloom:             dec.Bloom,
+		Logs:              make([]*Log, len(dec.Logs)),
+	}
+	for i, log := range dec.Logs {
+		r.Logs[i] = &Log{Address: log.Address, Topics: log.Topics, Data: log.Data}
+	}
+	return r, nil
+}
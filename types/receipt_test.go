@@ -38,3 +38,21 @@ func TestReceiptEncodeAndDecode(t *testing.T) {
 	common.AssertEquals(t, bytes, tmpBytes)
 
 }
+
+func TestReceiptRootDerivedFields(t *testing.T) {
+	receipt := NewReceipt(nil, false, 21000)
+	receipt.Logs = []*Log{{Address: common.Address{0x01}, Topics: []common.Hash{{0x02}}, Data: []byte{0x03}}}
+	root := DeriveSha(Receipts{receipt})
+
+	// The derived fields are filled in once the block hash is known
+	receipt.TxHash = common.Hash{0x04}
+	receipt.Logs[0].BlockHash = common.Hash{0x05}
+	receipt.Logs[0].BlockNumber = 1
+	receipt.Logs[0].TxIndex = 2
+	common.AssertEquals(t, root, DeriveSha(Receipts{receipt}))
+
+	receipt.Logs[0].Data = []byte{0x06}
+	if DeriveSha(Receipts{receipt}) == root {
+		t.Fatalf("receipt root doesn't cover the log data")
+	}
+}
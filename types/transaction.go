@@ -20,10 +20,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"math/big"
 	"reflect"
 	"sync/atomic"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/zipper-project/z0/common"
 	"github.com/zipper-project/z0/crypto"
 	"github.com/zipper-project/z0/utils/rlp"
@@ -32,14 +34,19 @@ import (
 // ErrInvalidSig invalid signature
 var ErrInvalidSig = errors.New("invalid transaction v, r, s values")
 
+// ValidityTimeThreshold separates the two kinds of validity bounds, bounds
+// below are block numbers and bounds from it on unix timestamps.
+const ValidityTimeThreshold = 500000000
+
 // Transaction represents an entire transaction in the block.
 type Transaction struct {
 	Data txdata
 
 	// caches
-	hash atomic.Value
-	size atomic.Value
-	from atomic.Value
+	hash      atomic.Value
+	size      atomic.Value
+	from      atomic.Value
+	cosigners atomic.Value
 }
 
 type txdata struct {
@@ -57,6 +64,32 @@ type txdata struct {
 
 	// This is only used when marshaling to JSON.
 	Hash *common.Hash `json:"hash" rlp:"-"`
+
+	// The fields below are optional, transactions not using them encode as
+	// before. See the "optional" tag of package rlp.
+	//
+	// FeeAsset pays the gas, the zero address stands for ZIP. The gas
+	// price is denominated in this asset.
+	FeeAsset common.Address `json:"feeAsset" rlp:"optional"`
+
+	// Signatures are co-signatures over the signing hash, see CoSignTx.
+	Signatures []hexutil.Bytes `json:"signatures" rlp:"optional"`
+
+	// Scheme is the signature scheme of V, R and S, see crypto.SigScheme.
+	// Schemes without public key recovery carry the public key of the
+	// sender, R and S then hold the two halves of the signature.
+	Scheme uint8         `json:"scheme" rlp:"optional"`
+	PubKey hexutil.Bytes `json:"pubKey" rlp:"optional"`
+
+	// ValidAfter and ValidUntil bound the blocks the transaction can be
+	// included in, see ValidityTimeThreshold. Zero leaves a side open.
+	ValidAfter uint64 `json:"validAfter" rlp:"optional"`
+	ValidUntil uint64 `json:"validUntil" rlp:"optional"`
+
+	// Tip is the most the sender pays the miner per gas on top of the base
+	// fee, nil tips the whole gas price. It has to stay the last field, a
+	// nil tip is then never encoded and decodes as nil again.
+	Tip *big.Int `json:"tip" rlp:"optional"`
 }
 
 // NewTransaction initialize transaction
@@ -89,6 +122,19 @@ func (tx *Transaction) WithInput(inputs ...interface{}) { tx.Data.Inputs = input
 // WithOutput add transaction output
 func (tx *Transaction) WithOutput(outputs ...interface{}) { tx.Data.Outputs = outputs }
 
+// WithFeeAsset sets the asset paying the transaction fee
+func (tx *Transaction) WithFeeAsset(assetID common.Address) { tx.Data.FeeAsset = assetID }
+
+// WithTip sets the most the sender pays the miner per gas on top of the base
+// fee. The gas price then caps the base fee and the tip together.
+func (tx *Transaction) WithTip(tip *big.Int) { tx.Data.Tip = new(big.Int).Set(tip) }
+
+// WithValidity limits the transaction to the blocks after the bound after
+// up to and including the bound until, zero leaves a side open.
+func (tx *Transaction) WithValidity(after, until uint64) {
+	tx.Data.ValidAfter, tx.Data.ValidUntil = after, until
+}
+
 // EncodeRLP implements rlp.Encoder
 func (tx *Transaction) EncodeRLP() ([]byte, error) {
 	return rlp.EncodeToBytes(&tx.Data)
@@ -119,74 +165,228 @@ func (tx *Transaction) Gas() uint64        { return tx.Data.GasLimit }
 func (tx *Transaction) GasPrice() *big.Int { return new(big.Int).Set(tx.Data.Price) }
 func (tx *Transaction) Nonce() uint64      { return tx.Data.Nonce }
 
+// Tip returns the most the sender pays the miner per gas, denominated in the
+// fee asset.
+func (tx *Transaction) Tip() *big.Int {
+	if tx.Data.Tip == nil {
+		return tx.GasPrice()
+	}
+	return new(big.Int).Set(tx.Data.Tip)
+}
+
+// EffectiveTip returns the tip per gas the miner receives in a block of the
+// base fee, both denominated in the fee asset. It is negative if the gas price
+// doesn't cover the base fee, a nil base fee leaves the whole gas price.
+func (tx *Transaction) EffectiveTip(baseFee *big.Int) *big.Int {
+	if baseFee == nil {
+		return tx.GasPrice()
+	}
+	tip := new(big.Int).Sub(tx.Data.Price, baseFee)
+	if limit := tx.Tip(); tip.Cmp(limit) > 0 {
+		tip = limit
+	}
+	return tip
+}
+
+// EffectivePrice returns the price per gas the sender pays in a block of the
+// base fee, the base fee plus the effective tip.
+func (tx *Transaction) EffectivePrice(baseFee *big.Int) *big.Int {
+	if baseFee == nil {
+		return tx.GasPrice()
+	}
+	return new(big.Int).Add(baseFee, tx.EffectiveTip(baseFee))
+}
+
+func (tx *Transaction) ValidAfter() uint64 { return tx.Data.ValidAfter }
+func (tx *Transaction) ValidUntil() uint64 { return tx.Data.ValidUntil }
+
+// NotYetValid reports whether a block of the number and timestamp is too early
+// to include the transaction.
+func (tx *Transaction) NotYetValid(number, time uint64) bool {
+	return validityPoint(tx.Data.ValidAfter, number, time) <= tx.Data.ValidAfter
+}
+
+// Expired reports whether a block of the number and timestamp is too late to
+// include the transaction. Block numbers and timestamps only grow, so the
+// transaction can't be included after it expired.
+func (tx *Transaction) Expired(number, time uint64) bool {
+	return tx.Data.ValidUntil != 0 && validityPoint(tx.Data.ValidUntil, number, time) > tx.Data.ValidUntil
+}
+
+// validityPoint returns the block number or timestamp, whichever the bound
+// is given in.
+func validityPoint(bound, number, time uint64) uint64 {
+	if bound < ValidityTimeThreshold {
+		return number
+	}
+	return time
+}
+
+// FeeAsset returns the asset paying the transaction fee.
+func (tx *Transaction) FeeAsset() common.Address {
+	if tx.Data.FeeAsset == (common.Address{}) {
+		return ZipAssetID
+	}
+	return tx.Data.FeeAsset
+}
+
+// Fee returns gasprice * gaslimit, denominated in the fee asset.
+func (tx *Transaction) Fee() *big.Int {
+	return new(big.Int).Mul(tx.Data.Price, new(big.Int).SetUint64(tx.Data.GasLimit))
+}
+
+// Value returns the amounts carried by the outputs, grouped by recipient and
+// asset.
 func (tx *Transaction) Value() map[common.Address]map[common.Address]*big.Int {
 	values := make(map[common.Address]map[common.Address]*big.Int)
-	for _, v := range tx.Data.Outputs {
-		if reflect.TypeOf(v) == AMOutputType {
-			output := v.(AMOutput)
-			if values[*output.Address] == nil {
-				values[*output.Address] = make(map[common.Address]*big.Int)
-			}
-			values[*output.Address][*output.AssertID] = output.Value
+	add := func(to, assetID *common.Address, amount *big.Int) {
+		if to == nil || assetID == nil || amount == nil {
+			return
+		}
+		if values[*to] == nil {
+			values[*to] = make(map[common.Address]*big.Int)
+		}
+		if value, ok := values[*to][*assetID]; ok {
+			values[*to][*assetID] = new(big.Int).Add(value, amount)
 		} else {
-			// todo utxo
+			values[*to][*assetID] = new(big.Int).Set(amount)
 		}
 	}
-	return nil
+	for _, v := range tx.outputs() {
+		switch output := v.(type) {
+		case AMOutput:
+			add(output.Address, output.AssertID, output.Value)
+		case UTXOOutput:
+			add(output.Address, output.AssertID, output.Value)
+		}
+	}
+	return values
 }
 
-func (tx *Transaction) GetInputs() []interface{} {
+// CreatesContract reports whether the transaction deploys a contract, that is
+// whether it has an account model output without recipient.
+func (tx *Transaction) CreatesContract() bool {
+	for _, v := range tx.outputs() {
+		if output, ok := v.(AMOutput); ok && output.Address == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// GetInputs returns the inputs as AMInput and UTXOInput values. Decoded
+// inputs are told apart by the length of their list, an input that doesn't
+// decode into either type is an error.
+func (tx *Transaction) GetInputs() ([]interface{}, error) {
 	results := make([]interface{}, len(tx.Data.Inputs))
 	for k, v := range tx.Data.Inputs {
 		switch reflect.TypeOf(v) {
-		case AMInputType:
+		case AMInputType, UTXOInputType:
 			results[k] = v
 		case DefaultType:
+			var err error
 			if len(v.([]interface{})) == 2 {
-				amIn := &AMInput{}
-				b, _ := rlp.EncodeToBytes(v)
-				rlp.DecodeBytes(b, amIn)
-				results[k] = *amIn
+				amIn := AMInput{}
+				err = redecode(v, &amIn)
+				results[k] = amIn
 			} else {
-				// todo utxo
+				utxoIn := UTXOInput{}
+				err = redecode(v, &utxoIn)
+				results[k] = utxoIn
 			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid transaction input %d: %v", k, err)
+			}
+		default:
+			return nil, fmt.Errorf("invalid transaction input %d: unexpected %T", k, v)
 		}
 	}
-	return results
+	return results, nil
 }
-func (tx *Transaction) GetOutputs() []interface{} {
-	results := make([]interface{}, len(tx.Data.Inputs))
+
+// GetOutputs returns the outputs as AMOutput and UTXOOutput values. Decoded
+// outputs are told apart by the length of their list, an output that doesn't
+// decode into either type is an error.
+func (tx *Transaction) GetOutputs() ([]interface{}, error) {
+	results := make([]interface{}, len(tx.Data.Outputs))
 	for k, v := range tx.Data.Outputs {
 		switch reflect.TypeOf(v) {
-		case AMOutputType:
+		case AMOutputType, UTXOOutputType:
 			results[k] = v
 		case DefaultType:
+			var err error
 			if len(v.([]interface{})) == 3 {
-				amOut := &AMOutput{}
-				b, _ := rlp.EncodeToBytes(v)
-				rlp.DecodeBytes(b, amOut)
-				results[k] = *amOut
+				amOut := AMOutput{}
+				err = redecode(v, &amOut)
+				results[k] = amOut
 			} else {
-				// todo utxo
+				utxoOut := UTXOOutput{}
+				err = redecode(v, &utxoOut)
+				results[k] = utxoOut
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid transaction output %d: %v", k, err)
 			}
+		default:
+			return nil, fmt.Errorf("invalid transaction output %d: unexpected %T", k, v)
 		}
 	}
-	return results
+	return results, nil
+}
+
+// redecode decodes a generically decoded RLP list into val.
+func redecode(list interface{}, val interface{}) error {
+	b, err := rlp.EncodeToBytes(list)
+	if err != nil {
+		return err
+	}
+	return rlp.DecodeBytes(b, val)
 }
 
-// Cost returns amount + gasprice * gaslimit.
+// outputs returns the outputs the amounts below are derived from. Validation
+// rejects transactions with malformed outputs, here they carry nothing.
+func (tx *Transaction) outputs() []interface{} {
+	outputs, _ := tx.GetOutputs()
+	return outputs
+}
+
+// Cost returns the ZIP amount of the outputs, plus gasprice * gaslimit if the
+// fee is paid in ZIP.
 func (tx *Transaction) Cost() *big.Int {
 	amount := big.NewInt(0)
-	for _, v := range tx.Data.Outputs {
+	for _, v := range tx.outputs() {
 		if reflect.TypeOf(v) == AMOutputType {
 			output := v.(AMOutput)
-			if output.AssertID.Hex() == ZipAssetID.Hex() {
+			if output.AssertID != nil && *output.AssertID == ZipAssetID && output.Value != nil {
 				amount.Add(amount, output.Value)
 			}
 		}
 	}
-	total := new(big.Int).Mul(tx.Data.Price, new(big.Int).SetUint64(tx.Data.GasLimit))
-	return total.Add(total, amount)
+	if tx.FeeAsset() == ZipAssetID {
+		amount.Add(amount, tx.Fee())
+	}
+	return amount
+}
+
+// AssetCost returns the amounts the account model outputs of the transaction
+// spend per asset, the fee included in its fee asset. The ZIP entry equals
+// Cost.
+func (tx *Transaction) AssetCost() map[common.Address]*big.Int {
+	costs := make(map[common.Address]*big.Int)
+	add := func(assetID common.Address, amount *big.Int) {
+		if cost, ok := costs[assetID]; ok {
+			costs[assetID] = new(big.Int).Add(cost, amount)
+		} else {
+			costs[assetID] = new(big.Int).Set(amount)
+		}
+	}
+	for _, v := range tx.outputs() {
+		if output, ok := v.(AMOutput); ok && output.AssertID != nil && output.Value != nil {
+			add(*output.AssertID, output.Value)
+		}
+	}
+	add(tx.FeeAsset(), tx.Fee())
+	return costs
 }
 
 // Size returns the true RLP encoded storage size of the transaction, either by
@@ -206,6 +406,9 @@ func (tx *Transaction) ChainID() *big.Int {
 	return deriveChainID(tx.Data.V)
 }
 
+// Scheme returns the signature scheme of the sender signature.
+func (tx *Transaction) Scheme() crypto.SigScheme { return crypto.SigScheme(tx.Data.Scheme) }
+
 // Protected returns whether the transaction is protected from replay protection.
 func (tx *Transaction) Protected() bool {
 	return isProtectedV(tx.Data.V)
@@ -220,6 +423,7 @@ func (tx *Transaction) WithSignature(signer Signer, sig []byte) (*Transaction, e
 	}
 	cpy := &Transaction{Data: tx.Data}
 	cpy.Data.R, cpy.Data.S, cpy.Data.V = r, s, v
+	cpy.Data.Scheme, cpy.Data.PubKey = uint8(crypto.Secp256k1), nil
 	return cpy, nil
 }
 
@@ -266,7 +470,8 @@ func (tx *Transaction) UnmarshalJSON(input []byte) error {
 	} else {
 		V = byte(dec.V.Uint64() - 27)
 	}
-	if !crypto.ValidateSignatureValues(V, dec.R, dec.S, false) {
+	// Signatures of the other schemes are only checked against the public key
+	if crypto.SigScheme(dec.Scheme) == crypto.Secp256k1 && !crypto.ValidateSignatureValues(V, dec.R, dec.S, false) {
 		return ErrInvalidSig
 	}
 
@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/utils/rlp"
 )
 
 var (
@@ -51,11 +52,168 @@ func TestTransactionEncodeAndDecode(t *testing.T) {
 		common.AssertEquals(t, newTx.Gas(), testTx.Gas())
 		common.AssertEquals(t, newTx.GasPrice(), testTx.GasPrice())
 		common.AssertEquals(t, newTx.Nonce(), testTx.Nonce())
-		common.AssertEquals(t, newTx.GetInputs(), []interface{}{amInput})
-		common.AssertEquals(t, newTx.GetOutputs(), []interface{}{amOutput})
+		inputs, err := newTx.GetInputs()
+		common.AssertEquals(t, err, nil)
+		common.AssertEquals(t, inputs, []interface{}{amInput})
+		outputs, err := newTx.GetOutputs()
+		common.AssertEquals(t, err, nil)
+		common.AssertEquals(t, outputs, []interface{}{amOutput})
 
 		tmpBytes, _ := newTx.EncodeRLP()
 		common.AssertEquals(t, bytes, tmpBytes)
 
 	}
 }
+
+func TestUTXOTransactionEncodeAndDecode(t *testing.T) {
+	utxoInput := UTXOInput{AssertID: &assertID, TxHash: common.HexToHash("0x01"), Index: 2}
+	utxoOutput := UTXOOutput{AssertID: &assertID, Address: &addr, Value: big.NewInt(300), LockTime: 5}
+	change := UTXOOutput{AssertID: &assertID, Address: &assertID, Value: big.NewInt(700)}
+
+	tx := NewTransaction(0, 2000, big.NewInt(1), nil)
+	tx.WithInput(amInput, utxoInput)
+	tx.WithOutput(amOutput, utxoOutput, change)
+
+	bytes, _ := tx.EncodeRLP()
+	newTx := &Transaction{}
+	if err := newTx.DecodeRLP(bytes); err != nil {
+		t.Fatal(err)
+	}
+	inputs, err := newTx.GetInputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs, err := newTx.GetOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	common.AssertEquals(t, inputs, []interface{}{amInput, utxoInput})
+	common.AssertEquals(t, outputs, []interface{}{amOutput, utxoOutput, change})
+	common.AssertEquals(t, inputs[1].(UTXOInput).OutPoint(), OutPoint{TxHash: common.HexToHash("0x01"), Index: 2})
+
+	values := newTx.Value()
+	common.AssertEquals(t, values[addr][assertID], big.NewInt(10300))
+	common.AssertEquals(t, values[assertID][assertID], big.NewInt(700))
+}
+
+func TestMalformedInputsAndOutputs(t *testing.T) {
+	tx := NewTransaction(0, 2000, big.NewInt(1), nil)
+	tx.WithInput(AMInput{AssertID: &assertID}, []interface{}{[]byte{1}, []byte{2}, []byte{3}, []byte{4}})
+	tx.WithOutput(amOutput, []interface{}{[]byte{1}})
+
+	bytes, _ := tx.EncodeRLP()
+	newTx := &Transaction{}
+	if err := newTx.DecodeRLP(bytes); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTx.GetInputs(); err == nil {
+		t.Error("malformed input decoded without error")
+	}
+	if _, err := newTx.GetOutputs(); err == nil {
+		t.Error("malformed output decoded without error")
+	}
+}
+
+func TestActionEncodeAndDecode(t *testing.T) {
+	register, err := NewActionInput(common.Address{}, ActionRegister, &RegisterParams{Name: "bitcoin", Symbol: "BTC", Total: big.NewInt(21), Decimals: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue, err := NewActionInput(assertID, ActionIssue, &IssueParams{Value: big.NewInt(5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := NewTransaction(0, 2000, big.NewInt(1), nil)
+	tx.WithInput(register, amInput, AMInput{AssertID: &assertID}, issue)
+
+	bytes, _ := tx.EncodeRLP()
+	newTx := &Transaction{}
+	if err := newTx.DecodeRLP(bytes); err != nil {
+		t.Fatal(err)
+	}
+	actions, err := newTx.Actions()
+	if err == nil {
+		t.Fatalf("opaque payload decoded as action")
+	}
+	newTx.WithInput(register, AMInput{AssertID: &assertID}, issue)
+	if actions, err = newTx.Actions(); err != nil {
+		t.Fatal(err)
+	}
+	common.AssertEquals(t, len(actions), 2)
+	common.AssertEquals(t, actions[0].Type, ActionRegister)
+	common.AssertEquals(t, actions[1].AssetID, assertID)
+
+	params, err := actions[0].DecodeParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	common.AssertEquals(t, params.(*RegisterParams).Symbol, "BTC")
+	common.AssertEquals(t, params.(*RegisterParams).Total, big.NewInt(21))
+	params, err = actions[1].DecodeParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	common.AssertEquals(t, params.(*IssueParams).Value, big.NewInt(5))
+
+	// unknown versions and types are rejected
+	payload, _ := rlp.EncodeToBytes(&Action{Version: ActionVersion + 1, Type: ActionIssue})
+	if _, err := DecodeAction(payload); err != ErrActionVersion {
+		t.Fatalf("version error mismatch: have %v, want %v", err, ErrActionVersion)
+	}
+	if _, err := (&Action{Version: ActionVersion, Type: 0xff}).DecodeParams(); err != ErrActionType {
+		t.Fatalf("type error mismatch: have %v, want %v", err, ErrActionType)
+	}
+}
+
+func TestEffectiveTip(t *testing.T) {
+	tests := []struct {
+		price, tip int64
+		baseFee    *big.Int
+		wantTip    int64
+		wantPrice  int64
+	}{
+		{10, 10, nil, 10, 10},            // no base fee, the whole gas price
+		{10, 3, nil, 10, 10},             // the tip only applies with a base fee
+		{10, 3, big.NewInt(5), 3, 8},     // the tip on top of the base fee
+		{10, 10, big.NewInt(5), 5, 10},   // capped by the gas price
+		{10, 10, big.NewInt(12), -2, 10}, // not covering the base fee
+	}
+	for i, tt := range tests {
+		tx := NewTransaction(0, 21000, big.NewInt(tt.price), nil)
+		tx.WithTip(big.NewInt(tt.tip))
+		if tip := tx.EffectiveTip(tt.baseFee); tip.Int64() != tt.wantTip {
+			t.Errorf("test %d: effective tip mismatch: have %v, want %d", i, tip, tt.wantTip)
+		}
+		if price := tx.EffectivePrice(tt.baseFee); price.Int64() != tt.wantPrice {
+			t.Errorf("test %d: effective price mismatch: have %v, want %d", i, price, tt.wantPrice)
+		}
+	}
+	// legacy transactions tip their whole gas price
+	if tip := NewTransaction(0, 21000, big.NewInt(7), nil).Tip(); tip.Int64() != 7 {
+		t.Errorf("legacy tip mismatch: have %v, want 7", tip)
+	}
+}
+
+func TestTipEncoding(t *testing.T) {
+	baseFee := big.NewInt(5)
+	for i, tip := range []*big.Int{nil, big.NewInt(0), big.NewInt(3)} {
+		tx := NewTransaction(0, 21000, big.NewInt(10), nil)
+		if tip != nil {
+			tx.WithTip(tip)
+		}
+		bytes, _ := tx.EncodeRLP()
+		newTx := &Transaction{}
+		if err := newTx.DecodeRLP(bytes); err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if have, want := newTx.EffectivePrice(baseFee), tx.EffectivePrice(baseFee); have.Cmp(want) != 0 {
+			t.Errorf("test %d: effective price changed: have %v, want %v", i, have, want)
+		}
+		if have, want := newTx.Tip(), tx.Tip(); have.Cmp(want) != 0 {
+			t.Errorf("test %d: tip changed: have %v, want %v", i, have, want)
+		}
+		if newTx.Hash() != tx.Hash() {
+			t.Errorf("test %d: hash changed", i)
+		}
+	}
+}
@@ -22,6 +22,8 @@ import (
 	"fmt"
 	"math/big"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/zipper-project/z0/common"
 	"github.com/zipper-project/z0/crypto"
 )
@@ -40,6 +42,13 @@ type sigCache struct {
 	from   common.Address
 }
 
+// cosigCache is used to cache the derived co-signers and contains the signer
+// used to derive them.
+type cosigCache struct {
+	signer    Signer
+	cosigners []common.Address
+}
+
 // MakeSigner returns a Signer based on the given chainID .
 func MakeSigner(chainID *big.Int) Signer {
 	return NewSigner(chainID)
@@ -55,9 +64,130 @@ func SignTx(tx *Transaction, s Signer, prv *ecdsa.PrivateKey) (*Transaction, err
 	return tx.WithSignature(s, sig)
 }
 
-// Sender returns the address derived from the signature (V, R, S) using secp256k1
-// elliptic curve and an error if it failed deriving or upon an incorrect
-// signature.
+// SignTxWithKey signs the transaction with a private key of any registered
+// signature scheme. Schemes without public key recovery put the public key
+// into the transaction.
+func SignTxWithKey(tx *Transaction, s Signer, key crypto.SchemeKey) (*Transaction, error) {
+	h := s.Hash(tx)
+	sig, err := key.Sign(h[:])
+	if err != nil {
+		return nil, err
+	}
+	if key.Scheme() == crypto.Secp256k1 {
+		return tx.WithSignature(s, sig)
+	}
+	// V only carries the chain id, like a signature with recovery id zero
+	cpy, err := tx.WithSignature(s, append(sig, 0))
+	if err != nil {
+		return nil, err
+	}
+	cpy.Data.Scheme, cpy.Data.PubKey = uint8(key.Scheme()), key.PublicKey()
+	return cpy, nil
+}
+
+// CoSignTx adds a co-signature of the given private key to the transaction.
+// Co-signers sign the same hash as the sender, so the order of signing doesn't
+// matter.
+func CoSignTx(tx *Transaction, s Signer, prv *ecdsa.PrivateKey) (*Transaction, error) {
+	h := s.Hash(tx)
+	sig, err := crypto.Sign(h[:], prv)
+	if err != nil {
+		return nil, err
+	}
+	cpy := &Transaction{Data: tx.Data}
+	cpy.Data.Signatures = append(append([]hexutil.Bytes{}, tx.Data.Signatures...), sig)
+	return cpy, nil
+}
+
+// CoSignTxWithKey adds a co-signature of a private key of any registered
+// signature scheme. Secp256k1 co-signatures are the 65 byte recoverable
+// signatures of CoSignTx, the others are encoded as scheme || pubkey || sig.
+func CoSignTxWithKey(tx *Transaction, s Signer, key crypto.SchemeKey) (*Transaction, error) {
+	h := s.Hash(tx)
+	sig, err := key.Sign(h[:])
+	if err != nil {
+		return nil, err
+	}
+	if key.Scheme() != crypto.Secp256k1 {
+		sig = append(append([]byte{byte(key.Scheme())}, key.PublicKey()...), sig...)
+	}
+	cpy := &Transaction{Data: tx.Data}
+	cpy.Data.Signatures = append(append([]hexutil.Bytes{}, tx.Data.Signatures...), sig)
+	return cpy, nil
+}
+
+// CoSigners returns the addresses recovered from the co-signatures of the
+// transaction, an error is returned if any of them is invalid.
+//
+// CoSigners caches the addresses like Sender caches the sender.
+func CoSigners(signer Signer, tx *Transaction) ([]common.Address, error) {
+	if len(tx.Data.Signatures) == 0 {
+		return nil, nil
+	}
+	if sc := tx.cosigners.Load(); sc != nil {
+		cache := sc.(cosigCache)
+		if cache.signer.Equal(signer) {
+			return append([]common.Address{}, cache.cosigners...), nil
+		}
+	}
+	addrs, err := recoverCoSigners(signer, tx)
+	if err != nil {
+		return nil, err
+	}
+	tx.cosigners.Store(cosigCache{signer: signer, cosigners: addrs})
+	return append([]common.Address{}, addrs...), nil
+}
+
+func recoverCoSigners(signer Signer, tx *Transaction) ([]common.Address, error) {
+	h := signer.Hash(tx)
+	addrs := make([]common.Address, 0, len(tx.Data.Signatures))
+	for _, sig := range tx.Data.Signatures {
+		var (
+			addr common.Address
+			err  error
+		)
+		switch {
+		case len(sig) == 65:
+			r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:64])
+			addr, err = recoverPlain(h, r, s, new(big.Int).SetUint64(uint64(sig[64])+27), true)
+		case len(sig) > 65:
+			addr, err = verifyPlain(h, crypto.SigScheme(sig[0]), sig[1:len(sig)-64], sig[len(sig)-64:])
+		default:
+			err = ErrInvalidSig
+		}
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// Signers returns the sender followed by the distinct co-signers of the
+// transaction.
+func Signers(signer Signer, tx *Transaction) ([]common.Address, error) {
+	from, err := Sender(signer, tx)
+	if err != nil {
+		return nil, err
+	}
+	cosigners, err := CoSigners(signer, tx)
+	if err != nil {
+		return nil, err
+	}
+	signers := []common.Address{from}
+	seen := map[common.Address]bool{from: true}
+	for _, addr := range cosigners {
+		if !seen[addr] {
+			seen[addr] = true
+			signers = append(signers, addr)
+		}
+	}
+	return signers, nil
+}
+
+// Sender returns the address derived from the signature (V, R, S) using the
+// signature scheme of the transaction and an error if it failed deriving or
+// upon an incorrect signature.
 //
 // Sender may cache the address, allowing it to be used regardless of
 // signing method. The cache is invalidated if the cached signer does
@@ -103,7 +233,10 @@ func (s Signer) Equal(s2 Signer) bool {
 	return s2.chainID.Cmp(s.chainID) == 0
 }
 
-var big8 = big.NewInt(8)
+var (
+	big8  = big.NewInt(8)
+	big35 = big.NewInt(35)
+)
 
 // Sender return transaction sender
 func (s Signer) Sender(tx *Transaction) (common.Address, error) {
@@ -113,6 +246,17 @@ func (s Signer) Sender(tx *Transaction) (common.Address, error) {
 	if tx.ChainID().Cmp(s.chainID) != 0 {
 		return common.Address{}, ErrInvalidchainID
 	}
+	if scheme := tx.Scheme(); scheme != crypto.Secp256k1 {
+		// V can't vary without recovery, or the signature would be malleable
+		if new(big.Int).Sub(tx.Data.V, s.chainIDMul).Cmp(big35) != 0 {
+			return common.Address{}, ErrInvalidSig
+		}
+		if tx.Data.R.BitLen() > 256 || tx.Data.S.BitLen() > 256 {
+			return common.Address{}, ErrInvalidSig
+		}
+		sig := append(math.PaddedBigBytes(tx.Data.R, 32), math.PaddedBigBytes(tx.Data.S, 32)...)
+		return verifyPlain(s.Hash(tx), scheme, tx.Data.PubKey, sig)
+	}
 	V := new(big.Int).Sub(tx.Data.V, s.chainIDMul)
 	V.Sub(V, big8)
 	return recoverPlain(s.Hash(tx), tx.Data.R, tx.Data.S, V, true)
@@ -135,20 +279,55 @@ func (s Signer) SignatureValues(tx *Transaction, sig []byte) (R, S, V *big.Int,
 	return R, S, V, nil
 }
 
+// sigdata is the part of a transaction signed by the sender. Like in txdata,
+// fields added later are optional so the signing hash of transactions not
+// using them stays the same.
+type sigdata struct {
+	Nonce      uint64
+	Price      *big.Int
+	GasLimit   uint64
+	Inputs     []interface{}
+	Outputs    []interface{}
+	Extra      []byte
+	ChainID    *big.Int
+	R, S       uint
+	FeeAsset   common.Address `rlp:"optional"`
+	ValidAfter uint64         `rlp:"optional"`
+	ValidUntil uint64         `rlp:"optional"`
+	Tip        *big.Int       `rlp:"optional"`
+}
+
 // Hash returns the hash to be signed by the sender.
 // It does not uniquely identify the transaction.
 func (s Signer) Hash(tx *Transaction) common.Hash {
-	return rlpHash([]interface{}{
-		tx.Data.Nonce,
-		tx.Data.Price,
-		tx.Data.GasLimit,
-		tx.Data.Inputs,
-		tx.Data.Outputs,
-		tx.Data.Extra,
-		s.chainID, uint(0), uint(0),
+	return rlpHash(&sigdata{
+		Nonce:      tx.Data.Nonce,
+		Price:      tx.Data.Price,
+		GasLimit:   tx.Data.GasLimit,
+		Inputs:     tx.Data.Inputs,
+		Outputs:    tx.Data.Outputs,
+		Extra:      tx.Data.Extra,
+		ChainID:    s.chainID,
+		FeeAsset:   tx.Data.FeeAsset,
+		ValidAfter: tx.Data.ValidAfter,
+		ValidUntil: tx.Data.ValidUntil,
+		Tip:        tx.Data.Tip,
 	})
 }
 
+// verifyPlain checks the 64 byte signature of a scheme without public key
+// recovery and derives the address of the public key.
+func verifyPlain(sighash common.Hash, id crypto.SigScheme, pub, sig []byte) (common.Address, error) {
+	scheme, err := crypto.LookupScheme(id)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if !scheme.Verify(pub, sighash[:], sig) {
+		return common.Address{}, ErrInvalidSig
+	}
+	return scheme.Address(pub)
+}
+
 func recoverPlain(sighash common.Hash, R, S, Vb *big.Int, homestead bool) (common.Address, error) {
 	if Vb.BitLen() > 8 {
 		return common.Address{}, ErrInvalidSig
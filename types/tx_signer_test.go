@@ -17,10 +17,14 @@
 package types
 
 import (
+	"bytes"
+	"crypto/ecdsa"
 	"math/big"
 	"testing"
 
+	"github.com/zipper-project/z0/common"
 	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/utils/rlp"
 )
 
 func TestSigning(t *testing.T) {
@@ -59,3 +63,237 @@ func TestChainID(t *testing.T) {
 		t.Error("expected chainId to be", signer.chainID, "got", tx.ChainID())
 	}
 }
+
+func TestCoSigning(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	key1, _ := crypto.GenerateKey()
+	key2, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	addr1 := crypto.PubkeyToAddress(key1.PublicKey)
+	addr2 := crypto.PubkeyToAddress(key2.PublicKey)
+
+	signer := NewSigner(big.NewInt(18))
+	tx, err := CoSignTx(NewTransaction(0, 0, new(big.Int), nil), signer, key1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the sender may sign in between co-signers
+	if tx, err = SignTx(tx, signer, key); err != nil {
+		t.Fatal(err)
+	}
+	if tx, err = CoSignTx(tx, signer, key2); err != nil {
+		t.Fatal(err)
+	}
+	if tx, err = CoSignTx(tx, signer, key1); err != nil {
+		t.Fatal(err)
+	}
+
+	// co-signatures survive encoding
+	enc, _ := tx.EncodeRLP()
+	dec := new(Transaction)
+	if err := dec.DecodeRLP(enc); err != nil {
+		t.Fatal(err)
+	}
+	signers, err := Signers(signer, dec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signers) != 3 || signers[0] != addr || signers[1] != addr1 || signers[2] != addr2 {
+		t.Errorf("signers mismatch: got %x", signers)
+	}
+
+	// a co-signature for another chain doesn't verify
+	if tx, err = CoSignTx(tx, NewSigner(big.NewInt(19)), key2); err != nil {
+		t.Fatal(err)
+	}
+	cosigners, _ := CoSigners(signer, tx)
+	if cosigners[3] == addr2 {
+		t.Errorf("co-signature of another chain recovered the co-signer")
+	}
+
+	// transactions without co-signers don't encode the optional fields
+	plain, _ := SignTx(NewTransaction(0, 0, new(big.Int), nil), signer, key)
+	enc, _ = plain.EncodeRLP()
+	legacy, _ := rlp.EncodeToBytes([]interface{}{plain.Data.Nonce, plain.Data.Price, plain.Data.GasLimit, plain.Data.Inputs, plain.Data.Outputs, plain.Data.Extra, plain.Data.V, plain.Data.R, plain.Data.S})
+	if !bytes.Equal(enc, legacy) {
+		t.Errorf("encoding changed for transaction without co-signers")
+	}
+}
+
+func TestLegacySigningHash(t *testing.T) {
+	signer := NewSigner(big.NewInt(18))
+	tx := NewTransaction(3, 21000, big.NewInt(5), []byte{1, 2})
+	tx.WithInput(&UTXOInput{})
+
+	// transactions using none of the optional fields sign what they did
+	// before those were added
+	legacy := rlpHash([]interface{}{tx.Data.Nonce, tx.Data.Price, tx.Data.GasLimit, tx.Data.Inputs, tx.Data.Outputs, tx.Data.Extra, signer.chainID, uint(0), uint(0)})
+	if h := signer.Hash(tx); h != legacy {
+		t.Errorf("signing hash mismatch: have %x, want %x", h, legacy)
+	}
+	tx.WithFeeAsset(common.HexToAddress("0x1"))
+	if signer.Hash(tx) == legacy {
+		t.Errorf("signing hash doesn't cover the fee asset")
+	}
+}
+
+func TestPartialTx(t *testing.T) {
+	var (
+		signer    = NewSigner(big.NewInt(18))
+		key, _    = crypto.GenerateKey()
+		cokey1, _ = crypto.GenerateKey()
+		cokey2, _ = crypto.GenerateKey()
+		from      = crypto.PubkeyToAddress(key.PublicKey)
+		co1       = crypto.PubkeyToAddress(cokey1.PublicKey)
+		co2       = crypto.PubkeyToAddress(cokey2.PublicKey)
+	)
+	keys := []PolicyKey{{Address: from}, {Address: co1}, {Address: co2, Weight: 2}}
+	ptx := NewPartialTx(NewTransaction(0, 21000, big.NewInt(1), nil), from, keys, 3)
+
+	// Every signer decodes the transaction, signs and passes it on
+	pass := func(ptx *PartialTx, prv *ecdsa.PrivateKey) *PartialTx {
+		enc, err := ptx.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec, err := DecodePartialTx(enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dec.Sign(signer, prv); err != nil {
+			t.Fatal(err)
+		}
+		return dec
+	}
+	ptx = pass(ptx, cokey2)
+	if complete, _ := ptx.Complete(signer); complete {
+		t.Fatalf("complete without the account key")
+	}
+	ptx = pass(ptx, key)
+	if complete, err := ptx.Complete(signer); err != nil || !complete {
+		t.Fatalf("incomplete after reaching the threshold: %v", err)
+	}
+	if missing, _ := ptx.Missing(signer); len(missing) != 1 || missing[0] != co1 {
+		t.Errorf("missing keys mismatch: %x", missing)
+	}
+	if sender, err := Sender(signer, ptx.Tx); err != nil || sender != from {
+		t.Errorf("sender mismatch: have %x, want %x (%v)", sender, from, err)
+	}
+	if signers, _ := Signers(signer, ptx.Tx); len(signers) != 2 || signers[1] != co2 {
+		t.Errorf("signers mismatch: %x", signers)
+	}
+
+	outsider, _ := crypto.GenerateKey()
+	if err := ptx.Sign(signer, outsider); err != ErrNotAccountKey {
+		t.Errorf("outsider error mismatch: have %v, want %v", err, ErrNotAccountKey)
+	}
+}
+
+func TestSchemeSigning(t *testing.T) {
+	signer := NewSigner(big.NewInt(18))
+	for _, id := range []crypto.SigScheme{crypto.Secp256k1, crypto.Secp256r1, crypto.Ed25519} {
+		key, _ := crypto.GenerateSchemeKey(id)
+		cokey, _ := crypto.GenerateSchemeKey(crypto.Ed25519)
+		addr, _ := crypto.KeyAddress(key)
+		coaddr, _ := crypto.KeyAddress(cokey)
+
+		tx, err := SignTxWithKey(NewTransaction(0, 0, new(big.Int), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tx, err = CoSignTxWithKey(tx, signer, cokey); err != nil {
+			t.Fatal(err)
+		}
+		if tx.Scheme() != id || tx.ChainID().Cmp(signer.chainID) != 0 {
+			t.Fatalf("%v: scheme %v chain %v", id, tx.Scheme(), tx.ChainID())
+		}
+
+		// the scheme and public key survive the encoding
+		enc, _ := tx.EncodeRLP()
+		dec := new(Transaction)
+		if err := dec.DecodeRLP(enc); err != nil {
+			t.Fatal(err)
+		}
+		signers, err := Signers(signer, dec)
+		if err != nil {
+			t.Fatalf("%v: %v", id, err)
+		}
+		if len(signers) != 2 || signers[0] != addr || signers[1] != coaddr {
+			t.Errorf("%v: signers mismatch: got %x want %x %x", id, signers, addr, coaddr)
+		}
+		if _, err := Sender(NewSigner(big.NewInt(19)), dec); err != ErrInvalidchainID {
+			t.Errorf("%v: expected %v for another chain, got %v", id, ErrInvalidchainID, err)
+		}
+	}
+
+	// a signature doesn't verify under another scheme or public key
+	key, _ := crypto.GenerateSchemeKey(crypto.Ed25519)
+	other, _ := crypto.GenerateSchemeKey(crypto.Ed25519)
+	tx, _ := SignTxWithKey(NewTransaction(0, 0, new(big.Int), nil), signer, key)
+	forged := &Transaction{Data: tx.Data}
+	forged.Data.PubKey = other.PublicKey()
+	if _, err := Sender(signer, forged); err != ErrInvalidSig {
+		t.Errorf("expected %v for a foreign public key, got %v", ErrInvalidSig, err)
+	}
+	forged = &Transaction{Data: tx.Data}
+	forged.Data.Scheme = uint8(crypto.Secp256r1)
+	if _, err := Sender(signer, forged); err != ErrInvalidSig {
+		t.Errorf("expected %v for a foreign scheme, got %v", ErrInvalidSig, err)
+	}
+	forged = &Transaction{Data: tx.Data}
+	forged.Data.V = new(big.Int).Add(tx.Data.V, big.NewInt(1))
+	if _, err := Sender(signer, forged); err != ErrInvalidSig {
+		t.Errorf("expected %v for a varied V, got %v", ErrInvalidSig, err)
+	}
+	forged = &Transaction{Data: tx.Data}
+	forged.Data.Scheme = 9
+	if _, err := Sender(signer, forged); err != crypto.ErrUnknownScheme {
+		t.Errorf("expected %v, got %v", crypto.ErrUnknownScheme, err)
+	}
+}
+
+func TestValidityWindow(t *testing.T) {
+	tm := uint64(ValidityTimeThreshold) + 1000
+	tests := []struct {
+		after, until uint64
+		number, time uint64
+		early, late  bool
+	}{
+		{0, 0, 1, 0, false, false},
+		{0, 0, 1 << 40, tm << 8, false, false},
+		// block number bounds
+		{5, 0, 5, tm, true, false},
+		{5, 0, 6, tm, false, false},
+		{0, 10, 10, tm, false, false},
+		{0, 10, 11, 0, false, true},
+		{5, 10, 8, tm * 2, false, false},
+		// timestamp bounds
+		{tm, 0, 1 << 20, tm, true, false},
+		{tm, 0, 1, tm + 1, false, false},
+		{0, tm, 1 << 20, tm, false, false},
+		{0, tm, 1, tm + 1, false, true},
+	}
+	signer := NewSigner(big.NewInt(18))
+	key, _ := crypto.GenerateKey()
+	for i, tt := range tests {
+		tx := NewTransaction(0, 0, new(big.Int), nil)
+		tx.WithValidity(tt.after, tt.until)
+		if early := tx.NotYetValid(tt.number, tt.time); early != tt.early {
+			t.Errorf("test %d: not yet valid mismatch: have %v, want %v", i, early, tt.early)
+		}
+		if late := tx.Expired(tt.number, tt.time); late != tt.late {
+			t.Errorf("test %d: expired mismatch: have %v, want %v", i, late, tt.late)
+		}
+	}
+
+	// the window is part of the signed data
+	tx := NewTransaction(0, 0, new(big.Int), nil)
+	tx.WithValidity(0, 10)
+	signed, _ := SignTx(tx, signer, key)
+	extended := &Transaction{Data: signed.Data}
+	extended.Data.ValidUntil = 20
+	from, _ := Sender(signer, signed)
+	if other, err := Sender(signer, extended); err == nil && other == from {
+		t.Errorf("extended window kept the sender")
+	}
+}
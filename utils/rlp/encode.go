@@ -530,8 +530,13 @@ func makeStructWriter(typ reflect.Type) (writer, error) {
 		return nil, err
 	}
 	writer := func(val reflect.Value, w *encbuf) error {
+		// Trailing optional fields are left out while zero
+		last := len(fields)
+		for last > 0 && fields[last-1].optional && val.Field(fields[last-1].index).IsZero() {
+			last--
+		}
 		lh := w.list()
-		for _, f := range fields {
+		for _, f := range fields[:last] {
 			if err := f.info.writer(val.Field(f.index), w); err != nil {
 				return err
 			}
@@ -17,14 +17,22 @@
 package zcnd
 
 import (
+	"path/filepath"
 	"time"
 
 	"github.com/zipper-project/z0/core"
+	"github.com/zipper-project/z0/miner"
 	"github.com/zipper-project/z0/txpool"
+	"github.com/zipper-project/z0/zcnd/gasprice"
 )
 
 // Config zcnd config
 type Config struct {
+	// Name of the ledger among the ledgers hosted by the node. It selects the
+	// RPC APIs and the directory of the databases of the ledger, the single
+	// ledger of a node has no name.
+	Name string `toml:",omitempty"`
+
 	// The genesis block, which is inserted if the database is empty.
 	// If nil, the main net block is used.
 	Genesis *core.Genesis `toml:",omitempty"`
@@ -40,4 +48,36 @@ type Config struct {
 
 	// Transaction pool options
 	TxPool *txpool.Config
+
+	// Block producer options
+	Miner *miner.Config
+
+	// Gas price oracle options
+	GPO gasprice.Config
+}
+
+// Copy returns a copy of the config for a hosted ledger to override. The
+// genesis isn't copied, every ledger is a chain of its own.
+func (c *Config) Copy() *Config {
+	cpy := *c
+	cpy.Name, cpy.Genesis = "", nil
+	if c.TxPool != nil {
+		txpool := *c.TxPool
+		cpy.TxPool = &txpool
+	}
+	if c.Miner != nil {
+		miner := *c.Miner
+		cpy.Miner = &miner
+	}
+	return &cpy
+}
+
+// LedgerPath returns the path of a file or database of the ledger relative to
+// the data directory. The files of a named ledger live in its own directory,
+// absolute paths are kept.
+func LedgerPath(ledger, name string) string {
+	if ledger == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join("ledgers", ledger, name)
 }
@@ -0,0 +1,129 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package gasprice suggests the fees of new transactions from the tips paid
+// in the recent blocks.
+package gasprice
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core"
+	"github.com/zipper-project/z0/params"
+	"github.com/zipper-project/z0/types"
+)
+
+// Config are the settings of the gas price oracle.
+type Config struct {
+	Blocks     int    // Number of recent blocks sampled
+	Percentile int    // Percentile of the sampled tips suggested
+	Default    uint64 // Tip suggested while the sampled blocks carry no transactions
+}
+
+// DefaultConfig are the oracle settings of nodes not configuring their own.
+var DefaultConfig = Config{
+	Blocks:     20,
+	Percentile: 60,
+	Default:    1,
+}
+
+// blockChain provides the recent blocks the oracle samples.
+type blockChain interface {
+	CurrentBlock() *types.Block
+	GetBlockByNumber(number uint64) *types.Block
+	Config() *params.ChainConfig
+}
+
+// Oracle suggests the tip and gas price of new transactions in ZIP per gas,
+// following the tips the recent blocks paid their miners. Only transactions
+// paying their fees in ZIP are sampled, the fee rates of other assets are
+// part of the state rather than the blocks.
+type Oracle struct {
+	chain  blockChain
+	config Config
+
+	mu       sync.Mutex
+	lastHead common.Hash
+	lastTip  *big.Int
+}
+
+// NewOracle creates an oracle sampling the blocks of the chain, zero settings
+// take their default.
+func NewOracle(chain blockChain, config Config) *Oracle {
+	if config.Blocks <= 0 {
+		config.Blocks = DefaultConfig.Blocks
+	}
+	if config.Percentile <= 0 {
+		config.Percentile = DefaultConfig.Percentile
+	}
+	if config.Percentile > 100 {
+		config.Percentile = 100
+	}
+	return &Oracle{chain: chain, config: config}
+}
+
+// SuggestTip returns the tip per gas likely to get a transaction into one of
+// the next blocks, the percentile of the tips paid in the recent blocks. The
+// suggestion is cached until the chain head changes.
+func (o *Oracle) SuggestTip() *big.Int {
+	head := o.chain.CurrentBlock()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.lastTip != nil && o.lastHead == head.Hash() {
+		return new(big.Int).Set(o.lastTip)
+	}
+	var tips []*big.Int
+	for block, n := head, 0; block != nil && n < o.config.Blocks; n++ {
+		baseFee := block.BaseFee()
+		for _, tx := range block.Transactions() {
+			if tx.FeeAsset() != types.ZipAssetID {
+				continue
+			}
+			if tip := tx.EffectiveTip(baseFee); tip.Sign() >= 0 {
+				tips = append(tips, tip)
+			}
+		}
+		if block.NumberU64() == 0 {
+			break
+		}
+		block = o.chain.GetBlockByNumber(block.NumberU64() - 1)
+	}
+	tip := new(big.Int).SetUint64(o.config.Default)
+	if len(tips) > 0 {
+		sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+		tip = tips[(len(tips)-1)*o.config.Percentile/100]
+	}
+	o.lastHead, o.lastTip = head.Hash(), tip
+	return new(big.Int).Set(tip)
+}
+
+// SuggestFees returns the suggested tip and a gas price covering it on top of
+// twice the base fee of the next block, which keeps the transaction
+// includable while the base fee rises for a few blocks. Before the base fee
+// fork the gas price is the tip.
+func (o *Oracle) SuggestFees() (tip, gasPrice *big.Int) {
+	tip = o.SuggestTip()
+	baseFee := core.CalcBaseFee(o.chain.Config(), o.chain.CurrentBlock().Header())
+	if baseFee == nil {
+		return tip, new(big.Int).Set(tip)
+	}
+	return tip, baseFee.Add(baseFee.Lsh(baseFee, 1), tip)
+}
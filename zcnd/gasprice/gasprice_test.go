@@ -0,0 +1,90 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/params"
+	"github.com/zipper-project/z0/types"
+)
+
+type testChain struct {
+	config *params.ChainConfig
+	blocks []*types.Block
+}
+
+func (c *testChain) CurrentBlock() *types.Block { return c.blocks[len(c.blocks)-1] }
+
+func (c *testChain) GetBlockByNumber(number uint64) *types.Block {
+	if number >= uint64(len(c.blocks)) {
+		return nil
+	}
+	return c.blocks[number]
+}
+
+func (c *testChain) Config() *params.ChainConfig { return c.config }
+
+// add appends a block of the base fee carrying transactions of the tips.
+func (c *testChain) add(baseFee int64, gasUsed uint64, tips ...int64) {
+	header := &types.Header{Number: big.NewInt(int64(len(c.blocks))), GasLimit: 1000000, GasUsed: gasUsed}
+	if baseFee != 0 {
+		header.BaseFee = big.NewInt(baseFee)
+	}
+	var txs []*types.Transaction
+	for _, tip := range tips {
+		tx := types.NewTransaction(0, 21000, big.NewInt(baseFee+tip+100), nil)
+		tx.WithTip(big.NewInt(tip))
+		txs = append(txs, tx)
+	}
+	// transactions paying in other assets aren't sampled
+	other := types.NewTransaction(0, 21000, big.NewInt(1000000), nil)
+	other.WithFeeAsset(common.Address{0x42})
+	txs = append(txs, other)
+
+	c.blocks = append(c.blocks, types.NewBlock(header, txs, nil, nil))
+}
+
+func TestSuggestFees(t *testing.T) {
+	chain := &testChain{config: &params.ChainConfig{BaseFeeBlock: big.NewInt(0)}}
+	chain.add(0, 0)
+	oracle := NewOracle(chain, Config{Blocks: 2, Percentile: 50, Default: 7})
+
+	// Without transactions the default tip is suggested
+	if tip, price := oracle.SuggestFees(); tip.Int64() != 7 || price.Int64() != 2*params.InitialBaseFee+7 {
+		t.Fatalf("default fees mismatch: tip %v price %v", tip, price)
+	}
+	// Only the blocks sampled count, the oldest one is left out
+	chain.add(100, 500000, 50, 50, 50)
+	chain.add(100, 500000, 1, 2, 3)
+	chain.add(100, 1000000, 4, 5, 6, 7)
+	if tip := oracle.SuggestTip(); tip.Int64() != 4 {
+		t.Fatalf("suggested tip mismatch: have %v, want 4", tip)
+	}
+	// The gas price covers twice the next base fee, 112 after the full block
+	if tip, price := oracle.SuggestFees(); tip.Int64() != 4 || price.Int64() != 2*112+4 {
+		t.Fatalf("suggested fees mismatch: tip %v price %v", tip, price)
+	}
+	// Before the fork the gas price is the tip
+	legacy := &testChain{config: params.DefaultChainconfig}
+	legacy.add(0, 0, 9)
+	if tip, price := NewOracle(legacy, Config{}).SuggestFees(); tip.Int64() != 109 || price.Int64() != 109 {
+		t.Fatalf("legacy fees mismatch: tip %v price %v", tip, price)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2018 The zipper team Authors
+// This file is part of the z0 library.
+//
+// The z0 library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The z0 library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
+
+package zcnd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/zipper-project/z0/node"
+	"github.com/zipper-project/z0/rpc"
+)
+
+var (
+	// ErrNoLedgers is returned if a node is set up to host no ledger.
+	ErrNoLedgers = errors.New("no ledgers configured")
+
+	ledgerName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+)
+
+// Ledgers hosts several independent ledgers in one node. Every ledger has its
+// own genesis, chain database, transaction pool and block producer.
+type Ledgers struct {
+	names   []string
+	ledgers map[string]*Zcnd
+}
+
+// NewLedgers creates the ledgers of the configs. Ledgers need distinct names,
+// which are used as directory names, and distinct chain ids.
+func NewLedgers(ctx *node.ServiceContext, configs []*Config) (*Ledgers, error) {
+	if len(configs) == 0 {
+		return nil, ErrNoLedgers
+	}
+	l := &Ledgers{ledgers: make(map[string]*Zcnd)}
+	chains := make(map[string]string)
+	for _, config := range configs {
+		if !ledgerName.MatchString(config.Name) {
+			l.Stop()
+			return nil, fmt.Errorf("invalid ledger name %q", config.Name)
+		}
+		if _, ok := l.ledgers[config.Name]; ok {
+			l.Stop()
+			return nil, fmt.Errorf("ledger %q configured twice", config.Name)
+		}
+		z, err := New(ctx, config)
+		if err != nil {
+			l.Stop()
+			return nil, fmt.Errorf("ledger %q: %v", config.Name, err)
+		}
+		l.names = append(l.names, config.Name)
+		l.ledgers[config.Name] = z
+
+		chainID := z.ChainConfig().ChainID.String()
+		if other, ok := chains[chainID]; ok {
+			l.Stop()
+			return nil, fmt.Errorf("ledgers %q and %q share chain id %s", other, config.Name, chainID)
+		}
+		chains[chainID] = config.Name
+	}
+	return l, nil
+}
+
+// Names returns the names of the ledgers in configuration order.
+func (l *Ledgers) Names() []string {
+	return append([]string{}, l.names...)
+}
+
+// Ledger returns the ledger of the name, nil if the node doesn't host it.
+func (l *Ledgers) Ledger(name string) *Zcnd {
+	return l.ledgers[name]
+}
+
+// APIs implements node.Service, returning the APIs of all ledgers tagged with
+// the ledger they serve.
+func (l *Ledgers) APIs() []rpc.API {
+	var apis []rpc.API
+	for _, name := range l.names {
+		for _, api := range l.ledgers[name].APIs() {
+			api.Ledger = name
+			apis = append(apis, api)
+		}
+	}
+	return apis
+}
+
+// Start implements node.Service, starting the ledgers. The ledgers already
+// started are stopped if one fails.
+func (l *Ledgers) Start() error {
+	for i, name := range l.names {
+		log.Info("Starting ledger", "name", name, "chainid", l.ledgers[name].ChainConfig().ChainID)
+		if err := l.ledgers[name].Start(); err != nil {
+			for _, started := range l.names[:i] {
+				l.ledgers[started].Stop()
+			}
+			return fmt.Errorf("ledger %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// Stop implements node.Service, stopping all ledgers.
+func (l *Ledgers) Stop() error {
+	for _, name := range l.names {
+		l.ledgers[name].Stop()
+	}
+	return nil
+}
@@ -18,28 +18,46 @@ package zcnd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/zipper-project/z0/consensus"
+	"github.com/zipper-project/z0/consensus/bft"
+	"github.com/zipper-project/z0/consensus/poa"
 	"github.com/zipper-project/z0/core"
 	"github.com/zipper-project/z0/core/vm"
+	"github.com/zipper-project/z0/feed"
+	"github.com/zipper-project/z0/miner"
 	"github.com/zipper-project/z0/node"
 	"github.com/zipper-project/z0/params"
 	"github.com/zipper-project/z0/rawdb"
 	"github.com/zipper-project/z0/rpc"
 	"github.com/zipper-project/z0/txpool"
 	"github.com/zipper-project/z0/utils/zdb"
+	"github.com/zipper-project/z0/zcnd/gasprice"
 )
 
+// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
+const chainHeadChanSize = 10
+
+// errBFTValidators is returned for a BFT chain of several validators, whose
+// consensus messages the node can't deliver yet.
+var errBFTValidators = errors.New("BFT consensus of several validators needs a network layer")
+
 // Zcnd implements the z0 service.
 type Zcnd struct {
 	config       *Config
 	chainConfig  *params.ChainConfig
 	shutdownChan chan bool // Channel for shutting down the service
 	blockchain   *core.BlockChain
+	engine       consensus.Engine
 	txPool       *txpool.TxPool
-	chainDb      zdb.Database // Block chain database
+	miner        *miner.Miner
+	gpo          *gasprice.Oracle
+	chainDb      zdb.Database      // Block chain database
+	headSub      feed.Subscription // Chain heads fed to a BFT engine, nil otherwise
 
 	lock sync.RWMutex // Protects the variadic fields (e.g. gas price)
 }
@@ -50,7 +68,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Zcnd, error) {
 	cfg, err := json.Marshal(config)
 	log.Info("znd config :", "config", string(cfg))
 
-	chainDb, err := CreateDB(ctx, config, "chaindata")
+	chainDb, err := CreateDB(ctx, config, LedgerPath(config.Name, "chaindata"))
 	if err != nil {
 		return nil, err
 	}
@@ -59,12 +77,22 @@ func New(ctx *node.ServiceContext, config *Config) (*Zcnd, error) {
 	if err != nil {
 		return nil, err
 	}
-	log.Info("Initialised chain configuration", "config", chainCfg)
+	log.Info("Initialised chain configuration", "ledger", config.Name, "config", chainCfg)
+
+	engine, err := CreateConsensusEngine(chainCfg, chainDb)
+	if err != nil {
+		return nil, err
+	}
+	// Without a network layer the consensus messages don't reach other nodes
+	if chainCfg.BFT != nil && len(chainCfg.BFT.Validators) > 1 {
+		return nil, errBFTValidators
+	}
 
 	zcnd := &Zcnd{
 		config:       config,
 		chainDb:      chainDb,
 		chainConfig:  chainCfg,
+		engine:       engine,
 		shutdownChan: make(chan bool),
 	}
 
@@ -77,21 +105,27 @@ func New(ctx *node.ServiceContext, config *Config) (*Zcnd, error) {
 	}
 	cacheConfig := &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout}
 
-	// todo add consensus and vmconfig
+	// todo add vmconfig
 	//blockchain
-	zcnd.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, zcnd.chainConfig, nil, vm.Config{})
+	zcnd.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, zcnd.chainConfig, zcnd.engine, CreateConsensusEngine, vm.Config{})
 	if err != nil {
 		return nil, err
 	}
 
 	// txpool
 	if config.TxPool.Journal != "" {
-		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
+		config.TxPool.Journal = ctx.ResolvePath(LedgerPath(config.Name, config.TxPool.Journal))
 	}
 
 	// todo add blockchian
 	zcnd.txPool = txpool.New(*config.TxPool, zcnd.chainConfig, zcnd.blockchain)
 
+	// block producer
+	zcnd.miner = miner.New(config.Miner, zcnd.chainConfig, zcnd.engine, zcnd.blockchain, zcnd.txPool)
+
+	// fee suggestions
+	zcnd.gpo = gasprice.NewOracle(zcnd.blockchain, config.GPO)
+
 	return zcnd, nil
 }
 
@@ -101,17 +135,88 @@ func (z *Zcnd) APIs() []rpc.API { return nil }
 // Start implements node.Service, starting all internal goroutines.
 func (z *Zcnd) Start() error {
 	log.Info("start zcnd...")
+	if engine, ok := z.engine.(*bft.BFT); ok {
+		if err := engine.Start(z.blockchain, localBroadcaster{}); err != nil {
+			return err
+		}
+		heads := make(chan txpool.ChainHeadEvent, chainHeadChanSize)
+		z.headSub = z.blockchain.SubscribeChainHeadEvent(heads)
+		go feedChainHeads(engine, heads, z.headSub)
+	}
+	if z.config.Miner.Start {
+		z.miner.Start()
+	}
 	return nil
 }
 
 // Stop implements node.Service, terminating all internal goroutine
 func (z *Zcnd) Stop() error {
+	z.miner.Stop()
+	if engine, ok := z.engine.(*bft.BFT); ok {
+		if z.headSub != nil {
+			z.headSub.Unsubscribe()
+		}
+		engine.Stop()
+	}
 	z.txPool.Stop()
 	z.chainDb.Close()
 	close(z.shutdownChan)
 	return nil
 }
 
+// feedChainHeads lets the BFT engine move on to the next height whenever the
+// chain head changes, until the subscription ends.
+func feedChainHeads(engine *bft.BFT, heads chan txpool.ChainHeadEvent, sub feed.Subscription) {
+	for {
+		select {
+		case ev := <-heads:
+			engine.NewChainHead(ev.Block.Header())
+		case <-sub.Err():
+			return
+		}
+	}
+}
+
+// localBroadcaster stands in for the network layer the node doesn't have yet,
+// it drops the consensus messages. A single validator reaches consensus on its
+// own.
+type localBroadcaster struct{}
+
+func (localBroadcaster) Broadcast(msg *bft.Message) {}
+
+// Name returns the name of the ledger, empty for the single ledger of a node.
+func (z *Zcnd) Name() string { return z.config.Name }
+
+// ChainConfig returns the chain configuration of the ledger.
+func (z *Zcnd) ChainConfig() *params.ChainConfig { return z.chainConfig }
+
+// BlockChain returns the local chain of the service.
+func (z *Zcnd) BlockChain() *core.BlockChain { return z.blockchain }
+
+// TxPool returns the transaction pool of the service.
+func (z *Zcnd) TxPool() *txpool.TxPool { return z.txPool }
+
+// Miner returns the block producer of the service.
+func (z *Zcnd) Miner() *miner.Miner { return z.miner }
+
+// FeeOracle returns the oracle suggesting the fees of new transactions.
+func (z *Zcnd) FeeOracle() *gasprice.Oracle { return z.gpo }
+
+// CreateConsensusEngine creates the consensus engine selected by the chain
+// configuration. A configuration selecting none, like the default one, gets a
+// nil engine, its chain can't be extended.
+func CreateConsensusEngine(chainConfig *params.ChainConfig, db zdb.Database) (consensus.Engine, error) {
+	switch {
+	case chainConfig.PoA != nil && chainConfig.BFT != nil:
+		return nil, errors.New("more than one consensus engine configured in the chain config")
+	case chainConfig.PoA != nil:
+		return poa.New(chainConfig.PoA, db), nil
+	case chainConfig.BFT != nil:
+		return bft.New(chainConfig.BFT, db), nil
+	}
+	return nil, nil
+}
+
 // CreateDB creates the chain database.
 func CreateDB(ctx *node.ServiceContext, config *Config, name string) (zdb.Database, error) {
 	db, err := ctx.OpenDatabase(name, config.DatabaseCache, config.DatabaseHandles)
@@ -15,3 +15,207 @@
 // along with the z0 library. If not, see <http://www.gnu.org/licenses/>.
 
 package zcnd_test
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zipper-project/z0/common"
+	"github.com/zipper-project/z0/core"
+	"github.com/zipper-project/z0/crypto"
+	"github.com/zipper-project/z0/miner"
+	"github.com/zipper-project/z0/node"
+	"github.com/zipper-project/z0/params"
+	"github.com/zipper-project/z0/txpool"
+	"github.com/zipper-project/z0/zcnd"
+)
+
+func ledgerConfig(name string, chainID int64) *zcnd.Config {
+	genesis := core.DeveloperGenesisBlock(0, common.Address{byte(chainID)})
+	chainConfig := *genesis.Config
+	chainConfig.ChainID = big.NewInt(chainID)
+	genesis.Config = &chainConfig
+
+	return &zcnd.Config{
+		Name:    name,
+		Genesis: genesis,
+		TxPool:  &txpool.Config{PriceLimit: 1, PriceBump: 10, AccountSlots: 16, GlobalSlots: 64, AccountQueue: 16, GlobalQueue: 64, Rejournal: time.Hour, Lifetime: time.Hour},
+		Miner:   &miner.Config{},
+	}
+}
+
+func startLedgers(configs ...*zcnd.Config) (*node.Node, error) {
+	stack := node.New(&node.Config{Name: "test"})
+	err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return zcnd.NewLedgers(ctx, configs)
+	})
+	if err == nil {
+		err = stack.Start()
+	}
+	return stack, err
+}
+
+func TestLedgers(t *testing.T) {
+	stack, err := startLedgers(ledgerConfig("alpha", 100), ledgerConfig("beta", 200))
+	if err != nil {
+		t.Fatalf("failed to start ledgers: %v", err)
+	}
+	defer stack.Stop()
+
+	var ledgers *zcnd.Ledgers
+	if err := stack.Service(&ledgers); err != nil {
+		t.Fatal(err)
+	}
+	if names := ledgers.Names(); len(names) != 2 || names[0] != "alpha" || names[1] != "beta" {
+		t.Fatalf("ledger names mismatch: %v", names)
+	}
+	alpha, beta := ledgers.Ledger("alpha"), ledgers.Ledger("beta")
+	if alpha.ChainConfig().ChainID.Int64() != 100 || beta.ChainConfig().ChainID.Int64() != 200 {
+		t.Fatalf("chain ids mismatch: %v, %v", alpha.ChainConfig().ChainID, beta.ChainConfig().ChainID)
+	}
+	// Every ledger keeps its own chain database and pool
+	if alpha.BlockChain().GetBlockByHash(beta.BlockChain().Genesis().Hash()) != nil {
+		t.Errorf("ledgers share the chain database")
+	}
+	if alpha.TxPool() == beta.TxPool() {
+		t.Errorf("ledgers share the transaction pool")
+	}
+	if ledgers.Ledger("gamma") != nil {
+		t.Errorf("unknown ledger returned")
+	}
+	// The APIs select the ledger they serve
+	apis := ledgers.APIs()
+	if len(apis) != len(alpha.APIs())+len(beta.APIs()) {
+		t.Fatalf("api count mismatch: %d", len(apis))
+	}
+	for _, api := range apis {
+		if api.Ledger != "alpha" && api.Ledger != "beta" {
+			t.Errorf("api %s not tagged with its ledger: %q", api.Namespace, api.Ledger)
+		}
+	}
+}
+
+func TestLedgersConfig(t *testing.T) {
+	tests := []struct {
+		configs []*zcnd.Config
+		err     string
+	}{
+		{nil, zcnd.ErrNoLedgers.Error()},
+		{[]*zcnd.Config{ledgerConfig("", 100)}, "invalid ledger name"},
+		{[]*zcnd.Config{ledgerConfig("../up", 100)}, "invalid ledger name"},
+		{[]*zcnd.Config{ledgerConfig("alpha", 100), ledgerConfig("alpha", 200)}, "configured twice"},
+		{[]*zcnd.Config{ledgerConfig("alpha", 100), ledgerConfig("beta", 100)}, "share chain id"},
+	}
+	for i, tt := range tests {
+		stack, err := startLedgers(tt.configs...)
+		if err == nil {
+			stack.Stop()
+			t.Errorf("test %d: ledgers started", i)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %q", i, err, tt.err)
+		}
+	}
+}
+
+func TestDefaultGenesis(t *testing.T) {
+	config := ledgerConfig("", 0)
+	config.Genesis = nil
+	config.Miner.Start = true
+
+	stack := node.New(&node.Config{Name: "test"})
+	err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return zcnd.New(ctx, config)
+	})
+	if err == nil {
+		err = stack.Start()
+	}
+	if err != nil {
+		t.Fatalf("failed to start on the default genesis: %v", err)
+	}
+	defer stack.Stop()
+
+	var z *zcnd.Zcnd
+	if err := stack.Service(&z); err != nil {
+		t.Fatal(err)
+	}
+	want, err := core.DefaultGenesisBlock().ToBlock(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash := z.BlockChain().Genesis().Hash(); hash != want.Hash() {
+		t.Errorf("genesis mismatch: have %x, want %x", hash, want.Hash())
+	}
+	// Without an engine the chain can't be extended
+	if z.Miner().Mining() {
+		t.Errorf("miner running without a consensus engine")
+	}
+}
+
+func TestBFTLedger(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	config := ledgerConfig("", 0)
+	config.Genesis = &core.Genesis{
+		Config:     &params.ChainConfig{ChainID: big.NewInt(1), BFT: &params.BFTConfig{Validators: []common.Address{addr}, Period: 1}},
+		GasLimit:   params.MinGasLimit * 100,
+		Difficulty: big.NewInt(1),
+	}
+	config.Miner.Interval = 10 * time.Millisecond
+
+	stack := node.New(&node.Config{Name: "test"})
+	err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return zcnd.New(ctx, config)
+	})
+	if err == nil {
+		err = stack.Start()
+	}
+	if err != nil {
+		t.Fatalf("failed to start the BFT ledger: %v", err)
+	}
+	defer stack.Stop()
+
+	var z *zcnd.Zcnd
+	if err := stack.Service(&z); err != nil {
+		t.Fatal(err)
+	}
+	err = z.Miner().Authorize(addr, func(_ common.Address, hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	z.Miner().Start()
+
+	// The engine runs with the node and follows the chain head
+	for deadline := time.Now().Add(5 * time.Second); z.BlockChain().CurrentBlock().NumberU64() < 2; {
+		if time.Now().After(deadline) {
+			t.Fatalf("chain stuck at block %d", z.BlockChain().CurrentBlock().NumberU64())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBFTValidators(t *testing.T) {
+	config := ledgerConfig("", 0)
+	config.Genesis = &core.Genesis{
+		Config:     &params.ChainConfig{ChainID: big.NewInt(1), BFT: &params.BFTConfig{Validators: []common.Address{{1}, {2}}}},
+		GasLimit:   params.MinGasLimit * 100,
+		Difficulty: big.NewInt(1),
+	}
+	stack := node.New(&node.Config{Name: "test"})
+	err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return zcnd.New(ctx, config)
+	})
+	if err == nil {
+		err = stack.Start()
+	}
+	if err == nil {
+		stack.Stop()
+		t.Fatal("BFT ledger of several validators started")
+	}
+}